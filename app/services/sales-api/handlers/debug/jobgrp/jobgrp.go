@@ -0,0 +1,126 @@
+// Package jobgrp maintains the group of handlers for looking into the job
+// queue.
+package jobgrp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/jobs"
+	"go.uber.org/zap"
+)
+
+// Handlers manages the set of job queue endpoints.
+type Handlers struct {
+	Log  *zap.SugaredLogger
+	Jobs jobs.Queue
+}
+
+// Query returns the most recent jobs that are queued, running and failed.
+// The status query parameter limits the list to one of those, and the rows
+// query parameter sets how many of each are returned.
+func (h Handlers) Query(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	statuses := []string{jobs.StatusQueued, jobs.StatusRunning, jobs.StatusFailed}
+	if status := r.URL.Query().Get("status"); status != "" {
+		statuses = []string{status}
+	}
+
+	rows := 50
+	if v := r.URL.Query().Get("rows"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			h.respond(w, r, http.StatusBadRequest, errorResponse{Error: "invalid rows format [" + v + "]"})
+			return
+		}
+		rows = n
+	}
+
+	data := make(map[string][]jobs.Job)
+	for _, status := range statuses {
+		js, err := h.Jobs.QueryByStatus(ctx, status, 1, rows)
+		if err != nil {
+			h.Log.Errorw("jobs", "status", status, "ERROR", err)
+			h.respond(w, r, http.StatusInternalServerError, errorResponse{Error: http.StatusText(http.StatusInternalServerError)})
+			return
+		}
+		data[status] = js
+	}
+
+	h.respond(w, r, http.StatusOK, data)
+}
+
+// Requeue queues a failed job, given by the id query parameter, to be run
+// again.
+func (h Handlers) Requeue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.respond(w, r, http.StatusMethodNotAllowed, errorResponse{Error: http.StatusText(http.StatusMethodNotAllowed)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	job, err := h.Jobs.Requeue(ctx, r.URL.Query().Get("id"), time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, jobs.ErrInvalidID):
+			h.respond(w, r, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		case errors.Is(err, jobs.ErrNotFound):
+			h.respond(w, r, http.StatusNotFound, errorResponse{Error: err.Error()})
+		case errors.Is(err, jobs.ErrNotFailed):
+			h.respond(w, r, http.StatusConflict, errorResponse{Error: err.Error()})
+		default:
+			h.Log.Errorw("jobs requeue", "ERROR", err)
+			h.respond(w, r, http.StatusInternalServerError, errorResponse{Error: http.StatusText(http.StatusInternalServerError)})
+		}
+		return
+	}
+
+	h.respond(w, r, http.StatusOK, job)
+}
+
+// =============================================================================
+
+// errorResponse is the form used for API responses from failures.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// respond writes the data as JSON and logs the request.
+func (h Handlers) respond(w http.ResponseWriter, r *http.Request, statusCode int, data interface{}) {
+	if err := response(w, statusCode, data); err != nil {
+		h.Log.Errorw("jobs", "ERROR", err)
+	}
+
+	h.Log.Infow("jobs", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
+}
+
+func response(w http.ResponseWriter, statusCode int, data interface{}) error {
+
+	// Convert the response value to JSON.
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	// Set the content type and headers once we know marshaling has succeeded.
+	w.Header().Set("Content-Type", "application/json")
+
+	// Write the status code to the response.
+	w.WriteHeader(statusCode)
+
+	// Send the result back to the client.
+	if _, err := w.Write(jsonData); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -10,11 +10,18 @@ import (
 	"os"
 
 	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/debug/checkgrp"
+	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/debug/jobgrp"
 	v1 "github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v1"
+	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v1/keygrp"
 	v2 "github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v2"
+	"github.com/colmmurphy91/go-service/business/core/user"
 	"github.com/colmmurphy91/go-service/business/sys/auth"
+	"github.com/colmmurphy91/go-service/business/sys/jobs"
+	"github.com/colmmurphy91/go-service/business/sys/ratelimit"
 	"github.com/colmmurphy91/go-service/business/web/v1/mid"
+	"github.com/colmmurphy91/go-service/foundation/oidc"
 	"github.com/colmmurphy91/go-service/foundation/web"
+	"github.com/colmmurphy91/go-service/foundation/worker"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
@@ -31,6 +38,17 @@ func WithCORS(origin string) func(opts *Options) {
 	}
 }
 
+// RateLimits holds the rate limits for each group of routes. The sign in
+// limit applies to the routes used to get a token and is kept by client
+// address. The v1 and v2 limits apply to authenticated routes and are kept
+// by API key or user. A zero limit, or no store, leaves a group unlimited.
+type RateLimits struct {
+	Store  ratelimit.Store
+	SignIn ratelimit.Limit
+	V1     ratelimit.Limit
+	V2     ratelimit.Limit
+}
+
 // APIMuxConfig contains all the mandatory systems required by handlers.
 type APIMuxConfig struct {
 	Shutdown chan os.Signal
@@ -38,6 +56,12 @@ type APIMuxConfig struct {
 	Auth     *auth.Auth
 	DB       *sqlx.DB
 	MDB      *mongo.Database
+	Mail     user.MailSender
+	MailURLs user.MailURLs
+	Keys     keygrp.KeySet
+	OIDC     *oidc.Provider
+	Limits   RateLimits
+	Worker   *worker.Worker
 }
 
 // APIMux constructs a http.Handler with all application routes defined.
@@ -68,16 +92,28 @@ func APIMux(cfg APIMuxConfig, options ...func(opts *Options)) http.Handler {
 
 	// Load the v1 routes.
 	v1.Routes(app, v1.Config{
-		Log:  cfg.Log,
-		Auth: cfg.Auth,
-		DB:   cfg.DB,
-		MDB:  cfg.MDB,
+		Log:      cfg.Log,
+		Auth:     cfg.Auth,
+		DB:       cfg.DB,
+		MDB:      cfg.MDB,
+		Mail:     cfg.Mail,
+		MailURLs: cfg.MailURLs,
+		Keys:     cfg.Keys,
+		OIDC:     cfg.OIDC,
+		Worker:   cfg.Worker,
+
+		RateLimit:   cfg.Limits.Store,
+		SignInLimit: cfg.Limits.SignIn,
+		APILimit:    cfg.Limits.V1,
 	})
 
 	v2.Routes(app, v2.Config{
 		Log:  cfg.Log,
 		Auth: cfg.Auth,
 		DB:   cfg.DB,
+
+		RateLimit: cfg.Limits.Store,
+		APILimit:  cfg.Limits.V2,
 	})
 
 	return app
@@ -117,5 +153,13 @@ func DebugMux(build string, log *zap.SugaredLogger, db *sqlx.DB) http.Handler {
 	mux.HandleFunc("/debug/readiness", cgh.Readiness)
 	mux.HandleFunc("/debug/liveness", cgh.Liveness)
 
+	// Register debug job queue endpoints.
+	jgh := jobgrp.Handlers{
+		Log:  log,
+		Jobs: jobs.NewQueue(log, db),
+	}
+	mux.HandleFunc("/debug/jobs", jgh.Query)
+	mux.HandleFunc("/debug/jobs/requeue", jgh.Requeue)
+
 	return mux
 }
@@ -0,0 +1,48 @@
+// Package auditgrp maintains the group of handlers for audit log access.
+package auditgrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/colmmurphy91/go-service/business/core/audit"
+	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
+	"github.com/colmmurphy91/go-service/foundation/web"
+)
+
+// Handlers manages the set of audit log endpoints.
+type Handlers struct {
+	Audit audit.Core
+}
+
+// Query returns a page of the audit log, newest first. The optional entity,
+// entity_id, actor and action query parameters filter the entries.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	page := web.Param(r, "page")
+	pageNumber, err := strconv.Atoi(page)
+	if err != nil {
+		return v1Web.NewRequestError(fmt.Errorf("invalid page format [%s]", page), http.StatusBadRequest)
+	}
+	rows := web.Param(r, "rows")
+	rowsPerPage, err := strconv.Atoi(rows)
+	if err != nil {
+		return v1Web.NewRequestError(fmt.Errorf("invalid rows format [%s]", rows), http.StatusBadRequest)
+	}
+
+	q := r.URL.Query()
+	filter := audit.QueryFilter{
+		ActorID:  q.Get("actor"),
+		Action:   q.Get("action"),
+		Entity:   q.Get("entity"),
+		EntityID: q.Get("entity_id"),
+	}
+
+	entries, err := h.Audit.Query(ctx, filter, pageNumber, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for audit entries: %w", err)
+	}
+
+	return web.Respond(ctx, w, entries, http.StatusOK)
+}
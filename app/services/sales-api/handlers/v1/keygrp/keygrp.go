@@ -0,0 +1,115 @@
+// Package keygrp maintains the group of handlers for publishing and rotating
+// the keys used to sign tokens.
+package keygrp
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
+	"github.com/colmmurphy91/go-service/foundation/keystore"
+	"github.com/colmmurphy91/go-service/foundation/web"
+)
+
+// KeySet is the behavior required to publish our public keys.
+type KeySet interface {
+	JWKS() keystore.JWKS
+}
+
+// Rotator is the behavior required of a KeySet for keys to be reloaded and
+// retired at runtime.
+type Rotator interface {
+	KeySet
+	Reload(now time.Time, activeKID string) ([]string, error)
+	Retire(kid string, now time.Time)
+	PrivateKey(kid string) (crypto.PrivateKey, error)
+	KIDs() []string
+}
+
+// The key store loaded from the key folder must keep satisfying Rotator or
+// the reload route silently disappears.
+var _ Rotator = (*keystore.KeyStore)(nil)
+
+// Handlers manages the set of key endpoints.
+type Handlers struct {
+	Auth    *auth.Auth
+	Keys    KeySet
+	Rotator Rotator
+}
+
+// JWKS returns the public keys that can be used to validate our tokens.
+func (h Handlers) JWKS(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	// Keep the cache short so a newly added key is picked up well before it
+	// is made active.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	return web.Respond(ctx, w, h.Keys.JWKS(), http.StatusOK)
+}
+
+// Reload reads the key folder again, adding new keys and retiring the ones
+// that were removed. When an active_kid is provided signing switches to that
+// key and the previous one is retired. Nothing changes when the key signing
+// would use is not in the folder.
+func (h Handlers) Reload(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var rk struct {
+		ActiveKID string `json:"active_kid"`
+	}
+	if r.ContentLength != 0 {
+		if err := web.Decode(r, &rk); err != nil {
+			return fmt.Errorf("unable to decode payload: %w", err)
+		}
+	}
+
+	// Signing must never be left pointing at a key that has been retired, so
+	// the reload is refused before anything changes if its key is gone.
+	activeKID := h.Auth.ActiveKID()
+	if rk.ActiveKID != "" {
+		activeKID = rk.ActiveKID
+	}
+
+	added, err := h.Rotator.Reload(v.Now, activeKID)
+	if err != nil {
+		switch {
+		case errors.Is(err, keystore.ErrNoSource):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		case errors.Is(err, keystore.ErrActiveMissing) && rk.ActiveKID != "":
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, keystore.ErrActiveMissing):
+			err := errors.New("active KID is no longer in the key folder, provide a new active_kid")
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("reloading keys: %w", err)
+		}
+	}
+
+	if activeKID != h.Auth.ActiveKID() {
+		previous, err := h.Auth.SetActiveKID(activeKID)
+		if err != nil {
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		}
+		h.Rotator.Retire(previous, v.Now)
+	}
+
+	resp := struct {
+		ActiveKID string   `json:"active_kid"`
+		Added     []string `json:"added"`
+		KIDs      []string `json:"kids"`
+	}{
+		ActiveKID: h.Auth.ActiveKID(),
+		Added:     added,
+		KIDs:      h.Rotator.KIDs(),
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
 
@@ -12,11 +13,16 @@ import (
 	"github.com/colmmurphy91/go-service/business/sys/auth"
 	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
 	"github.com/colmmurphy91/go-service/foundation/web"
+	"github.com/colmmurphy91/go-service/foundation/worker"
 )
 
+// maxImportSize is the largest upload accepted for an import.
+const maxImportSize = 10 << 20
+
 // Handlers manages the set of product endpoints.
 type Handlers struct {
 	Product product.Core
+	Worker  *worker.Worker
 }
 
 // Create adds a new product to the system.
@@ -39,8 +45,12 @@ func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Req
 	return web.Respond(ctx, w, prod, http.StatusCreated)
 }
 
-// Update updates a product in the system.
-func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+// Import starts adding the products in a CSV or NDJSON upload in the
+// background, and responds with the work key to poll for the result. The
+// mode query parameter is all, to add every row or none, or valid, to add
+// only the rows that pass validation. Rows without a user are given the
+// user_id query parameter, or the caller.
+func (h Handlers) Import(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	v, err := web.GetValues(ctx)
 	if err != nil {
 		return web.NewShutdownError("web value missing from context")
@@ -51,30 +61,82 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
 	}
 
-	var upd product.UpdateProduct
-	if err := web.Decode(r, &upd); err != nil {
-		return fmt.Errorf("unable to decode payload: %w", err)
+	q := r.URL.Query()
+
+	mode := q.Get("mode")
+	switch mode {
+	case "":
+		mode = product.ImportAll
+	case product.ImportAll, product.ImportValid:
+	default:
+		return v1Web.NewRequestError(fmt.Errorf("%w [%s]", product.ErrInvalidImportMode, mode), http.StatusBadRequest)
 	}
 
-	id := web.Param(r, "id")
+	var format string
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		format = product.FormatCSV
+	case "application/x-ndjson", "application/ndjson":
+		format = product.FormatNDJSON
+	default:
+		return v1Web.NewRequestError(fmt.Errorf("%w [%s]", product.ErrInvalidImportFormat, mediaType), http.StatusUnsupportedMediaType)
+	}
 
-	prd, err := h.Product.QueryByID(ctx, id)
+	userID := q.Get("user_id")
+	if userID == "" {
+		userID = claims.Subject
+	}
+
+	rows, err := product.DecodeImport(http.MaxBytesReader(w, r.Body, maxImportSize), format, userID)
 	if err != nil {
-		switch {
-		case errors.Is(err, product.ErrInvalidID):
-			return v1Web.NewRequestError(err, http.StatusBadRequest)
-		case errors.Is(err, product.ErrNotFound):
-			return v1Web.NewRequestError(err, http.StatusNotFound)
-		default:
-			return fmt.Errorf("querying product[%s]: %w", id, err)
-		}
+		return v1Web.NewRequestError(err, http.StatusBadRequest)
+	}
+	if len(rows) == 0 {
+		return v1Web.NewRequestError(errors.New("import has no rows"), http.StatusBadRequest)
 	}
 
-	// If you are not an admin and looking to update a product you don't own.
-	if !claims.Authorized(auth.RoleAdmin) && prd.UserID != claims.Subject {
-		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	// The import outlives the request, so it must not use its context. It
+	// still runs on behalf of the caller, so the products it adds are audited
+	// against them and the request.
+	jobCtx := auth.SetClaims(context.Background(), claims)
+	jobCtx = web.SetValues(jobCtx, v)
+
+	imp := product.Import{
+		Mode: mode,
+		Rows: rows,
+	}
+	key, err := h.Worker.Start(jobCtx, v.TraceID, product.ImportJob, imp)
+	if err != nil {
+		return fmt.Errorf("starting import: %w", err)
+	}
+
+	resp := struct {
+		WorkKey string `json:"work_key"`
+		Rows    int    `json:"rows"`
+	}{
+		WorkKey: key,
+		Rows:    len(rows),
+	}
+
+	w.Header().Set("Location", "/v1/work/"+key)
+	return web.Respond(ctx, w, resp, http.StatusAccepted)
+}
+
+// Update updates a product in the system.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
 	}
 
+	var upd product.UpdateProduct
+	if err := web.Decode(r, &upd); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	id := web.Param(r, "id")
+
 	if err := h.Product.Update(ctx, id, upd, v.Now); err != nil {
 		switch {
 		case errors.Is(err, product.ErrInvalidID):
@@ -91,35 +153,14 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 
 // Delete removes a product from the system.
 func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	claims, err := auth.GetClaims(ctx)
+	v, err := web.GetValues(ctx)
 	if err != nil {
-		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+		return web.NewShutdownError("web value missing from context")
 	}
 
 	id := web.Param(r, "id")
 
-	prd, err := h.Product.QueryByID(ctx, id)
-	if err != nil {
-		switch {
-		case errors.Is(err, product.ErrInvalidID):
-			return v1Web.NewRequestError(err, http.StatusBadRequest)
-		case errors.Is(err, product.ErrNotFound):
-
-			// Don't send StatusNotFound here since the call to Delete
-			// below won't if this product is not found. We only know
-			// this because we are doing the Query for the UserID.
-			return v1Web.NewRequestError(err, http.StatusNoContent)
-		default:
-			return fmt.Errorf("querying product[%s]: %w", id, err)
-		}
-	}
-
-	// If you are not an admin and looking to delete a product you don't own.
-	if !claims.Authorized(auth.RoleAdmin) && prd.UserID != claims.Subject {
-		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
-	}
-
-	if err := h.Product.Delete(ctx, id); err != nil {
+	if err := h.Product.Delete(ctx, id, v.Now); err != nil {
 		switch {
 		case errors.Is(err, product.ErrInvalidID):
 			return v1Web.NewRequestError(err, http.StatusBadRequest)
@@ -169,3 +210,45 @@ func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.
 
 	return web.Respond(ctx, w, prod, http.StatusOK)
 }
+
+// =============================================================================
+
+// Resource loads the product named by the id route parameter so mid.Require
+// can check who owns it.
+func (h Handlers) Resource(ctx context.Context, r *http.Request) (*auth.Resource, error) {
+	id := web.Param(r, "id")
+
+	prd, err := h.Product.QueryByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, product.ErrInvalidID):
+			return nil, v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, product.ErrNotFound):
+			return nil, v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return nil, fmt.Errorf("querying product[%s]: %w", id, err)
+		}
+	}
+
+	return &auth.Resource{OwnerID: prd.UserID}, nil
+}
+
+// DeleteResource loads the product like Resource, but doesn't treat a missing
+// product as an error since the call to Delete won't either.
+func (h Handlers) DeleteResource(ctx context.Context, r *http.Request) (*auth.Resource, error) {
+	id := web.Param(r, "id")
+
+	prd, err := h.Product.QueryByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, product.ErrInvalidID):
+			return nil, v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, product.ErrNotFound):
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("querying product[%s]: %w", id, err)
+		}
+	}
+
+	return &auth.Resource{OwnerID: prd.UserID}, nil
+}
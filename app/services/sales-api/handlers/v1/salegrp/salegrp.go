@@ -0,0 +1,147 @@
+// Package salegrp maintains the group of handlers for sale access.
+package salegrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/colmmurphy91/go-service/business/core/sale"
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
+	"github.com/colmmurphy91/go-service/foundation/web"
+)
+
+// Handlers manages the set of sale endpoints.
+type Handlers struct {
+	Sale sale.Core
+}
+
+// Create records a new sale for the authenticated user.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	var ns sale.NewSale
+	if err := web.Decode(r, &ns); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	sl, err := h.Sale.Create(ctx, ns, claims.Subject, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, sale.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, sale.ErrProductNotFound):
+			return v1Web.NewRequestError(sale.ErrProductNotFound, http.StatusNotFound)
+		case errors.Is(err, sale.ErrInsufficientStock):
+			return v1Web.NewRequestError(sale.ErrInsufficientStock, http.StatusConflict)
+		default:
+			return fmt.Errorf("creating new sale, ns[%+v]: %w", ns, err)
+		}
+	}
+
+	return web.Respond(ctx, w, sl, http.StatusCreated)
+}
+
+// Refund refunds a sale and returns the units to stock.
+func (h Handlers) Refund(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	id := web.Param(r, "id")
+
+	sl, err := h.Sale.Refund(ctx, id, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, sale.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, sale.ErrNotFound):
+			return v1Web.NewRequestError(sale.ErrNotFound, http.StatusNotFound)
+		case errors.Is(err, sale.ErrAlreadyRefunded):
+			return v1Web.NewRequestError(sale.ErrAlreadyRefunded, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, sl, http.StatusOK)
+}
+
+// Query returns a list of sales with paging.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	page := web.Param(r, "page")
+	pageNumber, err := strconv.Atoi(page)
+	if err != nil {
+		return v1Web.NewRequestError(fmt.Errorf("invalid page format, page[%s]", page), http.StatusBadRequest)
+	}
+	rows := web.Param(r, "rows")
+	rowsPerPage, err := strconv.Atoi(rows)
+	if err != nil {
+		return v1Web.NewRequestError(fmt.Errorf("invalid rows format, rows[%s]", rows), http.StatusBadRequest)
+	}
+
+	sales, err := h.Sale.Query(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for sales: %w", err)
+	}
+
+	return web.Respond(ctx, w, sales, http.StatusOK)
+}
+
+// QueryByID returns a sale by its ID.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	id := web.Param(r, "id")
+
+	sl, err := h.Sale.QueryByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sale.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, sale.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	// If you are not an admin and looking to retrieve someone else's sale.
+	if !claims.Authorized(auth.RoleAdmin) && sl.UserID != claims.Subject {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	return web.Respond(ctx, w, sl, http.StatusOK)
+}
+
+// QueryByProductID returns the sales of a product.
+func (h Handlers) QueryByProductID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+
+	sales, err := h.Sale.QueryByProductID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sale.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, sales, http.StatusOK)
+}
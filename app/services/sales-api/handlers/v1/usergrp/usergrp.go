@@ -3,21 +3,30 @@ package usergrp
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/colmmurphy91/go-service/business/core/user"
 	"github.com/colmmurphy91/go-service/business/sys/auth"
 	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
+	"github.com/colmmurphy91/go-service/foundation/oidc"
 	"github.com/colmmurphy91/go-service/foundation/web"
 )
 
+// stateCookie holds the state of a sign in through the identity provider in
+// the browser that started it, so a callback carrying a state started by
+// someone else is turned away.
+const stateCookie = "oidc_state"
+
 // Handlers manages the set of user endpoints.
 type Handlers struct {
 	User user.Core
 	Auth *auth.Auth
+	OIDC *oidc.Provider
 }
 
 // Create adds a new user to the system.
@@ -83,6 +92,11 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 
 // Delete removes a user from the system.
 func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
 	claims, err := auth.GetClaims(ctx)
 	if err != nil {
 		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
@@ -95,7 +109,7 @@ func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Req
 		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
 	}
 
-	if err := h.User.Delete(ctx, userID); err != nil {
+	if err := h.User.Delete(ctx, userID, v.Now); err != nil {
 		switch {
 		case errors.Is(err, user.ErrInvalidID):
 			return v1Web.NewRequestError(err, http.StatusBadRequest)
@@ -170,9 +184,12 @@ func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return v1Web.NewRequestError(err, http.StatusUnauthorized)
 	}
 
-	claims, err := h.User.Authenticate(ctx, v.Now, email, pass)
+	authn, err := h.User.Authenticate(ctx, v.Now, web.ClientIP(r), email, pass)
 	if err != nil {
+		var lockout *user.LockoutError
 		switch {
+		case errors.As(err, &lockout):
+			return v1Web.NewThrottleError(err, lockout.RetryAfter)
 		case errors.Is(err, user.ErrNotFound):
 			return v1Web.NewRequestError(err, http.StatusNotFound)
 		case errors.Is(err, user.ErrAuthenticationFailure):
@@ -184,31 +201,520 @@ func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		}
 	}
 
+	// The password was right but the user still has to give a code from
+	// their authenticator app to /users/token/2fa along with the challenge.
+	if authn.TwoFactorRequired() {
+		return respondChallenge(ctx, w, authn.Challenge)
+	}
+
+	refresh, err := h.Auth.IssueRefreshToken(ctx, authn.Claims, v.Now)
+	if err != nil {
+		return fmt.Errorf("issuing refresh token: %w", err)
+	}
+
+	return h.respondToken(ctx, w, authn.Claims, refresh)
+}
+
+// TokenTwoFactor finishes a sign in that is waiting on a second factor,
+// providing an API token once the code checks out.
+func (h Handlers) TokenTwoFactor(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	claims, err := h.User.AuthenticateTwoFactor(ctx, req.Challenge, req.Code, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrTokenInvalid),
+			errors.Is(err, user.ErrTokenExpired),
+			errors.Is(err, user.ErrCodeInvalid):
+			return v1Web.NewRequestError(err, http.StatusUnauthorized)
+		case errors.Is(err, user.ErrTokenLocked):
+			return v1Web.NewRequestError(err, http.StatusTooManyRequests)
+		case errors.Is(err, user.ErrNotFound),
+			errors.Is(err, user.ErrUserNotConfirmed):
+			return v1Web.NewRequestError(user.ErrTokenInvalid, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("authenticating second factor: %w", err)
+		}
+	}
+
+	refresh, err := h.Auth.IssueRefreshToken(ctx, claims, v.Now)
+	if err != nil {
+		return fmt.Errorf("issuing refresh token: %w", err)
+	}
+
+	return h.respondToken(ctx, w, claims, refresh)
+}
+
+// OIDCLogin starts a sign in through the external identity provider by
+// redirecting the user to it. The URL is also returned in the body for
+// clients that navigate on their own. The state is kept in a cookie the
+// callback checks.
+func (h Handlers) OIDCLogin(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	login, err := h.User.BeginLogin(ctx, v.Now)
+	if err != nil {
+		return fmt.Errorf("beginning login: %w", err)
+	}
+
+	authURL, err := h.OIDC.AuthCodeURL(ctx, login.State, login.Nonce, login.Verifier)
+	if err != nil {
+		return fmt.Errorf("building sign in url: %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    login.State,
+		Path:     "/v1/users/oidc",
+		Expires:  login.DateExpires,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	resp := struct {
+		URL string `json:"url"`
+	}{
+		URL: authURL,
+	}
+
+	w.Header().Set("Location", authURL)
+	return web.Respond(ctx, w, resp, http.StatusFound)
+}
+
+// OIDCCallback finishes a sign in through the external identity provider
+// when it redirects the user back, returning tokens for the user linked to
+// the identity.
+func (h Handlers) OIDCCallback(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	// The state cookie is only good for one callback.
+	cookie, err := r.Cookie(stateCookie)
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Path:     "/v1/users/oidc",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		err := fmt.Errorf("identity provider returned %s: %s", e, q.Get("error_description"))
+		return v1Web.NewRequestError(err, http.StatusUnauthorized)
+	}
+
+	// The sign in must have been started by this browser, or someone could
+	// sign the user in to their own account.
+	state := q.Get("state")
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return v1Web.NewRequestError(user.ErrLoginInvalid, http.StatusUnauthorized)
+	}
+
+	login, err := h.User.CompleteLogin(ctx, state, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrLoginInvalid):
+			return v1Web.NewRequestError(err, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("completing login: %w", err)
+		}
+	}
+
+	ident, err := h.OIDC.Exchange(ctx, q.Get("code"), login.Verifier, login.Nonce)
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrExchange), errors.Is(err, oidc.ErrInvalidIDToken):
+			return v1Web.NewRequestError(err, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("exchanging code: %w", err)
+		}
+	}
+
+	authn, err := h.User.AuthenticateIdentity(ctx, ident, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, user.ErrEmailNotVerified):
+			return v1Web.NewRequestError(err, http.StatusForbidden)
+		case errors.Is(err, user.ErrUserNotConfirmed):
+			return v1Web.NewRequestError(err, http.StatusForbidden)
+		case errors.Is(err, user.ErrUniqueEmail):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("authenticating identity: %w", err)
+		}
+	}
+
+	// The identity provider stands in for the password only, so a user with
+	// two-factor authentication enabled still has to give a code to
+	// /users/token/2fa along with the challenge.
+	if authn.TwoFactorRequired() {
+		return respondChallenge(ctx, w, authn.Challenge)
+	}
+
+	refresh, err := h.Auth.IssueRefreshToken(ctx, authn.Claims, v.Now)
+	if err != nil {
+		return fmt.Errorf("issuing refresh token: %w", err)
+	}
+
+	return h.respondToken(ctx, w, authn.Claims, refresh)
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. The presented refresh token can not be used again.
+func (h Handlers) RefreshToken(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var rt struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := web.Decode(r, &rt); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	refresh, err := h.Auth.RotateRefreshToken(ctx, rt.RefreshToken, v.Now)
+	if err != nil {
+		if errors.Is(err, auth.ErrRefreshInvalid) {
+			return v1Web.NewRequestError(err, http.StatusUnauthorized)
+		}
+		return fmt.Errorf("rotating refresh token: %w", err)
+	}
+
+	claims, err := h.User.RefreshClaims(ctx, refresh.Subject, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound),
+			errors.Is(err, user.ErrUserNotConfirmed):
+			return v1Web.NewRequestError(auth.ErrRefreshInvalid, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("refreshing claims[%s]: %w", refresh.Subject, err)
+		}
+	}
+
+	return h.respondToken(ctx, w, claims, refresh.Token)
+}
+
+// RevokeToken revokes the access token used to call it and, when one is
+// provided, the family of the refresh token issued alongside it.
+func (h Handlers) RevokeToken(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	var rt struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if r.ContentLength != 0 {
+		if err := web.Decode(r, &rt); err != nil {
+			return fmt.Errorf("unable to decode payload: %w", err)
+		}
+	}
+
+	if rt.RefreshToken != "" {
+		if err := h.Auth.RevokeRefreshToken(ctx, claims, rt.RefreshToken, v.Now); err != nil {
+			if errors.Is(err, auth.ErrRefreshInvalid) {
+				return v1Web.NewRequestError(err, http.StatusBadRequest)
+			}
+			return fmt.Errorf("revoking refresh token: %w", err)
+		}
+	}
+
+	if err := h.Auth.Revoke(ctx, claims, v.Now); err != nil {
+		return fmt.Errorf("revoking token[%s]: %w", claims.ID, err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// respondToken signs the claims and responds with the access and refresh
+// token pair.
+func (h Handlers) respondToken(ctx context.Context, w http.ResponseWriter, claims auth.Claims, refresh string) error {
 	var tkn struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
+
+	var err error
 	tkn.Token, err = h.Auth.GenerateToken(claims)
 	if err != nil {
 		return fmt.Errorf("generating token: %w", err)
 	}
+	tkn.RefreshToken = refresh
 
 	return web.Respond(ctx, w, tkn, http.StatusOK)
 }
 
+// respondChallenge responds that the user has to give a second factor to
+// /users/token/2fa, along with the challenge to present with it.
+func respondChallenge(ctx context.Context, w http.ResponseWriter, challenge string) error {
+	resp := struct {
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		Challenge         string `json:"challenge"`
+	}{
+		TwoFactorRequired: true,
+		Challenge:         challenge,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusAccepted)
+}
+
+// Confirm confirms the email address of a user with the token they were sent.
+// It answers GET as well as PUT so the link in the confirmation email works
+// when it is opened.
 func (h Handlers) Confirm(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
 	email := r.URL.Query().Get("email")
 	token := r.URL.Query().Get("token")
 
-	tokenParsed, err := strconv.ParseInt(token, 10, 64)
+	if err := h.User.Confirm(ctx, email, token, v.Now); err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound),
+			errors.Is(err, user.ErrTokenInvalid),
+			errors.Is(err, user.ErrTokenExpired):
+			return v1Web.NewRequestError(user.ErrTokenInvalid, http.StatusBadRequest)
+		case errors.Is(err, user.ErrAlreadyConfirmed):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		case errors.Is(err, user.ErrTokenLocked):
+			return v1Web.NewRequestError(err, http.StatusTooManyRequests)
+		default:
+			return fmt.Errorf("confirming email[%s]: %w", email, err)
+		}
+	}
 
+	return web.Respond(ctx, w, nil, http.StatusOK)
+}
+
+// ResendConfirmation sends a new confirmation email. It responds the same way
+// whether or not the email belongs to an unconfirmed user so it can't be used
+// to discover accounts.
+func (h Handlers) ResendConfirmation(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
 	if err != nil {
-		return v1Web.NewRequestError(err, http.StatusBadRequest)
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
 	}
 
-	err = h.User.Confirm(ctx, email, tokenParsed)
+	if err := h.User.ResendConfirmation(ctx, req.Email, v.Now); err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidEmail):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrNotFound),
+			errors.Is(err, user.ErrAlreadyConfirmed):
+		default:
+			return fmt.Errorf("resending confirmation email[%s]: %w", req.Email, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusAccepted)
+}
+
+// ForgotPassword emails a password reset link. It responds the same way
+// whether or not the email belongs to a user so it can't be used to discover
+// accounts.
+func (h Handlers) ForgotPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
 	if err != nil {
-		return v1Web.NewRequestError(err, http.StatusBadRequest)
+		return web.NewShutdownError("web value missing from context")
 	}
 
-	return web.Respond(ctx, w, nil, http.StatusOK)
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := h.User.ForgotPassword(ctx, req.Email, v.Now); err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidEmail):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrNotFound):
+		default:
+			return fmt.Errorf("sending password reset email[%s]: %w", req.Email, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusAccepted)
+}
+
+// ResetPassword sets a new password using the token from a reset email.
+func (h Handlers) ResetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var rp user.ResetPassword
+	if err := web.Decode(r, &rp); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := h.User.ResetPassword(ctx, rp, v.Now); err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound),
+			errors.Is(err, user.ErrTokenInvalid),
+			errors.Is(err, user.ErrTokenExpired):
+			return v1Web.NewRequestError(user.ErrTokenInvalid, http.StatusBadRequest)
+		case errors.Is(err, user.ErrTokenLocked):
+			return v1Web.NewRequestError(err, http.StatusTooManyRequests)
+		default:
+			return fmt.Errorf("resetting password email[%s]: %w", rp.Email, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Unlock lets a user locked out by failed sign ins try again straight away.
+func (h Handlers) Unlock(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID := web.Param(r, "id")
+	if err := h.User.Unlock(ctx, userID); err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// EnrollTOTP starts enrolling an authenticator app as a second factor for
+// the authenticated user.
+func (h Handlers) EnrollTOTP(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	enrollment, err := h.User.EnrollTOTP(ctx, claims.Subject, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, user.ErrTwoFactorEnabled):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("enrolling totp[%s]: %w", claims.Subject, err)
+		}
+	}
+
+	return web.Respond(ctx, w, enrollment, http.StatusCreated)
+}
+
+// ConfirmTOTP enables two-factor authentication for the authenticated user
+// with a code from the authenticator app they enrolled.
+func (h Handlers) ConfirmTOTP(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	confirm := func(ctx context.Context, userID string, req totpRequest, now time.Time) error {
+		return h.User.ConfirmTOTP(ctx, userID, req.Code, now)
+	}
+	return h.totpCode(ctx, w, r, confirm)
+}
+
+// DisableTOTP turns off two-factor authentication for the authenticated user
+// with their password and a code from their authenticator app or a recovery
+// code.
+func (h Handlers) DisableTOTP(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	disable := func(ctx context.Context, userID string, req totpRequest, now time.Time) error {
+		return h.User.DisableTOTP(ctx, userID, req.Password, req.Code, now)
+	}
+	return h.totpCode(ctx, w, r, disable)
+}
+
+// totpRequest is what a two-factor change is made with.
+type totpRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// totpCode runs a two-factor change for the authenticated user that needs
+// a code from the request.
+func (h Handlers) totpCode(ctx context.Context, w http.ResponseWriter, r *http.Request, fn func(context.Context, string, totpRequest, time.Time) error) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	var req totpRequest
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := fn(ctx, claims.Subject, req, v.Now); err != nil {
+		var lockout *user.LockoutError
+		switch {
+		case errors.As(err, &lockout):
+			return v1Web.NewThrottleError(err, lockout.RetryAfter)
+		case errors.Is(err, user.ErrCodeInvalid):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrAuthenticationFailure):
+			return v1Web.NewRequestError(err, http.StatusUnauthorized)
+		case errors.Is(err, user.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, user.ErrTwoFactorNotEnrolled):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, user.ErrTwoFactorEnabled):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("updating totp[%s]: %w", claims.Subject, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
 }
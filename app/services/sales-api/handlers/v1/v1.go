@@ -3,59 +3,139 @@
 package v1
 
 import (
+	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v1/auditgrp"
 	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v1/cafegrp"
+	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v1/keygrp"
 	"github.com/colmmurphy91/go-service/business/core/cafe"
 	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
 
 	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v1/productgrp"
+	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v1/salegrp"
 	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v1/usergrp"
+	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v1/workgrp"
+	"github.com/colmmurphy91/go-service/business/core/audit"
 	"github.com/colmmurphy91/go-service/business/core/product"
+	"github.com/colmmurphy91/go-service/business/core/sale"
 	"github.com/colmmurphy91/go-service/business/core/user"
 	"github.com/colmmurphy91/go-service/business/sys/auth"
+	"github.com/colmmurphy91/go-service/business/sys/ratelimit"
 	"github.com/colmmurphy91/go-service/business/web/v1/mid"
+	"github.com/colmmurphy91/go-service/foundation/oidc"
 	"github.com/colmmurphy91/go-service/foundation/web"
+	"github.com/colmmurphy91/go-service/foundation/worker"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
 // Config contains all the mandatory systems required by handlers.
 type Config struct {
-	Log  *zap.SugaredLogger
-	Auth *auth.Auth
-	DB   *sqlx.DB
-	MDB  *mongo.Database
+	Log      *zap.SugaredLogger
+	Auth     *auth.Auth
+	DB       *sqlx.DB
+	MDB      *mongo.Database
+	Mail     user.MailSender
+	MailURLs user.MailURLs
+	Keys     keygrp.KeySet
+	OIDC     *oidc.Provider
+	Worker   *worker.Worker
+
+	// RateLimit keeps the buckets for the sign in routes, limited by client
+	// address, and the authenticated routes, limited by API key or user.
+	RateLimit   ratelimit.Store
+	SignInLimit ratelimit.Limit
+	APILimit    ratelimit.Limit
 }
 
 // Routes binds all the version 1 routes.
 func Routes(app *web.App, cfg Config) {
 	const version = "v1"
 
-	authen := mid.Authenticate(cfg.Auth)
+	authen := web.Chain(mid.Authenticate(cfg.Auth), mid.RateLimit(version, cfg.RateLimit, cfg.APILimit, mid.ByAPIKey))
 	admin := mid.Authorize(auth.RoleAdmin)
+	signIn := mid.RateLimit("signin", cfg.RateLimit, cfg.SignInLimit, mid.ByIP)
 
 	// Register user management and authentication endpoints.
 	ugh := usergrp.Handlers{
-		User: user.NewCore(cfg.Log, cfg.DB),
+		User: user.NewCore(cfg.Log, cfg.DB, user.WithMail(cfg.Mail, cfg.MailURLs)),
 		Auth: cfg.Auth,
+		OIDC: cfg.OIDC,
+	}
+	app.Handle(http.MethodPut, version, "/users/confirm", ugh.Confirm, signIn)
+	app.Handle(http.MethodGet, version, "/users/confirm", ugh.Confirm, signIn)
+	app.Handle(http.MethodPost, version, "/users/confirm/resend", ugh.ResendConfirmation, signIn)
+	app.Handle(http.MethodPost, version, "/users/password/forgot", ugh.ForgotPassword, signIn)
+	app.Handle(http.MethodPost, version, "/users/password/reset", ugh.ResetPassword, signIn)
+	app.Handle(http.MethodGet, version, "/users/token", ugh.Token, signIn)
+	app.Handle(http.MethodPost, version, "/users/token/2fa", ugh.TokenTwoFactor, signIn)
+	app.Handle(http.MethodPost, version, "/users/token/refresh", ugh.RefreshToken, signIn)
+	app.Handle(http.MethodPost, version, "/users/token/revoke", ugh.RevokeToken, authen)
+	app.Handle(http.MethodPost, version, "/users/2fa/enroll", ugh.EnrollTOTP, authen)
+	app.Handle(http.MethodPost, version, "/users/2fa/confirm", ugh.ConfirmTOTP, authen)
+	app.Handle(http.MethodPost, version, "/users/2fa/disable", ugh.DisableTOTP, authen)
+	if cfg.OIDC != nil {
+		app.Handle(http.MethodGet, version, "/users/oidc/login", ugh.OIDCLogin, signIn)
+		app.Handle(http.MethodGet, version, "/users/oidc/callback", ugh.OIDCCallback, signIn)
 	}
-	app.Handle(http.MethodPut, version, "/users/confirm", ugh.Confirm)
-	app.Handle(http.MethodGet, version, "/users/token", ugh.Token)
 	app.Handle(http.MethodGet, version, "/users/:page/:rows", ugh.Query, authen, admin)
 	app.Handle(http.MethodGet, version, "/users/:id", ugh.QueryByID, authen)
 	app.Handle(http.MethodPost, version, "/users", ugh.Create, authen, admin)
 	app.Handle(http.MethodPut, version, "/users/:id", ugh.Update, authen, admin)
 	app.Handle(http.MethodDelete, version, "/users/:id", ugh.Delete, authen, admin)
+	app.Handle(http.MethodPost, version, "/users/:id/unlock", ugh.Unlock, authen, admin)
+
+	// Register key discovery and, when the key source supports it, rotation
+	// endpoints.
+	if cfg.Keys != nil {
+		kgh := keygrp.Handlers{
+			Auth: cfg.Auth,
+			Keys: cfg.Keys,
+		}
+		app.Handle(http.MethodGet, "", "/.well-known/jwks.json", kgh.JWKS)
+
+		if rotator, ok := cfg.Keys.(keygrp.Rotator); ok {
+			kgh.Rotator = rotator
+			app.Handle(http.MethodPost, version, "/keys/reload", kgh.Reload, authen, admin)
+		}
+	}
 
 	// Register product and sale endpoints.
 	pgh := productgrp.Handlers{
 		Product: product.NewCore(cfg.Log, cfg.DB),
+		Worker:  cfg.Worker,
 	}
 	app.Handle(http.MethodGet, version, "/products/:page/:rows", pgh.Query, authen)
 	app.Handle(http.MethodGet, version, "/products/:id", pgh.QueryByID, authen)
 	app.Handle(http.MethodPost, version, "/products", pgh.Create, authen)
-	app.Handle(http.MethodPut, version, "/products/:id", pgh.Update, authen)
-	app.Handle(http.MethodDelete, version, "/products/:id", pgh.Delete, authen)
+	app.Handle(http.MethodPost, version, "/products/import", pgh.Import, authen, admin)
+	app.Handle(http.MethodPut, version, "/products/:id", pgh.Update, authen, mid.Require(auth.PermProductWrite, pgh.Resource))
+	app.Handle(http.MethodDelete, version, "/products/:id", pgh.Delete, authen, mid.Require(auth.PermProductDelete, pgh.DeleteResource))
+
+	sgh := salegrp.Handlers{
+		Sale: sale.NewCore(cfg.Log, cfg.DB),
+	}
+	app.Handle(http.MethodGet, version, "/sales/:page/:rows", sgh.Query, authen, admin)
+	app.Handle(http.MethodGet, version, "/sales/:id", sgh.QueryByID, authen)
+	app.Handle(http.MethodGet, version, "/products/:id/sales", sgh.QueryByProductID, authen, admin)
+	app.Handle(http.MethodPost, version, "/sales", sgh.Create, authen)
+	app.Handle(http.MethodPost, version, "/sales/:id/refund", sgh.Refund, authen, admin)
+
+	// Register audit log endpoints.
+	agh := auditgrp.Handlers{
+		Audit: audit.NewCore(cfg.Log, cfg.DB),
+	}
+	app.Handle(http.MethodGet, version, "/audit/:page/:rows", agh.Query, authen, admin)
+
+	// Register background work endpoints so long running operations can be
+	// polled until they finish. Work is only known to the replica that
+	// started it, which is named in its key, so a deployment with more than
+	// one replica must route these requests to that replica.
+	wgh := workgrp.Handlers{
+		Worker: cfg.Worker,
+	}
+	app.Handle(http.MethodGet, version, "/work", wgh.Query, authen, admin)
+	app.Handle(http.MethodGet, version, "/work/:key", wgh.QueryByKey, authen, admin)
+	app.Handle(http.MethodDelete, version, "/work/:key", wgh.Stop, authen, admin)
 
 	cgh := cafegrp.Handlers{
 		Cafe: cafe.NewCore(cfg.Log, cfg.MDB),
@@ -0,0 +1,80 @@
+// Package workgrp maintains the group of handlers for checking on work
+// running in the background. Work is kept in memory by the replica that
+// started it, so requests for a piece of work must reach that replica. Work
+// keys name their replica, and the other replicas refuse them.
+package workgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
+	"github.com/colmmurphy91/go-service/foundation/web"
+	"github.com/colmmurphy91/go-service/foundation/worker"
+)
+
+// Handlers manages the set of background work endpoints.
+type Handlers struct {
+	Worker *worker.Worker
+}
+
+// Query returns the status of all the work that is queued, running or
+// recently finished, the most recently started first.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web.Respond(ctx, w, h.Worker.Statuses(), http.StatusOK)
+}
+
+// QueryByKey returns the status of the work identified by its work key.
+func (h Handlers) QueryByKey(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	key := web.Param(r, "key")
+
+	st, err := h.status(key)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, st, http.StatusOK)
+}
+
+// Stop cancels the work identified by its work key.
+func (h Handlers) Stop(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	key := web.Param(r, "key")
+
+	st, err := h.status(key)
+	if err != nil {
+		return err
+	}
+
+	if st.State != worker.StateQueued && st.State != worker.StateRunning {
+		return v1Web.NewRequestError(fmt.Errorf("work[%s] is %s", key, st.State), http.StatusConflict)
+	}
+
+	if err := h.Worker.Stop(key); err != nil {
+		return v1Web.NewRequestError(err, http.StatusConflict)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// =============================================================================
+
+// status retrieves the status of the work and maps the worker errors to
+// request errors. Work started by another replica is refused as misdirected
+// so it isn't mistaken for work that has been forgotten.
+func (h Handlers) status(key string) (worker.Status, error) {
+	st, err := h.Worker.Status(key)
+	if err != nil {
+		switch {
+		case errors.Is(err, worker.ErrUnknownWork):
+			return worker.Status{}, v1Web.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, worker.ErrOtherWorker):
+			return worker.Status{}, v1Web.NewRequestError(err, http.StatusMisdirectedRequest)
+		default:
+			return worker.Status{}, fmt.Errorf("key[%s]: %w", key, err)
+		}
+	}
+
+	return st, nil
+}
@@ -1,44 +1,133 @@
+// Package cafegrp maintains the group of handlers for cafe access.
 package cafegrp
 
 import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
+
 	"github.com/colmmurphy91/go-service/business/core/cafev2"
 	"github.com/colmmurphy91/go-service/business/sys/auth"
 	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
 	"github.com/colmmurphy91/go-service/foundation/web"
-	"net/http"
 )
 
+// Handlers manages the set of cafe endpoints.
 type Handlers struct {
 	Cafe cafev2.Core
 }
 
+// Create adds a new cafe owned by the authenticated user.
 func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	var newCafe cafev2.NewCafe
-	err := web.Decode(r, &newCafe)
+	v, err := web.GetValues(ctx)
 	if err != nil {
-		return fmt.Errorf("unable to decode payload: %w", err)
+		return web.NewShutdownError("web value missing from context")
 	}
+
 	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
 
-	createCafe, err := h.Cafe.Create(ctx, newCafe, claims.Subject)
+	var nc cafev2.NewCafe
+	if err := web.Decode(r, &nc); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	caf, err := h.Cafe.Create(ctx, nc, claims.Subject, v.Now)
 	if err != nil {
-		return fmt.Errorf("creating new cafe, nc[%+v]: %w", newCafe, err)
+		switch {
+		case errors.Is(err, cafev2.ErrOwnerHasCafe):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("creating new cafe, nc[%+v]: %w", nc, err)
+		}
 	}
-	return web.Respond(ctx, w, createCafe, http.StatusCreated)
+
+	return web.Respond(ctx, w, caf, http.StatusCreated)
 }
 
-func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+// Update updates a cafe in the system.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var upd cafev2.UpdateCafe
+	if err := web.Decode(r, &upd); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
 	id := web.Param(r, "id")
-	claims, err := auth.GetClaims(ctx)
-	caf, err := h.Cafe.QueryByOwnerID(ctx, claims.Subject)
-	if claims.Subject != caf.OwnerID {
-		return v1Web.NewRequestError(errors.New("user is not part of cafe"), http.StatusForbidden)
+
+	if err := h.Cafe.Update(ctx, id, upd, v.Now); err != nil {
+		switch {
+		case errors.Is(err, cafev2.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, cafev2.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] Cafe[%+v]: %w", id, &upd, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Delete removes a cafe from the system.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	id := web.Param(r, "id")
+
+	if err := h.Cafe.Delete(ctx, id, v.Now); err != nil {
+		switch {
+		case errors.Is(err, cafev2.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Query returns a list of cafes with paging.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	page := web.Param(r, "page")
+	pageNumber, err := strconv.Atoi(page)
+	if err != nil {
+		return v1Web.NewRequestError(fmt.Errorf("invalid page format, page[%s]", page), http.StatusBadRequest)
 	}
+	rows := web.Param(r, "rows")
+	rowsPerPage, err := strconv.Atoi(rows)
+	if err != nil {
+		return v1Web.NewRequestError(fmt.Errorf("invalid rows format, rows[%s]", rows), http.StatusBadRequest)
+	}
+
+	cafes, err := h.Cafe.Query(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for cafes: %w", err)
+	}
+
+	return web.Respond(ctx, w, cafes, http.StatusOK)
+}
+
+// QueryByID returns a cafe by its ID.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+
+	caf, err := h.Cafe.QueryByID(ctx, id)
 	if err != nil {
 		switch {
+		case errors.Is(err, cafev2.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
 		case errors.Is(err, cafev2.ErrNotFound):
 			return v1Web.NewRequestError(err, http.StatusNotFound)
 		default:
@@ -49,6 +138,204 @@ func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.
 	return web.Respond(ctx, w, caf, http.StatusOK)
 }
 
+// Members returns the staff of a cafe, including invitations that are yet to
+// be accepted.
+func (h Handlers) Members(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+
+	mems, err := h.Cafe.QueryMembers(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, cafev2.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, mems, http.StatusOK)
+}
+
+// Invite adds a user to the staff of a cafe. The user has to accept the
+// invitation before it grants them anything.
+func (h Handlers) Invite(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	var nm cafev2.NewMember
+	if err := web.Decode(r, &nm); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	id := web.Param(r, "id")
+
+	mem, err := h.Cafe.Invite(ctx, id, nm, claims.Subject, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, cafev2.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, cafev2.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, cafev2.ErrUserNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, cafev2.ErrMemberExists):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("inviting member, nm[%+v]: %w", nm, err)
+		}
+	}
+
+	return web.Respond(ctx, w, mem, http.StatusCreated)
+}
+
+// RemoveMember removes a user from the staff of a cafe.
+func (h Handlers) RemoveMember(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	id := web.Param(r, "id")
+	userID := web.Param(r, "user")
+
+	if err := h.Cafe.RemoveMember(ctx, id, userID, v.Now); err != nil {
+		switch {
+		case errors.Is(err, cafev2.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("ID[%s] User[%s]: %w", id, userID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Accept accepts the authenticated user's invitation to the staff of a cafe.
+func (h Handlers) Accept(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	id := web.Param(r, "id")
+
+	mem, err := h.Cafe.Accept(ctx, id, claims.Subject, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, cafev2.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, cafev2.ErrMemberNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] User[%s]: %w", id, claims.Subject, err)
+		}
+	}
+
+	return web.Respond(ctx, w, mem, http.StatusOK)
+}
+
+// Memberships returns the cafes the authenticated user is on the staff of or
+// has been invited to.
+func (h Handlers) Memberships(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	mems, err := h.Cafe.QueryMemberships(ctx, claims.Subject)
+	if err != nil {
+		switch {
+		case errors.Is(err, cafev2.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("User[%s]: %w", claims.Subject, err)
+		}
+	}
+
+	return web.Respond(ctx, w, mems, http.StatusOK)
+}
+
+// Hello is a simple unauthenticated endpoint for checking the v2 routes.
 func (h Handlers) Hello(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	return web.Respond(ctx, w, "{'status':'ok'}", http.StatusOK)
 }
+
+// =============================================================================
+
+// Resource loads the cafe named by the id route parameter so mid.Require can
+// check who owns it.
+func (h Handlers) Resource(ctx context.Context, r *http.Request) (*auth.Resource, error) {
+	id := web.Param(r, "id")
+
+	caf, err := h.Cafe.QueryByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, cafev2.ErrInvalidID):
+			return nil, v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, cafev2.ErrNotFound):
+			return nil, v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return nil, fmt.Errorf("querying cafe[%s]: %w", id, err)
+		}
+	}
+
+	return h.resource(ctx, caf)
+}
+
+// DeleteResource loads the cafe like Resource, but doesn't treat a missing
+// cafe as an error since there is nothing left to delete.
+func (h Handlers) DeleteResource(ctx context.Context, r *http.Request) (*auth.Resource, error) {
+	id := web.Param(r, "id")
+
+	caf, err := h.Cafe.QueryByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, cafev2.ErrInvalidID):
+			return nil, v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, cafev2.ErrNotFound):
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("querying cafe[%s]: %w", id, err)
+		}
+	}
+
+	return h.resource(ctx, caf)
+}
+
+// resource describes the cafe for the permission checks, including the role
+// the authenticated user holds on its staff.
+func (h Handlers) resource(ctx context.Context, caf cafev2.Cafe) (*auth.Resource, error) {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return nil, v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	res := auth.Resource{
+		OwnerID: caf.OwnerID,
+		CafeID:  caf.ID,
+	}
+
+	mem, err := h.Cafe.QueryMember(ctx, caf.ID, claims.Subject)
+	switch {
+	case err == nil:
+		if mem.Accepted() {
+			res.MemberRole = mem.Role
+		}
+	case !errors.Is(err, cafev2.ErrMemberNotFound):
+		return nil, fmt.Errorf("querying member cafe[%s] user[%s]: %w", caf.ID, claims.Subject, err)
+	}
+
+	return &res, nil
+}
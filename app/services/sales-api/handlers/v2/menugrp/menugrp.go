@@ -0,0 +1,123 @@
+// Package menugrp maintains the group of handlers for cafe menu access.
+package menugrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/colmmurphy91/go-service/business/core/menu"
+	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
+	"github.com/colmmurphy91/go-service/foundation/web"
+)
+
+// Handlers manages the set of menu endpoints.
+type Handlers struct {
+	Menu menu.Core
+}
+
+// Create adds a new item to a cafe menu.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	cafeID := web.Param(r, "id")
+
+	var nmi menu.NewMenuItem
+	if err := web.Decode(r, &nmi); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	item, err := h.Menu.Create(ctx, cafeID, nmi, v.Now)
+	if err != nil {
+		return fmt.Errorf("creating new menu item, nmi[%+v]: %w", nmi, err)
+	}
+
+	return web.Respond(ctx, w, item, http.StatusCreated)
+}
+
+// Update updates an item on a cafe menu.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	cafeID := web.Param(r, "id")
+
+	var upd menu.UpdateMenuItem
+	if err := web.Decode(r, &upd); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	itemID := web.Param(r, "item")
+
+	if err := h.Menu.Update(ctx, cafeID, itemID, upd, v.Now); err != nil {
+		switch {
+		case errors.Is(err, menu.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, menu.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] MenuItem[%+v]: %w", itemID, &upd, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Delete removes an item from a cafe menu.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	cafeID := web.Param(r, "id")
+
+	itemID := web.Param(r, "item")
+
+	if err := h.Menu.Delete(ctx, cafeID, itemID); err != nil {
+		switch {
+		case errors.Is(err, menu.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, menu.ErrNotFound):
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		default:
+			return fmt.Errorf("ID[%s]: %w", itemID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Query returns the full menu of a cafe.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	cafeID := web.Param(r, "id")
+
+	items, err := h.Menu.QueryByCafeID(ctx, cafeID)
+	if err != nil {
+		return fmt.Errorf("unable to query for menu: %w", err)
+	}
+
+	return web.Respond(ctx, w, items, http.StatusOK)
+}
+
+// QueryByID returns a single item from a cafe menu.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	cafeID := web.Param(r, "id")
+
+	itemID := web.Param(r, "item")
+
+	item, err := h.Menu.QueryByID(ctx, cafeID, itemID)
+	if err != nil {
+		switch {
+		case errors.Is(err, menu.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, menu.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s]: %w", itemID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, item, http.StatusOK)
+}
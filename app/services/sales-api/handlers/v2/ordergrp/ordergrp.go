@@ -0,0 +1,354 @@
+// Package ordergrp maintains the group of handlers for cafe order access.
+// Customers place, list and cancel their own orders. Cafe owners and staff see
+// the orders placed at their cafe and move them through the order lifecycle.
+package ordergrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/cafev2"
+	"github.com/colmmurphy91/go-service/business/core/order"
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
+	"github.com/colmmurphy91/go-service/foundation/web"
+)
+
+// heartbeat is how often an idle order stream is kept alive. It is shorter
+// than the default server write timeout so proxies see traffic.
+const heartbeat = 5 * time.Second
+
+// Handlers manages the set of order endpoints.
+type Handlers struct {
+	Order order.Core
+	Cafe  cafev2.Core
+}
+
+// Place records a new order for the authenticated customer.
+func (h Handlers) Place(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	var no order.NewOrder
+	if err := web.Decode(r, &no); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	ord, err := h.Order.Place(ctx, no, claims.Subject, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, order.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, order.ErrCafeNotFound),
+			errors.Is(err, order.ErrMenuItemNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		case errors.Is(err, order.ErrMenuItemUnavailable):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("placing order, no[%+v]: %w", no, err)
+		}
+	}
+
+	return web.Respond(ctx, w, ord, http.StatusCreated)
+}
+
+// Cancel cancels an order on behalf of the customer who placed it.
+func (h Handlers) Cancel(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	id := web.Param(r, "id")
+
+	ord, err := h.queryByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if ord.CustomerID != claims.Subject {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	ord, err = h.Order.Cancel(ctx, id, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, order.ErrNotCancellable),
+			errors.Is(err, order.ErrInvalidTransition):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, ord, http.StatusOK)
+}
+
+// Query returns the orders placed by the authenticated customer.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	pageNumber, rowsPerPage, err := paging(r)
+	if err != nil {
+		return err
+	}
+
+	ords, err := h.Order.QueryByCustomerID(ctx, claims.Subject, pageNumber, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for orders: %w", err)
+	}
+
+	return web.Respond(ctx, w, ords, http.StatusOK)
+}
+
+// QueryByID returns an order to the customer who placed it or to the owner
+// and staff of the cafe it was placed at.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	id := web.Param(r, "id")
+
+	ord, err := h.queryByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if ord.CustomerID != claims.Subject {
+		res, err := h.resource(ctx, ord)
+		if err != nil {
+			return err
+		}
+
+		if err := auth.Check(claims, auth.PermOrderManage, res); err != nil {
+			return v1Web.NewRequestError(err, http.StatusForbidden)
+		}
+	}
+
+	return web.Respond(ctx, w, ord, http.StatusOK)
+}
+
+// QueryCafe returns the orders placed at the cafe named by the id route
+// parameter, or at the cafe owned by the authenticated user when there is
+// none. The optional status query parameter takes a comma separated list of
+// statuses to filter on.
+func (h Handlers) QueryCafe(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	cafeID := web.Param(r, "id")
+	if cafeID == "" {
+		caf, err := h.ownedCafe(ctx)
+		if err != nil {
+			return err
+		}
+		cafeID = caf.ID
+	}
+
+	pageNumber, rowsPerPage, err := paging(r)
+	if err != nil {
+		return err
+	}
+
+	var statuses []order.Status
+	if s := r.URL.Query().Get("status"); s != "" {
+		for _, status := range strings.Split(s, ",") {
+			statuses = append(statuses, order.Status(strings.TrimSpace(status)))
+		}
+	}
+
+	ords, err := h.Order.QueryByCafeID(ctx, cafeID, statuses, pageNumber, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for cafe orders: %w", err)
+	}
+
+	return web.Respond(ctx, w, ords, http.StatusOK)
+}
+
+// UpdateStatus moves an order to the next step in its lifecycle. Who may do
+// so is checked against the cafe the order was placed at by Resource.
+func (h Handlers) UpdateStatus(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var us order.UpdateStatus
+	if err := web.Decode(r, &us); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	id := web.Param(r, "id")
+
+	ord, err := h.Order.Transition(ctx, id, us.Status, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, order.ErrInvalidTransition):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] Status[%s]: %w", id, us.Status, err)
+		}
+	}
+
+	return web.Respond(ctx, w, ord, http.StatusOK)
+}
+
+// Stream sends the orders placed or updated at a cafe to its owner as
+// server-sent events until the client goes away. Each connection lasts at most
+// the server write timeout; EventSource clients reconnect on their own.
+func (h Handlers) Stream(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	cafeID := web.Param(r, "id")
+
+	orders, unsubscribe := h.Order.Subscribe(cafeID)
+	defer unsubscribe()
+
+	stream, err := web.NewStream(ctx, w, heartbeat)
+	if err != nil {
+		return fmt.Errorf("starting stream: %w", err)
+	}
+	defer stream.Close()
+
+	for {
+		select {
+		case ord := <-orders:
+			ev := web.Event{
+				ID:   ord.ID,
+				Name: "order." + string(ord.Status),
+				Data: ord,
+			}
+			if err := stream.Send(ev); err != nil {
+				if errors.Is(err, web.ErrStreamClosed) {
+					return nil
+				}
+				return fmt.Errorf("sending order[%s]: %w", ord.ID, err)
+			}
+
+		case <-stream.Done():
+			return nil
+		}
+	}
+}
+
+// =============================================================================
+
+// Resource loads the cafe the order named by the id route parameter was
+// placed at so mid.Require can check who owns it and who is on its staff.
+func (h Handlers) Resource(ctx context.Context, r *http.Request) (*auth.Resource, error) {
+	ord, err := h.queryByID(ctx, web.Param(r, "id"))
+	if err != nil {
+		return nil, err
+	}
+
+	return h.resource(ctx, ord)
+}
+
+// resource describes the cafe the order was placed at for the permission
+// checks, including the role the authenticated user holds on its staff.
+func (h Handlers) resource(ctx context.Context, ord order.Order) (*auth.Resource, error) {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return nil, v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	caf, err := h.Cafe.QueryByID(ctx, ord.CafeID)
+	if err != nil {
+		return nil, fmt.Errorf("querying cafe[%s]: %w", ord.CafeID, err)
+	}
+
+	res := auth.Resource{
+		OwnerID: caf.OwnerID,
+		CafeID:  caf.ID,
+	}
+
+	mem, err := h.Cafe.QueryMember(ctx, caf.ID, claims.Subject)
+	switch {
+	case err == nil:
+		if mem.Accepted() {
+			res.MemberRole = mem.Role
+		}
+	case !errors.Is(err, cafev2.ErrMemberNotFound):
+		return nil, fmt.Errorf("querying member cafe[%s] user[%s]: %w", caf.ID, claims.Subject, err)
+	}
+
+	return &res, nil
+}
+
+// queryByID retrieves an order and maps the core errors to request errors.
+func (h Handlers) queryByID(ctx context.Context, id string) (order.Order, error) {
+	ord, err := h.Order.QueryByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, order.ErrInvalidID):
+			return order.Order{}, v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, order.ErrNotFound):
+			return order.Order{}, v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return order.Order{}, fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	return ord, nil
+}
+
+// ownedCafe retrieves the cafe owned by the authenticated user.
+func (h Handlers) ownedCafe(ctx context.Context) (cafev2.Cafe, error) {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return cafev2.Cafe{}, v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	caf, err := h.Cafe.QueryByOwnerID(ctx, claims.Subject)
+	if err != nil {
+		switch {
+		case errors.Is(err, cafev2.ErrNotFound):
+			return cafev2.Cafe{}, v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return cafev2.Cafe{}, fmt.Errorf("querying cafe for owner[%s]: %w", claims.Subject, err)
+		}
+	}
+
+	// Claims limited to a cafe, such as a cafe scoped API key, can't be
+	// used to manage the orders of any other.
+	res := auth.Resource{OwnerID: caf.OwnerID, CafeID: caf.ID}
+	if err := auth.Check(claims, auth.PermOrderManage, &res); err != nil {
+		return cafev2.Cafe{}, v1Web.NewRequestError(err, http.StatusForbidden)
+	}
+
+	return caf, nil
+}
+
+// paging reads the page and rows route parameters.
+func paging(r *http.Request) (int, int, error) {
+	page := web.Param(r, "page")
+	pageNumber, err := strconv.Atoi(page)
+	if err != nil {
+		return 0, 0, v1Web.NewRequestError(fmt.Errorf("invalid page format, page[%s]", page), http.StatusBadRequest)
+	}
+
+	rows := web.Param(r, "rows")
+	rowsPerPage, err := strconv.Atoi(rows)
+	if err != nil {
+		return 0, 0, v1Web.NewRequestError(fmt.Errorf("invalid rows format, rows[%s]", rows), http.StatusBadRequest)
+	}
+
+	return pageNumber, rowsPerPage, nil
+}
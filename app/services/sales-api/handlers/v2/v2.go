@@ -1,13 +1,20 @@
-// Package v1 contains the full set of handler functions and routes
-// supported by the v1 web api.
-package v1
+// Package v2 contains the full set of handler functions and routes
+// supported by the v2 web api.
+package v2
 
 import (
 	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v2/cafegrp"
+	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v2/menugrp"
+	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v2/ordergrp"
+	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers/v2/webhookgrp"
 	"github.com/colmmurphy91/go-service/business/core/cafev2"
+	"github.com/colmmurphy91/go-service/business/core/menu"
+	"github.com/colmmurphy91/go-service/business/core/order"
+	"github.com/colmmurphy91/go-service/business/core/webhook"
 	"net/http"
 
 	"github.com/colmmurphy91/go-service/business/sys/auth"
+	"github.com/colmmurphy91/go-service/business/sys/ratelimit"
 	"github.com/colmmurphy91/go-service/business/web/v1/mid"
 	"github.com/colmmurphy91/go-service/foundation/web"
 	"github.com/jmoiron/sqlx"
@@ -19,19 +26,83 @@ type Config struct {
 	Log  *zap.SugaredLogger
 	Auth *auth.Auth
 	DB   *sqlx.DB
+
+	// RateLimit keeps the buckets for the authenticated routes, limited by
+	// API key or user.
+	RateLimit ratelimit.Store
+	APILimit  ratelimit.Limit
 }
 
 // Routes binds all the version 2 routes.
 func Routes(app *web.App, cfg Config) {
 	const version = "v2"
 
-	authen := mid.Authenticate(cfg.Auth)
+	authen := web.Chain(mid.Authenticate(cfg.Auth), mid.RateLimit(version, cfg.RateLimit, cfg.APILimit, mid.ByAPIKey))
+
+	cafeCore := cafev2.NewCore(cfg.Log, cfg.DB)
 
 	cgh := cafegrp.Handlers{
-		Cafe: cafev2.NewCore(cfg.Log, cfg.DB),
+		Cafe: cafeCore,
 	}
 
-	app.Handle(http.MethodPost, version, "/cafes", cgh.Create, authen)
-	app.Handle(http.MethodGet, version, "/cafes/:id", cgh.QueryByID, authen)
+	app.Handle(http.MethodGet, version, "/cafes/:page/:rows", cgh.Query, authen, mid.Require(auth.PermCafeList, nil))
+	app.Handle(http.MethodGet, version, "/cafes/:id", cgh.QueryByID, authen, mid.Require(auth.PermCafeRead, cgh.Resource))
+	app.Handle(http.MethodPost, version, "/cafes", cgh.Create, authen, mid.Require(auth.PermCafeCreate, nil))
+	app.Handle(http.MethodPut, version, "/cafes/:id", cgh.Update, authen, mid.Require(auth.PermCafeWrite, cgh.Resource))
+	app.Handle(http.MethodDelete, version, "/cafes/:id", cgh.Delete, authen, mid.Require(auth.PermCafeDelete, cgh.DeleteResource))
 	app.Handle(http.MethodGet, version, "/hello", cgh.Hello)
+
+	// Register cafe staff endpoints. Owners and managers look after the staff
+	// while invited users accept their own invitations.
+	staff := mid.Require(auth.PermStaffManage, cgh.Resource)
+	app.Handle(http.MethodGet, version, "/cafes/:id/members", cgh.Members, authen, staff)
+	app.Handle(http.MethodPost, version, "/cafes/:id/members", cgh.Invite, authen, staff)
+	app.Handle(http.MethodDelete, version, "/cafes/:id/members/:user", cgh.RemoveMember, authen, staff)
+	app.Handle(http.MethodPost, version, "/cafes/:id/members/accept", cgh.Accept, authen)
+	app.Handle(http.MethodGet, version, "/memberships", cgh.Memberships, authen)
+
+	// Register cafe menu endpoints. These are restricted to the cafe owner and
+	// staff.
+	mgh := menugrp.Handlers{
+		Menu: menu.NewCore(cfg.Log, cfg.DB),
+	}
+	menuRead := mid.Require(auth.PermMenuRead, cgh.Resource)
+	menuWrite := mid.Require(auth.PermMenuWrite, cgh.Resource)
+	app.Handle(http.MethodGet, version, "/cafes/:id/menu", mgh.Query, authen, menuRead)
+	app.Handle(http.MethodGet, version, "/cafes/:id/menu/:item", mgh.QueryByID, authen, menuRead)
+	app.Handle(http.MethodPost, version, "/cafes/:id/menu", mgh.Create, authen, menuWrite)
+	app.Handle(http.MethodPut, version, "/cafes/:id/menu/:item", mgh.Update, authen, menuWrite)
+	app.Handle(http.MethodDelete, version, "/cafes/:id/menu/:item", mgh.Delete, authen, menuWrite)
+
+	// Register order endpoints. Customers work with their own orders while
+	// cafe owners and staff work the queue of orders placed at their cafe.
+	ogh := ordergrp.Handlers{
+		Order: order.NewCore(cfg.Log, cfg.DB),
+		Cafe:  cafeCore,
+	}
+	manage := mid.Require(auth.PermOrderManage, nil)
+	manageCafe := mid.Require(auth.PermOrderManage, cgh.Resource)
+	manageOrder := mid.Require(auth.PermOrderManage, ogh.Resource)
+	app.Handle(http.MethodGet, version, "/orders/:page/:rows", ogh.Query, authen)
+	app.Handle(http.MethodGet, version, "/orders/:id", ogh.QueryByID, authen)
+	app.Handle(http.MethodPost, version, "/orders", ogh.Place, authen)
+	app.Handle(http.MethodPost, version, "/orders/:id/cancel", ogh.Cancel, authen)
+	app.Handle(http.MethodGet, version, "/orders/cafe/:page/:rows", ogh.QueryCafe, authen, manage)
+	app.Handle(http.MethodPut, version, "/orders/:id/status", ogh.UpdateStatus, authen, manageOrder)
+	app.Handle(http.MethodGet, version, "/cafes/:id/orders/:page/:rows", ogh.QueryCafe, authen, manageCafe)
+	app.Handle(http.MethodGet, version, "/cafes/:id/orders/stream", ogh.Stream, authen, manageCafe)
+
+	// Register webhook endpoints. Cafe owners register where events about
+	// their cafe are sent and can resend past deliveries.
+	wgh := webhookgrp.Handlers{
+		Webhook: webhook.NewCore(cfg.Log, cfg.DB),
+		Cafe:    cafeCore,
+	}
+	app.Handle(http.MethodGet, version, "/webhooks", wgh.Query, authen)
+	app.Handle(http.MethodPost, version, "/webhooks", wgh.Create, authen)
+	app.Handle(http.MethodGet, version, "/webhooks/:id", wgh.QueryByID, authen)
+	app.Handle(http.MethodPut, version, "/webhooks/:id", wgh.Update, authen)
+	app.Handle(http.MethodDelete, version, "/webhooks/:id", wgh.Delete, authen)
+	app.Handle(http.MethodGet, version, "/webhooks/:id/deliveries/:page/:rows", wgh.Deliveries, authen)
+	app.Handle(http.MethodPost, version, "/webhooks/:id/deliveries/:delivery/redeliver", wgh.Redeliver, authen)
 }
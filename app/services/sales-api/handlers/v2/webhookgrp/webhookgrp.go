@@ -0,0 +1,239 @@
+// Package webhookgrp maintains the group of handlers for webhook access.
+// Cafe owners register the URLs they want events sent to and can see and
+// resend what was delivered to them.
+package webhookgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/colmmurphy91/go-service/business/core/cafev2"
+	"github.com/colmmurphy91/go-service/business/core/webhook"
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
+	"github.com/colmmurphy91/go-service/foundation/web"
+)
+
+// Handlers manages the set of webhook endpoints.
+type Handlers struct {
+	Webhook webhook.Core
+	Cafe    cafev2.Core
+}
+
+// Create registers a webhook for the cafe owned by the authenticated user.
+// The response holds the secret deliveries are signed with, which is not
+// shown again.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	caf, err := h.ownedCafe(ctx)
+	if err != nil {
+		return err
+	}
+
+	var nw webhook.NewWebhook
+	if err := web.Decode(r, &nw); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	wh, err := h.Webhook.Create(ctx, caf.ID, nw, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, webhook.ErrInvalidURL), errors.Is(err, webhook.ErrInvalidEventType):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("creating webhook, nw[%+v]: %w", nw, err)
+		}
+	}
+
+	return web.Respond(ctx, w, wh, http.StatusCreated)
+}
+
+// Query returns the webhooks of the cafe owned by the authenticated user.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	caf, err := h.ownedCafe(ctx)
+	if err != nil {
+		return err
+	}
+
+	whs, err := h.Webhook.QueryByCafeID(ctx, caf.ID)
+	if err != nil {
+		return fmt.Errorf("unable to query for webhooks: %w", err)
+	}
+
+	return web.Respond(ctx, w, whs, http.StatusOK)
+}
+
+// QueryByID returns a webhook of the cafe owned by the authenticated user.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	wh, err := h.ownedWebhook(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, wh, http.StatusOK)
+}
+
+// Update modifies a webhook of the cafe owned by the authenticated user.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	wh, err := h.ownedWebhook(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	var uw webhook.UpdateWebhook
+	if err := web.Decode(r, &uw); err != nil {
+		return fmt.Errorf("unable to decode payload: %w", err)
+	}
+
+	if err := h.Webhook.Update(ctx, wh.ID, uw, v.Now); err != nil {
+		switch {
+		case errors.Is(err, webhook.ErrInvalidURL), errors.Is(err, webhook.ErrInvalidEventType):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, webhook.ErrNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] Webhook[%+v]: %w", wh.ID, &uw, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Delete removes a webhook of the cafe owned by the authenticated user.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	wh, err := h.ownedWebhook(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Webhook.Delete(ctx, wh.ID); err != nil {
+		return fmt.Errorf("ID[%s]: %w", wh.ID, err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Deliveries returns the log of deliveries to a webhook, newest first.
+func (h Handlers) Deliveries(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	wh, err := h.ownedWebhook(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	page := web.Param(r, "page")
+	pageNumber, err := strconv.Atoi(page)
+	if err != nil {
+		return v1Web.NewRequestError(fmt.Errorf("invalid page format [%s]", page), http.StatusBadRequest)
+	}
+	rows := web.Param(r, "rows")
+	rowsPerPage, err := strconv.Atoi(rows)
+	if err != nil {
+		return v1Web.NewRequestError(fmt.Errorf("invalid rows format [%s]", rows), http.StatusBadRequest)
+	}
+
+	ds, err := h.Webhook.QueryDeliveries(ctx, wh.ID, pageNumber, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query for deliveries: %w", err)
+	}
+
+	return web.Respond(ctx, w, ds, http.StatusOK)
+}
+
+// Redeliver queues a delivery to a webhook to be sent again.
+func (h Handlers) Redeliver(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	wh, err := h.ownedWebhook(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	deliveryID := web.Param(r, "delivery")
+
+	d, err := h.Webhook.Redeliver(ctx, wh.ID, deliveryID, v.Now)
+	if err != nil {
+		switch {
+		case errors.Is(err, webhook.ErrInvalidID):
+			return v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, webhook.ErrDeliveryNotFound):
+			return v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] Delivery[%s]: %w", wh.ID, deliveryID, err)
+		}
+	}
+
+	return web.Respond(ctx, w, d, http.StatusAccepted)
+}
+
+// =============================================================================
+
+// ownedCafe returns the cafe owned by the authenticated user, checking they
+// may manage its webhooks.
+func (h Handlers) ownedCafe(ctx context.Context) (cafev2.Cafe, error) {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return cafev2.Cafe{}, v1Web.NewRequestError(auth.ErrForbidden, http.StatusForbidden)
+	}
+
+	caf, err := h.Cafe.QueryByOwnerID(ctx, claims.Subject)
+	if err != nil {
+		switch {
+		case errors.Is(err, cafev2.ErrNotFound):
+			return cafev2.Cafe{}, v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return cafev2.Cafe{}, fmt.Errorf("querying cafe for owner[%s]: %w", claims.Subject, err)
+		}
+	}
+
+	res := auth.Resource{OwnerID: caf.OwnerID, CafeID: caf.ID}
+	if err := auth.Check(claims, auth.PermWebhookManage, &res); err != nil {
+		return cafev2.Cafe{}, v1Web.NewRequestError(err, http.StatusForbidden)
+	}
+
+	return caf, nil
+}
+
+// ownedWebhook returns the webhook identified in the request if it belongs
+// to the cafe owned by the authenticated user. Webhooks of other cafes are
+// reported as not found.
+func (h Handlers) ownedWebhook(ctx context.Context, r *http.Request) (webhook.Webhook, error) {
+	caf, err := h.ownedCafe(ctx)
+	if err != nil {
+		return webhook.Webhook{}, err
+	}
+
+	id := web.Param(r, "id")
+
+	wh, err := h.Webhook.QueryByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, webhook.ErrInvalidID):
+			return webhook.Webhook{}, v1Web.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, webhook.ErrNotFound):
+			return webhook.Webhook{}, v1Web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return webhook.Webhook{}, fmt.Errorf("ID[%s]: %w", id, err)
+		}
+	}
+
+	if wh.CafeID != caf.ID {
+		return webhook.Webhook{}, v1Web.NewRequestError(webhook.ErrNotFound, http.StatusNotFound)
+	}
+
+	return wh, nil
+}
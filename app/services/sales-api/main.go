@@ -16,10 +16,23 @@ import (
 
 	"github.com/ardanlabs/conf/v3"
 	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers"
+	"github.com/colmmurphy91/go-service/business/core/apikey"
+	"github.com/colmmurphy91/go-service/business/core/product"
+	"github.com/colmmurphy91/go-service/business/core/user"
+	"github.com/colmmurphy91/go-service/business/core/webhook"
 	"github.com/colmmurphy91/go-service/business/sys/auth"
+	authdb "github.com/colmmurphy91/go-service/business/sys/auth/db"
+	"github.com/colmmurphy91/go-service/business/sys/events"
+	"github.com/colmmurphy91/go-service/business/sys/jobs"
+	"github.com/colmmurphy91/go-service/business/sys/ratelimit"
+	ratelimitdb "github.com/colmmurphy91/go-service/business/sys/ratelimit/db"
 	"github.com/colmmurphy91/go-service/foundation/keystore"
 	"github.com/colmmurphy91/go-service/foundation/logger"
+	"github.com/colmmurphy91/go-service/foundation/mail"
+	"github.com/colmmurphy91/go-service/foundation/oidc"
+	"github.com/colmmurphy91/go-service/foundation/worker"
 	"github.com/emadolsky/automaxprocs/maxprocs"
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/zipkin"
@@ -83,8 +96,26 @@ func run(log *zap.SugaredLogger) error {
 			DebugHost       string        `conf:"default:0.0.0.0:4000"`
 		}
 		Auth struct {
-			KeysFolder string `conf:"default:zarf/keys/"`
-			ActiveKID  string `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"`
+			KeysFolder string        `conf:"default:zarf/keys/"`
+			ActiveKID  string        `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"`
+			RefreshTTL time.Duration `conf:"default:720h"`
+			KeyGrace   time.Duration `conf:"default:2h"`
+			Algorithms []string      `conf:"default:RS256;ES256;EdDSA"`
+			KeySource  string        `conf:"default:fs,help:where to read keys from: fs | env | vault"`
+			KeyPrefix  string        `conf:"default:SALES_JWT_KEY_"`
+			Vault      struct {
+				Address  string        `conf:"default:http://vault:8200"`
+				Token    string        `conf:"mask"`
+				Path     string        `conf:"default:secret/data/sales-api/keys"`
+				CacheTTL time.Duration `conf:"default:5m"`
+			}
+			OIDC struct {
+				Issuer       string `conf:"help:sign in through this OpenID Connect provider when set"`
+				ClientID     string
+				ClientSecret string   `conf:"mask"`
+				RedirectURL  string   `conf:"default:http://localhost:3000/v1/users/oidc/callback"`
+				Scopes       []string `conf:"default:openid;email;profile"`
+			}
 		}
 		DB struct {
 			User         string `conf:"default:postgres"`
@@ -101,6 +132,57 @@ func run(log *zap.SugaredLogger) error {
 			Host     string `conf:"default:localhost"`
 			Database string `conf:"default:cafe"`
 		}
+		Mail struct {
+			Host       string `conf:"help:SMTP server host, mail is written to the outbox folder when empty"`
+			Port       int    `conf:"default:587"`
+			Username   string
+			Password   string `conf:"mask"`
+			From       string `conf:"default:noreply@localhost"`
+			Outbox     string `conf:"default:zarf/outbox/"`
+			ConfirmURL string `conf:"default:http://localhost:3000/v1/users/confirm"`
+			ResetURL   string `conf:"help:page that asks for a new password and POSTs it with the email and token to /v1/users/password/reset, the token is mailed on its own when empty"`
+		}
+		RateLimit struct {
+			Store       string        `conf:"default:memory,help:where to keep rate limit buckets: memory | postgres"`
+			Per         time.Duration `conf:"default:1m"`
+			SignInRate  int           `conf:"default:10"`
+			SignInBurst int           `conf:"default:20"`
+			V1Rate      int           `conf:"default:600"`
+			V1Burst     int           `conf:"default:100"`
+			V2Rate      int           `conf:"default:600"`
+			V2Burst     int           `conf:"default:100"`
+		}
+		Events struct {
+			Interval    time.Duration `conf:"default:1s"`
+			BatchSize   int           `conf:"default:100"`
+			Lease       time.Duration `conf:"default:1m"`
+			MaxAttempts int           `conf:"default:10"`
+			Backoff     time.Duration `conf:"default:1s"`
+			MaxBackoff  time.Duration `conf:"default:1h"`
+			Retention   time.Duration `conf:"default:168h"`
+		}
+		Worker struct {
+			Name        string        `conf:"help:names the replica in work keys, defaults to the host name"`
+			Retention   time.Duration `conf:"default:1h"`
+			ImportLimit int           `conf:"default:2"`
+		}
+		Jobs struct {
+			Interval    time.Duration `conf:"default:1s"`
+			BatchSize   int           `conf:"default:10"`
+			Lease       time.Duration `conf:"default:1m"`
+			MaxAttempts int           `conf:"default:5"`
+			Backoff     time.Duration `conf:"default:10s"`
+			MaxBackoff  time.Duration `conf:"default:1h"`
+			Retention   time.Duration `conf:"default:168h"`
+		}
+		Webhooks struct {
+			Interval    time.Duration `conf:"default:1s"`
+			BatchSize   int           `conf:"default:50"`
+			Timeout     time.Duration `conf:"default:10s"`
+			MaxAttempts int           `conf:"default:8"`
+			Backoff     time.Duration `conf:"default:30s"`
+			MaxBackoff  time.Duration `conf:"default:6h"`
+		}
 		Zipkin struct {
 			ReporterURI string  `conf:"default:http://localhost:9411/api/v2/spans"`
 			ServiceName string  `conf:"default:sales-api"`
@@ -137,23 +219,6 @@ func run(log *zap.SugaredLogger) error {
 
 	expvar.NewString("build").Set(build)
 
-	// =========================================================================
-	// Initialize authentication support
-
-	log.Infow("startup", "status", "initializing authentication support")
-
-	// Construct a key store based on the key files stored in
-	// the specified directory.
-	ks, err := keystore.NewFS(os.DirFS(cfg.Auth.KeysFolder))
-	if err != nil {
-		return fmt.Errorf("reading keys: %w", err)
-	}
-
-	auth, err := auth.New(cfg.Auth.ActiveKID, ks)
-	if err != nil {
-		return fmt.Errorf("constructing auth: %w", err)
-	}
-
 	// =========================================================================
 	// Database Support
 
@@ -189,6 +254,219 @@ func run(log *zap.SugaredLogger) error {
 		db.Close()
 	}()
 
+	// =========================================================================
+	// Initialize authentication support
+
+	log.Infow("startup", "status", "initializing authentication support")
+
+	// Construct a key store from the configured source. Keys read from the
+	// key folder can be rotated at runtime. Retired keys keep validating for
+	// the grace period, which should be at least as long as an access token
+	// lives.
+	var keys interface {
+		auth.KeyLookup
+		JWKS() keystore.JWKS
+	}
+
+	switch cfg.Auth.KeySource {
+	case "fs":
+		keys, err = keystore.NewFS(os.DirFS(cfg.Auth.KeysFolder), keystore.WithGrace(cfg.Auth.KeyGrace))
+	case "env":
+		keys, err = keystore.NewEnv(cfg.Auth.KeyPrefix)
+	case "vault":
+		keys, err = keystore.NewHTTP(keystore.HTTPConfig{
+			Address:  cfg.Auth.Vault.Address,
+			Token:    cfg.Auth.Vault.Token,
+			Path:     cfg.Auth.Vault.Path,
+			CacheTTL: cfg.Auth.Vault.CacheTTL,
+		})
+	default:
+		err = fmt.Errorf("unknown key source %q", cfg.Auth.KeySource)
+	}
+	if err != nil {
+		return fmt.Errorf("reading keys: %w", err)
+	}
+
+	// Tokens are checked against the user records so a password reset or a
+	// deleted user ends any outstanding sessions. Refresh tokens, revoked
+	// token ids and API keys are kept in the database.
+	auth, err := auth.New(
+		cfg.Auth.ActiveKID,
+		keys,
+		auth.WithRevocationChecker(user.NewCore(log, db)),
+		auth.WithStore(authdb.NewStore(log, db)),
+		auth.WithAPIKeys(apikey.NewCore(log, db)),
+		auth.WithRefreshTTL(cfg.Auth.RefreshTTL),
+		auth.WithAlgorithms(cfg.Auth.Algorithms...),
+	)
+	if err != nil {
+		return fmt.Errorf("constructing auth: %w", err)
+	}
+
+	// Users can sign in through an external identity provider when one is
+	// configured.
+	var provider *oidc.Provider
+	if cfg.Auth.OIDC.Issuer != "" {
+		provider, err = oidc.New(oidc.Config{
+			Issuer:       cfg.Auth.OIDC.Issuer,
+			ClientID:     cfg.Auth.OIDC.ClientID,
+			ClientSecret: cfg.Auth.OIDC.ClientSecret,
+			RedirectURL:  cfg.Auth.OIDC.RedirectURL,
+			Scopes:       cfg.Auth.OIDC.Scopes,
+		})
+		if err != nil {
+			return fmt.Errorf("constructing oidc provider: %w", err)
+		}
+	}
+
+	// =========================================================================
+	// Mail Support
+
+	// Send mail through an SMTP server when one is configured, otherwise write
+	// it to the outbox folder so it can be read during local development.
+	var mailer user.MailSender
+
+	switch cfg.Mail.Host {
+	case "":
+		log.Infow("startup", "status", "initializing mail support", "outbox", cfg.Mail.Outbox)
+
+		mailer, err = mail.NewFile(cfg.Mail.Outbox, cfg.Mail.From)
+		if err != nil {
+			return fmt.Errorf("constructing mail outbox: %w", err)
+		}
+
+	default:
+		log.Infow("startup", "status", "initializing mail support", "host", cfg.Mail.Host)
+
+		mailer = mail.NewSMTP(mail.SMTPConfig{
+			Host:     cfg.Mail.Host,
+			Port:     cfg.Mail.Port,
+			Username: cfg.Mail.Username,
+			Password: cfg.Mail.Password,
+			From:     cfg.Mail.From,
+		})
+	}
+
+	// =========================================================================
+	// Rate Limit Support
+
+	// Keep buckets in the database when there is more than one replica so the
+	// limits hold across all of them.
+	var limitStore ratelimit.Store
+
+	switch cfg.RateLimit.Store {
+	case "memory":
+		limitStore = ratelimit.NewMemoryStore()
+	case "postgres":
+		limitStore = ratelimitdb.NewStore(log, db)
+	default:
+		return fmt.Errorf("unknown rate limit store %q", cfg.RateLimit.Store)
+	}
+
+	limits := handlers.RateLimits{
+		Store:  limitStore,
+		SignIn: ratelimit.Limit{Rate: cfg.RateLimit.SignInRate, Per: cfg.RateLimit.Per, Burst: cfg.RateLimit.SignInBurst},
+		V1:     ratelimit.Limit{Rate: cfg.RateLimit.V1Rate, Per: cfg.RateLimit.Per, Burst: cfg.RateLimit.V1Burst},
+		V2:     ratelimit.Limit{Rate: cfg.RateLimit.V2Rate, Per: cfg.RateLimit.Per, Burst: cfg.RateLimit.V2Burst},
+	}
+
+	// =========================================================================
+	// Start Background Workers
+
+	log.Infow("startup", "status", "initializing background workers")
+
+	// The relay delivers the events core packages write to the outbox. Every
+	// replica runs one, and they share the work between them.
+	relay := events.NewRelay(log, db, events.RelayConfig{
+		Interval:    cfg.Events.Interval,
+		BatchSize:   cfg.Events.BatchSize,
+		Lease:       cfg.Events.Lease,
+		MaxAttempts: cfg.Events.MaxAttempts,
+		Backoff:     cfg.Events.Backoff,
+		MaxBackoff:  cfg.Events.MaxBackoff,
+		Retention:   cfg.Events.Retention,
+	})
+
+	// Events cafe owners subscribed to are queued as webhook deliveries and
+	// sent by the dispatcher.
+	webhookCore := webhook.NewCore(log, db)
+	for _, et := range webhook.EventTypes {
+		relay.Register(et, webhookCore.Enqueue)
+	}
+
+	dispatcher := webhook.NewDispatcher(log, db, webhook.DispatchConfig{
+		Interval:    cfg.Webhooks.Interval,
+		BatchSize:   cfg.Webhooks.BatchSize,
+		Timeout:     cfg.Webhooks.Timeout,
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		Backoff:     cfg.Webhooks.Backoff,
+		MaxBackoff:  cfg.Webhooks.MaxBackoff,
+	})
+
+	// The runner runs the jobs in the durable job queue, and queues the jobs
+	// of schedules as they fire.
+	runner := jobs.NewRunner(log, db, jobs.RunnerConfig{
+		Interval:    cfg.Jobs.Interval,
+		BatchSize:   cfg.Jobs.BatchSize,
+		Lease:       cfg.Jobs.Lease,
+		MaxAttempts: cfg.Jobs.MaxAttempts,
+		Backoff:     cfg.Jobs.Backoff,
+		MaxBackoff:  cfg.Jobs.MaxBackoff,
+		Retention:   cfg.Jobs.Retention,
+	})
+
+	// The loops above run for the life of the process on their own worker,
+	// which is not given to the API so they can't be stopped over HTTP.
+	sys := worker.New(map[string]worker.JobFunc{
+		events.RelayJob:     relay.Run,
+		webhook.DispatchJob: dispatcher.Run,
+		jobs.RunJob:         runner.Run,
+	})
+
+	if _, err := sys.Start(context.Background(), uuid.NewString(), events.RelayJob, nil); err != nil {
+		return fmt.Errorf("starting events relay: %w", err)
+	}
+	if _, err := sys.Start(context.Background(), uuid.NewString(), webhook.DispatchJob, nil); err != nil {
+		return fmt.Errorf("starting webhook dispatcher: %w", err)
+	}
+	if _, err := sys.Start(context.Background(), uuid.NewString(), jobs.RunJob, nil); err != nil {
+		return fmt.Errorf("starting jobs runner: %w", err)
+	}
+
+	// Product imports are started by the API, a few at a time. Their status
+	// is only kept by this replica, so the worker is named after it and the
+	// API refuses the work keys of other replicas.
+	productCore := product.NewCore(log, db)
+
+	workerName := cfg.Worker.Name
+	if workerName == "" {
+		if workerName, err = os.Hostname(); err != nil {
+			return fmt.Errorf("reading host name: %w", err)
+		}
+	}
+
+	wrk := worker.New(map[string]worker.JobFunc{
+		product.ImportJob: productCore.RunImport,
+	},
+		worker.WithName(workerName),
+		worker.WithRetention(cfg.Worker.Retention),
+		worker.WithLimit(product.ImportJob, cfg.Worker.ImportLimit),
+	)
+
+	defer func() {
+		log.Infow("shutdown", "status", "stopping background workers")
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
+		defer cancel()
+
+		if err := wrk.Shutdown(ctx); err != nil {
+			log.Errorw("shutdown", "status", "background work did not stop", "ERROR", err)
+		}
+		if err := sys.Shutdown(ctx); err != nil {
+			log.Errorw("shutdown", "status", "background workers did not stop", "ERROR", err)
+		}
+	}()
+
 	// =========================================================================
 	// Start Tracing Support
 
@@ -240,6 +518,15 @@ func run(log *zap.SugaredLogger) error {
 		Auth:     auth,
 		DB:       db,
 		MDB:      mDB,
+		Mail:     mailer,
+		MailURLs: user.MailURLs{
+			Confirm: cfg.Mail.ConfirmURL,
+			Reset:   cfg.Mail.ResetURL,
+		},
+		Keys:   keys,
+		OIDC:   provider,
+		Limits: limits,
+		Worker: wrk,
 	})
 
 	// Construct a server to service the requests against the mux.
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers"
+	"github.com/colmmurphy91/go-service/business/core/cafev2"
+	"github.com/colmmurphy91/go-service/business/core/menu"
+	"github.com/colmmurphy91/go-service/business/core/order"
+	"github.com/colmmurphy91/go-service/business/data/dbtest"
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+)
+
+// OrderTests holds methods for each order subtest. This type allows passing
+// dependencies for tests while still providing a convenient syntax when
+// subtests are registered.
+type OrderTests struct {
+	app       http.Handler
+	cafe      cafev2.Core
+	userToken string
+	cafeID    string
+	orderID   string
+}
+
+// TestOrders is the entry point for testing how the staff of a cafe work its
+// order queue.
+func TestOrders(t *testing.T) {
+	t.Parallel()
+
+	test := dbtest.NewIntegration(t, sqlC, "inttestorders")
+	t.Cleanup(test.Teardown)
+
+	ctx := context.Background()
+	now := time.Now()
+	adminID := "5cf37266-3473-4006-984f-9325122678b7"
+
+	cafeCore := cafev2.NewCore(test.Log, test.DB)
+	caf, err := cafeCore.Create(ctx, cafev2.NewCafe{Name: "Gopher Beans", Address: "1 Go Way"}, adminID, now)
+	if err != nil {
+		t.Fatalf("creating cafe: %v", err)
+	}
+
+	mi, err := menu.NewCore(test.Log, test.DB).Create(ctx, caf.ID, menu.NewMenuItem{Name: "Flat White", Category: "Coffee", Price: 350}, now)
+	if err != nil {
+		t.Fatalf("creating menu item: %v", err)
+	}
+
+	no := order.NewOrder{
+		CafeID: caf.ID,
+		Items:  []order.NewOrderItem{{MenuItemID: mi.ID, Quantity: 1}},
+	}
+	ord, err := order.NewCore(test.Log, test.DB).Place(ctx, no, adminID, now)
+	if err != nil {
+		t.Fatalf("placing order: %v", err)
+	}
+
+	if _, err := cafeCore.Invite(ctx, caf.ID, cafev2.NewMember{Email: "user@example.com", Role: auth.CafeRoleBarista}, adminID, now); err != nil {
+		t.Fatalf("inviting barista: %v", err)
+	}
+
+	shutdown := make(chan os.Signal, 1)
+	tests := OrderTests{
+		app: handlers.APIMux(handlers.APIMuxConfig{
+			Shutdown: shutdown,
+			Log:      test.Log,
+			Auth:     test.Auth,
+			DB:       test.DB,
+		}),
+		cafe:      cafeCore,
+		userToken: test.Token("user@example.com", "gophers"),
+		cafeID:    caf.ID,
+		orderID:   ord.ID,
+	}
+
+	t.Run("baristaUpdatesStatus", tests.baristaUpdatesStatus)
+}
+
+// baristaUpdatesStatus validates a barista can only work the order queue of a
+// cafe once they have accepted the invitation to its staff.
+func (ot *OrderTests) baristaUpdatesStatus(t *testing.T) {
+	status := func() *httptest.ResponseRecorder {
+		body := strings.NewReader(`{"status":"accepted"}`)
+		r := httptest.NewRequest(http.MethodPut, "/v2/orders/"+ot.orderID+"/status", body)
+		w := httptest.NewRecorder()
+
+		r.Header.Set("Authorization", "Bearer "+ot.userToken)
+		ot.app.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Log("Given the need to let the staff of a cafe work its order queue.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a barista has not accepted their invitation.", testID)
+		{
+			if w := status(); w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", dbtest.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a barista on the staff updates an order.", testID)
+		{
+			if _, err := ot.cafe.Accept(context.Background(), ot.cafeID, "45b5fbd3-755f-4379-8f07-a58d4a30fa2f", time.Now()); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to accept the invitation : %v", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to accept the invitation.", dbtest.Success, testID)
+
+			w := status()
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", dbtest.Success, testID)
+
+			var got order.Order
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to unmarshal the response.", dbtest.Success, testID)
+
+			if got.Status != order.StatusAccepted {
+				t.Fatalf("\t%s\tTest %d:\tShould see the order accepted : %s", dbtest.Failed, testID, got.Status)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see the order accepted.", dbtest.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen a barista on the staff lists the order queue.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v2/cafes/"+ot.cafeID+"/orders/1/10", nil)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+ot.userToken)
+			ot.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", dbtest.Success, testID)
+
+			var got []order.Order
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", dbtest.Failed, testID, err)
+			}
+
+			if len(got) != 1 || got[0].ID != ot.orderID {
+				t.Fatalf("\t%s\tTest %d:\tShould see the order in the queue : %+v", dbtest.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see the order in the queue.", dbtest.Success, testID)
+		}
+	}
+}
@@ -2,18 +2,22 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers"
+	"github.com/colmmurphy91/go-service/business/core/audit"
 	"github.com/colmmurphy91/go-service/business/core/product"
 	"github.com/colmmurphy91/go-service/business/data/dbtest"
 	"github.com/colmmurphy91/go-service/business/sys/validate"
 	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
+	"github.com/colmmurphy91/go-service/foundation/worker"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 )
@@ -23,6 +27,7 @@ import (
 // when subtests are registered.
 type ProductTests struct {
 	app       http.Handler
+	audit     audit.Core
 	userToken string
 }
 
@@ -38,6 +43,11 @@ func TestProducts(t *testing.T) {
 	test := dbtest.NewIntegration(t, sqlC, "inttestprods")
 	t.Cleanup(test.Teardown)
 
+	wrk := worker.New(map[string]worker.JobFunc{
+		product.ImportJob: product.NewCore(test.Log, test.DB).RunImport,
+	})
+	t.Cleanup(func() { wrk.Shutdown(context.Background()) })
+
 	shutdown := make(chan os.Signal, 1)
 	tests := ProductTests{
 		app: handlers.APIMux(handlers.APIMuxConfig{
@@ -45,7 +55,9 @@ func TestProducts(t *testing.T) {
 			Log:      test.Log,
 			Auth:     test.Auth,
 			DB:       test.DB,
+			Worker:   wrk,
 		}),
+		audit:     audit.NewCore(test.Log, test.DB),
 		userToken: test.Token("admin@example.com", "gophers"),
 	}
 
@@ -56,6 +68,81 @@ func TestProducts(t *testing.T) {
 	t.Run("deleteProductNotFound", tests.deleteProductNotFound)
 	t.Run("putProduct404", tests.putProduct404)
 	t.Run("crudProducts", tests.crudProduct)
+	t.Run("importProducts", tests.importProducts)
+}
+
+// importProducts validates the products added by an import are audited
+// against the user who started it.
+func (pt *ProductTests) importProducts(t *testing.T) {
+	body := strings.NewReader("name,cost,quantity\nImported Comics,10,5\n")
+	r := httptest.NewRequest(http.MethodPost, "/v1/products/import", body)
+	w := httptest.NewRecorder()
+
+	r.Header.Set("Authorization", "Bearer "+pt.userToken)
+	r.Header.Set("Content-Type", "text/csv")
+	pt.app.ServeHTTP(w, r)
+
+	t.Log("Given the need to import products in the background.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen an admin imports products.", testID)
+		{
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 202 for the response : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 202 for the response.", dbtest.Success, testID)
+
+			var started struct {
+				WorkKey string `json:"work_key"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&started); err != nil || started.WorkKey == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a work key : %v", dbtest.Failed, testID, err)
+			}
+
+			var st worker.Status
+			for i := 0; i < 50 && st.State != worker.StateSucceeded; i++ {
+				r := httptest.NewRequest(http.MethodGet, "/v1/work/"+started.WorkKey, nil)
+				w := httptest.NewRecorder()
+
+				r.Header.Set("Authorization", "Bearer "+pt.userToken)
+				pt.app.ServeHTTP(w, r)
+
+				if err := json.NewDecoder(w.Body).Decode(&st); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the work status : %v", dbtest.Failed, testID, err)
+				}
+				if st.State == worker.StateFailed {
+					t.Fatalf("\t%s\tTest %d:\tShould finish the import : %s", dbtest.Failed, testID, st.Error)
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+			if st.State != worker.StateSucceeded {
+				t.Fatalf("\t%s\tTest %d:\tShould finish the import : %s", dbtest.Failed, testID, st.State)
+			}
+			t.Logf("\t%s\tTest %d:\tShould finish the import.", dbtest.Success, testID)
+
+			filter := audit.QueryFilter{Action: audit.ActionCreate, Entity: "product"}
+			entries, err := pt.audit.Query(context.Background(), filter, 1, 100)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the audit log : %v", dbtest.Failed, testID, err)
+			}
+
+			var found bool
+			for _, e := range entries {
+				if !strings.Contains(string(e.After), "Imported Comics") {
+					continue
+				}
+				found = true
+
+				if e.ActorID != "5cf37266-3473-4006-984f-9325122678b7" || e.TraceID != st.TraceID {
+					t.Fatalf("\t%s\tTest %d:\tShould audit the import against the admin and the request : %+v", dbtest.Failed, testID, e)
+				}
+			}
+			if !found {
+				t.Fatalf("\t%s\tTest %d:\tShould audit the imported product.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould audit the import against the admin and the request.", dbtest.Success, testID)
+		}
+	}
 }
 
 // postProduct400 validates a product can't be created with the endpoint
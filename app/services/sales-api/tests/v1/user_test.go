@@ -2,19 +2,25 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/colmmurphy91/go-service/app/services/sales-api/handlers"
+	"github.com/colmmurphy91/go-service/business/core/apikey"
 	"github.com/colmmurphy91/go-service/business/core/user"
 	"github.com/colmmurphy91/go-service/business/data/dbtest"
 	"github.com/colmmurphy91/go-service/business/sys/auth"
 	"github.com/colmmurphy91/go-service/business/sys/validate"
 	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
+	"github.com/colmmurphy91/go-service/foundation/oidc"
+	"github.com/colmmurphy91/go-service/foundation/oidc/oidctest"
+	"github.com/colmmurphy91/go-service/foundation/totp"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 )
@@ -26,6 +32,8 @@ type UserTests struct {
 	app        http.Handler
 	userToken  string
 	adminToken string
+	adminKey   string
+	idp        *oidctest.IdP
 }
 
 // TestUsers is the entry point for testing user management functions.
@@ -35,6 +43,21 @@ func TestUsers(t *testing.T) {
 	test := dbtest.NewIntegration(t, sqlC, "inttestusers")
 	t.Cleanup(test.Teardown)
 
+	idp, err := oidctest.New("sales-api")
+	if err != nil {
+		t.Fatalf("starting identity provider: %v", err)
+	}
+	t.Cleanup(idp.Close)
+
+	provider, err := oidc.New(oidc.Config{
+		Issuer:      idp.URL,
+		ClientID:    "sales-api",
+		RedirectURL: "http://localhost:3000/v1/users/oidc/callback",
+	})
+	if err != nil {
+		t.Fatalf("constructing oidc provider: %v", err)
+	}
+
 	shutdown := make(chan os.Signal, 1)
 	tests := UserTests{
 		app: handlers.APIMux(handlers.APIMuxConfig{
@@ -42,16 +65,35 @@ func TestUsers(t *testing.T) {
 			Log:      test.Log,
 			Auth:     test.Auth,
 			DB:       test.DB,
+			OIDC:     provider,
 		}),
 		userToken:  test.Token("user@example.com", "gophers"),
 		adminToken: test.Token("admin@example.com", "gophers"),
+		idp:        idp,
+	}
+
+	nk := apikey.NewAPIKey{
+		UserID: "5cf37266-3473-4006-984f-9325122678b7",
+		Name:   "Integration",
+		Roles:  []string{auth.RoleAdmin},
 	}
+	_, adminKey, err := apikey.NewCore(test.Log, test.DB).Create(context.Background(), nk, time.Now())
+	if err != nil {
+		t.Fatalf("creating api key: %v", err)
+	}
+	tests.adminKey = adminKey
 
 	t.Run("getToken404", tests.getToken404)
 	t.Run("getToken200", tests.getToken200)
 	t.Run("notConfirmed", tests.getToken400NotConfirmed)
 	t.Run("putConfirm200", tests.putConfirmUser)
 	t.Run("shouldGetToken", tests.getToken200AfterConfirmed)
+	t.Run("refreshToken", tests.refreshToken)
+	t.Run("revokeToken", tests.revokeToken)
+	t.Run("apiKey", tests.apiKey)
+	t.Run("oidcLogin", tests.oidcLogin)
+	t.Run("twoFactor", tests.twoFactor)
+	t.Run("tokenLockout", tests.tokenLockout)
 	t.Run("postUser400", tests.postUser400)
 	t.Run("postUser401", tests.postUser401)
 	t.Run("postUser403", tests.postUser403)
@@ -174,6 +216,451 @@ func (ut *UserTests) getToken200AfterConfirmed(t *testing.T) {
 	}
 }
 
+// tokenPair is the response of the token and refresh endpoints.
+type tokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// fetchToken gets a token pair for the user with the specified credentials.
+func (ut *UserTests) fetchToken(t *testing.T, email, pass string) tokenPair {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+	w := httptest.NewRecorder()
+
+	r.SetBasicAuth(email, pass)
+	ut.app.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("\t%s\tShould receive a status code of 200 fetching a token : %v", dbtest.Failed, w.Code)
+	}
+
+	var tp tokenPair
+	if err := json.NewDecoder(w.Body).Decode(&tp); err != nil {
+		t.Fatalf("\t%s\tShould be able to unmarshal the token response : %v", dbtest.Failed, err)
+	}
+
+	return tp
+}
+
+// postRefresh exchanges a refresh token using the refresh endpoint.
+func (ut *UserTests) postRefresh(refreshToken string) *httptest.ResponseRecorder {
+	body := strings.NewReader(`{"refresh_token":"` + refreshToken + `"}`)
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/users/token/refresh", body)
+	w := httptest.NewRecorder()
+	ut.app.ServeHTTP(w, r)
+
+	return w
+}
+
+// refreshToken validates refresh tokens rotate and can only be used once.
+func (ut *UserTests) refreshToken(t *testing.T) {
+	first := ut.fetchToken(t, "user@example.com", "gophers")
+
+	t.Log("Given the need to refresh access tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen exchanging a refresh token.", testID)
+		{
+			if first.RefreshToken == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a refresh token with the access token.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a refresh token with the access token.", dbtest.Success, testID)
+
+			w := ut.postRefresh(first.RefreshToken)
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", dbtest.Success, testID)
+
+			var second tokenPair
+			if err := json.NewDecoder(w.Body).Decode(&second); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to unmarshal the response.", dbtest.Success, testID)
+
+			if second.Token == "" || second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a new token pair : %+v", dbtest.Failed, testID, second)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a new token pair.", dbtest.Success, testID)
+
+			if w := ut.postRefresh(first.RefreshToken); w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 reusing a refresh token : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 reusing a refresh token.", dbtest.Success, testID)
+
+			if w := ut.postRefresh(second.RefreshToken); w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke the rest of the family after reuse : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke the rest of the family after reuse.", dbtest.Success, testID)
+		}
+	}
+}
+
+// revokeToken validates a revoked access token is no longer accepted.
+func (ut *UserTests) revokeToken(t *testing.T) {
+	tp := ut.fetchToken(t, "user@example.com", "gophers")
+
+	t.Log("Given the need to revoke tokens.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen revoking the token in use.", testID)
+		{
+			body := strings.NewReader(`{"refresh_token":"` + tp.RefreshToken + `"}`)
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/users/token/revoke", body)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+tp.Token)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 for the response : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 204 for the response.", dbtest.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/users/1/1", nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("Authorization", "Bearer "+tp.Token)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 using the revoked token : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 using the revoked token.", dbtest.Success, testID)
+
+			if w := ut.postRefresh(tp.RefreshToken); w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 using the revoked refresh token : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 using the revoked refresh token.", dbtest.Success, testID)
+		}
+	}
+}
+
+// apiKey validates a machine client can authenticate with an API key.
+func (ut *UserTests) apiKey(t *testing.T) {
+	t.Log("Given the need to authenticate machine clients.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen calling an endpoint with an API key.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/1/1", nil)
+			w := httptest.NewRecorder()
+
+			r.Header.Set("X-API-Key", ut.adminKey)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", dbtest.Success, testID)
+
+			r = httptest.NewRequest(http.MethodGet, "/v1/users/1/1", nil)
+			w = httptest.NewRecorder()
+
+			r.Header.Set("X-API-Key", "sk_unknown")
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 for an unknown key : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 for an unknown key.", dbtest.Success, testID)
+		}
+	}
+}
+
+// oidcSignIn signs the user in at the identity provider and returns the
+// callback request the provider redirects the browser back with, carrying
+// the cookies set when the sign in started.
+func (ut *UserTests) oidcSignIn(t *testing.T, usr oidctest.User) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/oidc/login", nil)
+	w := httptest.NewRecorder()
+	ut.app.ServeHTTP(w, r)
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("\t%s\tShould receive a status code of 302 starting a sign in : %v", dbtest.Failed, w.Code)
+	}
+
+	redirect, err := ut.idp.Authorize(w.Header().Get("Location"), usr)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to sign in at the identity provider : %v", dbtest.Failed, err)
+	}
+
+	callback := httptest.NewRequest(http.MethodGet, redirect, nil)
+	for _, c := range w.Result().Cookies() {
+		callback.AddCookie(c)
+	}
+
+	return callback
+}
+
+// oidcLogin validates users can sign in through an external identity
+// provider, being provisioned on their first sign in.
+func (ut *UserTests) oidcLogin(t *testing.T) {
+	usr := oidctest.User{
+		Subject:       "oidc-gopher",
+		Email:         "oidc@example.com",
+		EmailVerified: true,
+		Name:          "OIDC Gopher",
+	}
+
+	t.Log("Given the need to sign in through an identity provider.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen signing in for the first time.", testID)
+		{
+			callback := ut.oidcSignIn(t, usr)
+
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, callback)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", dbtest.Success, testID)
+
+			var tp tokenPair
+			if err := json.NewDecoder(w.Body).Decode(&tp); err != nil || tp.Token == "" || tp.RefreshToken == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back a token pair : %v", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back a token pair.", dbtest.Success, testID)
+
+			w = httptest.NewRecorder()
+			ut.app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, callback.URL.String(), nil))
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 finishing the sign in twice : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 finishing the sign in twice.", dbtest.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen signing in again.", testID)
+		{
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, ut.oidcSignIn(t, usr))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 for the response : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", dbtest.Success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen the identity provider hasn't verified the email.", testID)
+		{
+			unverified := oidctest.User{
+				Subject: "oidc-unverified",
+				Email:   "user@example.com",
+			}
+
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, ut.oidcSignIn(t, unverified))
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 403 for the response : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 403 for the response.", dbtest.Success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen the callback comes from a browser that didn't start the sign in.", testID)
+		{
+			callback := ut.oidcSignIn(t, usr)
+
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, callback.URL.String(), nil))
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 without the state cookie : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 without the state cookie.", dbtest.Success, testID)
+
+			w = httptest.NewRecorder()
+			ut.app.ServeHTTP(w, callback)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould still finish the sign in from the browser that started it : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould still finish the sign in from the browser that started it.", dbtest.Success, testID)
+		}
+	}
+}
+
+// twoFactor validates a user with two-factor authentication enabled has to
+// give a code after their password to get a token.
+func (ut *UserTests) twoFactor(t *testing.T) {
+	const email = "notconfirmed@example.com"
+	tp := ut.fetchToken(t, email, "gophers")
+
+	post := func(path string, token string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		ut.app.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Log("Given the need to sign in with a second factor.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen the user has enrolled an authenticator app.", testID)
+		{
+			w := post("/v1/users/2fa/enroll", tp.Token, "")
+			if w.Code != http.StatusCreated {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 201 enrolling : %v", dbtest.Failed, testID, w.Code)
+			}
+
+			var enrollment user.TOTPEnrollment
+			if err := json.NewDecoder(w.Body).Decode(&enrollment); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unmarshal the response : %v", dbtest.Failed, testID, err)
+			}
+			if !strings.HasPrefix(enrollment.URI, "otpauth://totp/") || len(enrollment.RecoveryCodes) == 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a provisioning uri and recovery codes : %+v", dbtest.Failed, testID, enrollment)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a provisioning uri and recovery codes.", dbtest.Success, testID)
+
+			code, err := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a code : %v", dbtest.Failed, testID, err)
+			}
+
+			if w := post("/v1/users/2fa/confirm", tp.Token, `{"code":"`+code+`"}`); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 confirming : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to confirm the authenticator app.", dbtest.Success, testID)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+			w = httptest.NewRecorder()
+			r.SetBasicAuth(email, "gophers")
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 202 fetching a token : %v", dbtest.Failed, testID, w.Code)
+			}
+
+			var step struct {
+				TwoFactorRequired bool   `json:"two_factor_required"`
+				Challenge         string `json:"challenge"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&step); err != nil || !step.TwoFactorRequired || step.Challenge == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a challenge instead of a token : %v", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a challenge instead of a token.", dbtest.Success, testID)
+
+			body := `{"challenge":"` + step.Challenge + `","code":"000000"}`
+			if w := post("/v1/users/token/2fa", "", body); w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 with a wrong code : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 with a wrong code.", dbtest.Success, testID)
+
+			body = `{"challenge":"` + step.Challenge + `","code":"` + enrollment.RecoveryCodes[0] + `"}`
+			w = post("/v1/users/token/2fa", "", body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 with a recovery code : %v", dbtest.Failed, testID, w.Code)
+			}
+
+			var second tokenPair
+			if err := json.NewDecoder(w.Body).Decode(&second); err != nil || second.Token == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a token pair : %v", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a token pair with a recovery code.", dbtest.Success, testID)
+
+			linked := oidctest.User{
+				Subject:       "oidc-two-factor",
+				Email:         email,
+				EmailVerified: true,
+			}
+			w = httptest.NewRecorder()
+			ut.app.ServeHTTP(w, ut.oidcSignIn(t, linked))
+
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 202 signing in through the identity provider : %v", dbtest.Failed, testID, w.Code)
+			}
+
+			step.Challenge = ""
+			if err := json.NewDecoder(w.Body).Decode(&step); err != nil || !step.TwoFactorRequired || step.Challenge == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a challenge signing in through the identity provider : %v", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a challenge signing in through the identity provider.", dbtest.Success, testID)
+
+			body = `{"challenge":"` + step.Challenge + `","code":"` + enrollment.RecoveryCodes[2] + `"}`
+			if w := post("/v1/users/token/2fa", "", body); w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 finishing the sign in : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould finish the sign in through the identity provider with a second factor.", dbtest.Success, testID)
+
+			body = `{"password":"wrong","code":"` + enrollment.RecoveryCodes[1] + `"}`
+			if w := post("/v1/users/2fa/disable", second.Token, body); w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 disabling without the password : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to disable two-factor authentication without the password.", dbtest.Success, testID)
+
+			body = `{"password":"gophers","code":"` + enrollment.RecoveryCodes[1] + `"}`
+			if w := post("/v1/users/2fa/disable", second.Token, body); w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 disabling : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to disable two-factor authentication.", dbtest.Success, testID)
+
+			ut.fetchToken(t, email, "gophers")
+			t.Logf("\t%s\tTest %d:\tShould get a token with just the password once disabled.", dbtest.Success, testID)
+		}
+	}
+}
+
+// tokenLockout validates repeated failed sign ins for an email are throttled
+// until an admin unlocks the account.
+func (ut *UserTests) tokenLockout(t *testing.T) {
+	getToken := func(pass string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+		w := httptest.NewRecorder()
+		r.SetBasicAuth("user@example.com", pass)
+		ut.app.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Log("Given the need to throttle password guessing.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen an email has failed to sign in too often.", testID)
+		{
+			for i := 0; i < 5; i++ {
+				if w := getToken("wrong"); w.Code != http.StatusUnauthorized {
+					t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 401 with a wrong password : %v", dbtest.Failed, testID, w.Code)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 401 with a wrong password.", dbtest.Success, testID)
+
+			w := getToken("gophers")
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 429 once locked out : %v", dbtest.Failed, testID, w.Code)
+			}
+			if w.Header().Get("Retry-After") == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould be told when to try again.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 429 once locked out.", dbtest.Success, testID)
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/users/45b5fbd3-755f-4379-8f07-a58d4a30fa2f/unlock", nil)
+			w = httptest.NewRecorder()
+			r.Header.Set("Authorization", "Bearer "+ut.adminToken)
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 204 unlocking : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to unlock the account.", dbtest.Success, testID)
+
+			if w := getToken("gophers"); w.Code != http.StatusOK {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 200 once unlocked : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 once unlocked.", dbtest.Success, testID)
+		}
+	}
+}
+
 func (ut *UserTests) putConfirmUser(t *testing.T) {
 	r := httptest.NewRequest(http.MethodPut, "/v1/users/confirm?email=notconfirmed@example.com&token=12345", nil)
 	w := httptest.NewRecorder()
@@ -189,6 +676,19 @@ func (ut *UserTests) putConfirmUser(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould receive a status code of 200 for the response.", dbtest.Success, testID)
 		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen opening the link from the confirmation email.", testID)
+		{
+			r := httptest.NewRequest(http.MethodGet, "/v1/users/confirm?email=notconfirmed@example.com&token=12345", nil)
+			w := httptest.NewRecorder()
+			ut.app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusConflict {
+				t.Fatalf("\t%s\tTest %d:\tShould receive a status code of 409 once already confirmed : %v", dbtest.Failed, testID, w.Code)
+			}
+			t.Logf("\t%s\tTest %d:\tShould receive a status code of 409 once already confirmed.", dbtest.Success, testID)
+		}
 	}
 }
 
@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/apikey"
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"go.uber.org/zap"
+)
+
+// APIKeyAdd creates a new api key for a machine client acting for a user. The
+// roles are a comma separated list. The cafe id and time to live are optional.
+func APIKeyAdd(log *zap.SugaredLogger, cfg sql.Config, userID, name, roles, cafeID, ttl string) error {
+	if userID == "" || name == "" || roles == "" {
+		fmt.Println("help: apikeyadd <user_id> <name> <roles> [cafe_id] [ttl]")
+		return ErrHelp
+	}
+
+	now := time.Now()
+
+	var expires time.Time
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return fmt.Errorf("parsing ttl: %w", err)
+		}
+		expires = now.Add(d)
+	}
+
+	db, err := sql.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	core := apikey.NewCore(log, db)
+
+	nk := apikey.NewAPIKey{
+		UserID:      userID,
+		CafeID:      cafeID,
+		Name:        name,
+		Roles:       strings.Split(roles, ","),
+		DateExpires: expires,
+	}
+
+	key, secret, err := core.Create(ctx, nk, now)
+	if err != nil {
+		return fmt.Errorf("create api key: %w", err)
+	}
+
+	fmt.Println("api key id:", key.ID)
+	fmt.Println("api key   :", secret)
+	fmt.Println("store the key now, it can't be shown again")
+	return nil
+}
+
+// APIKeys retrieves all api keys from the database.
+func APIKeys(log *zap.SugaredLogger, cfg sql.Config, pageNumber string, rowsPerPage string) error {
+	db, err := sql.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	page, err := strconv.Atoi(pageNumber)
+	if err != nil {
+		return fmt.Errorf("converting page number: %w", err)
+	}
+
+	rows, err := strconv.Atoi(rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("converting rows per page: %w", err)
+	}
+
+	core := apikey.NewCore(log, db)
+
+	keys, err := core.Query(ctx, page, rows)
+	if err != nil {
+		return fmt.Errorf("retrieve api keys: %w", err)
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(keys)
+}
+
+// APIKeyRevoke stops the specified api key from being accepted.
+func APIKeyRevoke(log *zap.SugaredLogger, cfg sql.Config, keyID string) error {
+	if keyID == "" {
+		fmt.Println("help: apikeyrevoke <api_key_id>")
+		return ErrHelp
+	}
+
+	db, err := sql.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	core := apikey.NewCore(log, db)
+
+	if err := core.Revoke(ctx, keyID, time.Now()); err != nil {
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+
+	fmt.Println("api key revoked")
+	return nil
+}
@@ -1,6 +1,10 @@
 package commands
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -9,12 +13,58 @@ import (
 	"os"
 )
 
-// GenKey creates an x509 private/public key for auth tokens.
-func GenKey() error {
+// GenKey creates an x509 private/public key for auth tokens. The key type
+// decides the signing algorithm: rsa for RS256, ecdsa for ES256 and ed25519
+// for EdDSA. RSA is used when no type is provided.
+func GenKey(keyType string) error {
+	var privateBlock pem.Block
+	var publicKey crypto.PublicKey
 
-	// Generate a new private key.
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
+	// Generate a new private key and construct a PEM block for it.
+	switch keyType {
+	case "", "rsa":
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return fmt.Errorf("generating key: %w", err)
+		}
+		privateBlock = pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		}
+		publicKey = &privateKey.PublicKey
+
+	case "ecdsa":
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return fmt.Errorf("generating key: %w", err)
+		}
+		der, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return fmt.Errorf("marshaling private key: %w", err)
+		}
+		privateBlock = pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}
+		publicKey = &privateKey.PublicKey
+
+	case "ed25519":
+		pub, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("generating key: %w", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return fmt.Errorf("marshaling private key: %w", err)
+		}
+		privateBlock = pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: der,
+		}
+		publicKey = pub
+
+	default:
+		fmt.Println("help: genkey [rsa|ecdsa|ed25519]")
 		return ErrHelp
 	}
 
@@ -25,19 +75,13 @@ func GenKey() error {
 	}
 	defer privateFile.Close()
 
-	// Construct a PEM block for the private key.
-	privateBlock := pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-	}
-
 	// Write the private key to the private key file.
 	if err := pem.Encode(privateFile, &privateBlock); err != nil {
 		return fmt.Errorf("encoding to private file: %w", err)
 	}
 
 	// Marshal the public key from the private key to PKIX.
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(publicKey)
 	if err != nil {
 		return fmt.Errorf("marshaling public key: %w", err)
 	}
@@ -49,11 +93,15 @@ func GenKey() error {
 	}
 	defer publicFile.Close()
 
-	// Construct a PEM block for the public key.
+	// Construct a PEM block for the public key. RSA keys keep the block type
+	// they have always been written with.
 	publicBlock := pem.Block{
-		Type:  "RSA PUBLIC KEY",
+		Type:  "PUBLIC KEY",
 		Bytes: asn1Bytes,
 	}
+	if _, ok := publicKey.(*rsa.PublicKey); ok {
+		publicBlock.Type = "RSA PUBLIC KEY"
+	}
 
 	// Write the public key to the private key file.
 	if err := pem.Encode(publicFile, &publicBlock); err != nil {
@@ -117,8 +117,32 @@ func processCommands(args conf.Args, log *zap.SugaredLogger, dbConfig sql.Config
 			return fmt.Errorf("getting users: %w", err)
 		}
 
+	case "apikeyadd":
+		userID := args.Num(1)
+		name := args.Num(2)
+		roles := args.Num(3)
+		cafeID := args.Num(4)
+		ttl := args.Num(5)
+		if err := commands.APIKeyAdd(log, dbConfig, userID, name, roles, cafeID, ttl); err != nil {
+			return fmt.Errorf("adding api key: %w", err)
+		}
+
+	case "apikeys":
+		pageNumber := args.Num(1)
+		rowsPerPage := args.Num(2)
+		if err := commands.APIKeys(log, dbConfig, pageNumber, rowsPerPage); err != nil {
+			return fmt.Errorf("getting api keys: %w", err)
+		}
+
+	case "apikeyrevoke":
+		keyID := args.Num(1)
+		if err := commands.APIKeyRevoke(log, dbConfig, keyID); err != nil {
+			return fmt.Errorf("revoking api key: %w", err)
+		}
+
 	case "genkey":
-		if err := commands.GenKey(); err != nil {
+		keyType := args.Num(1)
+		if err := commands.GenKey(keyType); err != nil {
 			return fmt.Errorf("key generation: %w", err)
 		}
 
@@ -134,7 +158,10 @@ func processCommands(args conf.Args, log *zap.SugaredLogger, dbConfig sql.Config
 		fmt.Println("seed: add data to the database")
 		fmt.Println("useradd: add a new user to the database")
 		fmt.Println("users: get a list of users from the database")
-		fmt.Println("genkey: generate a set of private/public key files")
+		fmt.Println("apikeyadd: add a new api key for a machine client")
+		fmt.Println("apikeys: get a list of api keys from the database")
+		fmt.Println("apikeyrevoke: revoke an api key")
+		fmt.Println("genkey: generate a set of private/public key files (rsa, ecdsa or ed25519)")
 		fmt.Println("gentoken: generate a JWT for a user with claims")
 		fmt.Println("provide a command to get more help.")
 		return commands.ErrHelp
@@ -0,0 +1,252 @@
+// Package apikey provides the core business API for the keys machine clients,
+// such as POS terminals and reporting jobs, use to call the service.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/apikey/db"
+	cafedb "github.com/colmmurphy91/go-service/business/core/cafev2/db"
+	userdb "github.com/colmmurphy91/go-service/business/core/user/db"
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+	csql "github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound     = errors.New("api key not found")
+	ErrInvalidID    = errors.New("ID is not in its proper form")
+	ErrUserNotFound = errors.New("user not found")
+	ErrCafeNotFound = errors.New("cafe not found")
+	ErrRoleNotHeld  = errors.New("api key roles must be held by the user")
+	ErrCafeNotOwned = errors.New("api key can only be limited to a cafe the user owns")
+	ErrExpiryInPast = errors.New("api key expiry must be in the future")
+)
+
+const (
+	// keyPrefix marks a string as one of our API keys.
+	keyPrefix = "sk_"
+
+	// lastUsedInterval is how stale the last used time can get before a use
+	// of the key records it again.
+	lastUsedInterval = time.Minute
+)
+
+// Core manages the set of APIs for api key access.
+type Core struct {
+	store db.Store
+	user  userdb.Store
+	cafe  cafedb.Store
+}
+
+// NewCore constructs a core for api key api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+	return Core{
+		store: db.NewStore(log, sqlxDB),
+		user:  userdb.NewStore(log, sqlxDB),
+		cafe:  cafedb.NewStore(log, sqlxDB),
+	}
+}
+
+// Create adds an APIKey to the database. It returns the created APIKey along
+// with the key itself, which is not stored and can't be retrieved again.
+func (c Core) Create(ctx context.Context, nk NewAPIKey, now time.Time) (APIKey, string, error) {
+	if err := validate.Check(nk); err != nil {
+		return APIKey{}, "", fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := validate.CheckID(nk.UserID); err != nil {
+		return APIKey{}, "", ErrInvalidID
+	}
+
+	if !nk.DateExpires.IsZero() && !nk.DateExpires.After(now) {
+		return APIKey{}, "", ErrExpiryInPast
+	}
+
+	dbUsr, err := c.user.QueryByID(ctx, nk.UserID)
+	if err != nil {
+		if errors.Is(err, csql.ErrDBNotFound) {
+			return APIKey{}, "", ErrUserNotFound
+		}
+		return APIKey{}, "", fmt.Errorf("query user: %w", err)
+	}
+
+	for _, role := range nk.Roles {
+		if !contains(dbUsr.Roles, role) {
+			return APIKey{}, "", ErrRoleNotHeld
+		}
+	}
+
+	var cafeID sql.NullString
+	if nk.CafeID != "" {
+		if err := validate.CheckID(nk.CafeID); err != nil {
+			return APIKey{}, "", ErrInvalidID
+		}
+
+		dbCaf, err := c.cafe.QueryByID(ctx, nk.CafeID)
+		if err != nil {
+			if errors.Is(err, csql.ErrDBNotFound) {
+				return APIKey{}, "", ErrCafeNotFound
+			}
+			return APIKey{}, "", fmt.Errorf("query cafe: %w", err)
+		}
+
+		if dbCaf.OwnerID != dbUsr.ID && !contains(dbUsr.Roles, auth.RoleAdmin) {
+			return APIKey{}, "", ErrCafeNotOwned
+		}
+
+		cafeID = sql.NullString{String: nk.CafeID, Valid: true}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return APIKey{}, "", fmt.Errorf("generating key: %w", err)
+	}
+	key := keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	dbKey := db.APIKey{
+		ID:          validate.GenerateID(),
+		UserID:      nk.UserID,
+		CafeID:      cafeID,
+		Name:        nk.Name,
+		Prefix:      key[:len(keyPrefix)+6],
+		Hash:        hashKey(key),
+		Roles:       nk.Roles,
+		DateCreated: now,
+		DateExpires: sql.NullTime{Time: nk.DateExpires, Valid: !nk.DateExpires.IsZero()},
+	}
+
+	if err := c.store.Create(ctx, dbKey); err != nil {
+		return APIKey{}, "", fmt.Errorf("create: %w", err)
+	}
+
+	return toAPIKey(dbKey), key, nil
+}
+
+// Revoke stops the api key identified by a given ID from being accepted.
+func (c Core) Revoke(ctx context.Context, keyID string, now time.Time) error {
+	if err := validate.CheckID(keyID); err != nil {
+		return ErrInvalidID
+	}
+
+	if err := c.store.Revoke(ctx, keyID, now); err != nil {
+		if errors.Is(err, csql.ErrDBNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("revoke: %w", err)
+	}
+
+	return nil
+}
+
+// Query retrieves a list of existing api keys from the database.
+func (c Core) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]APIKey, error) {
+	dbKeys, err := c.store.Query(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toAPIKeySlice(dbKeys), nil
+}
+
+// QueryByID gets the specified api key from the database.
+func (c Core) QueryByID(ctx context.Context, keyID string) (APIKey, error) {
+	if err := validate.CheckID(keyID); err != nil {
+		return APIKey{}, ErrInvalidID
+	}
+
+	dbKey, err := c.store.QueryByID(ctx, keyID)
+	if err != nil {
+		if errors.Is(err, csql.ErrDBNotFound) {
+			return APIKey{}, ErrNotFound
+		}
+		return APIKey{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toAPIKey(dbKey), nil
+}
+
+// Authenticate exchanges an api key for the claims it grants. The claims act
+// for the user that owns the key, limited to the roles on the key that the
+// user still holds. It returns auth.ErrAPIKeyInvalid if the key is unknown,
+// expired or revoked.
+func (c Core) Authenticate(ctx context.Context, key string, now time.Time) (auth.Claims, error) {
+	dbKey, err := c.store.QueryByHash(ctx, hashKey(key))
+	if err != nil {
+		if errors.Is(err, csql.ErrDBNotFound) {
+			return auth.Claims{}, auth.ErrAPIKeyInvalid
+		}
+		return auth.Claims{}, fmt.Errorf("query: %w", err)
+	}
+
+	if dbKey.DateRevoked.Valid {
+		return auth.Claims{}, auth.ErrAPIKeyInvalid
+	}
+
+	if dbKey.DateExpires.Valid && !now.Before(dbKey.DateExpires.Time) {
+		return auth.Claims{}, auth.ErrAPIKeyInvalid
+	}
+
+	dbUsr, err := c.user.QueryByID(ctx, dbKey.UserID)
+	if err != nil {
+		if errors.Is(err, csql.ErrDBNotFound) {
+			return auth.Claims{}, auth.ErrAPIKeyInvalid
+		}
+		return auth.Claims{}, fmt.Errorf("query user: %w", err)
+	}
+
+	roles := make([]string, 0, len(dbKey.Roles))
+	for _, role := range dbKey.Roles {
+		if contains(dbUsr.Roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	if err := c.store.TouchLastUsed(ctx, dbKey.ID, now, now.Add(-lastUsedInterval)); err != nil {
+		return auth.Claims{}, fmt.Errorf("touch: %w", err)
+	}
+
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       dbKey.ID,
+			Subject:  dbKey.UserID,
+			Issuer:   "service project",
+			IssuedAt: jwt.NewNumericDate(now),
+		},
+		Roles:  roles,
+		CafeID: dbKey.CafeID.String,
+	}
+
+	return claims, nil
+}
+
+// =============================================================================
+
+// hashKey returns the hex encoded sha256 of an api key. Keys are random so a
+// fast hash is enough to keep a leaked table from being usable.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// contains reports whether the role is in the set of roles.
+func contains(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package apikey_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/apikey"
+	"github.com/colmmurphy91/go-service/business/core/cafev2"
+	"github.com/colmmurphy91/go-service/business/data/dbtest"
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+	"github.com/colmmurphy91/go-service/foundation/docker"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestAPIKey(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testapikey")
+	t.Cleanup(teardown)
+
+	core := apikey.NewCore(log, db)
+	cafeCore := cafev2.NewCore(log, db)
+
+	const (
+		adminID = "5cf37266-3473-4006-984f-9325122678b7"
+		ownerID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2e"
+	)
+
+	t.Log("Given the need to work with API keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single API key.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			nk := apikey.NewAPIKey{
+				UserID: adminID,
+				Name:   "Reporting",
+				Roles:  []string{auth.RoleUser},
+			}
+
+			key, secret, err := core.Create(ctx, nk, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an api key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create an api key.", dbtest.Success, testID)
+
+			claims, err := core.Authenticate(ctx, secret, now.Add(time.Hour))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate with the key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to authenticate with the key.", dbtest.Success, testID)
+
+			if claims.Subject != adminID || len(claims.Roles) != 1 || claims.Roles[0] != auth.RoleUser {
+				t.Fatalf("\t%s\tTest %d:\tShould get claims limited to the key's roles : %+v.", dbtest.Failed, testID, claims)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get claims limited to the key's roles.", dbtest.Success, testID)
+
+			saved, err := core.QueryByID(ctx, key.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve api key by ID : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve api key by ID.", dbtest.Success, testID)
+
+			if !saved.DateLastUsed.Equal(now.Add(time.Hour)) {
+				t.Fatalf("\t%s\tTest %d:\tShould record when the key was last used : %v.", dbtest.Failed, testID, saved.DateLastUsed)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record when the key was last used.", dbtest.Success, testID)
+
+			if _, err := core.Authenticate(ctx, secret+"x", now); !errors.Is(err, auth.ErrAPIKeyInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to authenticate with an unknown key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to authenticate with an unknown key.", dbtest.Success, testID)
+
+			if err := core.Revoke(ctx, key.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to revoke the key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to revoke the key.", dbtest.Success, testID)
+
+			if _, err := core.Authenticate(ctx, secret, now); !errors.Is(err, auth.ErrAPIKeyInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to authenticate with a revoked key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to authenticate with a revoked key.", dbtest.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen limiting an API key.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			nk := apikey.NewAPIKey{
+				UserID: ownerID,
+				Name:   "Escalation",
+				Roles:  []string{auth.RoleAdmin},
+			}
+
+			if _, _, err := core.Create(ctx, nk, now); !errors.Is(err, apikey.ErrRoleNotHeld) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to grant a role the user doesn't hold : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to grant a role the user doesn't hold.", dbtest.Success, testID)
+
+			caf, err := cafeCore.Create(ctx, cafev2.NewCafe{Name: "Gopher Beans", Address: "1 Main Street"}, ownerID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a cafe : %s.", dbtest.Failed, testID, err)
+			}
+
+			nk = apikey.NewAPIKey{
+				UserID:      ownerID,
+				CafeID:      caf.ID,
+				Name:        "POS terminal",
+				Roles:       []string{auth.RoleOwner},
+				DateExpires: now.Add(24 * time.Hour),
+			}
+
+			_, secret, err := core.Create(ctx, nk, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a cafe scoped api key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a cafe scoped api key.", dbtest.Success, testID)
+
+			claims, err := core.Authenticate(ctx, secret, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate with the key : %s.", dbtest.Failed, testID, err)
+			}
+
+			if claims.CafeID != caf.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould get claims limited to the cafe : %+v.", dbtest.Failed, testID, claims)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get claims limited to the cafe.", dbtest.Success, testID)
+
+			if _, err := core.Authenticate(ctx, secret, now.Add(24*time.Hour)); !errors.Is(err, auth.ErrAPIKeyInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to authenticate with an expired key : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to authenticate with an expired key.", dbtest.Success, testID)
+		}
+	}
+}
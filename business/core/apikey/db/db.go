@@ -0,0 +1,199 @@
+// Package db contains api key related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for api key access.
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           sql.Transactor
+	db           sqlx.ExtContext
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		tr:  db,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	return sql.WithinTran(ctx, s.log, s.tr, fn)
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// Create inserts a new api key into the database.
+func (s Store) Create(ctx context.Context, key APIKey) error {
+	const q = `
+	INSERT INTO api_keys
+		(api_key_id, user_id, cafe_id, name, prefix, key_hash, roles, date_created, date_expires)
+	VALUES
+		(:api_key_id, :user_id, :cafe_id, :name, :prefix, :key_hash, :roles, :date_created, :date_expires)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, key); err != nil {
+		return fmt.Errorf("inserting api key: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke marks an api key as revoked. ErrDBNotFound is returned if the key
+// does not exist or was already revoked.
+func (s Store) Revoke(ctx context.Context, keyID string, now time.Time) error {
+	data := struct {
+		ID  string    `db:"api_key_id"`
+		Now time.Time `db:"now"`
+	}{
+		ID:  keyID,
+		Now: now,
+	}
+
+	const q = `
+	UPDATE
+		api_keys
+	SET
+		"date_revoked" = :now
+	WHERE
+		api_key_id = :api_key_id AND
+		date_revoked IS NULL
+	RETURNING
+		api_key_id`
+
+	var result struct {
+		ID string `db:"api_key_id"`
+	}
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &result); err != nil {
+		return fmt.Errorf("revoking api key[%s]: %w", keyID, err)
+	}
+
+	return nil
+}
+
+// TouchLastUsed records that the api key was used. To keep authentication
+// from writing on every request the timestamp is only moved once it is older
+// than the specified threshold.
+func (s Store) TouchLastUsed(ctx context.Context, keyID string, now time.Time, threshold time.Time) error {
+	data := struct {
+		ID        string    `db:"api_key_id"`
+		Now       time.Time `db:"now"`
+		Threshold time.Time `db:"threshold"`
+	}{
+		ID:        keyID,
+		Now:       now,
+		Threshold: threshold,
+	}
+
+	const q = `
+	UPDATE
+		api_keys
+	SET
+		"date_last_used" = :now
+	WHERE
+		api_key_id = :api_key_id AND
+		(date_last_used IS NULL OR date_last_used < :threshold)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("touching api key[%s]: %w", keyID, err)
+	}
+
+	return nil
+}
+
+// Query retrieves a list of existing api keys from the database.
+func (s Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]APIKey, error) {
+	data := struct {
+		Offset      int `db:"offset"`
+		RowsPerPage int `db:"rows_per_page"`
+	}{
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	ORDER BY
+		date_created
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var keys []APIKey
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &keys); err != nil {
+		return nil, fmt.Errorf("selecting api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// QueryByID gets the specified api key from the database.
+func (s Store) QueryByID(ctx context.Context, keyID string) (APIKey, error) {
+	data := struct {
+		ID string `db:"api_key_id"`
+	}{
+		ID: keyID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		api_key_id = :api_key_id`
+
+	var key APIKey
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &key); err != nil {
+		return APIKey{}, fmt.Errorf("selecting api key[%q]: %w", keyID, err)
+	}
+
+	return key, nil
+}
+
+// QueryByHash gets the api key with the specified hash from the database.
+func (s Store) QueryByHash(ctx context.Context, hash string) (APIKey, error) {
+	data := struct {
+		Hash string `db:"key_hash"`
+	}{
+		Hash: hash,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		api_keys
+	WHERE
+		key_hash = :key_hash`
+
+	var key APIKey
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &key); err != nil {
+		return APIKey{}, fmt.Errorf("selecting api key by hash: %w", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,24 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKey represent the structure we need for moving data
+// between the app and the database.
+type APIKey struct {
+	ID           string         `db:"api_key_id"`
+	UserID       string         `db:"user_id"`
+	CafeID       sql.NullString `db:"cafe_id"`
+	Name         string         `db:"name"`
+	Prefix       string         `db:"prefix"`
+	Hash         string         `db:"key_hash"`
+	Roles        pq.StringArray `db:"roles"`
+	DateCreated  time.Time      `db:"date_created"`
+	DateExpires  sql.NullTime   `db:"date_expires"`
+	DateLastUsed sql.NullTime   `db:"date_last_used"`
+	DateRevoked  sql.NullTime   `db:"date_revoked"`
+}
@@ -0,0 +1,57 @@
+package apikey
+
+import (
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/apikey/db"
+)
+
+// APIKey represents a key a machine client uses in place of a user's
+// credentials. The key itself is only ever shown once, when it is created.
+type APIKey struct {
+	ID           string    `json:"id"`             // Unique identifier.
+	UserID       string    `json:"user_id"`        // ID of the user the key acts for.
+	CafeID       string    `json:"cafe_id"`        // ID of the cafe the key is limited to, empty if not limited.
+	Name         string    `json:"name"`           // Display name of the key.
+	Prefix       string    `json:"prefix"`         // Start of the key so it can be recognized.
+	Roles        []string  `json:"roles"`          // Roles the key grants.
+	DateCreated  time.Time `json:"date_created"`   // When the key was created.
+	DateExpires  time.Time `json:"date_expires"`   // When the key expires, zero if it doesn't.
+	DateLastUsed time.Time `json:"date_last_used"` // When the key was last used, zero if never.
+	DateRevoked  time.Time `json:"date_revoked"`   // When the key was revoked, zero if it wasn't.
+}
+
+// NewAPIKey is what we require when creating an APIKey. The roles must all be
+// held by the user the key acts for.
+type NewAPIKey struct {
+	UserID      string    `json:"user_id" validate:"required"`
+	CafeID      string    `json:"cafe_id"`
+	Name        string    `json:"name" validate:"required"`
+	Roles       []string  `json:"roles" validate:"required,min=1"`
+	DateExpires time.Time `json:"date_expires"`
+}
+
+// =============================================================================
+
+func toAPIKey(dbKey db.APIKey) APIKey {
+	return APIKey{
+		ID:           dbKey.ID,
+		UserID:       dbKey.UserID,
+		CafeID:       dbKey.CafeID.String,
+		Name:         dbKey.Name,
+		Prefix:       dbKey.Prefix,
+		Roles:        dbKey.Roles,
+		DateCreated:  dbKey.DateCreated,
+		DateExpires:  dbKey.DateExpires.Time,
+		DateLastUsed: dbKey.DateLastUsed.Time,
+		DateRevoked:  dbKey.DateRevoked.Time,
+	}
+}
+
+func toAPIKeySlice(dbKeys []db.APIKey) []APIKey {
+	keys := make([]APIKey, len(dbKeys))
+	for i, dbKey := range dbKeys {
+		keys[i] = toAPIKey(dbKey)
+	}
+	return keys
+}
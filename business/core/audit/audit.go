@@ -0,0 +1,181 @@
+// Package audit provides a core business API for recording who changed what.
+// Other cores record their changes in the same transaction as the change
+// itself so the log can't disagree with the data.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/audit/db"
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/colmmurphy91/go-service/foundation/web"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of actions that are recorded.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Change describes a change to an entity. Before is nil for a create and
+// After is nil for a delete. Both are marshalled to JSON, so only the fields
+// an entity exposes through JSON are recorded.
+type Change struct {
+	Action   string
+	Entity   string
+	EntityID string
+	Before   interface{}
+	After    interface{}
+}
+
+// Core manages the set of APIs for audit log access.
+type Core struct {
+	store db.Store
+}
+
+// NewCore constructs a core for audit log api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+	return Core{
+		store: db.NewStore(log, sqlxDB),
+	}
+}
+
+// Record writes an entry for the change using the transaction it was made
+// in. The actor and trace id are taken from the context. For an update only
+// the fields that changed are kept, and nothing is written if none did.
+func (c Core) Record(ctx context.Context, tx sqlx.ExtContext, ch Change, now time.Time) error {
+	before, err := toMap(ch.Before)
+	if err != nil {
+		return fmt.Errorf("before: %w", err)
+	}
+
+	after, err := toMap(ch.After)
+	if err != nil {
+		return fmt.Errorf("after: %w", err)
+	}
+
+	if before != nil && after != nil {
+		before, after = diff(before, after)
+		if len(before) == 0 && len(after) == 0 {
+			return nil
+		}
+	}
+
+	var actorID string
+	if claims, err := auth.GetClaims(ctx); err == nil {
+		actorID = claims.Subject
+	}
+
+	dbEntry := db.Entry{
+		ID:          validate.GenerateID(),
+		ActorID:     actorID,
+		TraceID:     web.GetTraceID(ctx),
+		Action:      ch.Action,
+		Entity:      ch.Entity,
+		EntityID:    ch.EntityID,
+		DateCreated: now,
+	}
+
+	if dbEntry.Before, err = toJSON(before); err != nil {
+		return fmt.Errorf("before: %w", err)
+	}
+
+	if dbEntry.After, err = toJSON(after); err != nil {
+		return fmt.Errorf("after: %w", err)
+	}
+
+	if err := c.store.Tran(tx).Create(ctx, dbEntry); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	return nil
+}
+
+// Query gets the entries that match the filter, newest first.
+func (c Core) Query(ctx context.Context, filter QueryFilter, pageNumber int, rowsPerPage int) ([]Entry, error) {
+	dbFilter := db.Filter{
+		ActorID:  filter.ActorID,
+		Action:   filter.Action,
+		Entity:   filter.Entity,
+		EntityID: filter.EntityID,
+	}
+
+	dbEntries, err := c.store.Query(ctx, dbFilter, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toEntrySlice(dbEntries), nil
+}
+
+// =============================================================================
+
+// toMap returns the JSON fields of the value, or nil if there is no value.
+func toMap(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	return m, nil
+}
+
+// diff returns the fields that differ between before and after, each with
+// the value it had on that side.
+func diff(before map[string]interface{}, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	b := make(map[string]interface{})
+	a := make(map[string]interface{})
+
+	for k, bv := range before {
+		av, ok := after[k]
+		if !ok {
+			b[k] = bv
+			continue
+		}
+		if !reflect.DeepEqual(bv, av) {
+			b[k] = bv
+			a[k] = av
+		}
+	}
+
+	for k, av := range after {
+		if _, ok := before[k]; !ok {
+			a[k] = av
+		}
+	}
+
+	return b, a
+}
+
+// toJSON marshals the fields for storing, leaving the column null if there
+// are none.
+func toJSON(m map[string]interface{}) (sql.NullString, error) {
+	if m == nil {
+		return sql.NullString{}, nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("marshal: %w", err)
+	}
+
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
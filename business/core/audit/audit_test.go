@@ -0,0 +1,127 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/audit"
+	"github.com/colmmurphy91/go-service/business/core/product"
+	"github.com/colmmurphy91/go-service/business/data/dbtest"
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+	"github.com/colmmurphy91/go-service/foundation/docker"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestAudit(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testaudit")
+	t.Cleanup(teardown)
+
+	core := audit.NewCore(log, db)
+	prdCore := product.NewCore(log, db)
+
+	const actorID = "5cf37266-3473-4006-984f-9325122678b7"
+
+	t.Log("Given the need to audit changes to entities.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen a product is created, updated and deleted.", testID)
+		{
+			claims := auth.Claims{
+				RegisteredClaims: jwt.RegisteredClaims{Subject: actorID},
+				Roles:            []string{auth.RoleAdmin},
+			}
+			ctx := auth.SetClaims(context.Background(), claims)
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			np := product.NewProduct{
+				Name:     "Comic Books",
+				Cost:     10,
+				Quantity: 55,
+				UserID:   actorID,
+			}
+
+			prd, err := prdCore.Create(ctx, np, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a product : %s.", dbtest.Failed, testID, err)
+			}
+
+			upd := product.UpdateProduct{
+				Name: dbtest.StringPointer("Graphic Novels"),
+			}
+			if err := prdCore.Update(ctx, prd.ID, upd, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update a product : %s.", dbtest.Failed, testID, err)
+			}
+
+			if err := prdCore.Delete(ctx, prd.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete a product : %s.", dbtest.Failed, testID, err)
+			}
+
+			filter := audit.QueryFilter{
+				Entity:   "product",
+				EntityID: prd.ID,
+			}
+			entries, err := core.Query(ctx, filter, 1, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the audit log : %s.", dbtest.Failed, testID, err)
+			}
+			if len(entries) != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould have an entry for each change : got %d.", dbtest.Failed, testID, len(entries))
+			}
+			t.Logf("\t%s\tTest %d:\tShould have an entry for each change.", dbtest.Success, testID)
+
+			actions := map[string]audit.Entry{}
+			for _, e := range entries {
+				if e.ActorID != actorID {
+					t.Fatalf("\t%s\tTest %d:\tShould record the actor : got %q.", dbtest.Failed, testID, e.ActorID)
+				}
+				actions[e.Action] = e
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the actor.", dbtest.Success, testID)
+
+			create, update, del := actions[audit.ActionCreate], actions[audit.ActionUpdate], actions[audit.ActionDelete]
+			if create.Before != nil || create.After == nil || del.Before == nil || del.After != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould record the state around creates and deletes : %+v %+v.", dbtest.Failed, testID, create, del)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record the state around creates and deletes.", dbtest.Success, testID)
+
+			var before, after map[string]interface{}
+			if err := json.Unmarshal(update.Before, &before); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the update : %s.", dbtest.Failed, testID, err)
+			}
+			if err := json.Unmarshal(update.After, &after); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to read the update : %s.", dbtest.Failed, testID, err)
+			}
+			if len(before) != 1 || before["name"] != np.Name || len(after) != 1 || after["name"] != *upd.Name {
+				t.Fatalf("\t%s\tTest %d:\tShould record only the fields that changed : %v %v.", dbtest.Failed, testID, before, after)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record only the fields that changed.", dbtest.Success, testID)
+
+			filter = audit.QueryFilter{
+				Action: audit.ActionDelete,
+				Entity: "product",
+			}
+			entries, err = core.Query(ctx, filter, 1, 10)
+			if err != nil || len(entries) != 1 || entries[0].EntityID != prd.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to filter by action : %v %v.", dbtest.Failed, testID, entries, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to filter by action.", dbtest.Success, testID)
+		}
+	}
+}
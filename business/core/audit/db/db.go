@@ -0,0 +1,95 @@
+// Package db contains audit log related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for audit log access.
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           sql.Transactor
+	db           sqlx.ExtContext
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		tr:  db,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	return sql.WithinTran(ctx, s.log, s.tr, fn)
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// Create inserts a new entry into the audit log.
+func (s Store) Create(ctx context.Context, entry Entry) error {
+	const q = `
+	INSERT INTO audit_log
+		(audit_id, actor_id, trace_id, action, entity, entity_id, before, after, date_created)
+	VALUES
+		(:audit_id, :actor_id, :trace_id, :action, :entity, :entity_id, :before, :after, :date_created)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, entry); err != nil {
+		return fmt.Errorf("inserting audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// Query gets the entries that match the filter, newest first.
+func (s Store) Query(ctx context.Context, filter Filter, pageNumber int, rowsPerPage int) ([]Entry, error) {
+	data := struct {
+		Filter
+		Offset      int `db:"offset"`
+		RowsPerPage int `db:"rows_per_page"`
+	}{
+		Filter:      filter,
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		audit_log
+	WHERE
+		(:actor_id = '' OR actor_id = :actor_id) AND
+		(:action = '' OR action = :action) AND
+		(:entity = '' OR entity = :entity) AND
+		(:entity_id = '' OR entity_id = :entity_id)
+	ORDER BY
+		date_created DESC, audit_id
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var entries []Entry
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &entries); err != nil {
+		return nil, fmt.Errorf("selecting audit entries: %w", err)
+	}
+
+	return entries, nil
+}
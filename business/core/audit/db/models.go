@@ -0,0 +1,29 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Entry represents a change made to an entity. Before and After hold JSON.
+// The actor is kept as plain text rather than a reference so entries outlive
+// the users who made them.
+type Entry struct {
+	ID          string         `db:"audit_id"`
+	ActorID     string         `db:"actor_id"`
+	TraceID     string         `db:"trace_id"`
+	Action      string         `db:"action"`
+	Entity      string         `db:"entity"`
+	EntityID    string         `db:"entity_id"`
+	Before      sql.NullString `db:"before"`
+	After       sql.NullString `db:"after"`
+	DateCreated time.Time      `db:"date_created"`
+}
+
+// Filter narrows the entries a query returns. Empty fields match anything.
+type Filter struct {
+	ActorID  string `db:"actor_id"`
+	Action   string `db:"action"`
+	Entity   string `db:"entity"`
+	EntityID string `db:"entity_id"`
+}
@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/audit/db"
+)
+
+// Entry represents a recorded change to an entity. Before and After hold
+// the fields that changed, as they were and as they became.
+type Entry struct {
+	ID          string          `json:"id"`
+	ActorID     string          `json:"actor_id"`
+	TraceID     string          `json:"trace_id"`
+	Action      string          `json:"action"`
+	Entity      string          `json:"entity"`
+	EntityID    string          `json:"entity_id"`
+	Before      json.RawMessage `json:"before,omitempty"`
+	After       json.RawMessage `json:"after,omitempty"`
+	DateCreated time.Time       `json:"date_created"`
+}
+
+// QueryFilter holds the optional fields entries can be filtered by.
+type QueryFilter struct {
+	ActorID  string `json:"actor_id"`
+	Action   string `json:"action"`
+	Entity   string `json:"entity"`
+	EntityID string `json:"entity_id"`
+}
+
+// =============================================================================
+
+func toEntry(dbEntry db.Entry) Entry {
+	e := Entry{
+		ID:          dbEntry.ID,
+		ActorID:     dbEntry.ActorID,
+		TraceID:     dbEntry.TraceID,
+		Action:      dbEntry.Action,
+		Entity:      dbEntry.Entity,
+		EntityID:    dbEntry.EntityID,
+		DateCreated: dbEntry.DateCreated,
+	}
+	if dbEntry.Before.Valid {
+		e.Before = json.RawMessage(dbEntry.Before.String)
+	}
+	if dbEntry.After.Valid {
+		e.After = json.RawMessage(dbEntry.After.String)
+	}
+	return e
+}
+
+func toEntrySlice(dbEntries []db.Entry) []Entry {
+	entries := make([]Entry, len(dbEntries))
+	for i, dbEntry := range dbEntries {
+		entries[i] = toEntry(dbEntry)
+	}
+	return entries
+}
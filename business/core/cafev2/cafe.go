@@ -1,13 +1,17 @@
-// Package product provides an example of a core business API. Right now these
-// calls are just wrapping the data/store layer. But at some point you will
-// want auditing or something that isn't specific to the data/store layer.
+// Package cafev2 provides the core business API for cafes stored in
+// Postgres. Right now these calls are just wrapping the data/store layer.
 package cafev2
 
 import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/audit"
 	"github.com/colmmurphy91/go-service/business/core/cafev2/db"
+	userdb "github.com/colmmurphy91/go-service/business/core/user/db"
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
 	"github.com/colmmurphy91/go-service/business/sys/validate"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
@@ -15,137 +19,228 @@ import (
 
 // Set of error variables for CRUD operations.
 var (
-	ErrNotFound  = errors.New("cafe not found")
-	ErrInvalidID = errors.New("ID is not in its proper form")
+	ErrNotFound       = errors.New("cafe not found")
+	ErrInvalidID      = errors.New("ID is not in its proper form")
+	ErrOwnerHasCafe   = errors.New("owner already has cafe created")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrMemberNotFound = errors.New("member not found")
+	ErrMemberExists   = errors.New("user is already on the staff of the cafe")
+)
+
+// Set of names cafes and their staff are recorded under in the audit log.
+const (
+	entityCafe   = "cafe"
+	entityMember = "cafe_member"
 )
 
-// Core manages the set of APIs for product access.
+// Core manages the set of APIs for cafe access.
 type Core struct {
 	store db.Store
+	user  userdb.Store
+	audit audit.Core
 }
 
-// NewCore constructs a core for product api access.
+// NewCore constructs a core for cafe api access.
 func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
 	return Core{
 		store: db.NewStore(log, sqlxDB),
+		user:  userdb.NewStore(log, sqlxDB),
+		audit: audit.NewCore(log, sqlxDB),
 	}
 }
 
-// Create adds a Product to the database. It returns the created Product with
-// fields like ID and DateCreated populated.
-func (c Core) Create(ctx context.Context, np NewCafe, ownerID string) (Cafe, error) {
-	if err := validate.Check(np); err != nil {
+// Create adds a Cafe to the database for the specified owner. It returns the
+// created Cafe with fields like ID populated. An owner can only have one cafe.
+func (c Core) Create(ctx context.Context, nc NewCafe, ownerID string, now time.Time) (Cafe, error) {
+	if err := validate.Check(nc); err != nil {
 		return Cafe{}, fmt.Errorf("validating data: %w", err)
 	}
 
-	_, err := c.store.QueryByOwnerID(ctx, ownerID)
+	if err := validate.CheckID(ownerID); err != nil {
+		return Cafe{}, ErrInvalidID
+	}
 
-	if err == nil {
-		return Cafe{}, errors.New("owner already has cafe created")
+	_, err := c.store.QueryByOwnerID(ctx, ownerID)
+	switch {
+	case err == nil:
+		return Cafe{}, ErrOwnerHasCafe
+	case !errors.Is(err, sql.ErrDBNotFound):
+		return Cafe{}, fmt.Errorf("query: %w", err)
 	}
 
 	dbCaf := db.Cafe{
 		ID:      validate.GenerateID(),
-		Name:    np.Name,
-		Address: np.Address,
-		LogoURL: np.LogoURL,
+		Name:    nc.Name,
+		Address: nc.Address,
+		LogoURL: nc.LogoURL,
 		OwnerID: ownerID,
 	}
 
-	if err := c.store.Create(ctx, dbCaf); err != nil {
-		return Cafe{}, fmt.Errorf("create: %w", err)
+	caf := toCafe(dbCaf)
+
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.store.Tran(tx).Create(ctx, dbCaf); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+
+		ch := audit.Change{
+			Action:   audit.ActionCreate,
+			Entity:   entityCafe,
+			EntityID: caf.ID,
+			After:    caf,
+		}
+		if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return Cafe{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return caf, nil
+}
+
+// Update modifies data about a Cafe. It will error if the specified ID is
+// invalid or does not reference an existing Cafe.
+func (c Core) Update(ctx context.Context, cafeID string, uc UpdateCafe, now time.Time) error {
+	if err := validate.CheckID(cafeID); err != nil {
+		return ErrInvalidID
+	}
+
+	if err := validate.Check(uc); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	dbCaf, err := c.store.QueryByID(ctx, cafeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("updating cafe cafeID[%s]: %w", cafeID, err)
+	}
+	before := toCafe(dbCaf)
+
+	if uc.Name != nil {
+		dbCaf.Name = *uc.Name
+	}
+	if uc.Address != nil {
+		dbCaf.Address = *uc.Address
+	}
+	if uc.LogoURL != nil {
+		dbCaf.LogoURL = *uc.LogoURL
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.store.Tran(tx).Update(ctx, dbCaf); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+
+		ch := audit.Change{
+			Action:   audit.ActionUpdate,
+			Entity:   entityCafe,
+			EntityID: cafeID,
+			Before:   before,
+			After:    toCafe(dbCaf),
+		}
+		if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the cafe identified by a given ID. Deleting a cafe that
+// does not exist is not an error and is not audited.
+func (c Core) Delete(ctx context.Context, cafeID string, now time.Time) error {
+	if err := validate.CheckID(cafeID); err != nil {
+		return ErrInvalidID
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbCaf, err := store.QueryByID(ctx, cafeID)
+		if err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return nil
+			}
+			return fmt.Errorf("query: %w", err)
+		}
+
+		if err := store.Delete(ctx, cafeID); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+
+		ch := audit.Change{
+			Action:   audit.ActionDelete,
+			Entity:   entityCafe,
+			EntityID: cafeID,
+			Before:   toCafe(dbCaf),
+		}
+		if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
+	}
+
+	return nil
+}
+
+// Query gets all Cafes from the database.
+func (c Core) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Cafe, error) {
+	dbCafs, err := c.store.Query(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toCafeSlice(dbCafs), nil
+}
+
+// QueryByID finds the cafe identified by a given ID.
+func (c Core) QueryByID(ctx context.Context, cafeID string) (Cafe, error) {
+	if err := validate.CheckID(cafeID); err != nil {
+		return Cafe{}, ErrInvalidID
+	}
+
+	dbCaf, err := c.store.QueryByID(ctx, cafeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return Cafe{}, ErrNotFound
+		}
+		return Cafe{}, fmt.Errorf("query: %w", err)
 	}
 
 	return toCafe(dbCaf), nil
 }
 
-//// Update modifies data about a Product. It will error if the specified ID is
-//// invalid or does not reference an existing Product.
-//func (c Core) Update(ctx context.Context, productID string, up UpdateProduct, now time.Time) error {
-//	if err := validate.CheckID(productID); err != nil {
-//		return ErrInvalidID
-//	}
-//
-//	if err := validate.Check(up); err != nil {
-//		return fmt.Errorf("validating data: %w", err)
-//	}
-//
-//	dbPrd, err := c.store.QueryByID(ctx, productID)
-//	if err != nil {
-//		if errors.Is(err, sql.ErrDBNotFound) {
-//			return ErrNotFound
-//		}
-//		return fmt.Errorf("updating product productID[%s]: %w", productID, err)
-//	}
-//
-//	if up.Name != nil {
-//		dbPrd.Name = *up.Name
-//	}
-//	if up.Cost != nil {
-//		dbPrd.Cost = *up.Cost
-//	}
-//	if up.Quantity != nil {
-//		dbPrd.Quantity = *up.Quantity
-//	}
-//	dbPrd.DateUpdated = now
-//
-//	if err := c.store.Update(ctx, dbPrd); err != nil {
-//		return fmt.Errorf("update: %w", err)
-//	}
-//
-//	return nil
-//}
-//
-//// Delete removes the product identified by a given ID.
-//func (c Core) Delete(ctx context.Context, productID string) error {
-//	if err := validate.CheckID(productID); err != nil {
-//		return ErrInvalidID
-//	}
-//
-//	if err := c.store.Delete(ctx, productID); err != nil {
-//		return fmt.Errorf("delete: %w", err)
-//	}
-//
-//	return nil
-//}
-//
-//// Query gets all Products from the database.
-//func (c Core) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Product, error) {
-//	dbPrds, err := c.store.Query(ctx, pageNumber, rowsPerPage)
-//	if err != nil {
-//		return nil, fmt.Errorf("query: %w", err)
-//	}
-//
-//	return toProductSlice(dbPrds), nil
-//}
-//
-//// QueryByID finds the product identified by a given ID.
-//func (c Core) QueryByID(ctx context.Context, productID string) (Product, error) {
-//	if err := validate.CheckID(productID); err != nil {
-//		return Product{}, ErrInvalidID
-//	}
-//
-//	dbPrd, err := c.store.QueryByID(ctx, productID)
-//	if err != nil {
-//		if errors.Is(err, sql.ErrDBNotFound) {
-//			return Product{}, ErrNotFound
-//		}
-//		return Product{}, fmt.Errorf("query: %w", err)
-//	}
-//
-//	return toProduct(dbPrd), nil
-//}
-//
-// QueryByUserID finds the products identified by a given User ID.
+// QueryByOwnerID finds the cafe owned by a given User ID.
 func (c Core) QueryByOwnerID(ctx context.Context, ownerID string) (Cafe, error) {
 	if err := validate.CheckID(ownerID); err != nil {
 		return Cafe{}, ErrInvalidID
 	}
 
-	dbCafe, err := c.store.QueryByOwnerID(ctx, ownerID)
+	dbCaf, err := c.store.QueryByOwnerID(ctx, ownerID)
 	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return Cafe{}, ErrNotFound
+		}
 		return Cafe{}, fmt.Errorf("query: %w", err)
 	}
 
-	return toCafe(dbCafe), nil
+	return toCafe(dbCaf), nil
 }
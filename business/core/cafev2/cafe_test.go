@@ -1,12 +1,15 @@
-package cafev2
+package cafev2_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/colmmurphy91/go-service/business/core/cafev2"
 	"github.com/colmmurphy91/go-service/business/data/dbtest"
 	"github.com/colmmurphy91/go-service/foundation/docker"
 	"github.com/google/go-cmp/cmp"
 	"testing"
+	"time"
 )
 
 var c *docker.Container
@@ -24,25 +27,26 @@ func TestMain(m *testing.M) {
 }
 
 func TestCafeV2(t *testing.T) {
-	log, db, teardown := dbtest.NewUnit(t, c, "testprod")
+	log, db, teardown := dbtest.NewUnit(t, c, "testcafe")
 	t.Cleanup(teardown)
 
-	core := NewCore(log, db)
+	core := cafev2.NewCore(log, db)
 
-	t.Log("Given the need to work with Product records.")
+	t.Log("Given the need to work with Cafe records.")
 	{
 		testID := 0
-		t.Logf("\tTest %d:\tWhen handling a single Product.", testID)
+		t.Logf("\tTest %d:\tWhen handling a single Cafe.", testID)
 		{
 			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
 
-			nc := NewCafe{
+			nc := cafev2.NewCafe{
 				Name:    "Comic Books",
 				Address: "My Address",
 				LogoURL: "www.blah.com",
 			}
 
-			cafe, err := core.Create(ctx, nc, "45b5fbd3-755f-4379-8f07-a58d4a30fa2e")
+			cafe, err := core.Create(ctx, nc, "45b5fbd3-755f-4379-8f07-a58d4a30fa2e", now)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to create a cafe : %s.", dbtest.Failed, testID, err)
 			}
@@ -50,89 +54,179 @@ func TestCafeV2(t *testing.T) {
 
 			saved, err := core.QueryByOwnerID(ctx, "45b5fbd3-755f-4379-8f07-a58d4a30fa2e")
 			if err != nil {
-				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", dbtest.Failed, testID, err)
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve cafe by owner ID: %s.", dbtest.Failed, testID, err)
 			}
-			t.Logf("\t%s\tTest %d:\tShould be able to retrieve product by ID.", dbtest.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve cafe by owner ID.", dbtest.Success, testID)
 
 			if diff := cmp.Diff(cafe, saved); diff != "" {
-				t.Fatalf("\t%s\tTest %d:\tShould get back the same product. Diff:\n%s", dbtest.Failed, testID, diff)
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same cafe. Diff:\n%s", dbtest.Failed, testID, diff)
 			}
-			t.Logf("\t%s\tTest %d:\tShould get back the same product.", dbtest.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould get back the same cafe.", dbtest.Success, testID)
 
-			_, err = core.Create(ctx, nc, "45b5fbd3-755f-4379-8f07-a58d4a30fa2e")
-			if err == nil {
+			_, err = core.Create(ctx, nc, "45b5fbd3-755f-4379-8f07-a58d4a30fa2e", now)
+			if !errors.Is(err, cafev2.ErrOwnerHasCafe) {
 				t.Fatalf("\t%s\tTest %d:\tShould be not be able to create 2 cafes : %s.", dbtest.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould NOT be able to create a cafe.", dbtest.Success, testID)
 
-			//upd := product.UpdateProduct{
-			//	Name:     dbtest.StringPointer("Comics"),
-			//	Cost:     dbtest.IntPointer(50),
-			//	Quantity: dbtest.IntPointer(40),
-			//}
-			//updatedTime := time.Date(2019, time.January, 1, 1, 1, 1, 0, time.UTC)
-			//
-			//if err := core.Update(ctx, prd.ID, upd, updatedTime); err != nil {
-			//	t.Fatalf("\t%s\tTest %d:\tShould be able to update product : %s.", dbtest.Failed, testID, err)
-			//}
-			//t.Logf("\t%s\tTest %d:\tShould be able to update product.", dbtest.Success, testID)
-
-			//products, err := core.Query(ctx, 1, 3)
-			//if err != nil {
-			//	t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve updated product : %s.", dbtest.Failed, testID, err)
-			//}
-			//t.Logf("\t%s\tTest %d:\tShould be able to retrieve updated product.", dbtest.Success, testID)
-
-			// Check specified fields were updated. Make a copy of the original product
+			upd := cafev2.UpdateCafe{
+				Name:    dbtest.StringPointer("Graphic Novels"),
+				LogoURL: dbtest.StringPointer("www.novels.com"),
+			}
+
+			if err := core.Update(ctx, cafe.ID, upd, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update cafe : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update cafe.", dbtest.Success, testID)
+
+			cafes, err := core.Query(ctx, 1, 3)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve updated cafe : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve updated cafe.", dbtest.Success, testID)
+
+			// Check specified fields were updated. Make a copy of the original cafe
 			// and change just the fields we expect then diff it with what was saved.
-			//want := prd
-			//want.Name = *upd.Name
-			//want.Cost = *upd.Cost
-			//want.Quantity = *upd.Quantity
-			//want.DateUpdated = updatedTime
-			//
-			//var idx int
-			//for i, p := range products {
-			//	if p.ID == want.ID {
-			//		idx = i
-			//	}
-			//}
-			//if diff := cmp.Diff(want, products[idx]); diff != "" {
-			//	t.Fatalf("\t%s\tTest %d:\tShould get back the same product. Diff:\n%s", dbtest.Failed, testID, diff)
-			//}
-			//t.Logf("\t%s\tTest %d:\tShould get back the same product.", dbtest.Success, testID)
-			//
-			//upd = product.UpdateProduct{
-			//	Name: dbtest.StringPointer("Graphic Novels"),
-			//}
-			//
-			//if err := core.Update(ctx, prd.ID, upd, updatedTime); err != nil {
-			//	t.Fatalf("\t%s\tTest %d:\tShould be able to update just some fields of product : %s.", dbtest.Failed, testID, err)
-			//}
-			//t.Logf("\t%s\tTest %d:\tShould be able to update just some fields of product.", dbtest.Success, testID)
-			//
-			//saved, err = core.QueryByID(ctx, prd.ID)
-			//if err != nil {
-			//	t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve updated product : %s.", dbtest.Failed, testID, err)
-			//}
-			//t.Logf("\t%s\tTest %d:\tShould be able to retrieve updated product.", dbtest.Success, testID)
-			//
-			//if saved.Name != *upd.Name {
-			//	t.Fatalf("\t%s\tTest %d:\tShould be able to see updated Name field : got %q want %q.", dbtest.Failed, testID, saved.Name, *upd.Name)
-			//} else {
-			//	t.Logf("\t%s\tTest %d:\tShould be able to see updated Name field.", dbtest.Success, testID)
-			//}
-			//
-			//if err := core.Delete(ctx, prd.ID); err != nil {
-			//	t.Fatalf("\t%s\tTest %d:\tShould be able to delete product : %s.", dbtest.Failed, testID, err)
-			//}
-			//t.Logf("\t%s\tTest %d:\tShould be able to delete product.", dbtest.Success, testID)
-			//
-			//_, err = core.QueryByID(ctx, prd.ID)
-			//if !errors.Is(err, product.ErrNotFound) {
-			//	t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted product : %s.", dbtest.Failed, testID, err)
-			//}
-			//t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted product.", dbtest.Success, testID)
+			want := cafe
+			want.Name = *upd.Name
+			want.LogoURL = *upd.LogoURL
+
+			var idx int
+			for i, c := range cafes {
+				if c.ID == want.ID {
+					idx = i
+				}
+			}
+			if diff := cmp.Diff(want, cafes[idx]); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same cafe. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same cafe.", dbtest.Success, testID)
+
+			saved, err = core.QueryByID(ctx, cafe.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve cafe by ID : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve cafe by ID.", dbtest.Success, testID)
+
+			if saved.Address != nc.Address {
+				t.Fatalf("\t%s\tTest %d:\tShould keep fields that were not updated : got %q want %q.", dbtest.Failed, testID, saved.Address, nc.Address)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep fields that were not updated.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, cafe.ID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete cafe : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete cafe.", dbtest.Success, testID)
+
+			_, err = core.QueryByID(ctx, cafe.ID)
+			if !errors.Is(err, cafev2.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted cafe : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted cafe.", dbtest.Success, testID)
+		}
+	}
+}
+
+func TestMembers(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testmembers")
+	t.Cleanup(teardown)
+
+	core := cafev2.NewCore(log, db)
+
+	const (
+		ownerID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2e"
+		staffID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+	)
+
+	t.Log("Given the need to work with the staff of a Cafe.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single Member.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			nc := cafev2.NewCafe{
+				Name:    "Gopher Beans",
+				Address: "1 Main Street",
+			}
+
+			cafe, err := core.Create(ctx, nc, ownerID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a cafe : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a cafe.", dbtest.Success, testID)
+
+			nm := cafev2.NewMember{
+				Email: "user@example.com",
+				Role:  "BARISTA",
+			}
+
+			mem, err := core.Invite(ctx, cafe.ID, nm, ownerID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to invite a member : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to invite a member.", dbtest.Success, testID)
+
+			if mem.UserID != staffID || mem.Accepted() {
+				t.Fatalf("\t%s\tTest %d:\tShould get a pending invitation for the user : %+v.", dbtest.Failed, testID, mem)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get a pending invitation for the user.", dbtest.Success, testID)
+
+			if _, err := core.Invite(ctx, cafe.ID, nm, ownerID, now); !errors.Is(err, cafev2.ErrMemberExists) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to invite the user twice : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to invite the user twice.", dbtest.Success, testID)
+
+			nm.Email = "notconfirmed@example.com"
+			if _, err := core.Invite(ctx, cafe.ID, nm, ownerID, now); !errors.Is(err, cafev2.ErrMemberExists) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to invite the owner : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to invite the owner.", dbtest.Success, testID)
+
+			nm.Email = "nobody@example.com"
+			if _, err := core.Invite(ctx, cafe.ID, nm, ownerID, now); !errors.Is(err, cafev2.ErrUserNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to invite an unknown user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to invite an unknown user.", dbtest.Success, testID)
+
+			accepted := now.Add(time.Hour)
+			mem, err = core.Accept(ctx, cafe.ID, staffID, accepted)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to accept the invitation : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to accept the invitation.", dbtest.Success, testID)
+
+			if !mem.DateAccepted.Equal(accepted) {
+				t.Fatalf("\t%s\tTest %d:\tShould record when the invitation was accepted : got %v want %v.", dbtest.Failed, testID, mem.DateAccepted, accepted)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record when the invitation was accepted.", dbtest.Success, testID)
+
+			if _, err := core.Accept(ctx, cafe.ID, staffID, accepted); !errors.Is(err, cafev2.ErrMemberNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to accept the invitation twice : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to accept the invitation twice.", dbtest.Success, testID)
+
+			mems, err := core.QueryMembers(ctx, cafe.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the staff : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the staff.", dbtest.Success, testID)
+
+			if diff := cmp.Diff([]cafev2.Member{mem}, mems); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the accepted member. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the accepted member.", dbtest.Success, testID)
+
+			if err := core.RemoveMember(ctx, cafe.ID, staffID, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to remove the member : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to remove the member.", dbtest.Success, testID)
+
+			if _, err := core.QueryMember(ctx, cafe.ID, staffID); !errors.Is(err, cafev2.ErrMemberNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve the removed member : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve the removed member.", dbtest.Success, testID)
 		}
 	}
 }
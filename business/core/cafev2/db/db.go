@@ -1,16 +1,17 @@
-// Package db contains product related CRUD functionality.
+// Package db contains cafe related CRUD functionality.
 package db
 
 import (
 	"context"
 	"fmt"
-	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"time"
 
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
-// Store manages the set of APIs for user access.
+// Store manages the set of APIs for cafe access.
 type Store struct {
 	log          *zap.SugaredLogger
 	tr           sql.Transactor
@@ -30,7 +31,7 @@ func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
 // WithinTran runs passed function and do commit/rollback at the end.
 func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
 	if s.isWithinTran {
-		fn(s.db)
+		return fn(s.db)
 	}
 	return sql.WithinTran(ctx, s.log, s.tr, fn)
 }
@@ -45,6 +46,7 @@ func (s Store) Tran(tx sqlx.ExtContext) Store {
 	}
 }
 
+// Create adds a Cafe to the database.
 func (s Store) Create(ctx context.Context, caf Cafe) error {
 	const q = `
 	INSERT INTO cafes
@@ -53,11 +55,104 @@ func (s Store) Create(ctx context.Context, caf Cafe) error {
 		(:cafe_id, :owner_id, :cafe_name, :address, :logo_url)`
 
 	if err := sql.NamedExecContext(ctx, s.log, s.db, q, caf); err != nil {
-		return fmt.Errorf("inserting cafe: #{err}")
+		return fmt.Errorf("inserting cafe: %w", err)
+	}
+
+	return nil
+}
+
+// Update modifies data about a Cafe.
+func (s Store) Update(ctx context.Context, caf Cafe) error {
+	const q = `
+	UPDATE
+		cafes
+	SET
+		"cafe_name" = :cafe_name,
+		"address" = :address,
+		"logo_url" = :logo_url
+	WHERE
+		cafe_id = :cafe_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, caf); err != nil {
+		return fmt.Errorf("updating cafe cafeID[%s]: %w", caf.ID, err)
 	}
+
 	return nil
 }
 
+// Delete removes the cafe identified by a given ID.
+func (s Store) Delete(ctx context.Context, cafeID string) error {
+	data := struct {
+		CafeID string `db:"cafe_id"`
+	}{
+		CafeID: cafeID,
+	}
+
+	const q = `
+	DELETE FROM
+		cafes
+	WHERE
+		cafe_id = :cafe_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting cafe cafeID[%s]: %w", cafeID, err)
+	}
+
+	return nil
+}
+
+// Query gets all Cafes from the database.
+func (s Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Cafe, error) {
+	data := struct {
+		Offset      int `db:"offset"`
+		RowsPerPage int `db:"rows_per_page"`
+	}{
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		cafes
+	ORDER BY
+		cafe_name
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var cafs []Cafe
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &cafs); err != nil {
+		return nil, fmt.Errorf("selecting cafes: %w", err)
+	}
+
+	return cafs, nil
+}
+
+// QueryByID finds the cafe identified by a given ID.
+func (s Store) QueryByID(ctx context.Context, cafeID string) (Cafe, error) {
+	data := struct {
+		CafeID string `db:"cafe_id"`
+	}{
+		CafeID: cafeID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		cafes
+	WHERE
+		cafe_id = :cafe_id`
+
+	var caf Cafe
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &caf); err != nil {
+		return Cafe{}, fmt.Errorf("selecting cafe cafeID[%q]: %w", cafeID, err)
+	}
+
+	return caf, nil
+}
+
+// QueryByOwnerID finds the cafe owned by a given User ID.
 func (s Store) QueryByOwnerID(ctx context.Context, ownerID string) (Cafe, error) {
 	data := struct {
 		OwnerID string `db:"owner_id"`
@@ -66,151 +161,171 @@ func (s Store) QueryByOwnerID(ctx context.Context, ownerID string) (Cafe, error)
 	}
 
 	const q = `
-	SELECT 
-		* 
-	FROM 
-		cafes 
-	WHERE 
-		owner_id=:owner_id`
-
-	var cafe Cafe
-	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &cafe); err != nil {
-		return Cafe{}, fmt.Errorf("selecting ownerid[%q]: %w", ownerID, err)
-	}
-	return cafe, nil
-}
-
-//// Update modifies data about a Product. It will error if the specified ID is
-//// invalid or does not reference an existing Product.
-//func (s Store) Update(ctx context.Context, prd Product) error {
-//	const q = `
-//	UPDATE
-//		products
-//	SET
-//		"name" = :name,
-//		"cost" = :cost,
-//		"quantity" = :quantity,
-//		"date_updated" = :date_updated
-//	WHERE
-//		product_id = :product_id`
-//
-//	if err := sql.NamedExecContext(ctx, s.log, s.db, q, prd); err != nil {
-//		return fmt.Errorf("updating product productID[%s]: %w", prd.ID, err)
-//	}
-//
-//	return nil
-//}
-//
-//// Delete removes the product identified by a given ID.
-//func (s Store) Delete(ctx context.Context, productID string) error {
-//	data := struct {
-//		ProductID string `db:"product_id"`
-//	}{
-//		ProductID: productID,
-//	}
-//
-//	const q = `
-//	DELETE FROM
-//		products
-//	WHERE
-//		product_id = :product_id`
-//
-//	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
-//		return fmt.Errorf("deleting product productID[%s]: %w", productID, err)
-//	}
-//
-//	return nil
-//}
-//
-//// Query gets all Products from the database.
-//func (s Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Product, error) {
-//	data := struct {
-//		Offset      int `db:"offset"`
-//		RowsPerPage int `db:"rows_per_page"`
-//	}{
-//		Offset:      (pageNumber - 1) * rowsPerPage,
-//		RowsPerPage: rowsPerPage,
-//	}
-//
-//	const q = `
-//	SELECT
-//		p.*,
-//		COALESCE(SUM(s.quantity) ,0) AS sold,
-//		COALESCE(SUM(s.paid), 0) AS revenue
-//	FROM
-//		products AS p
-//	LEFT JOIN
-//		sales AS s ON p.product_id = s.product_id
-//	GROUP BY
-//		p.product_id
-//	ORDER BY
-//		user_id
-//	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
-//
-//	var prds []Product
-//	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &prds); err != nil {
-//		return nil, fmt.Errorf("selecting products: %w", err)
-//	}
-//
-//	return prds, nil
-//}
-//
-//// QueryByID finds the product identified by a given ID.
-//func (s Store) QueryByID(ctx context.Context, productID string) (Product, error) {
-//	data := struct {
-//		ProductID string `db:"product_id"`
-//	}{
-//		ProductID: productID,
-//	}
-//
-//	const q = `
-//	SELECT
-//		p.*,
-//		COALESCE(SUM(s.quantity), 0) AS sold,
-//		COALESCE(SUM(s.paid), 0) AS revenue
-//	FROM
-//		products AS p
-//	LEFT JOIN
-//		sales AS s ON p.product_id = s.product_id
-//	WHERE
-//		p.product_id = :product_id
-//	GROUP BY
-//		p.product_id`
-//
-//	var prd Product
-//	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &prd); err != nil {
-//		return Product{}, fmt.Errorf("selecting product productID[%q]: %w", productID, err)
-//	}
-//
-//	return prd, nil
-//}
-//
-//// QueryByUserID finds the product identified by a given User ID.
-//func (s Store) QueryByUserID(ctx context.Context, userID string) ([]Product, error) {
-//	data := struct {
-//		UserID string `db:"user_id"`
-//	}{
-//		UserID: userID,
-//	}
-//
-//	const q = `
-//	SELECT
-//		p.*,
-//		COALESCE(SUM(s.quantity), 0) AS sold,
-//		COALESCE(SUM(s.paid), 0) AS revenue
-//	FROM
-//		products AS p
-//	LEFT JOIN
-//		sales AS s ON p.product_id = s.product_id
-//	WHERE
-//		p.user_id = :user_id
-//	GROUP BY
-//		p.product_id`
-//
-//	var prds []Product
-//	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &prds); err != nil {
-//		return nil, fmt.Errorf("selecting products userID[%s]: %w", userID, err)
-//	}
-//
-//	return prds, nil
-//}
+	SELECT
+		*
+	FROM
+		cafes
+	WHERE
+		owner_id = :owner_id`
+
+	var caf Cafe
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &caf); err != nil {
+		return Cafe{}, fmt.Errorf("selecting cafe ownerID[%q]: %w", ownerID, err)
+	}
+
+	return caf, nil
+}
+
+// CreateMember adds a Member to the staff of a cafe.
+func (s Store) CreateMember(ctx context.Context, mem Member) error {
+	const q = `
+	INSERT INTO cafe_members
+		(cafe_id, user_id, role, invited_by, date_invited, date_accepted)
+	VALUES
+		(:cafe_id, :user_id, :role, :invited_by, :date_invited, :date_accepted)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, mem); err != nil {
+		return fmt.Errorf("inserting member: %w", err)
+	}
+
+	return nil
+}
+
+// AcceptMember marks the invitation of a user to the staff of a cafe as
+// accepted. ErrDBNotFound is returned if there is no invitation waiting to be
+// accepted.
+func (s Store) AcceptMember(ctx context.Context, cafeID string, userID string, now time.Time) (Member, error) {
+	data := struct {
+		CafeID string    `db:"cafe_id"`
+		UserID string    `db:"user_id"`
+		Now    time.Time `db:"now"`
+	}{
+		CafeID: cafeID,
+		UserID: userID,
+		Now:    now,
+	}
+
+	const q = `
+	UPDATE
+		cafe_members
+	SET
+		"date_accepted" = :now
+	WHERE
+		cafe_id = :cafe_id AND
+		user_id = :user_id AND
+		date_accepted IS NULL
+	RETURNING
+		*`
+
+	var mem Member
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &mem); err != nil {
+		return Member{}, fmt.Errorf("accepting member cafeID[%s] userID[%s]: %w", cafeID, userID, err)
+	}
+
+	return mem, nil
+}
+
+// DeleteMember removes a user from the staff of a cafe.
+func (s Store) DeleteMember(ctx context.Context, cafeID string, userID string) error {
+	data := struct {
+		CafeID string `db:"cafe_id"`
+		UserID string `db:"user_id"`
+	}{
+		CafeID: cafeID,
+		UserID: userID,
+	}
+
+	const q = `
+	DELETE FROM
+		cafe_members
+	WHERE
+		cafe_id = :cafe_id AND
+		user_id = :user_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting member cafeID[%s] userID[%s]: %w", cafeID, userID, err)
+	}
+
+	return nil
+}
+
+// QueryMember finds the membership of a user in the staff of a cafe.
+func (s Store) QueryMember(ctx context.Context, cafeID string, userID string) (Member, error) {
+	data := struct {
+		CafeID string `db:"cafe_id"`
+		UserID string `db:"user_id"`
+	}{
+		CafeID: cafeID,
+		UserID: userID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		cafe_members
+	WHERE
+		cafe_id = :cafe_id AND
+		user_id = :user_id`
+
+	var mem Member
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &mem); err != nil {
+		return Member{}, fmt.Errorf("selecting member cafeID[%s] userID[%s]: %w", cafeID, userID, err)
+	}
+
+	return mem, nil
+}
+
+// QueryMembers gets the staff of a cafe, including invitations that are yet
+// to be accepted.
+func (s Store) QueryMembers(ctx context.Context, cafeID string) ([]Member, error) {
+	data := struct {
+		CafeID string `db:"cafe_id"`
+	}{
+		CafeID: cafeID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		cafe_members
+	WHERE
+		cafe_id = :cafe_id
+	ORDER BY
+		date_invited`
+
+	var mems []Member
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &mems); err != nil {
+		return nil, fmt.Errorf("selecting members cafeID[%s]: %w", cafeID, err)
+	}
+
+	return mems, nil
+}
+
+// QueryMembershipsByUserID gets the cafes a user is on the staff of or has
+// been invited to.
+func (s Store) QueryMembershipsByUserID(ctx context.Context, userID string) ([]Member, error) {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		cafe_members
+	WHERE
+		user_id = :user_id
+	ORDER BY
+		date_invited`
+
+	var mems []Member
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &mems); err != nil {
+		return nil, fmt.Errorf("selecting memberships userID[%s]: %w", userID, err)
+	}
+
+	return mems, nil
+}
@@ -1,5 +1,11 @@
 package db
 
+import (
+	"database/sql"
+	"time"
+)
+
+// Cafe represents an individual cafe.
 type Cafe struct {
 	ID      string `db:"cafe_id"`
 	OwnerID string `db:"owner_id"`
@@ -7,3 +13,13 @@ type Cafe struct {
 	Address string `db:"address"`
 	LogoURL string `db:"logo_url"`
 }
+
+// Member represents a user on the staff of a cafe.
+type Member struct {
+	CafeID       string       `db:"cafe_id"`
+	UserID       string       `db:"user_id"`
+	Role         string       `db:"role"`
+	InvitedBy    string       `db:"invited_by"`
+	DateInvited  time.Time    `db:"date_invited"`
+	DateAccepted sql.NullTime `db:"date_accepted"`
+}
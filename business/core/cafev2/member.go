@@ -0,0 +1,230 @@
+package cafev2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/audit"
+	"github.com/colmmurphy91/go-service/business/core/cafev2/db"
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/jmoiron/sqlx"
+)
+
+// Invite adds the user with the specified email to the staff of a cafe. The
+// membership doesn't grant anything until the user accepts it.
+func (c Core) Invite(ctx context.Context, cafeID string, nm NewMember, invitedBy string, now time.Time) (Member, error) {
+	if err := validate.CheckID(cafeID); err != nil {
+		return Member{}, ErrInvalidID
+	}
+
+	if err := validate.Check(nm); err != nil {
+		return Member{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	dbCaf, err := c.store.QueryByID(ctx, cafeID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return Member{}, ErrNotFound
+		}
+		return Member{}, fmt.Errorf("query cafe: %w", err)
+	}
+
+	dbUsr, err := c.user.QueryByEmail(ctx, nm.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return Member{}, ErrUserNotFound
+		}
+		return Member{}, fmt.Errorf("query user: %w", err)
+	}
+
+	if dbUsr.ID == dbCaf.OwnerID {
+		return Member{}, ErrMemberExists
+	}
+
+	_, err = c.store.QueryMember(ctx, cafeID, dbUsr.ID)
+	switch {
+	case err == nil:
+		return Member{}, ErrMemberExists
+	case !errors.Is(err, sql.ErrDBNotFound):
+		return Member{}, fmt.Errorf("query member: %w", err)
+	}
+
+	dbMem := db.Member{
+		CafeID:      cafeID,
+		UserID:      dbUsr.ID,
+		Role:        nm.Role,
+		InvitedBy:   invitedBy,
+		DateInvited: now,
+	}
+
+	mem := toMember(dbMem)
+
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.store.Tran(tx).CreateMember(ctx, dbMem); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+
+		ch := audit.Change{
+			Action:   audit.ActionCreate,
+			Entity:   entityMember,
+			EntityID: memberID(cafeID, dbMem.UserID),
+			After:    mem,
+		}
+		if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return Member{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return mem, nil
+}
+
+// Accept marks the invitation of a user to the staff of a cafe as accepted.
+// ErrMemberNotFound is returned if there is no invitation waiting.
+func (c Core) Accept(ctx context.Context, cafeID string, userID string, now time.Time) (Member, error) {
+	if err := validate.CheckID(cafeID); err != nil {
+		return Member{}, ErrInvalidID
+	}
+
+	var dbMem db.Member
+	tran := func(tx sqlx.ExtContext) error {
+		var err error
+		if dbMem, err = c.store.Tran(tx).AcceptMember(ctx, cafeID, userID, now); err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrMemberNotFound
+			}
+			return fmt.Errorf("accept: %w", err)
+		}
+
+		mem := toMember(dbMem)
+		before := mem
+		before.DateAccepted = time.Time{}
+
+		ch := audit.Change{
+			Action:   audit.ActionUpdate,
+			Entity:   entityMember,
+			EntityID: memberID(cafeID, userID),
+			Before:   before,
+			After:    mem,
+		}
+		if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return Member{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return toMember(dbMem), nil
+}
+
+// RemoveMember removes a user from the staff of a cafe, whether or not they
+// accepted their invitation.
+func (c Core) RemoveMember(ctx context.Context, cafeID string, userID string, now time.Time) error {
+	if err := validate.CheckID(cafeID); err != nil {
+		return ErrInvalidID
+	}
+
+	if err := validate.CheckID(userID); err != nil {
+		return ErrInvalidID
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbMem, err := store.QueryMember(ctx, cafeID, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return nil
+			}
+			return fmt.Errorf("query: %w", err)
+		}
+
+		if err := store.DeleteMember(ctx, cafeID, userID); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+
+		ch := audit.Change{
+			Action:   audit.ActionDelete,
+			Entity:   entityMember,
+			EntityID: memberID(cafeID, userID),
+			Before:   toMember(dbMem),
+		}
+		if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
+	}
+
+	return nil
+}
+
+// QueryMember finds the membership of a user in the staff of a cafe.
+func (c Core) QueryMember(ctx context.Context, cafeID string, userID string) (Member, error) {
+	if err := validate.CheckID(cafeID); err != nil {
+		return Member{}, ErrInvalidID
+	}
+
+	dbMem, err := c.store.QueryMember(ctx, cafeID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return Member{}, ErrMemberNotFound
+		}
+		return Member{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toMember(dbMem), nil
+}
+
+// QueryMembers gets the staff of a cafe, including invitations that are yet
+// to be accepted.
+func (c Core) QueryMembers(ctx context.Context, cafeID string) ([]Member, error) {
+	if err := validate.CheckID(cafeID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	dbMems, err := c.store.QueryMembers(ctx, cafeID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toMemberSlice(dbMems), nil
+}
+
+// QueryMemberships gets the cafes a user is on the staff of or has been
+// invited to.
+func (c Core) QueryMemberships(ctx context.Context, userID string) ([]Member, error) {
+	if err := validate.CheckID(userID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	dbMems, err := c.store.QueryMembershipsByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toMemberSlice(dbMems), nil
+}
+
+// =============================================================================
+
+// memberID identifies a membership in the audit log.
+func memberID(cafeID string, userID string) string {
+	return cafeID + "/" + userID
+}
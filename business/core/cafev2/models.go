@@ -1,11 +1,13 @@
 package cafev2
 
 import (
+	"time"
 	"unsafe"
 
 	"github.com/colmmurphy91/go-service/business/core/cafev2/db"
 )
 
+// Cafe represents an individual cafe.
 type Cafe struct {
 	ID      string `json:"cafe_id"`
 	OwnerID string `json:"-"`
@@ -14,24 +16,47 @@ type Cafe struct {
 	LogoURL string `json:"logo_url"`
 }
 
-// NewCafe is what we require from clients when adding a Product.
+// NewCafe is what we require from clients when adding a Cafe.
 type NewCafe struct {
 	Name    string `json:"name" validate:"required"`
 	Address string `json:"address" validate:"required"`
 	LogoURL string `json:"logo_url"`
 }
 
-// UpdateProduct defines what information may be provided to modify an
-// existing Product. All fields are optional so clients can send just the
+// UpdateCafe defines what information may be provided to modify an
+// existing Cafe. All fields are optional so clients can send just the
 // fields they want changed. It uses pointer fields so we can differentiate
 // between a field that was not provided and a field that was provided as
 // explicitly blank. Normally we do not want to use pointers to basic types but
 // we make exceptions around marshalling/unmarshalling.
-//type UpdateProduct struct {
-//	Name     *string `json:"name"`
-//	Cost     *int    `json:"cost" validate:"omitempty,gte=0"`
-//	Quantity *int    `json:"quantity" validate:"omitempty,gte=1"`
-//}
+type UpdateCafe struct {
+	Name    *string `json:"name" validate:"omitempty,min=1"`
+	Address *string `json:"address" validate:"omitempty,min=1"`
+	LogoURL *string `json:"logo_url"`
+}
+
+// Member represents a user on the staff of a cafe. Until the user accepts
+// their invitation the membership grants nothing.
+type Member struct {
+	CafeID       string    `json:"cafe_id"`       // ID of the cafe.
+	UserID       string    `json:"user_id"`       // ID of the user on the staff.
+	Role         string    `json:"role"`          // Role the user holds at the cafe.
+	InvitedBy    string    `json:"invited_by"`    // ID of the user who sent the invitation.
+	DateInvited  time.Time `json:"date_invited"`  // When the user was invited.
+	DateAccepted time.Time `json:"date_accepted"` // When the user accepted, zero if they haven't.
+}
+
+// Accepted reports whether the user has accepted their invitation.
+func (m Member) Accepted() bool {
+	return !m.DateAccepted.IsZero()
+}
+
+// NewMember is what we require from clients when inviting a user to the staff
+// of a cafe.
+type NewMember struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=MANAGER BARISTA"`
+}
 
 // =============================================================================
 
@@ -39,3 +64,30 @@ func toCafe(dbCaf db.Cafe) Cafe {
 	cu := (*Cafe)(unsafe.Pointer(&dbCaf))
 	return *cu
 }
+
+func toCafeSlice(dbCafs []db.Cafe) []Cafe {
+	cafs := make([]Cafe, len(dbCafs))
+	for i, dbCaf := range dbCafs {
+		cafs[i] = toCafe(dbCaf)
+	}
+	return cafs
+}
+
+func toMember(dbMem db.Member) Member {
+	return Member{
+		CafeID:       dbMem.CafeID,
+		UserID:       dbMem.UserID,
+		Role:         dbMem.Role,
+		InvitedBy:    dbMem.InvitedBy,
+		DateInvited:  dbMem.DateInvited,
+		DateAccepted: dbMem.DateAccepted.Time,
+	}
+}
+
+func toMemberSlice(dbMems []db.Member) []Member {
+	mems := make([]Member, len(dbMems))
+	for i, dbMem := range dbMems {
+		mems[i] = toMember(dbMem)
+	}
+	return mems
+}
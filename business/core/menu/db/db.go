@@ -0,0 +1,156 @@
+// Package db contains menu related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for menu access.
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           sql.Transactor
+	db           sqlx.ExtContext
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		tr:  db,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	return sql.WithinTran(ctx, s.log, s.tr, fn)
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// Create adds a MenuItem to the database.
+func (s Store) Create(ctx context.Context, item MenuItem) error {
+	const q = `
+	INSERT INTO menu_items
+		(menu_item_id, cafe_id, name, description, category, price, available, position, date_created, date_updated)
+	VALUES
+		(:menu_item_id, :cafe_id, :name, :description, :category, :price, :available, :position, :date_created, :date_updated)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, item); err != nil {
+		return fmt.Errorf("inserting menu item: %w", err)
+	}
+
+	return nil
+}
+
+// Update modifies data about a MenuItem.
+func (s Store) Update(ctx context.Context, item MenuItem) error {
+	const q = `
+	UPDATE
+		menu_items
+	SET
+		"name" = :name,
+		"description" = :description,
+		"category" = :category,
+		"price" = :price,
+		"available" = :available,
+		"position" = :position,
+		"date_updated" = :date_updated
+	WHERE
+		menu_item_id = :menu_item_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, item); err != nil {
+		return fmt.Errorf("updating menu item itemID[%s]: %w", item.ID, err)
+	}
+
+	return nil
+}
+
+// Delete removes the menu item identified by a given ID.
+func (s Store) Delete(ctx context.Context, itemID string) error {
+	data := struct {
+		ItemID string `db:"menu_item_id"`
+	}{
+		ItemID: itemID,
+	}
+
+	const q = `
+	DELETE FROM
+		menu_items
+	WHERE
+		menu_item_id = :menu_item_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting menu item itemID[%s]: %w", itemID, err)
+	}
+
+	return nil
+}
+
+// QueryByID finds the menu item identified by a given ID.
+func (s Store) QueryByID(ctx context.Context, itemID string) (MenuItem, error) {
+	data := struct {
+		ItemID string `db:"menu_item_id"`
+	}{
+		ItemID: itemID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		menu_items
+	WHERE
+		menu_item_id = :menu_item_id`
+
+	var item MenuItem
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &item); err != nil {
+		return MenuItem{}, fmt.Errorf("selecting menu item itemID[%q]: %w", itemID, err)
+	}
+
+	return item, nil
+}
+
+// QueryByCafeID finds the menu of a given cafe, sorted by category and
+// then by each item's position within its category.
+func (s Store) QueryByCafeID(ctx context.Context, cafeID string) ([]MenuItem, error) {
+	data := struct {
+		CafeID string `db:"cafe_id"`
+	}{
+		CafeID: cafeID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		menu_items
+	WHERE
+		cafe_id = :cafe_id
+	ORDER BY
+		category, position, name`
+
+	var items []MenuItem
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &items); err != nil {
+		return nil, fmt.Errorf("selecting menu cafeID[%s]: %w", cafeID, err)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,17 @@
+package db
+
+import "time"
+
+// MenuItem represents an individual item on a cafe menu.
+type MenuItem struct {
+	ID          string    `db:"menu_item_id"` // Unique identifier.
+	CafeID      string    `db:"cafe_id"`      // ID of the cafe the item belongs to.
+	Name        string    `db:"name"`         // Display name of the item.
+	Description string    `db:"description"`  // Optional longer description.
+	Category    string    `db:"category"`     // Menu section such as "Coffee" or "Pastries".
+	Price       int       `db:"price"`        // Price for one item in cents.
+	Available   bool      `db:"available"`    // Whether the item can currently be ordered.
+	Position    int       `db:"position"`     // Display order within the category.
+	DateCreated time.Time `db:"date_created"` // When the item was added.
+	DateUpdated time.Time `db:"date_updated"` // When the item record was last modified.
+}
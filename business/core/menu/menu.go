@@ -0,0 +1,172 @@
+// Package menu provides the core business API for the items a cafe sells.
+// Every call is scoped to a cafe so an item can only be read or changed
+// through the cafe it belongs to.
+package menu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/menu/db"
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound  = errors.New("menu item not found")
+	ErrInvalidID = errors.New("ID is not in its proper form")
+)
+
+// Core manages the set of APIs for menu access.
+type Core struct {
+	store db.Store
+}
+
+// NewCore constructs a core for menu api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+	return Core{
+		store: db.NewStore(log, sqlxDB),
+	}
+}
+
+// Create adds a MenuItem to the menu of the specified cafe.
+func (c Core) Create(ctx context.Context, cafeID string, nmi NewMenuItem, now time.Time) (MenuItem, error) {
+	if err := validate.CheckID(cafeID); err != nil {
+		return MenuItem{}, ErrInvalidID
+	}
+
+	if err := validate.Check(nmi); err != nil {
+		return MenuItem{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	available := true
+	if nmi.Available != nil {
+		available = *nmi.Available
+	}
+
+	dbItem := db.MenuItem{
+		ID:          validate.GenerateID(),
+		CafeID:      cafeID,
+		Name:        nmi.Name,
+		Description: nmi.Description,
+		Category:    nmi.Category,
+		Price:       nmi.Price,
+		Available:   available,
+		Position:    nmi.Position,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := c.store.Create(ctx, dbItem); err != nil {
+		return MenuItem{}, fmt.Errorf("create: %w", err)
+	}
+
+	return toMenuItem(dbItem), nil
+}
+
+// Update modifies a MenuItem on the menu of the specified cafe. It will error
+// if the item does not exist or belongs to a different cafe.
+func (c Core) Update(ctx context.Context, cafeID string, itemID string, umi UpdateMenuItem, now time.Time) error {
+	if err := validate.Check(umi); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	dbItem, err := c.queryByID(ctx, cafeID, itemID)
+	if err != nil {
+		return err
+	}
+
+	if umi.Name != nil {
+		dbItem.Name = *umi.Name
+	}
+	if umi.Description != nil {
+		dbItem.Description = *umi.Description
+	}
+	if umi.Category != nil {
+		dbItem.Category = *umi.Category
+	}
+	if umi.Price != nil {
+		dbItem.Price = *umi.Price
+	}
+	if umi.Available != nil {
+		dbItem.Available = *umi.Available
+	}
+	if umi.Position != nil {
+		dbItem.Position = *umi.Position
+	}
+	dbItem.DateUpdated = now
+
+	if err := c.store.Update(ctx, dbItem); err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a MenuItem from the menu of the specified cafe.
+func (c Core) Delete(ctx context.Context, cafeID string, itemID string) error {
+	if _, err := c.queryByID(ctx, cafeID, itemID); err != nil {
+		return err
+	}
+
+	if err := c.store.Delete(ctx, itemID); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+// QueryByID finds a MenuItem on the menu of the specified cafe.
+func (c Core) QueryByID(ctx context.Context, cafeID string, itemID string) (MenuItem, error) {
+	dbItem, err := c.queryByID(ctx, cafeID, itemID)
+	if err != nil {
+		return MenuItem{}, err
+	}
+
+	return toMenuItem(dbItem), nil
+}
+
+// QueryByCafeID returns the full menu of a cafe sorted by category and the
+// position of each item within its category.
+func (c Core) QueryByCafeID(ctx context.Context, cafeID string) ([]MenuItem, error) {
+	if err := validate.CheckID(cafeID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	dbItems, err := c.store.QueryByCafeID(ctx, cafeID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toMenuItemSlice(dbItems), nil
+}
+
+// queryByID retrieves an item and makes sure it belongs to the specified cafe.
+func (c Core) queryByID(ctx context.Context, cafeID string, itemID string) (db.MenuItem, error) {
+	if err := validate.CheckID(cafeID); err != nil {
+		return db.MenuItem{}, ErrInvalidID
+	}
+
+	if err := validate.CheckID(itemID); err != nil {
+		return db.MenuItem{}, ErrInvalidID
+	}
+
+	dbItem, err := c.store.QueryByID(ctx, itemID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return db.MenuItem{}, ErrNotFound
+		}
+		return db.MenuItem{}, fmt.Errorf("query: %w", err)
+	}
+
+	if dbItem.CafeID != cafeID {
+		return db.MenuItem{}, ErrNotFound
+	}
+
+	return dbItem, nil
+}
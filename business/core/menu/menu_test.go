@@ -0,0 +1,148 @@
+package menu_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/cafev2"
+	"github.com/colmmurphy91/go-service/business/core/menu"
+	"github.com/colmmurphy91/go-service/business/data/dbtest"
+	"github.com/colmmurphy91/go-service/foundation/docker"
+	"github.com/google/go-cmp/cmp"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestMenu(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testmenu")
+	t.Cleanup(teardown)
+
+	core := menu.NewCore(log, db)
+	cafeCore := cafev2.NewCore(log, db)
+
+	t.Log("Given the need to work with MenuItem records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single MenuItem.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			nc := cafev2.NewCafe{
+				Name:    "Gopher Beans",
+				Address: "1 Main Street",
+			}
+
+			caf, err := cafeCore.Create(ctx, nc, "45b5fbd3-755f-4379-8f07-a58d4a30fa2e", now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a cafe : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a cafe.", dbtest.Success, testID)
+
+			nmi := menu.NewMenuItem{
+				Name:     "Flat White",
+				Category: "Coffee",
+				Price:    350,
+				Position: 2,
+			}
+
+			item, err := core.Create(ctx, caf.ID, nmi, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a menu item : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a menu item.", dbtest.Success, testID)
+
+			if !item.Available {
+				t.Fatalf("\t%s\tTest %d:\tShould default a new item to available.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould default a new item to available.", dbtest.Success, testID)
+
+			saved, err := core.QueryByID(ctx, caf.ID, item.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve menu item by ID: %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve menu item by ID.", dbtest.Success, testID)
+
+			if diff := cmp.Diff(item, saved); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same menu item. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same menu item.", dbtest.Success, testID)
+
+			_, err = core.QueryByID(ctx, "5cf37266-3473-4006-984f-9325122678b7", item.ID)
+			if !errors.Is(err, menu.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve the item through another cafe : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve the item through another cafe.", dbtest.Success, testID)
+
+			nmi = menu.NewMenuItem{
+				Name:     "Espresso",
+				Category: "Coffee",
+				Price:    250,
+				Position: 1,
+			}
+
+			if _, err := core.Create(ctx, caf.ID, nmi, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a second menu item : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a second menu item.", dbtest.Success, testID)
+
+			upd := menu.UpdateMenuItem{
+				Price:     dbtest.IntPointer(400),
+				Available: dbtest.BoolPointer(false),
+			}
+			updatedTime := time.Date(2019, time.January, 1, 1, 1, 1, 0, time.UTC)
+
+			if err := core.Update(ctx, caf.ID, item.ID, upd, updatedTime); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to update menu item : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to update menu item.", dbtest.Success, testID)
+
+			items, err := core.QueryByCafeID(ctx, caf.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the menu : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the menu.", dbtest.Success, testID)
+
+			if len(items) != 2 || items[0].Name != "Espresso" {
+				t.Fatalf("\t%s\tTest %d:\tShould get the menu in position order : %+v.", dbtest.Failed, testID, items)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the menu in position order.", dbtest.Success, testID)
+
+			want := item
+			want.Price = *upd.Price
+			want.Available = false
+			want.DateUpdated = updatedTime
+
+			if diff := cmp.Diff(want, items[1]); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the updated menu item. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the updated menu item.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, caf.ID, item.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete menu item : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to delete menu item.", dbtest.Success, testID)
+
+			_, err = core.QueryByID(ctx, caf.ID, item.ID)
+			if !errors.Is(err, menu.ErrNotFound) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted menu item : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve deleted menu item.", dbtest.Success, testID)
+		}
+	}
+}
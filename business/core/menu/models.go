@@ -0,0 +1,63 @@
+package menu
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/colmmurphy91/go-service/business/core/menu/db"
+)
+
+// MenuItem represents an individual item on a cafe menu.
+type MenuItem struct {
+	ID          string    `json:"id"`           // Unique identifier.
+	CafeID      string    `json:"cafe_id"`      // ID of the cafe the item belongs to.
+	Name        string    `json:"name"`         // Display name of the item.
+	Description string    `json:"description"`  // Optional longer description.
+	Category    string    `json:"category"`     // Menu section such as "Coffee" or "Pastries".
+	Price       int       `json:"price"`        // Price for one item in cents.
+	Available   bool      `json:"available"`    // Whether the item can currently be ordered.
+	Position    int       `json:"position"`     // Display order within the category.
+	DateCreated time.Time `json:"date_created"` // When the item was added.
+	DateUpdated time.Time `json:"date_updated"` // When the item record was last modified.
+}
+
+// NewMenuItem is what we require from clients when adding a MenuItem.
+// Available is a pointer so an omitted value can default to true.
+type NewMenuItem struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+	Category    string `json:"category" validate:"required"`
+	Price       int    `json:"price" validate:"gte=0"`
+	Available   *bool  `json:"available"`
+	Position    int    `json:"position" validate:"gte=0"`
+}
+
+// UpdateMenuItem defines what information may be provided to modify an
+// existing MenuItem. All fields are optional so clients can send just the
+// fields they want changed. It uses pointer fields so we can differentiate
+// between a field that was not provided and a field that was provided as
+// explicitly blank. Normally we do not want to use pointers to basic types but
+// we make exceptions around marshalling/unmarshalling.
+type UpdateMenuItem struct {
+	Name        *string `json:"name" validate:"omitempty,min=1"`
+	Description *string `json:"description"`
+	Category    *string `json:"category" validate:"omitempty,min=1"`
+	Price       *int    `json:"price" validate:"omitempty,gte=0"`
+	Available   *bool   `json:"available"`
+	Position    *int    `json:"position" validate:"omitempty,gte=0"`
+}
+
+// =============================================================================
+
+func toMenuItem(dbItem db.MenuItem) MenuItem {
+	mi := (*MenuItem)(unsafe.Pointer(&dbItem))
+	return *mi
+}
+
+func toMenuItemSlice(dbItems []db.MenuItem) []MenuItem {
+	items := make([]MenuItem, len(dbItems))
+	for i, dbItem := range dbItems {
+		items[i] = toMenuItem(dbItem)
+	}
+	return items
+}
@@ -0,0 +1,235 @@
+// Package db contains order related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for order access.
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           sql.Transactor
+	db           sqlx.ExtContext
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		tr:  db,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	return sql.WithinTran(ctx, s.log, s.tr, fn)
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// Create adds an Order to the database.
+func (s Store) Create(ctx context.Context, ord Order) error {
+	const q = `
+	INSERT INTO orders
+		(order_id, cafe_id, customer_id, status, total, note, date_placed, date_updated)
+	VALUES
+		(:order_id, :cafe_id, :customer_id, :status, :total, :note, :date_placed, :date_updated)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, ord); err != nil {
+		return fmt.Errorf("inserting order: %w", err)
+	}
+
+	return nil
+}
+
+// CreateItem adds a line to an existing Order.
+func (s Store) CreateItem(ctx context.Context, item Item) error {
+	const q = `
+	INSERT INTO order_items
+		(order_item_id, order_id, menu_item_id, name, price, quantity)
+	VALUES
+		(:order_item_id, :order_id, :menu_item_id, :name, :price, :quantity)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, item); err != nil {
+		return fmt.Errorf("inserting order item: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateStatus moves an order to a new status, but only if the order is still
+// in the status the caller last saw. This stops two concurrent transitions from
+// both succeeding. ErrDBNotFound is returned when the order has moved on.
+func (s Store) UpdateStatus(ctx context.Context, ord Order, from string) error {
+	data := struct {
+		Order
+		From string `db:"from_status"`
+	}{
+		Order: ord,
+		From:  from,
+	}
+
+	const q = `
+	UPDATE
+		orders
+	SET
+		"status" = :status,
+		"date_accepted" = :date_accepted,
+		"date_preparing" = :date_preparing,
+		"date_ready" = :date_ready,
+		"date_collected" = :date_collected,
+		"date_cancelled" = :date_cancelled,
+		"date_updated" = :date_updated
+	WHERE
+		order_id = :order_id AND
+		status = :from_status
+	RETURNING
+		order_id`
+
+	var result struct {
+		ID string `db:"order_id"`
+	}
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &result); err != nil {
+		return fmt.Errorf("updating order status orderID[%s]: %w", ord.ID, err)
+	}
+
+	return nil
+}
+
+// QueryByID finds the order identified by a given ID.
+func (s Store) QueryByID(ctx context.Context, orderID string) (Order, error) {
+	data := struct {
+		OrderID string `db:"order_id"`
+	}{
+		OrderID: orderID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		orders
+	WHERE
+		order_id = :order_id`
+
+	var ord Order
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &ord); err != nil {
+		return Order{}, fmt.Errorf("selecting order orderID[%q]: %w", orderID, err)
+	}
+
+	return ord, nil
+}
+
+// QueryByCustomerID retrieves the orders placed by a customer, newest first.
+func (s Store) QueryByCustomerID(ctx context.Context, customerID string, pageNumber int, rowsPerPage int) ([]Order, error) {
+	data := struct {
+		CustomerID  string `db:"customer_id"`
+		Offset      int    `db:"offset"`
+		RowsPerPage int    `db:"rows_per_page"`
+	}{
+		CustomerID:  customerID,
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		orders
+	WHERE
+		customer_id = :customer_id
+	ORDER BY
+		date_placed DESC
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var ords []Order
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &ords); err != nil {
+		return nil, fmt.Errorf("selecting orders customerID[%s]: %w", customerID, err)
+	}
+
+	return ords, nil
+}
+
+// QueryByCafeID retrieves the orders placed at a cafe, oldest first so the
+// queue reads in the order it should be worked. The statuses filter is
+// optional; an empty list returns orders in every status.
+func (s Store) QueryByCafeID(ctx context.Context, cafeID string, statuses []string, pageNumber int, rowsPerPage int) ([]Order, error) {
+	data := struct {
+		CafeID      string         `db:"cafe_id"`
+		Statuses    pq.StringArray `db:"statuses"`
+		AnyStatus   bool           `db:"any_status"`
+		Offset      int            `db:"offset"`
+		RowsPerPage int            `db:"rows_per_page"`
+	}{
+		CafeID:      cafeID,
+		Statuses:    statuses,
+		AnyStatus:   len(statuses) == 0,
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		orders
+	WHERE
+		cafe_id = :cafe_id AND
+		(:any_status OR status = ANY(:statuses))
+	ORDER BY
+		date_placed
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var ords []Order
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &ords); err != nil {
+		return nil, fmt.Errorf("selecting orders cafeID[%s]: %w", cafeID, err)
+	}
+
+	return ords, nil
+}
+
+// QueryItems retrieves the lines of the specified orders.
+func (s Store) QueryItems(ctx context.Context, orderIDs []string) ([]Item, error) {
+	data := struct {
+		OrderIDs pq.StringArray `db:"order_ids"`
+	}{
+		OrderIDs: orderIDs,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		order_items
+	WHERE
+		order_id = ANY(:order_ids)
+	ORDER BY
+		name`
+
+	var items []Item
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &items); err != nil {
+		return nil, fmt.Errorf("selecting order items: %w", err)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Order represents a customer order placed at a cafe.
+type Order struct {
+	ID            string       `db:"order_id"`
+	CafeID        string       `db:"cafe_id"`
+	CustomerID    string       `db:"customer_id"`
+	Status        string       `db:"status"`
+	Total         int          `db:"total"`
+	Note          string       `db:"note"`
+	DatePlaced    time.Time    `db:"date_placed"`
+	DateAccepted  sql.NullTime `db:"date_accepted"`
+	DatePreparing sql.NullTime `db:"date_preparing"`
+	DateReady     sql.NullTime `db:"date_ready"`
+	DateCollected sql.NullTime `db:"date_collected"`
+	DateCancelled sql.NullTime `db:"date_cancelled"`
+	DateUpdated   time.Time    `db:"date_updated"`
+}
+
+// Item represents a line on an order. The name and price are copied from the
+// menu when the order is placed so later menu changes don't alter the order.
+type Item struct {
+	ID         string         `db:"order_item_id"`
+	OrderID    string         `db:"order_id"`
+	MenuItemID sql.NullString `db:"menu_item_id"`
+	Name       string         `db:"name"`
+	Price      int            `db:"price"`
+	Quantity   int            `db:"quantity"`
+}
@@ -0,0 +1,14 @@
+package order
+
+// Set of events the order core publishes.
+const (
+	EventPlaced        = "order.placed"
+	EventStatusChanged = "order.status_changed"
+)
+
+// OrderEvent is the payload of the order events. From is the status the
+// order moved from and is empty when it was placed.
+type OrderEvent struct {
+	Order Order  `json:"order"`
+	From  Status `json:"from,omitempty"`
+}
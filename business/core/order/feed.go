@@ -0,0 +1,63 @@
+package order
+
+import "sync"
+
+// feedBuffer is how many orders a subscriber can fall behind before further
+// orders are dropped for it.
+const feedBuffer = 16
+
+// feed fans out placed and updated orders to the subscribers watching the
+// cafe they belong to. It only reaches subscribers in this process.
+type feed struct {
+	mu   sync.Mutex
+	subs map[string]map[chan Order]struct{}
+}
+
+func newFeed() *feed {
+	return &feed{
+		subs: make(map[string]map[chan Order]struct{}),
+	}
+}
+
+// subscribe registers interest in the orders of a cafe. The returned function
+// must be called to release the subscription.
+func (f *feed) subscribe(cafeID string) (<-chan Order, func()) {
+	ch := make(chan Order, feedBuffer)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.subs[cafeID] == nil {
+		f.subs[cafeID] = make(map[chan Order]struct{})
+	}
+	f.subs[cafeID][ch] = struct{}{}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			delete(f.subs[cafeID], ch)
+			if len(f.subs[cafeID]) == 0 {
+				delete(f.subs, cafeID)
+			}
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// publish sends the order to every subscriber of its cafe. A subscriber that
+// is not keeping up misses the order rather than blocking the caller.
+func (f *feed) publish(ord Order) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for ch := range f.subs[ord.CafeID] {
+		select {
+		case ch <- ord:
+		default:
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package order
+
+import (
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/order/db"
+)
+
+// Order represents a customer order placed at a cafe. The date of each step is
+// zero until the order reaches that status.
+type Order struct {
+	ID            string    `json:"id"`             // Unique identifier.
+	CafeID        string    `json:"cafe_id"`        // ID of the cafe the order was placed at.
+	CustomerID    string    `json:"customer_id"`    // ID of the user who placed the order.
+	Status        Status    `json:"status"`         // Current step in the order lifecycle.
+	Items         []Item    `json:"items"`          // Lines on the order.
+	Total         int       `json:"total"`          // Total price of the order in cents.
+	Note          string    `json:"note"`           // Optional note from the customer.
+	DatePlaced    time.Time `json:"date_placed"`    // When the order was placed.
+	DateAccepted  time.Time `json:"date_accepted"`  // When the cafe accepted the order.
+	DatePreparing time.Time `json:"date_preparing"` // When the cafe started preparing the order.
+	DateReady     time.Time `json:"date_ready"`     // When the order was ready for collection.
+	DateCollected time.Time `json:"date_collected"` // When the customer collected the order.
+	DateCancelled time.Time `json:"date_cancelled"` // When the order was cancelled.
+	DateUpdated   time.Time `json:"date_updated"`   // When the order record was last modified.
+}
+
+// Item represents a line on an order. Name and Price are what the menu said
+// when the order was placed.
+type Item struct {
+	ID         string `json:"id"`           // Unique identifier.
+	MenuItemID string `json:"menu_item_id"` // ID of the menu item, empty if since removed.
+	Name       string `json:"name"`         // Display name of the item.
+	Price      int    `json:"price"`        // Price for one item in cents.
+	Quantity   int    `json:"quantity"`     // Number of items ordered.
+}
+
+// NewOrder is what we require from clients when placing an Order.
+type NewOrder struct {
+	CafeID string         `json:"cafe_id" validate:"required"`
+	Items  []NewOrderItem `json:"items" validate:"required,min=1,dive"`
+	Note   string         `json:"note" validate:"max=500"`
+}
+
+// NewOrderItem is a single line of a NewOrder.
+type NewOrderItem struct {
+	MenuItemID string `json:"menu_item_id" validate:"required"`
+	Quantity   int    `json:"quantity" validate:"gte=1"`
+}
+
+// UpdateStatus is what we require from cafe owners and staff when moving an
+// Order through its lifecycle.
+type UpdateStatus struct {
+	Status Status `json:"status" validate:"required,oneof=accepted preparing ready collected cancelled"`
+}
+
+// =============================================================================
+
+func toOrder(dbOrd db.Order, dbItems []db.Item) Order {
+	items := make([]Item, 0, len(dbItems))
+	for _, dbItem := range dbItems {
+		if dbItem.OrderID != dbOrd.ID {
+			continue
+		}
+		items = append(items, Item{
+			ID:         dbItem.ID,
+			MenuItemID: dbItem.MenuItemID.String,
+			Name:       dbItem.Name,
+			Price:      dbItem.Price,
+			Quantity:   dbItem.Quantity,
+		})
+	}
+
+	return Order{
+		ID:            dbOrd.ID,
+		CafeID:        dbOrd.CafeID,
+		CustomerID:    dbOrd.CustomerID,
+		Status:        Status(dbOrd.Status),
+		Items:         items,
+		Total:         dbOrd.Total,
+		Note:          dbOrd.Note,
+		DatePlaced:    dbOrd.DatePlaced,
+		DateAccepted:  dbOrd.DateAccepted.Time,
+		DatePreparing: dbOrd.DatePreparing.Time,
+		DateReady:     dbOrd.DateReady.Time,
+		DateCollected: dbOrd.DateCollected.Time,
+		DateCancelled: dbOrd.DateCancelled.Time,
+		DateUpdated:   dbOrd.DateUpdated,
+	}
+}
+
+func toOrderSlice(dbOrds []db.Order, dbItems []db.Item) []Order {
+	ords := make([]Order, len(dbOrds))
+	for i, dbOrd := range dbOrds {
+		ords[i] = toOrder(dbOrd, dbItems)
+	}
+	return ords
+}
@@ -0,0 +1,319 @@
+// Package order provides the core business API for customer orders placed at
+// a cafe. An order is priced from the cafe menu when it is placed and then
+// moves through placed, accepted, preparing, ready and collected, or is
+// cancelled along the way.
+package order
+
+import (
+	"context"
+	gosql "database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	cafedb "github.com/colmmurphy91/go-service/business/core/cafev2/db"
+	menudb "github.com/colmmurphy91/go-service/business/core/menu/db"
+	"github.com/colmmurphy91/go-service/business/core/order/db"
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/events"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound            = errors.New("order not found")
+	ErrInvalidID           = errors.New("ID is not in its proper form")
+	ErrCafeNotFound        = errors.New("cafe not found")
+	ErrMenuItemNotFound    = errors.New("menu item not found")
+	ErrMenuItemUnavailable = errors.New("menu item is not available")
+	ErrInvalidTransition   = errors.New("order cannot move to the requested status")
+	ErrNotCancellable      = errors.New("order can no longer be cancelled")
+)
+
+// Core manages the set of APIs for order access.
+type Core struct {
+	store  db.Store
+	cafe   cafedb.Store
+	menu   menudb.Store
+	events events.Outbox
+	feed   *feed
+}
+
+// NewCore constructs a core for order api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+	return Core{
+		store:  db.NewStore(log, sqlxDB),
+		cafe:   cafedb.NewStore(log, sqlxDB),
+		menu:   menudb.NewStore(log, sqlxDB),
+		events: events.NewOutbox(log, sqlxDB),
+		feed:   newFeed(),
+	}
+}
+
+// Place records a new order for the specified customer. Each line is priced
+// from the cafe menu and every item must be on the menu of the cafe the
+// order is for and currently available.
+func (c Core) Place(ctx context.Context, no NewOrder, customerID string, now time.Time) (Order, error) {
+	if err := validate.Check(no); err != nil {
+		return Order{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := validate.CheckID(no.CafeID); err != nil {
+		return Order{}, ErrInvalidID
+	}
+
+	if err := validate.CheckID(customerID); err != nil {
+		return Order{}, ErrInvalidID
+	}
+
+	for _, noi := range no.Items {
+		if err := validate.CheckID(noi.MenuItemID); err != nil {
+			return Order{}, ErrInvalidID
+		}
+	}
+
+	dbOrd := db.Order{
+		ID:          validate.GenerateID(),
+		CafeID:      no.CafeID,
+		CustomerID:  customerID,
+		Status:      string(StatusPlaced),
+		Note:        no.Note,
+		DatePlaced:  now,
+		DateUpdated: now,
+	}
+	dbItems := make([]db.Item, len(no.Items))
+
+	tran := func(tx sqlx.ExtContext) error {
+		if _, err := c.cafe.Tran(tx).QueryByID(ctx, no.CafeID); err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrCafeNotFound
+			}
+			return fmt.Errorf("query cafe: %w", err)
+		}
+
+		for i, noi := range no.Items {
+			dbMenuItem, err := c.menu.Tran(tx).QueryByID(ctx, noi.MenuItemID)
+			if err != nil {
+				if errors.Is(err, sql.ErrDBNotFound) {
+					return ErrMenuItemNotFound
+				}
+				return fmt.Errorf("query menu item: %w", err)
+			}
+
+			if dbMenuItem.CafeID != no.CafeID {
+				return ErrMenuItemNotFound
+			}
+
+			if !dbMenuItem.Available {
+				return ErrMenuItemUnavailable
+			}
+
+			dbItems[i] = db.Item{
+				ID:         validate.GenerateID(),
+				OrderID:    dbOrd.ID,
+				MenuItemID: gosql.NullString{String: dbMenuItem.ID, Valid: true},
+				Name:       dbMenuItem.Name,
+				Price:      dbMenuItem.Price,
+				Quantity:   noi.Quantity,
+			}
+			dbOrd.Total += dbMenuItem.Price * noi.Quantity
+		}
+
+		if err := c.store.Tran(tx).Create(ctx, dbOrd); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+
+		for _, dbItem := range dbItems {
+			if err := c.store.Tran(tx).CreateItem(ctx, dbItem); err != nil {
+				return fmt.Errorf("create item: %w", err)
+			}
+		}
+
+		evt := OrderEvent{
+			Order: toOrder(dbOrd, dbItems),
+		}
+		if err := c.events.Publish(ctx, tx, EventPlaced, evt, now); err != nil {
+			return fmt.Errorf("publish: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return Order{}, fmt.Errorf("tran: %w", err)
+	}
+
+	ord := toOrder(dbOrd, dbItems)
+	c.feed.publish(ord)
+
+	return ord, nil
+}
+
+// Transition moves an order to the specified status. Only the moves allowed
+// by the order lifecycle are accepted; anything else returns
+// ErrInvalidTransition.
+func (c Core) Transition(ctx context.Context, orderID string, to Status, now time.Time) (Order, error) {
+	return c.transition(ctx, orderID, to, now, func(from Status) error {
+		if !from.CanTransition(to) {
+			return ErrInvalidTransition
+		}
+		return nil
+	})
+}
+
+// Cancel cancels an order on behalf of the customer who placed it. Customers
+// can only cancel an order the cafe has not yet accepted.
+func (c Core) Cancel(ctx context.Context, orderID string, now time.Time) (Order, error) {
+	return c.transition(ctx, orderID, StatusCancelled, now, func(from Status) error {
+		if from != StatusPlaced {
+			return ErrNotCancellable
+		}
+		return nil
+	})
+}
+
+// QueryByID finds the order identified by a given ID.
+func (c Core) QueryByID(ctx context.Context, orderID string) (Order, error) {
+	if err := validate.CheckID(orderID); err != nil {
+		return Order{}, ErrInvalidID
+	}
+
+	dbOrd, err := c.store.QueryByID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return Order{}, ErrNotFound
+		}
+		return Order{}, fmt.Errorf("query: %w", err)
+	}
+
+	dbItems, err := c.store.QueryItems(ctx, []string{dbOrd.ID})
+	if err != nil {
+		return Order{}, fmt.Errorf("query items: %w", err)
+	}
+
+	return toOrder(dbOrd, dbItems), nil
+}
+
+// QueryByCustomerID retrieves the orders placed by a customer, newest first.
+func (c Core) QueryByCustomerID(ctx context.Context, customerID string, pageNumber int, rowsPerPage int) ([]Order, error) {
+	if err := validate.CheckID(customerID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	dbOrds, err := c.store.QueryByCustomerID(ctx, customerID, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return c.withItems(ctx, dbOrds)
+}
+
+// QueryByCafeID retrieves the orders placed at a cafe, oldest first. When
+// statuses are provided only orders in one of those statuses are returned.
+func (c Core) QueryByCafeID(ctx context.Context, cafeID string, statuses []Status, pageNumber int, rowsPerPage int) ([]Order, error) {
+	if err := validate.CheckID(cafeID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	filter := make([]string, len(statuses))
+	for i, s := range statuses {
+		filter[i] = string(s)
+	}
+
+	dbOrds, err := c.store.QueryByCafeID(ctx, cafeID, filter, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return c.withItems(ctx, dbOrds)
+}
+
+// Subscribe returns a channel that receives every order placed or updated at
+// the specified cafe through this Core. Orders are dropped for a subscriber
+// that falls behind. The returned function must be called to unsubscribe.
+func (c Core) Subscribe(cafeID string) (<-chan Order, func()) {
+	return c.feed.subscribe(cafeID)
+}
+
+// =============================================================================
+
+// transition loads the order, asks check whether the move is allowed from the
+// current status and then records the new status. The update only applies if
+// the status has not changed since it was read.
+func (c Core) transition(ctx context.Context, orderID string, to Status, now time.Time, check func(from Status) error) (Order, error) {
+	if err := validate.CheckID(orderID); err != nil {
+		return Order{}, ErrInvalidID
+	}
+
+	dbOrd, err := c.store.QueryByID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return Order{}, ErrNotFound
+		}
+		return Order{}, fmt.Errorf("query: %w", err)
+	}
+
+	from := Status(dbOrd.Status)
+	if err := check(from); err != nil {
+		return Order{}, err
+	}
+
+	stamp(&dbOrd, to, now)
+
+	var ord Order
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		if err := store.UpdateStatus(ctx, dbOrd, string(from)); err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrInvalidTransition
+			}
+			return fmt.Errorf("update status: %w", err)
+		}
+
+		dbItems, err := store.QueryItems(ctx, []string{dbOrd.ID})
+		if err != nil {
+			return fmt.Errorf("query items: %w", err)
+		}
+		ord = toOrder(dbOrd, dbItems)
+
+		evt := OrderEvent{
+			Order: ord,
+			From:  from,
+		}
+		if err := c.events.Publish(ctx, tx, EventStatusChanged, evt, now); err != nil {
+			return fmt.Errorf("publish: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return Order{}, fmt.Errorf("tran: %w", err)
+	}
+
+	c.feed.publish(ord)
+
+	return ord, nil
+}
+
+// withItems loads the lines for a page of orders in a single query.
+func (c Core) withItems(ctx context.Context, dbOrds []db.Order) ([]Order, error) {
+	if len(dbOrds) == 0 {
+		return []Order{}, nil
+	}
+
+	ids := make([]string, len(dbOrds))
+	for i, dbOrd := range dbOrds {
+		ids[i] = dbOrd.ID
+	}
+
+	dbItems, err := c.store.QueryItems(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query items: %w", err)
+	}
+
+	return toOrderSlice(dbOrds, dbItems), nil
+}
@@ -0,0 +1,153 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/cafev2"
+	"github.com/colmmurphy91/go-service/business/core/menu"
+	"github.com/colmmurphy91/go-service/business/core/order"
+	"github.com/colmmurphy91/go-service/business/data/dbtest"
+	"github.com/colmmurphy91/go-service/foundation/docker"
+	"github.com/google/go-cmp/cmp"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestOrder(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testorder")
+	t.Cleanup(teardown)
+
+	core := order.NewCore(log, db)
+	cafeCore := cafev2.NewCore(log, db)
+	menuCore := menu.NewCore(log, db)
+
+	const customerID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+	t.Log("Given the need to work with Order records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single Order.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			nc := cafev2.NewCafe{
+				Name:    "Gopher Beans",
+				Address: "1 Main Street",
+			}
+
+			caf, err := cafeCore.Create(ctx, nc, "45b5fbd3-755f-4379-8f07-a58d4a30fa2e", now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a cafe : %s.", dbtest.Failed, testID, err)
+			}
+
+			coffee, err := menuCore.Create(ctx, caf.ID, menu.NewMenuItem{Name: "Flat White", Category: "Coffee", Price: 350}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a menu item : %s.", dbtest.Failed, testID, err)
+			}
+
+			cake, err := menuCore.Create(ctx, caf.ID, menu.NewMenuItem{Name: "Carrot Cake", Category: "Cakes", Price: 425, Available: dbtest.BoolPointer(false)}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a menu item : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to set up a cafe menu.", dbtest.Success, testID)
+
+			no := order.NewOrder{
+				CafeID: caf.ID,
+				Items:  []order.NewOrderItem{{MenuItemID: cake.ID, Quantity: 1}},
+			}
+
+			if _, err := core.Place(ctx, no, customerID, now); !errors.Is(err, order.ErrMenuItemUnavailable) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to order an unavailable item : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to order an unavailable item.", dbtest.Success, testID)
+
+			no.Items = []order.NewOrderItem{{MenuItemID: coffee.ID, Quantity: 2}}
+			no.Note = "Oat milk please"
+
+			ord, err := core.Place(ctx, no, customerID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to place an order : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to place an order.", dbtest.Success, testID)
+
+			if ord.Status != order.StatusPlaced || ord.Total != 700 {
+				t.Fatalf("\t%s\tTest %d:\tShould price a new order from the menu : got %s/%d.", dbtest.Failed, testID, ord.Status, ord.Total)
+			}
+			t.Logf("\t%s\tTest %d:\tShould price a new order from the menu.", dbtest.Success, testID)
+
+			saved, err := core.QueryByID(ctx, ord.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve order by ID: %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve order by ID.", dbtest.Success, testID)
+
+			if diff := cmp.Diff(ord, saved); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same order. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same order.", dbtest.Success, testID)
+
+			if _, err := core.Transition(ctx, ord.ID, order.StatusReady, now); !errors.Is(err, order.ErrInvalidTransition) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to skip a step : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to skip a step.", dbtest.Success, testID)
+
+			for i, to := range []order.Status{order.StatusAccepted, order.StatusPreparing, order.StatusReady, order.StatusCollected} {
+				at := now.Add(time.Duration(i+1) * time.Minute)
+				ord, err = core.Transition(ctx, ord.ID, to, at)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to move the order to %s : %s.", dbtest.Failed, testID, to, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to move the order through its lifecycle.", dbtest.Success, testID)
+
+			if !ord.DateCollected.Equal(now.Add(4 * time.Minute)) {
+				t.Fatalf("\t%s\tTest %d:\tShould record when the order was collected : got %v.", dbtest.Failed, testID, ord.DateCollected)
+			}
+			t.Logf("\t%s\tTest %d:\tShould record when the order was collected.", dbtest.Success, testID)
+
+			if _, err := core.Cancel(ctx, ord.ID, now); !errors.Is(err, order.ErrNotCancellable) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to cancel a collected order : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to cancel a collected order.", dbtest.Success, testID)
+
+			second, err := core.Place(ctx, no, customerID, now.Add(time.Hour))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to place a second order : %s.", dbtest.Failed, testID, err)
+			}
+
+			if _, err := core.Cancel(ctx, second.ID, now.Add(time.Hour)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to cancel a placed order : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to cancel a placed order.", dbtest.Success, testID)
+
+			mine, err := core.QueryByCustomerID(ctx, customerID, 1, 10)
+			if err != nil || len(mine) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the customer orders : %d %v.", dbtest.Failed, testID, len(mine), err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the customer orders.", dbtest.Success, testID)
+
+			cancelled, err := core.QueryByCafeID(ctx, caf.ID, []order.Status{order.StatusCancelled}, 1, 10)
+			if err != nil || len(cancelled) != 1 || cancelled[0].ID != second.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to filter cafe orders by status : %d %v.", dbtest.Failed, testID, len(cancelled), err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to filter cafe orders by status.", dbtest.Success, testID)
+		}
+	}
+}
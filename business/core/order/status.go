@@ -0,0 +1,68 @@
+package order
+
+import (
+	gosql "database/sql"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/order/db"
+)
+
+// Status represents a step in the order lifecycle.
+type Status string
+
+// Set of statuses an order moves through. An order starts as placed and ends
+// as either collected or cancelled.
+const (
+	StatusPlaced    Status = "placed"
+	StatusAccepted  Status = "accepted"
+	StatusPreparing Status = "preparing"
+	StatusReady     Status = "ready"
+	StatusCollected Status = "collected"
+	StatusCancelled Status = "cancelled"
+)
+
+// transitions defines the statuses an order may move to from each status.
+// Collected and cancelled are terminal and have no entry.
+var transitions = map[Status][]Status{
+	StatusPlaced:    {StatusAccepted, StatusCancelled},
+	StatusAccepted:  {StatusPreparing, StatusCancelled},
+	StatusPreparing: {StatusReady, StatusCancelled},
+	StatusReady:     {StatusCollected},
+}
+
+// CanTransition reports whether an order in this status may move to the
+// specified status.
+func (s Status) CanTransition(to Status) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Terminal reports whether no further transitions are possible.
+func (s Status) Terminal() bool {
+	return len(transitions[s]) == 0
+}
+
+// stamp sets the status of the order and records when it got there.
+func stamp(dbOrd *db.Order, to Status, now time.Time) {
+	t := gosql.NullTime{Time: now, Valid: true}
+
+	switch to {
+	case StatusAccepted:
+		dbOrd.DateAccepted = t
+	case StatusPreparing:
+		dbOrd.DatePreparing = t
+	case StatusReady:
+		dbOrd.DateReady = t
+	case StatusCollected:
+		dbOrd.DateCollected = t
+	case StatusCancelled:
+		dbOrd.DateCancelled = t
+	}
+
+	dbOrd.Status = string(to)
+	dbOrd.DateUpdated = now
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
@@ -30,7 +31,7 @@ func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
 // WithinTran runs passed function and do commit/rollback at the end.
 func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
 	if s.isWithinTran {
-		fn(s.db)
+		return fn(s.db)
 	}
 	return sql.WithinTran(ctx, s.log, s.tr, fn)
 }
@@ -103,6 +104,43 @@ func (s Store) Delete(ctx context.Context, productID string) error {
 	return nil
 }
 
+// AdjustQuantity adds delta to the stock of the specified product and returns
+// the resulting quantity. A negative delta removes stock. The update is refused
+// when it would take the stock below zero, in which case ErrDBNotFound is
+// returned just as it is for a product that does not exist.
+func (s Store) AdjustQuantity(ctx context.Context, productID string, delta int, now time.Time) (int, error) {
+	data := struct {
+		ProductID   string    `db:"product_id"`
+		Delta       int       `db:"delta"`
+		DateUpdated time.Time `db:"date_updated"`
+	}{
+		ProductID:   productID,
+		Delta:       delta,
+		DateUpdated: now,
+	}
+
+	const q = `
+	UPDATE
+		products
+	SET
+		"quantity" = quantity + :delta,
+		"date_updated" = :date_updated
+	WHERE
+		product_id = :product_id AND
+		quantity + :delta >= 0
+	RETURNING
+		quantity`
+
+	var result struct {
+		Quantity int `db:"quantity"`
+	}
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &result); err != nil {
+		return 0, fmt.Errorf("adjusting quantity productID[%s]: %w", productID, err)
+	}
+
+	return result.Quantity, nil
+}
+
 // Query gets all Products from the database.
 func (s Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Product, error) {
 	data := struct {
@@ -121,7 +159,7 @@ func (s Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Pr
 	FROM
 		products AS p
 	LEFT JOIN
-		sales AS s ON p.product_id = s.product_id
+		sales AS s ON p.product_id = s.product_id AND s.date_refunded IS NULL
 	GROUP BY
 		p.product_id
 	ORDER BY
@@ -152,7 +190,7 @@ func (s Store) QueryByID(ctx context.Context, productID string) (Product, error)
 	FROM
 		products AS p
 	LEFT JOIN
-		sales AS s ON p.product_id = s.product_id
+		sales AS s ON p.product_id = s.product_id AND s.date_refunded IS NULL
 	WHERE
 		p.product_id = :product_id
 	GROUP BY
@@ -182,7 +220,7 @@ func (s Store) QueryByUserID(ctx context.Context, userID string) ([]Product, err
 	FROM
 		products AS p
 	LEFT JOIN
-		sales AS s ON p.product_id = s.product_id
+		sales AS s ON p.product_id = s.product_id AND s.date_refunded IS NULL
 	WHERE
 		p.user_id = :user_id
 	GROUP BY
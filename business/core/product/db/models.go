@@ -7,7 +7,7 @@ type Product struct {
 	ID          string    `db:"product_id"`   // Unique identifier.
 	Name        string    `db:"name"`         // Display name of the product.
 	Cost        int       `db:"cost"`         // Price for one item in cents.
-	Quantity    int       `db:"quantity"`     // Original number of items available.
+	Quantity    int       `db:"quantity"`     // Number of items still available.
 	Sold        int       `db:"sold"`         // Aggregate field showing number of items sold.
 	Revenue     int       `db:"revenue"`      // Aggregate field showing total cost of sold items.
 	UserID      string    `db:"user_id"`      // ID of the user who created the product.
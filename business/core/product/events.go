@@ -0,0 +1,23 @@
+package product
+
+// Set of events published about products.
+const (
+	EventStockChanged = "product.stock_changed"
+	EventSoldOut      = "product.sold_out"
+)
+
+// StockChangedEvent is published whenever the quantity of a product in
+// stock changes.
+type StockChangedEvent struct {
+	ProductID string `json:"product_id"`
+	UserID    string `json:"user_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// SoldOutEvent is published when a sale takes the last of the stock of a
+// product.
+type SoldOutEvent struct {
+	ProductID string `json:"product_id"`
+	Name      string `json:"name"`
+	UserID    string `json:"user_id"`
+}
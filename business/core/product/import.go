@@ -0,0 +1,336 @@
+package product
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/colmmurphy91/go-service/foundation/worker"
+	"github.com/jmoiron/sqlx"
+)
+
+// ImportJob is the key imports are registered under with a worker.
+const ImportJob = "product-import"
+
+// Set of modes an import can run in.
+const (
+	ImportAll   = "all"   // Import every row or, if any row is invalid, none.
+	ImportValid = "valid" // Import the valid rows and skip the rest.
+)
+
+// Set of formats products can be imported from.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Set of error variables for imports.
+var (
+	ErrInvalidImportMode   = errors.New("import mode is not valid")
+	ErrInvalidImportFormat = errors.New("import format is not valid")
+	ErrImportRejected      = errors.New("import rejected")
+)
+
+// ImportRow is a product read from an import and the row it was read from.
+// Rows that could not be read hold why.
+type ImportRow struct {
+	Row     int
+	Product NewProduct
+	err     string
+}
+
+// Import is what we require to run an import.
+type Import struct {
+	Mode string
+	Rows []ImportRow
+}
+
+// ImportError describes why a row was not imported.
+type ImportError struct {
+	Row    int                  `json:"row"`
+	Error  string               `json:"error,omitempty"`
+	Fields validate.FieldErrors `json:"fields,omitempty"`
+}
+
+// ImportProgress is how far along a running import is.
+type ImportProgress struct {
+	Rows      int `json:"rows"`
+	Processed int `json:"processed"`
+}
+
+// ImportResult reports how an import went.
+type ImportResult struct {
+	Mode     string        `json:"mode"`
+	Rows     int           `json:"rows"`
+	Imported int           `json:"imported"`
+	Errors   []ImportError `json:"errors"`
+}
+
+// DecodeImport reads the rows of an import in the specified format. CSV
+// needs a header naming the name, cost and quantity columns, and may have a
+// user_id column. NDJSON has a NewProduct on each line. Rows that can not
+// be read are kept with the reason so they are reported with the rest. Rows
+// without a user are given the default user.
+func DecodeImport(r io.Reader, format string, defaultUserID string) ([]ImportRow, error) {
+	var rows []ImportRow
+	var err error
+
+	switch format {
+	case FormatCSV:
+		rows, err = decodeCSV(r)
+	case FormatNDJSON:
+		rows, err = decodeNDJSON(r)
+	default:
+		return nil, ErrInvalidImportFormat
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rows {
+		if rows[i].Product.UserID == "" {
+			rows[i].Product.UserID = defaultUserID
+		}
+	}
+
+	return rows, nil
+}
+
+// Import validates every row of the import, including that the user of each
+// product exists, and adds the products in one transaction. In ImportAll
+// mode nothing is added if any row is invalid, and ErrImportRejected is
+// returned with the result.
+func (c Core) Import(ctx context.Context, imp Import, now time.Time) (ImportResult, error) {
+	if imp.Mode != ImportAll && imp.Mode != ImportValid {
+		return ImportResult{}, ErrInvalidImportMode
+	}
+
+	res := ImportResult{
+		Mode:   imp.Mode,
+		Rows:   len(imp.Rows),
+		Errors: []ImportError{},
+	}
+
+	users := make(map[string]bool)
+
+	var valid []NewProduct
+	for _, row := range imp.Rows {
+		if row.err != "" {
+			res.Errors = append(res.Errors, ImportError{Row: row.Row, Error: row.err})
+			continue
+		}
+
+		if err := validate.Check(row.Product); err != nil {
+			ie := ImportError{Row: row.Row}
+			var fields validate.FieldErrors
+			if errors.As(err, &fields) {
+				ie.Fields = fields
+			} else {
+				ie.Error = err.Error()
+			}
+			res.Errors = append(res.Errors, ie)
+			continue
+		}
+
+		reason, err := c.checkUser(ctx, row.Product.UserID, users)
+		if err != nil {
+			return ImportResult{}, err
+		}
+		if reason != "" {
+			ie := ImportError{
+				Row:    row.Row,
+				Fields: validate.FieldErrors{{Field: "user_id", Error: reason}},
+			}
+			res.Errors = append(res.Errors, ie)
+			continue
+		}
+
+		valid = append(valid, row.Product)
+	}
+
+	if imp.Mode == ImportAll && len(res.Errors) > 0 {
+		return res, fmt.Errorf("%w: %d of %d rows are invalid", ErrImportRejected, len(res.Errors), res.Rows)
+	}
+
+	progress := ImportProgress{Rows: res.Rows, Processed: len(res.Errors)}
+	worker.SetProgress(ctx, progress)
+
+	tran := func(tx sqlx.ExtContext) error {
+		for _, np := range valid {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if _, err := c.create(ctx, tx, np, now); err != nil {
+				return err
+			}
+
+			progress.Processed++
+			worker.SetProgress(ctx, progress)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return res, fmt.Errorf("tran: %w", err)
+	}
+
+	res.Imported = len(valid)
+
+	return res, nil
+}
+
+// RunImport runs an Import given as the payload. It has the signature of a
+// worker job so imports can be started by a worker, which keeps the result.
+func (c Core) RunImport(ctx context.Context, traceID string, payload interface{}) (interface{}, error) {
+	imp, ok := payload.(Import)
+	if !ok {
+		return nil, fmt.Errorf("payload is %T, not an import", payload)
+	}
+
+	return c.Import(ctx, imp, time.Now())
+}
+
+// =============================================================================
+
+// checkUser returns why products can't be imported for the user, or an empty
+// string when they can. Users already looked up are remembered in known so
+// each is only queried once.
+func (c Core) checkUser(ctx context.Context, userID string, known map[string]bool) (string, error) {
+	if err := validate.CheckID(userID); err != nil {
+		return "user_id is not in its proper form", nil
+	}
+
+	exists, ok := known[userID]
+	if !ok {
+		_, err := c.user.QueryByID(ctx, userID)
+		switch {
+		case err == nil:
+			exists = true
+		case !errors.Is(err, sql.ErrDBNotFound):
+			return "", fmt.Errorf("query user[%s]: %w", userID, err)
+		}
+		known[userID] = exists
+	}
+
+	if !exists {
+		return "user does not exist", nil
+	}
+
+	return "", nil
+}
+
+// decodeCSV reads the rows of a CSV import. The row of a record is its line
+// in the file.
+func decodeCSV(r io.Reader) ([]ImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv is empty")
+		}
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "name", "cost", "quantity", "user_id":
+			columns[name] = i
+		default:
+			return nil, fmt.Errorf("unknown csv column %q", name)
+		}
+	}
+	for _, name := range []string{"name", "cost", "quantity"} {
+		if _, exists := columns[name]; !exists {
+			return nil, fmt.Errorf("csv is missing column %q", name)
+		}
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return nil, fmt.Errorf("reading csv: %w", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		row := ImportRow{Row: line}
+
+		if err != nil {
+			row.err = fmt.Sprintf("has %d fields, the header has %d", len(record), len(header))
+			rows = append(rows, row)
+			continue
+		}
+
+		row.Product.Name = record[columns["name"]]
+		if i, exists := columns["user_id"]; exists {
+			row.Product.UserID = record[i]
+		}
+
+		if row.Product.Cost, err = atoi(record[columns["cost"]]); err != nil {
+			row.err = fmt.Sprintf("cost %q is not a whole number", record[columns["cost"]])
+		} else if row.Product.Quantity, err = atoi(record[columns["quantity"]]); err != nil {
+			row.err = fmt.Sprintf("quantity %q is not a whole number", record[columns["quantity"]])
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// decodeNDJSON reads the rows of an NDJSON import, skipping blank lines. The
+// row of a product is its line in the file.
+func decodeNDJSON(r io.Reader) ([]ImportRow, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var rows []ImportRow
+	for line := 1; sc.Scan(); line++ {
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		row := ImportRow{Row: line}
+
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row.Product); err != nil {
+			row.err = fmt.Sprintf("invalid json: %s", err)
+		}
+
+		rows = append(rows, row)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading ndjson: %w", err)
+	}
+
+	return rows, nil
+}
+
+// atoi parses a whole number, allowing surrounding spaces.
+func atoi(s string) (int, error) {
+	return strconv.Atoi(strings.TrimSpace(s))
+}
@@ -12,7 +12,7 @@ type Product struct {
 	ID          string    `json:"id"`           // Unique identifier.
 	Name        string    `json:"name"`         // Display name of the product.
 	Cost        int       `json:"cost"`         // Price for one item in cents.
-	Quantity    int       `json:"quantity"`     // Original number of items available.
+	Quantity    int       `json:"quantity"`     // Number of items still available.
 	Sold        int       `json:"sold"`         // Aggregate field showing number of items sold.
 	Revenue     int       `json:"revenue"`      // Aggregate field showing total cost of sold items.
 	UserID      string    `json:"user_id"`      // ID of the user who created the product.
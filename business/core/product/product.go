@@ -10,7 +10,10 @@ import (
 	"github.com/colmmurphy91/go-service/business/sys/database/sql"
 	"time"
 
+	"github.com/colmmurphy91/go-service/business/core/audit"
 	"github.com/colmmurphy91/go-service/business/core/product/db"
+	userdb "github.com/colmmurphy91/go-service/business/core/user/db"
+	"github.com/colmmurphy91/go-service/business/sys/events"
 	"github.com/colmmurphy91/go-service/business/sys/validate"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
@@ -22,15 +25,24 @@ var (
 	ErrInvalidID = errors.New("ID is not in its proper form")
 )
 
+// entity is the name products are recorded under in the audit log.
+const entity = "product"
+
 // Core manages the set of APIs for product access.
 type Core struct {
-	store db.Store
+	store  db.Store
+	user   userdb.Store
+	audit  audit.Core
+	events events.Outbox
 }
 
 // NewCore constructs a core for product api access.
 func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
 	return Core{
-		store: db.NewStore(log, sqlxDB),
+		store:  db.NewStore(log, sqlxDB),
+		user:   userdb.NewStore(log, sqlxDB),
+		audit:  audit.NewCore(log, sqlxDB),
+		events: events.NewOutbox(log, sqlxDB),
 	}
 }
 
@@ -41,25 +53,23 @@ func (c Core) Create(ctx context.Context, np NewProduct, now time.Time) (Product
 		return Product{}, fmt.Errorf("validating data: %w", err)
 	}
 
-	dbPrd := db.Product{
-		ID:          validate.GenerateID(),
-		Name:        np.Name,
-		Cost:        np.Cost,
-		Quantity:    np.Quantity,
-		UserID:      np.UserID,
-		DateCreated: now,
-		DateUpdated: now,
+	var prd Product
+	tran := func(tx sqlx.ExtContext) error {
+		var err error
+		prd, err = c.create(ctx, tx, np, now)
+		return err
 	}
 
-	if err := c.store.Create(ctx, dbPrd); err != nil {
-		return Product{}, fmt.Errorf("create: %w", err)
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return Product{}, fmt.Errorf("tran: %w", err)
 	}
 
-	return toProduct(dbPrd), nil
+	return prd, nil
 }
 
 // Update modifies data about a Product. It will error if the specified ID is
-// invalid or does not reference an existing Product.
+// invalid or does not reference an existing Product. Changing the quantity
+// publishes EventStockChanged.
 func (c Core) Update(ctx context.Context, productID string, up UpdateProduct, now time.Time) error {
 	if err := validate.CheckID(productID); err != nil {
 		return ErrInvalidID
@@ -76,6 +86,7 @@ func (c Core) Update(ctx context.Context, productID string, up UpdateProduct, no
 		}
 		return fmt.Errorf("updating product productID[%s]: %w", productID, err)
 	}
+	before := toProduct(dbPrd)
 
 	if up.Name != nil {
 		dbPrd.Name = *up.Name
@@ -88,21 +99,80 @@ func (c Core) Update(ctx context.Context, productID string, up UpdateProduct, no
 	}
 	dbPrd.DateUpdated = now
 
-	if err := c.store.Update(ctx, dbPrd); err != nil {
-		return fmt.Errorf("update: %w", err)
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.store.Tran(tx).Update(ctx, dbPrd); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+
+		ch := audit.Change{
+			Action:   audit.ActionUpdate,
+			Entity:   entity,
+			EntityID: productID,
+			Before:   before,
+			After:    toProduct(dbPrd),
+		}
+		if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		if dbPrd.Quantity != before.Quantity {
+			evt := StockChangedEvent{
+				ProductID: productID,
+				UserID:    dbPrd.UserID,
+				Quantity:  dbPrd.Quantity,
+			}
+			if err := c.events.Publish(ctx, tx, EventStockChanged, evt, now); err != nil {
+				return fmt.Errorf("publish: %w", err)
+			}
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
 	}
 
 	return nil
 }
 
-// Delete removes the product identified by a given ID.
-func (c Core) Delete(ctx context.Context, productID string) error {
+// Delete removes the product identified by a given ID. Deleting a product
+// that does not exist is not an error and is not audited.
+func (c Core) Delete(ctx context.Context, productID string, now time.Time) error {
 	if err := validate.CheckID(productID); err != nil {
 		return ErrInvalidID
 	}
 
-	if err := c.store.Delete(ctx, productID); err != nil {
-		return fmt.Errorf("delete: %w", err)
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbPrd, err := store.QueryByID(ctx, productID)
+		if err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return nil
+			}
+			return fmt.Errorf("query: %w", err)
+		}
+
+		if err := store.Delete(ctx, productID); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+
+		ch := audit.Change{
+			Action:   audit.ActionDelete,
+			Entity:   entity,
+			EntityID: productID,
+			Before:   toProduct(dbPrd),
+		}
+		if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
 	}
 
 	return nil
@@ -148,3 +218,37 @@ func (c Core) QueryByUserID(ctx context.Context, userID string) ([]Product, erro
 
 	return toProductSlice(dbPrds), nil
 }
+
+// =============================================================================
+
+// create adds a validated Product to the database and records it in the
+// audit log using the specified transaction.
+func (c Core) create(ctx context.Context, tx sqlx.ExtContext, np NewProduct, now time.Time) (Product, error) {
+	dbPrd := db.Product{
+		ID:          validate.GenerateID(),
+		Name:        np.Name,
+		Cost:        np.Cost,
+		Quantity:    np.Quantity,
+		UserID:      np.UserID,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := c.store.Tran(tx).Create(ctx, dbPrd); err != nil {
+		return Product{}, fmt.Errorf("create: %w", err)
+	}
+
+	prd := toProduct(dbPrd)
+
+	ch := audit.Change{
+		Action:   audit.ActionCreate,
+		Entity:   entity,
+		EntityID: prd.ID,
+		After:    prd,
+	}
+	if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+		return Product{}, fmt.Errorf("audit: %w", err)
+	}
+
+	return prd, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -123,7 +124,7 @@ func TestProduct(t *testing.T) {
 				t.Logf("\t%s\tTest %d:\tShould be able to see updated Name field.", dbtest.Success, testID)
 			}
 
-			if err := core.Delete(ctx, prd.ID); err != nil {
+			if err := core.Delete(ctx, prd.ID, now); err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to delete product : %s.", dbtest.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to delete product.", dbtest.Success, testID)
@@ -136,3 +137,92 @@ func TestProduct(t *testing.T) {
 		}
 	}
 }
+
+func TestImport(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testimport")
+	t.Cleanup(teardown)
+
+	core := product.NewCore(log, db)
+
+	const userID = "5cf37266-3473-4006-984f-9325122678b7"
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	csv := "name,cost,quantity\nComic Books,10,55\nMcDonalds Toys,ten,75\n,25,5\nDVDs,20,3\n"
+
+	t.Log("Given the need to import Products in bulk.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen importing a CSV with invalid rows.", testID)
+		{
+			rows, err := product.DecodeImport(strings.NewReader(csv), product.FormatCSV, userID)
+			if err != nil || len(rows) != 4 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the rows : %d %v.", dbtest.Failed, testID, len(rows), err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to decode the rows.", dbtest.Success, testID)
+
+			res, err := core.Import(ctx, product.Import{Mode: product.ImportAll, Rows: rows}, now)
+			if !errors.Is(err, product.ErrImportRejected) || res.Imported != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould import nothing in all mode : %+v %v.", dbtest.Failed, testID, res, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould import nothing in all mode.", dbtest.Success, testID)
+
+			if len(res.Errors) != 2 || res.Errors[0].Row != 3 || res.Errors[1].Row != 4 || len(res.Errors[1].Fields) == 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould report the invalid rows : %+v.", dbtest.Failed, testID, res.Errors)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the invalid rows.", dbtest.Success, testID)
+
+			res, err = core.Import(ctx, product.Import{Mode: product.ImportValid, Rows: rows}, now)
+			if err != nil || res.Imported != 2 || len(res.Errors) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould import the valid rows in valid mode : %+v %v.", dbtest.Failed, testID, res, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould import the valid rows in valid mode.", dbtest.Success, testID)
+
+			prds, err := core.QueryByUserID(ctx, userID)
+			if err != nil || len(prds) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve the imported products : %d %v.", dbtest.Failed, testID, len(prds), err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve the imported products.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen importing rows for users that are not valid.", testID)
+		{
+			csv := "name,cost,quantity,user_id\nPosters,5,10,\nStickers,1,100,bad-id\nBadges,2,50,9b468f90-1cf1-4377-b3fa-68b450d632a0\n"
+
+			rows, err := product.DecodeImport(strings.NewReader(csv), product.FormatCSV, userID)
+			if err != nil || len(rows) != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the rows : %d %v.", dbtest.Failed, testID, len(rows), err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to decode the rows.", dbtest.Success, testID)
+
+			res, err := core.Import(ctx, product.Import{Mode: product.ImportValid, Rows: rows}, now)
+			if err != nil || res.Imported != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould import the rows for known users in valid mode : %+v %v.", dbtest.Failed, testID, res, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould import the rows for known users in valid mode.", dbtest.Success, testID)
+
+			if len(res.Errors) != 2 || res.Errors[0].Row != 3 || res.Errors[1].Row != 4 || len(res.Errors[1].Fields) == 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould report the rows for unknown users : %+v.", dbtest.Failed, testID, res.Errors)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the rows for unknown users.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen decoding NDJSON.", testID)
+		{
+			ndjson := `{"name":"Comic Books","cost":10,"quantity":55}` + "\n\n" + `{"name":"DVDs","cost":"20"}` + "\n"
+
+			rows, err := product.DecodeImport(strings.NewReader(ndjson), product.FormatNDJSON, userID)
+			if err != nil || len(rows) != 2 || rows[0].Row != 1 || rows[1].Row != 3 || rows[0].Product.UserID != userID {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to decode the rows : %+v %v.", dbtest.Failed, testID, rows, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to decode the rows.", dbtest.Success, testID)
+
+			if _, err := product.DecodeImport(strings.NewReader("name,price\n"), product.FormatCSV, userID); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a CSV with unknown columns.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a CSV with unknown columns.", dbtest.Success, testID)
+		}
+	}
+}
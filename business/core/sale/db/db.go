@@ -0,0 +1,187 @@
+// Package db contains sale related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for sale access.
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           sql.Transactor
+	db           sqlx.ExtContext
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		tr:  db,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	return sql.WithinTran(ctx, s.log, s.tr, fn)
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// Create adds a Sale to the database.
+func (s Store) Create(ctx context.Context, sale Sale) error {
+	const q = `
+	INSERT INTO sales
+		(sale_id, user_id, product_id, quantity, paid, date_created)
+	VALUES
+		(:sale_id, :user_id, :product_id, :quantity, :paid, :date_created)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, sale); err != nil {
+		return fmt.Errorf("inserting sale: %w", err)
+	}
+
+	return nil
+}
+
+// Refund marks the specified sale as refunded, unless it already has been.
+// It reports whether the sale was marked, so a sale is only ever refunded
+// once however many refunds race.
+func (s Store) Refund(ctx context.Context, sale Sale) (bool, error) {
+	const q = `
+	UPDATE
+		sales
+	SET
+		"date_refunded" = :date_refunded
+	WHERE
+		sale_id = :sale_id AND
+		date_refunded IS NULL
+	RETURNING
+		*`
+
+	var sales []Sale
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, sale, &sales); err != nil {
+		return false, fmt.Errorf("refunding sale saleID[%s]: %w", sale.ID, err)
+	}
+
+	return len(sales) == 1, nil
+}
+
+// Query gets all Sales from the database.
+func (s Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Sale, error) {
+	data := struct {
+		Offset      int `db:"offset"`
+		RowsPerPage int `db:"rows_per_page"`
+	}{
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		sales
+	ORDER BY
+		date_created DESC
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var sales []Sale
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &sales); err != nil {
+		return nil, fmt.Errorf("selecting sales: %w", err)
+	}
+
+	return sales, nil
+}
+
+// QueryByID finds the sale identified by a given ID.
+func (s Store) QueryByID(ctx context.Context, saleID string) (Sale, error) {
+	data := struct {
+		SaleID string `db:"sale_id"`
+	}{
+		SaleID: saleID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		sales
+	WHERE
+		sale_id = :sale_id`
+
+	var sale Sale
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &sale); err != nil {
+		return Sale{}, fmt.Errorf("selecting sale saleID[%q]: %w", saleID, err)
+	}
+
+	return sale, nil
+}
+
+// QueryByProductID finds the sales for a given Product ID.
+func (s Store) QueryByProductID(ctx context.Context, productID string) ([]Sale, error) {
+	data := struct {
+		ProductID string `db:"product_id"`
+	}{
+		ProductID: productID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		sales
+	WHERE
+		product_id = :product_id
+	ORDER BY
+		date_created DESC`
+
+	var sales []Sale
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &sales); err != nil {
+		return nil, fmt.Errorf("selecting sales productID[%s]: %w", productID, err)
+	}
+
+	return sales, nil
+}
+
+// QueryByUserID finds the sales made by a given User ID.
+func (s Store) QueryByUserID(ctx context.Context, userID string) ([]Sale, error) {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		sales
+	WHERE
+		user_id = :user_id
+	ORDER BY
+		date_created DESC`
+
+	var sales []Sale
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &sales); err != nil {
+		return nil, fmt.Errorf("selecting sales userID[%s]: %w", userID, err)
+	}
+
+	return sales, nil
+}
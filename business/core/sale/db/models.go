@@ -0,0 +1,17 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Sale represents a transaction where a user bought some quantity of a product.
+type Sale struct {
+	ID           string       `db:"sale_id"`       // Unique identifier.
+	UserID       string       `db:"user_id"`       // ID of the user who made the purchase.
+	ProductID    string       `db:"product_id"`    // ID of the product sold.
+	Quantity     int          `db:"quantity"`      // Number of units sold.
+	Paid         int          `db:"paid"`          // Total price paid in cents.
+	DateCreated  time.Time    `db:"date_created"`  // When the sale was recorded.
+	DateRefunded sql.NullTime `db:"date_refunded"` // When the sale was refunded, if ever.
+}
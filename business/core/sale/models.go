@@ -0,0 +1,48 @@
+package sale
+
+import (
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/sale/db"
+)
+
+// Sale represents a transaction where a user bought some quantity of a product.
+type Sale struct {
+	ID           string    `json:"id"`            // Unique identifier.
+	UserID       string    `json:"user_id"`       // ID of the user who made the purchase.
+	ProductID    string    `json:"product_id"`    // ID of the product sold.
+	Quantity     int       `json:"quantity"`      // Number of units sold.
+	Paid         int       `json:"paid"`          // Total price paid in cents.
+	Refunded     bool      `json:"refunded"`      // Whether the sale has been refunded.
+	DateCreated  time.Time `json:"date_created"`  // When the sale was recorded.
+	DateRefunded time.Time `json:"date_refunded"` // When the sale was refunded, zero if not refunded.
+}
+
+// NewSale is what we require from clients when recording a Sale.
+type NewSale struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"gte=1"`
+}
+
+// =============================================================================
+
+func toSale(dbSale db.Sale) Sale {
+	return Sale{
+		ID:           dbSale.ID,
+		UserID:       dbSale.UserID,
+		ProductID:    dbSale.ProductID,
+		Quantity:     dbSale.Quantity,
+		Paid:         dbSale.Paid,
+		Refunded:     dbSale.DateRefunded.Valid,
+		DateCreated:  dbSale.DateCreated,
+		DateRefunded: dbSale.DateRefunded.Time,
+	}
+}
+
+func toSaleSlice(dbSales []db.Sale) []Sale {
+	sales := make([]Sale, len(dbSales))
+	for i, dbSale := range dbSales {
+		sales[i] = toSale(dbSale)
+	}
+	return sales
+}
@@ -0,0 +1,250 @@
+// Package sale provides the core business API for recording and refunding
+// sales of products. Recording a sale removes the sold units from the product
+// stock in the same transaction so the two can never disagree.
+package sale
+
+import (
+	"context"
+	gosql "database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/product"
+	productdb "github.com/colmmurphy91/go-service/business/core/product/db"
+	"github.com/colmmurphy91/go-service/business/core/sale/db"
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/events"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound          = errors.New("sale not found")
+	ErrProductNotFound   = errors.New("product not found")
+	ErrInvalidID         = errors.New("ID is not in its proper form")
+	ErrInsufficientStock = errors.New("not enough stock to complete the sale")
+	ErrAlreadyRefunded   = errors.New("sale is already refunded")
+)
+
+// Core manages the set of APIs for sale access.
+type Core struct {
+	store   db.Store
+	product productdb.Store
+	events  events.Outbox
+}
+
+// NewCore constructs a core for sale api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+	return Core{
+		store:   db.NewStore(log, sqlxDB),
+		product: productdb.NewStore(log, sqlxDB),
+		events:  events.NewOutbox(log, sqlxDB),
+	}
+}
+
+// Create records a sale of a product made by the specified user. The product
+// stock is decremented in the same transaction and the sale is rejected if
+// there is not enough stock left. The change of stock is published, along
+// with product.EventSoldOut when the sale takes the last of it.
+func (c Core) Create(ctx context.Context, ns NewSale, userID string, now time.Time) (Sale, error) {
+	if err := validate.Check(ns); err != nil {
+		return Sale{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := validate.CheckID(ns.ProductID); err != nil {
+		return Sale{}, ErrInvalidID
+	}
+
+	if err := validate.CheckID(userID); err != nil {
+		return Sale{}, ErrInvalidID
+	}
+
+	dbSale := db.Sale{
+		ID:          validate.GenerateID(),
+		UserID:      userID,
+		ProductID:   ns.ProductID,
+		Quantity:    ns.Quantity,
+		DateCreated: now,
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		dbPrd, err := c.product.Tran(tx).QueryByID(ctx, ns.ProductID)
+		if err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrProductNotFound
+			}
+			return fmt.Errorf("query product: %w", err)
+		}
+
+		left, err := c.product.Tran(tx).AdjustQuantity(ctx, ns.ProductID, -ns.Quantity, now)
+		if err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrInsufficientStock
+			}
+			return fmt.Errorf("adjust stock: %w", err)
+		}
+
+		if err := c.publishStock(ctx, tx, dbPrd, left, now); err != nil {
+			return err
+		}
+
+		if left == 0 {
+			evt := product.SoldOutEvent{
+				ProductID: dbPrd.ID,
+				Name:      dbPrd.Name,
+				UserID:    dbPrd.UserID,
+			}
+			if err := c.events.Publish(ctx, tx, product.EventSoldOut, evt, now); err != nil {
+				return fmt.Errorf("publish: %w", err)
+			}
+		}
+
+		dbSale.Paid = dbPrd.Cost * ns.Quantity
+
+		if err := c.store.Tran(tx).Create(ctx, dbSale); err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return Sale{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return toSale(dbSale), nil
+}
+
+// Refund marks a sale as refunded and returns the sold units to the product
+// stock in the same transaction.
+func (c Core) Refund(ctx context.Context, saleID string, now time.Time) (Sale, error) {
+	if err := validate.CheckID(saleID); err != nil {
+		return Sale{}, ErrInvalidID
+	}
+
+	var dbSale db.Sale
+	tran := func(tx sqlx.ExtContext) error {
+		var err error
+		dbSale, err = c.store.Tran(tx).QueryByID(ctx, saleID)
+		if err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("query: %w", err)
+		}
+
+		if dbSale.DateRefunded.Valid {
+			return ErrAlreadyRefunded
+		}
+
+		dbSale.DateRefunded = gosql.NullTime{Time: now, Valid: true}
+		refunded, err := c.store.Tran(tx).Refund(ctx, dbSale)
+		if err != nil {
+			return fmt.Errorf("refund: %w", err)
+		}
+
+		// Another refund marked the sale after we read it.
+		if !refunded {
+			return ErrAlreadyRefunded
+		}
+
+		left, err := c.product.Tran(tx).AdjustQuantity(ctx, dbSale.ProductID, dbSale.Quantity, now)
+		if err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrProductNotFound
+			}
+			return fmt.Errorf("adjust stock: %w", err)
+		}
+
+		dbPrd, err := c.product.Tran(tx).QueryByID(ctx, dbSale.ProductID)
+		if err != nil {
+			return fmt.Errorf("query product: %w", err)
+		}
+
+		if err := c.publishStock(ctx, tx, dbPrd, left, now); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return Sale{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return toSale(dbSale), nil
+}
+
+// Query gets all Sales from the database.
+func (c Core) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]Sale, error) {
+	dbSales, err := c.store.Query(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toSaleSlice(dbSales), nil
+}
+
+// QueryByID finds the sale identified by a given ID.
+func (c Core) QueryByID(ctx context.Context, saleID string) (Sale, error) {
+	if err := validate.CheckID(saleID); err != nil {
+		return Sale{}, ErrInvalidID
+	}
+
+	dbSale, err := c.store.QueryByID(ctx, saleID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return Sale{}, ErrNotFound
+		}
+		return Sale{}, fmt.Errorf("query: %w", err)
+	}
+
+	return toSale(dbSale), nil
+}
+
+// QueryByProductID finds the sales of a given Product ID.
+func (c Core) QueryByProductID(ctx context.Context, productID string) ([]Sale, error) {
+	if err := validate.CheckID(productID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	dbSales, err := c.store.QueryByProductID(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toSaleSlice(dbSales), nil
+}
+
+// QueryByUserID finds the sales made by a given User ID.
+func (c Core) QueryByUserID(ctx context.Context, userID string) ([]Sale, error) {
+	if err := validate.CheckID(userID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	dbSales, err := c.store.QueryByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toSaleSlice(dbSales), nil
+}
+
+// =============================================================================
+
+// publishStock tells other systems how much of the product is left.
+func (c Core) publishStock(ctx context.Context, tx sqlx.ExtContext, dbPrd productdb.Product, left int, now time.Time) error {
+	evt := product.StockChangedEvent{
+		ProductID: dbPrd.ID,
+		UserID:    dbPrd.UserID,
+		Quantity:  left,
+	}
+	if err := c.events.Publish(ctx, tx, product.EventStockChanged, evt, now); err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+
+	return nil
+}
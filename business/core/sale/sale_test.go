@@ -0,0 +1,200 @@
+package sale_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/product"
+	"github.com/colmmurphy91/go-service/business/core/sale"
+	"github.com/colmmurphy91/go-service/business/data/dbtest"
+	"github.com/colmmurphy91/go-service/foundation/docker"
+	"github.com/google/go-cmp/cmp"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestSale(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testsale")
+	t.Cleanup(teardown)
+
+	core := sale.NewCore(log, db)
+	prdCore := product.NewCore(log, db)
+
+	t.Log("Given the need to work with Sale records.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a single Sale.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			np := product.NewProduct{
+				Name:     "Comic Books",
+				Cost:     10,
+				Quantity: 5,
+				UserID:   "5cf37266-3473-4006-984f-9325122678b7",
+			}
+
+			prd, err := prdCore.Create(ctx, np, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a product.", dbtest.Success, testID)
+
+			ns := sale.NewSale{
+				ProductID: prd.ID,
+				Quantity:  3,
+			}
+
+			sl, err := core.Create(ctx, ns, "45b5fbd3-755f-4379-8f07-a58d4a30fa2f", now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a sale : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a sale.", dbtest.Success, testID)
+
+			if sl.Paid != 30 {
+				t.Fatalf("\t%s\tTest %d:\tShould be charged for the product : got %d want %d.", dbtest.Failed, testID, sl.Paid, 30)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be charged for the product.", dbtest.Success, testID)
+
+			saved, err := core.QueryByID(ctx, sl.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve sale by ID: %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve sale by ID.", dbtest.Success, testID)
+
+			if diff := cmp.Diff(sl, saved); diff != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the same sale. Diff:\n%s", dbtest.Failed, testID, diff)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the same sale.", dbtest.Success, testID)
+
+			prd, err = prdCore.QueryByID(ctx, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", dbtest.Failed, testID, err)
+			}
+
+			if prd.Quantity != 2 || prd.Sold != 3 || prd.Revenue != 30 {
+				t.Fatalf("\t%s\tTest %d:\tShould see stock decremented : quantity %d sold %d revenue %d.", dbtest.Failed, testID, prd.Quantity, prd.Sold, prd.Revenue)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see stock decremented.", dbtest.Success, testID)
+
+			_, err = core.Create(ctx, ns, "45b5fbd3-755f-4379-8f07-a58d4a30fa2f", now)
+			if !errors.Is(err, sale.ErrInsufficientStock) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to oversell a product : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to oversell a product.", dbtest.Success, testID)
+
+			refundTime := time.Date(2019, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+			refunded, err := core.Refund(ctx, sl.ID, refundTime)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to refund a sale : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to refund a sale.", dbtest.Success, testID)
+
+			if !refunded.Refunded || !refunded.DateRefunded.Equal(refundTime) {
+				t.Fatalf("\t%s\tTest %d:\tShould see the sale marked as refunded : %+v.", dbtest.Failed, testID, refunded)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see the sale marked as refunded.", dbtest.Success, testID)
+
+			prd, err = prdCore.QueryByID(ctx, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", dbtest.Failed, testID, err)
+			}
+
+			if prd.Quantity != 5 || prd.Sold != 0 || prd.Revenue != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould see stock restored : quantity %d sold %d revenue %d.", dbtest.Failed, testID, prd.Quantity, prd.Sold, prd.Revenue)
+			}
+			t.Logf("\t%s\tTest %d:\tShould see stock restored.", dbtest.Success, testID)
+
+			if _, err := core.Refund(ctx, sl.ID, refundTime); !errors.Is(err, sale.ErrAlreadyRefunded) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to refund a sale twice : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to refund a sale twice.", dbtest.Success, testID)
+
+			sales, err := core.QueryByProductID(ctx, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve sales by product : %s.", dbtest.Failed, testID, err)
+			}
+
+			if len(sales) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould have a single sale for the product : got %d.", dbtest.Failed, testID, len(sales))
+			}
+			t.Logf("\t%s\tTest %d:\tShould have a single sale for the product.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen refunding a Sale concurrently.", testID)
+		{
+			ctx := context.Background()
+			now := time.Date(2019, time.January, 3, 0, 0, 0, 0, time.UTC)
+
+			np := product.NewProduct{
+				Name:     "Trading Cards",
+				Cost:     5,
+				Quantity: 5,
+				UserID:   "5cf37266-3473-4006-984f-9325122678b7",
+			}
+
+			prd, err := prdCore.Create(ctx, np, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a product : %s.", dbtest.Failed, testID, err)
+			}
+
+			sl, err := core.Create(ctx, sale.NewSale{ProductID: prd.ID, Quantity: 2}, "45b5fbd3-755f-4379-8f07-a58d4a30fa2f", now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a sale : %s.", dbtest.Failed, testID, err)
+			}
+
+			const refunds = 5
+			errs := make(chan error, refunds)
+			for i := 0; i < refunds; i++ {
+				go func() {
+					_, err := core.Refund(ctx, sl.ID, now)
+					errs <- err
+				}()
+			}
+
+			var succeeded int
+			for i := 0; i < refunds; i++ {
+				err := <-errs
+				switch {
+				case err == nil:
+					succeeded++
+				case !errors.Is(err, sale.ErrAlreadyRefunded):
+					t.Fatalf("\t%s\tTest %d:\tShould only fail to refund because it is refunded : %s.", dbtest.Failed, testID, err)
+				}
+			}
+
+			if succeeded != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould refund the sale once : got %d.", dbtest.Failed, testID, succeeded)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refund the sale once.", dbtest.Success, testID)
+
+			prd, err = prdCore.QueryByID(ctx, prd.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve product by ID: %s.", dbtest.Failed, testID, err)
+			}
+
+			if prd.Quantity != 5 {
+				t.Fatalf("\t%s\tTest %d:\tShould restore the stock once : quantity %d.", dbtest.Failed, testID, prd.Quantity)
+			}
+			t.Logf("\t%s\tTest %d:\tShould restore the stock once.", dbtest.Success, testID)
+		}
+	}
+}
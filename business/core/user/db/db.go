@@ -49,9 +49,9 @@ func (s Store) Tran(tx sqlx.ExtContext) Store {
 func (s Store) Create(ctx context.Context, usr User) error {
 	const q = `
 	INSERT INTO users
-		(user_id, name, email, password_hash, roles, date_created, date_updated, confirmed, confirm_hash)
+		(user_id, name, email, password_hash, roles, date_created, date_updated, confirmed)
 	VALUES
-		(:user_id, :name, :email, :password_hash, :roles, :date_created, :date_updated, :confirmed, :confirm_hash)`
+		(:user_id, :name, :email, :password_hash, :roles, :date_created, :date_updated, :confirmed)`
 
 	if err := sql.NamedExecContext(ctx, s.log, s.db, q, usr); err != nil {
 		return fmt.Errorf("inserting user: %w", err)
@@ -72,7 +72,7 @@ func (s Store) Update(ctx context.Context, usr User) error {
 		"password_hash" = :password_hash,
 		"date_updated" = :date_updated,
 		"confirmed" = :confirmed,
-		"confirm_hash" = :confirm_hash
+		"date_sessions_revoked" = :date_sessions_revoked
 	WHERE
 		user_id = :user_id`
 
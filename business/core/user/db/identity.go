@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+)
+
+// CreateIdentity links a user to an account with an external identity
+// provider.
+func (s Store) CreateIdentity(ctx context.Context, ident Identity) error {
+	const q = `
+	INSERT INTO user_identities
+		(issuer, subject, user_id, email, date_created)
+	VALUES
+		(:issuer, :subject, :user_id, :email, :date_created)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, ident); err != nil {
+		return fmt.Errorf("inserting identity: %w", err)
+	}
+
+	return nil
+}
+
+// QueryIdentity finds the link for an account with an external identity
+// provider.
+func (s Store) QueryIdentity(ctx context.Context, issuer string, subject string) (Identity, error) {
+	data := struct {
+		Issuer  string `db:"issuer"`
+		Subject string `db:"subject"`
+	}{
+		Issuer:  issuer,
+		Subject: subject,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		user_identities
+	WHERE
+		issuer = :issuer AND
+		subject = :subject`
+
+	var ident Identity
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &ident); err != nil {
+		return Identity{}, fmt.Errorf("selecting identity issuer[%s] subject[%s]: %w", issuer, subject, err)
+	}
+
+	return ident, nil
+}
+
+// CreateLogin stores a sign in that has been started. Sign ins that expired
+// without being finished are pruned first so the table doesn't grow.
+func (s Store) CreateLogin(ctx context.Context, login Login) error {
+	data := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: login.DateCreated,
+	}
+
+	const prune = `
+	DELETE FROM
+		oidc_logins
+	WHERE
+		date_expires < :now`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, prune, data); err != nil {
+		return fmt.Errorf("pruning logins: %w", err)
+	}
+
+	const q = `
+	INSERT INTO oidc_logins
+		(state_hash, nonce, code_verifier, date_created, date_expires)
+	VALUES
+		(:state_hash, :nonce, :code_verifier, :date_created, :date_expires)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, login); err != nil {
+		return fmt.Errorf("inserting login: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeLogin removes the sign in with the specified state hash and returns
+// it, so each sign in can only be finished once. Expiry is left to the caller.
+func (s Store) ConsumeLogin(ctx context.Context, stateHash string) (Login, error) {
+	data := struct {
+		StateHash string `db:"state_hash"`
+	}{
+		StateHash: stateHash,
+	}
+
+	const q = `
+	DELETE FROM
+		oidc_logins
+	WHERE
+		state_hash = :state_hash
+	RETURNING
+		*`
+
+	var login Login
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &login); err != nil {
+		return Login{}, fmt.Errorf("consuming login: %w", err)
+	}
+
+	return login, nil
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+)
+
+// QueryLoginFailure gets the failed sign ins counted against a key.
+func (s Store) QueryLoginFailure(ctx context.Context, key string) (LoginFailure, error) {
+	data := struct {
+		Key string `db:"failure_key"`
+	}{
+		Key: key,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		login_failures
+	WHERE
+		failure_key = :failure_key`
+
+	var lf LoginFailure
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &lf); err != nil {
+		return LoginFailure{}, fmt.Errorf("selecting login failure key[%s]: %w", key, err)
+	}
+
+	return lf, nil
+}
+
+// LockLoginFailure holds a lock on the failed sign ins counted against a key
+// until the transaction the store is in ends, so attempts against the key
+// are counted one at a time. The key does not need to have any failures.
+func (s Store) LockLoginFailure(ctx context.Context, key string) error {
+	data := struct {
+		Key string `db:"failure_key"`
+	}{
+		Key: key,
+	}
+
+	const q = `
+	SELECT
+		pg_advisory_xact_lock(hashtext(:failure_key))`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("locking login failure key[%s]: %w", key, err)
+	}
+
+	return nil
+}
+
+// RecordLoginFailure counts a failed sign in against a key and returns the
+// new count. Failures from before the window started are forgotten, so the
+// count starts over.
+func (s Store) RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (LoginFailure, error) {
+	data := struct {
+		Key         string    `db:"failure_key"`
+		Now         time.Time `db:"now"`
+		WindowStart time.Time `db:"window_start"`
+	}{
+		Key:         key,
+		Now:         now,
+		WindowStart: now.Add(-window),
+	}
+
+	const q = `
+	INSERT INTO login_failures
+		(failure_key, failures, date_last_failure)
+	VALUES
+		(:failure_key, 1, :now)
+	ON CONFLICT (failure_key) DO UPDATE SET
+		"failures" = CASE
+			WHEN login_failures.date_last_failure < :window_start THEN 1
+			ELSE login_failures.failures + 1
+		END,
+		"date_last_failure" = :now
+	RETURNING
+		*`
+
+	var lf LoginFailure
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &lf); err != nil {
+		return LoginFailure{}, fmt.Errorf("recording login failure key[%s]: %w", key, err)
+	}
+
+	return lf, nil
+}
+
+// UncountLoginFailure takes back one failed sign in counted against a key.
+func (s Store) UncountLoginFailure(ctx context.Context, key string) error {
+	data := struct {
+		Key string `db:"failure_key"`
+	}{
+		Key: key,
+	}
+
+	const q = `
+	UPDATE
+		login_failures
+	SET
+		"failures" = failures - 1
+	WHERE
+		failure_key = :failure_key AND
+		failures > 0`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("uncounting login failure key[%s]: %w", key, err)
+	}
+
+	return nil
+}
+
+// PruneLoginFailures deletes the keys with no failed sign ins since the
+// specified time so the table doesn't grow.
+func (s Store) PruneLoginFailures(ctx context.Context, before time.Time) error {
+	data := struct {
+		Before time.Time `db:"before"`
+	}{
+		Before: before,
+	}
+
+	const q = `
+	DELETE FROM
+		login_failures
+	WHERE
+		date_last_failure < :before`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("pruning login failures: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteLoginFailure clears the failed sign ins counted against a key.
+func (s Store) DeleteLoginFailure(ctx context.Context, key string) error {
+	data := struct {
+		Key string `db:"failure_key"`
+	}{
+		Key: key,
+	}
+
+	const q = `
+	DELETE FROM
+		login_failures
+	WHERE
+		failure_key = :failure_key`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting login failure key[%s]: %w", key, err)
+	}
+
+	return nil
+}
@@ -17,5 +17,69 @@ type User struct {
 	DateCreated  time.Time      `db:"date_created"`
 	DateUpdated  time.Time      `db:"date_updated"`
 	Confirmed    bool           `db:"confirmed"`
-	ConfirmHash  sql.NullInt64  `db:"confirm_hash"`
+
+	// DateSessionsRevoked invalidates every token issued to the user
+	// before it.
+	DateSessionsRevoked sql.NullTime `db:"date_sessions_revoked"`
+}
+
+// Token represents a single-use verification token. Only the hash of the
+// token is stored so a leaked table can't be used to verify anything.
+type Token struct {
+	ID          string       `db:"token_id"`
+	UserID      string       `db:"user_id"`
+	Purpose     string       `db:"purpose"`
+	Hash        string       `db:"token_hash"`
+	Attempts    int          `db:"attempts"`
+	DateCreated time.Time    `db:"date_created"`
+	DateExpires time.Time    `db:"date_expires"`
+	DateUsed    sql.NullTime `db:"date_used"`
+	DateRevoked sql.NullTime `db:"date_revoked"`
+}
+
+// Identity links a user to the account they hold with an external identity
+// provider. The issuer and subject together identify that account.
+type Identity struct {
+	Issuer      string    `db:"issuer"`
+	Subject     string    `db:"subject"`
+	UserID      string    `db:"user_id"`
+	Email       string    `db:"email"`
+	DateCreated time.Time `db:"date_created"`
+}
+
+// Login represents a sign in through an external identity provider that has
+// been started but not finished. Only the hash of the state is stored.
+type Login struct {
+	StateHash    string    `db:"state_hash"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	DateCreated  time.Time `db:"date_created"`
+	DateExpires  time.Time `db:"date_expires"`
+}
+
+// TOTP represents the authenticator app a user has enrolled as a second
+// factor. It only counts once it has been confirmed with a code. LastStep is
+// the time step of the last code accepted so a code can't be replayed.
+type TOTP struct {
+	UserID        string       `db:"user_id"`
+	Secret        string       `db:"secret"`
+	LastStep      int64        `db:"last_step"`
+	DateCreated   time.Time    `db:"date_created"`
+	DateConfirmed sql.NullTime `db:"date_confirmed"`
+}
+
+// RecoveryCode represents a single-use code that stands in for the second
+// factor when the authenticator app is lost. Only the hash is stored.
+type RecoveryCode struct {
+	UserID   string       `db:"user_id"`
+	Hash     string       `db:"code_hash"`
+	DateUsed sql.NullTime `db:"date_used"`
+}
+
+// LoginFailure counts the failed sign ins for an email or a client address
+// since the count was last cleared.
+type LoginFailure struct {
+	Key             string    `db:"failure_key"`
+	Failures        int       `db:"failures"`
+	DateLastFailure time.Time `db:"date_last_failure"`
 }
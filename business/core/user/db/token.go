@@ -0,0 +1,211 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+)
+
+// CreateToken inserts a new verification token into the database.
+func (s Store) CreateToken(ctx context.Context, tkn Token) error {
+	const q = `
+	INSERT INTO verification_tokens
+		(token_id, user_id, purpose, token_hash, attempts, date_created, date_expires)
+	VALUES
+		(:token_id, :user_id, :purpose, :token_hash, :attempts, :date_created, :date_expires)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, tkn); err != nil {
+		return fmt.Errorf("inserting token: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeTokens revokes every outstanding token a user holds for a purpose.
+func (s Store) RevokeTokens(ctx context.Context, userID string, purpose string, now time.Time) error {
+	data := struct {
+		UserID  string    `db:"user_id"`
+		Purpose string    `db:"purpose"`
+		Now     time.Time `db:"now"`
+	}{
+		UserID:  userID,
+		Purpose: purpose,
+		Now:     now,
+	}
+
+	const q = `
+	UPDATE
+		verification_tokens
+	SET
+		"date_revoked" = :now
+	WHERE
+		user_id = :user_id AND
+		purpose = :purpose AND
+		date_used IS NULL AND
+		date_revoked IS NULL`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking tokens userID[%s] purpose[%s]: %w", userID, purpose, err)
+	}
+
+	return nil
+}
+
+// QueryActiveToken gets the newest token a user holds for a purpose that has
+// been neither used nor revoked. Expiry is left to the caller.
+func (s Store) QueryActiveToken(ctx context.Context, userID string, purpose string) (Token, error) {
+	data := struct {
+		UserID  string `db:"user_id"`
+		Purpose string `db:"purpose"`
+	}{
+		UserID:  userID,
+		Purpose: purpose,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		verification_tokens
+	WHERE
+		user_id = :user_id AND
+		purpose = :purpose AND
+		date_used IS NULL AND
+		date_revoked IS NULL
+	ORDER BY
+		date_created DESC
+	LIMIT 1`
+
+	var tkn Token
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &tkn); err != nil {
+		return Token{}, fmt.Errorf("selecting token userID[%s] purpose[%s]: %w", userID, purpose, err)
+	}
+
+	return tkn, nil
+}
+
+// ReserveTokenAttempt counts an attempt against a token before it is checked
+// and returns the new number of attempts. ErrDBNotFound is returned if the
+// token already has the maximum number of attempts, so attempts made at the
+// same time can't go over it.
+func (s Store) ReserveTokenAttempt(ctx context.Context, tokenID string, max int) (int, error) {
+	data := struct {
+		TokenID string `db:"token_id"`
+		Max     int    `db:"max"`
+	}{
+		TokenID: tokenID,
+		Max:     max,
+	}
+
+	const q = `
+	UPDATE
+		verification_tokens
+	SET
+		"attempts" = attempts + 1
+	WHERE
+		token_id = :token_id AND
+		attempts < :max
+	RETURNING
+		attempts`
+
+	var result struct {
+		Attempts int `db:"attempts"`
+	}
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &result); err != nil {
+		return 0, fmt.Errorf("reserving attempt tokenID[%s]: %w", tokenID, err)
+	}
+
+	return result.Attempts, nil
+}
+
+// ReleaseTokenAttempt takes back an attempt counted by ReserveTokenAttempt
+// that turned out not to be a wrong guess.
+func (s Store) ReleaseTokenAttempt(ctx context.Context, tokenID string) error {
+	data := struct {
+		TokenID string `db:"token_id"`
+	}{
+		TokenID: tokenID,
+	}
+
+	const q = `
+	UPDATE
+		verification_tokens
+	SET
+		"attempts" = attempts - 1
+	WHERE
+		token_id = :token_id AND
+		attempts > 0`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("releasing attempt tokenID[%s]: %w", tokenID, err)
+	}
+
+	return nil
+}
+
+// UseToken marks a token as used. ErrDBNotFound is returned if the token was
+// already used or revoked so a token can never be used twice.
+func (s Store) UseToken(ctx context.Context, tokenID string, now time.Time) error {
+	data := struct {
+		TokenID string    `db:"token_id"`
+		Now     time.Time `db:"now"`
+	}{
+		TokenID: tokenID,
+		Now:     now,
+	}
+
+	const q = `
+	UPDATE
+		verification_tokens
+	SET
+		"date_used" = :now
+	WHERE
+		token_id = :token_id AND
+		date_used IS NULL AND
+		date_revoked IS NULL
+	RETURNING
+		token_id`
+
+	var result struct {
+		TokenID string `db:"token_id"`
+	}
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &result); err != nil {
+		return fmt.Errorf("using tokenID[%s]: %w", tokenID, err)
+	}
+
+	return nil
+}
+
+// QueryActiveTokenByHash gets the token with the specified hash if it was
+// issued for the purpose and has been neither used nor revoked. It is for
+// tokens that have to identify the user on their own. Expiry is left to the
+// caller.
+func (s Store) QueryActiveTokenByHash(ctx context.Context, purpose string, hash string) (Token, error) {
+	data := struct {
+		Purpose string `db:"purpose"`
+		Hash    string `db:"token_hash"`
+	}{
+		Purpose: purpose,
+		Hash:    hash,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		verification_tokens
+	WHERE
+		token_hash = :token_hash AND
+		purpose = :purpose AND
+		date_used IS NULL AND
+		date_revoked IS NULL`
+
+	var tkn Token
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &tkn); err != nil {
+		return Token{}, fmt.Errorf("selecting token purpose[%s]: %w", purpose, err)
+	}
+
+	return tkn, nil
+}
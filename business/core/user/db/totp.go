@@ -0,0 +1,198 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+)
+
+// SaveTOTP stores the enrolment of an authenticator app for a user, replacing
+// any enrolment that hasn't been confirmed yet. A confirmed enrolment is left
+// alone and ErrDBNotFound is returned.
+func (s Store) SaveTOTP(ctx context.Context, totp TOTP) error {
+	const q = `
+	INSERT INTO user_totp
+		(user_id, secret, last_step, date_created, date_confirmed)
+	VALUES
+		(:user_id, :secret, :last_step, :date_created, :date_confirmed)
+	ON CONFLICT (user_id) DO UPDATE SET
+		"secret" = EXCLUDED.secret,
+		"last_step" = EXCLUDED.last_step,
+		"date_created" = EXCLUDED.date_created,
+		"date_confirmed" = EXCLUDED.date_confirmed
+	WHERE
+		user_totp.date_confirmed IS NULL
+	RETURNING
+		user_id`
+
+	var result struct {
+		UserID string `db:"user_id"`
+	}
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, totp, &result); err != nil {
+		return fmt.Errorf("saving totp userID[%s]: %w", totp.UserID, err)
+	}
+
+	return nil
+}
+
+// QueryTOTP gets the authenticator app enrolled for a user.
+func (s Store) QueryTOTP(ctx context.Context, userID string) (TOTP, error) {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		user_totp
+	WHERE
+		user_id = :user_id`
+
+	var totp TOTP
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &totp); err != nil {
+		return TOTP{}, fmt.Errorf("selecting totp userID[%s]: %w", userID, err)
+	}
+
+	return totp, nil
+}
+
+// UseTOTPStep records the time step of a code that was accepted, confirming
+// the enrolment if it wasn't already. ErrDBNotFound is returned if a code
+// from the same or a later step was already accepted, so a code can never be
+// used twice.
+func (s Store) UseTOTPStep(ctx context.Context, userID string, step int64, now time.Time) error {
+	data := struct {
+		UserID string    `db:"user_id"`
+		Step   int64     `db:"step"`
+		Now    time.Time `db:"now"`
+	}{
+		UserID: userID,
+		Step:   step,
+		Now:    now,
+	}
+
+	const q = `
+	UPDATE
+		user_totp
+	SET
+		"last_step" = :step,
+		"date_confirmed" = COALESCE(date_confirmed, :now)
+	WHERE
+		user_id = :user_id AND
+		last_step < :step
+	RETURNING
+		user_id`
+
+	var result struct {
+		UserID string `db:"user_id"`
+	}
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &result); err != nil {
+		return fmt.Errorf("using totp step userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// DeleteTOTP removes the authenticator app and recovery codes of a user.
+func (s Store) DeleteTOTP(ctx context.Context, userID string) error {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const q = `
+	DELETE FROM
+		user_totp
+	WHERE
+		user_id = :user_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting totp userID[%s]: %w", userID, err)
+	}
+
+	const codes = `
+	DELETE FROM
+		recovery_codes
+	WHERE
+		user_id = :user_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, codes, data); err != nil {
+		return fmt.Errorf("deleting recovery codes userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps the recovery codes of a user for a new set.
+func (s Store) ReplaceRecoveryCodes(ctx context.Context, userID string, codes []RecoveryCode) error {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const del = `
+	DELETE FROM
+		recovery_codes
+	WHERE
+		user_id = :user_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, del, data); err != nil {
+		return fmt.Errorf("deleting recovery codes userID[%s]: %w", userID, err)
+	}
+
+	const q = `
+	INSERT INTO recovery_codes
+		(user_id, code_hash, date_used)
+	VALUES
+		(:user_id, :code_hash, :date_used)`
+
+	for _, code := range codes {
+		if err := sql.NamedExecContext(ctx, s.log, s.db, q, code); err != nil {
+			return fmt.Errorf("inserting recovery code userID[%s]: %w", userID, err)
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks a recovery code as used. ErrDBNotFound is returned if
+// the user holds no unused code with the hash.
+func (s Store) UseRecoveryCode(ctx context.Context, userID string, hash string, now time.Time) error {
+	data := struct {
+		UserID string    `db:"user_id"`
+		Hash   string    `db:"code_hash"`
+		Now    time.Time `db:"now"`
+	}{
+		UserID: userID,
+		Hash:   hash,
+		Now:    now,
+	}
+
+	const q = `
+	UPDATE
+		recovery_codes
+	SET
+		"date_used" = :now
+	WHERE
+		user_id = :user_id AND
+		code_hash = :code_hash AND
+		date_used IS NULL
+	RETURNING
+		user_id`
+
+	var result struct {
+		UserID string `db:"user_id"`
+	}
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &result); err != nil {
+		return fmt.Errorf("using recovery code userID[%s]: %w", userID, err)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package user
 
 import "context"
 
+// MailSender represents the behavior required to deliver email to a user.
 type MailSender interface {
-	Send(ctx context.Context, email, message string) error
+	Send(ctx context.Context, email string, subject string, body string) error
 }
@@ -0,0 +1,13 @@
+package user
+
+// Set of events the user core publishes.
+const (
+	EventConfirmed = "user.confirmed"
+)
+
+// ConfirmedEvent is published once a user has confirmed their email, or
+// when they are created already confirmed by an identity provider.
+type ConfirmedEvent struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
@@ -0,0 +1,188 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/user/db"
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/colmmurphy91/go-service/foundation/oidc"
+	"github.com/jmoiron/sqlx"
+)
+
+// loginTTL is how long a user has to finish signing in at the identity
+// provider.
+const loginTTL = 10 * time.Minute
+
+// Login holds what is needed to finish a sign in through an external
+// identity provider. The state travels through the provider and comes back
+// on the redirect; the nonce and verifier never leave the service.
+type Login struct {
+	State       string
+	Nonce       string
+	Verifier    string
+	DateExpires time.Time
+}
+
+// BeginLogin starts a sign in through an external identity provider.
+func (c Core) BeginLogin(ctx context.Context, now time.Time) (Login, error) {
+	login := Login{
+		DateExpires: now.Add(loginTTL),
+	}
+	for _, v := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+		var err error
+		if *v, err = oidc.NewVerifier(); err != nil {
+			return Login{}, err
+		}
+	}
+
+	dbLogin := db.Login{
+		StateHash:    hashToken(login.State),
+		Nonce:        login.Nonce,
+		CodeVerifier: login.Verifier,
+		DateCreated:  now,
+		DateExpires:  login.DateExpires,
+	}
+
+	if err := c.store.CreateLogin(ctx, dbLogin); err != nil {
+		return Login{}, fmt.Errorf("create: %w", err)
+	}
+
+	return login, nil
+}
+
+// CompleteLogin finishes the sign in started with the specified state. A sign
+// in can only be finished once.
+func (c Core) CompleteLogin(ctx context.Context, state string, now time.Time) (Login, error) {
+	dbLogin, err := c.store.ConsumeLogin(ctx, hashToken(state))
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return Login{}, ErrLoginInvalid
+		}
+		return Login{}, fmt.Errorf("consume: %w", err)
+	}
+
+	if now.After(dbLogin.DateExpires) {
+		return Login{}, ErrLoginInvalid
+	}
+
+	login := Login{
+		State:    state,
+		Nonce:    dbLogin.Nonce,
+		Verifier: dbLogin.CodeVerifier,
+	}
+
+	return login, nil
+}
+
+// AuthenticateIdentity returns the claims for the user linked to an account
+// with an external identity provider. The first sign in links the account to
+// the user with the same email, creating a user with the USER role if there
+// isn't one. Linking needs an email the provider has verified. The identity
+// provider stands in for the password only, so if the user has two-factor
+// authentication enabled the claims are withheld until a code is given to
+// AuthenticateTwoFactor with the challenge returned instead.
+func (c Core) AuthenticateIdentity(ctx context.Context, ident oidc.Identity, now time.Time) (Authentication, error) {
+	dbIdent, err := c.store.QueryIdentity(ctx, ident.Issuer, ident.Subject)
+	switch {
+	case err == nil:
+		dbUsr, err := c.store.QueryByID(ctx, dbIdent.UserID)
+		if err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return Authentication{}, ErrNotFound
+			}
+			return Authentication{}, fmt.Errorf("query: %w", err)
+		}
+
+		if !dbUsr.Confirmed {
+			return Authentication{}, ErrUserNotConfirmed
+		}
+
+		return c.authentication(ctx, dbUsr, now)
+
+	case !errors.Is(err, sql.ErrDBNotFound):
+		return Authentication{}, fmt.Errorf("query identity: %w", err)
+	}
+
+	if !ident.EmailVerified || ident.Email == "" {
+		return Authentication{}, ErrEmailNotVerified
+	}
+
+	var dbUsr db.User
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		var err error
+		dbUsr, err = store.QueryByEmail(ctx, ident.Email)
+		switch {
+		case err == nil:
+
+			// Whoever registered an email first could take over the account
+			// of the real owner once it's linked, so only link accounts whose
+			// email has been confirmed.
+			if !dbUsr.Confirmed {
+				return ErrUserNotConfirmed
+			}
+
+		case errors.Is(err, sql.ErrDBNotFound):
+			name := ident.Name
+			if name == "" {
+				name = ident.Email
+			}
+
+			// The provider verified the email so there is nothing left to
+			// confirm. There is no password until the user sets one.
+			dbUsr = db.User{
+				ID:          validate.GenerateID(),
+				Name:        name,
+				Email:       ident.Email,
+				Roles:       []string{auth.RoleUser},
+				DateCreated: now,
+				DateUpdated: now,
+				Confirmed:   true,
+			}
+
+			if err := store.Create(ctx, dbUsr); err != nil {
+				if errors.Is(err, sql.ErrDBDuplicatedEntry) {
+					return fmt.Errorf("create: %w", ErrUniqueEmail)
+				}
+				return fmt.Errorf("create: %w", err)
+			}
+
+			if err := c.auditCreate(ctx, tx, dbUsr, now); err != nil {
+				return err
+			}
+
+			if err := c.publishConfirmed(ctx, tx, dbUsr, now); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("query user: %w", err)
+		}
+
+		dbIdent := db.Identity{
+			Issuer:      ident.Issuer,
+			Subject:     ident.Subject,
+			UserID:      dbUsr.ID,
+			Email:       ident.Email,
+			DateCreated: now,
+		}
+
+		if err := store.CreateIdentity(ctx, dbIdent); err != nil {
+			return fmt.Errorf("create identity: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return Authentication{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return c.authentication(ctx, dbUsr, now)
+}
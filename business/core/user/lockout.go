@@ -0,0 +1,204 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// emailFailureLimit is how many failed sign ins an email can have
+	// before each further attempt has to wait.
+	emailFailureLimit = 5
+
+	// ipFailureLimit is how many failed sign ins a client address can have
+	// before each further attempt has to wait. It is higher than the limit
+	// for an email since many users can share an address.
+	ipFailureLimit = 20
+
+	// loginBackoff is the wait after the first failure over a limit. It
+	// doubles with every failure after that.
+	loginBackoff = time.Second
+
+	// maxLoginLockout caps the wait between sign in attempts.
+	maxLoginLockout = 15 * time.Minute
+
+	// loginFailureWindow is how long a failed sign in is remembered.
+	loginFailureWindow = time.Hour
+)
+
+// LockoutError is returned when too many sign ins have failed for an email
+// or from a client address and the caller has to wait before trying again.
+// It matches ErrLoginLocked.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (le *LockoutError) Error() string {
+	return ErrLoginLocked.Error()
+}
+
+// Is reports whether the target is ErrLoginLocked.
+func (le *LockoutError) Is(target error) bool {
+	return target == ErrLoginLocked
+}
+
+// Unlock clears the failed sign ins counted against the email of the
+// specified user, and the wrong codes they gave changing two-factor
+// authentication, so they can try again straight away.
+func (c Core) Unlock(ctx context.Context, userID string) error {
+	if err := validate.CheckID(userID); err != nil {
+		return ErrInvalidID
+	}
+
+	dbUsr, err := c.store.QueryByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("query: %w", err)
+	}
+
+	for _, key := range []string{emailKey(dbUsr.Email), totpKey(dbUsr.ID)} {
+		if err := c.store.DeleteLoginFailure(ctx, key); err != nil {
+			return fmt.Errorf("delete login failure: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// loginLimit is a key failed sign ins are counted against along with how
+// many it can have before attempts have to wait.
+type loginLimit struct {
+	key   string
+	limit int
+}
+
+// loginLimits returns the keys a sign in for the email from the client
+// address is counted against.
+func loginLimits(email string, clientIP string) []loginLimit {
+	limits := []loginLimit{{key: emailKey(email), limit: emailFailureLimit}}
+	if clientIP != "" {
+		limits = append(limits, loginLimit{key: "ip:" + clientIP, limit: ipFailureLimit})
+	}
+	return limits
+}
+
+// reserveLoginAttempt counts a sign in as failed against each of the keys
+// before it is checked, so attempts made at the same time can't all get past
+// the lockout before any of them has failed. Nothing is counted and a
+// LockoutError is returned if any of the keys has failed too often to try
+// again yet, reporting the longest wait. An attempt that turns out not to
+// have failed is taken back with releaseLoginAttempt.
+func (c Core) reserveLoginAttempt(ctx context.Context, limits []loginLimit, now time.Time) error {
+	var wait time.Duration
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		for _, ll := range limits {
+			if err := store.LockLoginFailure(ctx, ll.key); err != nil {
+				return err
+			}
+
+			lf, err := store.QueryLoginFailure(ctx, ll.key)
+			if err != nil {
+				if errors.Is(err, sql.ErrDBNotFound) {
+					continue
+				}
+				return fmt.Errorf("query login failure: %w", err)
+			}
+
+			if now.Sub(lf.DateLastFailure) > loginFailureWindow {
+				continue
+			}
+
+			until := lf.DateLastFailure.Add(lockoutFor(lf.Failures, ll.limit))
+			if d := until.Sub(now); d > wait {
+				wait = d
+			}
+		}
+
+		if wait > 0 {
+			return nil
+		}
+
+		for _, ll := range limits {
+			lf, err := store.RecordLoginFailure(ctx, ll.key, now, loginFailureWindow)
+			if err != nil {
+				return fmt.Errorf("record login failure: %w", err)
+			}
+
+			if lf.Failures >= ll.limit {
+				c.log.Infow("login lockout", "key", ll.key, "failures", lf.Failures, "for", lockoutFor(lf.Failures, ll.limit))
+			}
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
+	}
+
+	if wait > 0 {
+		return &LockoutError{RetryAfter: wait}
+	}
+
+	if err := c.store.PruneLoginFailures(ctx, now.Add(-loginFailureWindow)); err != nil {
+		c.log.Errorw("login lockout", "status", "pruning login failures", "ERROR", err)
+	}
+
+	return nil
+}
+
+// releaseLoginAttempt takes back the attempt reserved against each of the
+// keys for a sign in that did not fail.
+func (c Core) releaseLoginAttempt(ctx context.Context, limits []loginLimit) error {
+	for _, ll := range limits {
+		if err := c.store.UncountLoginFailure(ctx, ll.key); err != nil {
+			return fmt.Errorf("release login attempt: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// lockoutFor returns how long to wait after the last of the specified
+// number of failures before trying again.
+func lockoutFor(failures int, limit int) time.Duration {
+	if failures < limit {
+		return 0
+	}
+
+	d := loginBackoff
+	for i := limit; i < failures && d < maxLoginLockout; i++ {
+		d *= 2
+	}
+
+	if d > maxLoginLockout {
+		d = maxLoginLockout
+	}
+
+	return d
+}
+
+// emailKey returns the key failed sign ins for an email are counted against.
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// totpKey returns the key wrong codes a user gives changing two-factor
+// authentication are counted against.
+func totpKey(userID string) string {
+	return "totp:" + userID
+}
@@ -0,0 +1,105 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"net/url"
+	"text/template"
+)
+
+// templates holds the emails sent to users. Each file defines a "subject"
+// and a "body" template.
+//
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// MailURLs holds the addresses of the pages linked to from emails. The email
+// and token are added to each as query parameters.
+type MailURLs struct {
+	Confirm string
+	Reset   string
+}
+
+// Options represent optional parameters.
+type Options struct {
+	mail MailSender
+	urls MailURLs
+}
+
+// WithMail configures the core to email users.
+func WithMail(sender MailSender, urls MailURLs) func(opts *Options) {
+	return func(opts *Options) {
+		opts.mail = sender
+		opts.urls = urls
+	}
+}
+
+// sendConfirmation emails the user the token needed to confirm their account.
+func (c Core) sendConfirmation(ctx context.Context, usr User, token string) error {
+	return c.sendToken(ctx, usr, token, c.opts.urls.Confirm, "confirm.tmpl")
+}
+
+// sendReset emails the user the token needed to reset their password.
+func (c Core) sendReset(ctx context.Context, usr User, token string) error {
+	return c.sendToken(ctx, usr, token, c.opts.urls.Reset, "reset.tmpl")
+}
+
+// sendToken emails the user a link to the specified page carrying a token.
+// Without a page the user is sent just the token to enter themselves.
+func (c Core) sendToken(ctx context.Context, usr User, token string, page string, name string) error {
+	if c.opts.mail == nil {
+		return nil
+	}
+
+	var link url.URL
+	if page != "" {
+		l, err := url.Parse(page)
+		if err != nil {
+			return fmt.Errorf("parsing url for %s: %w", name, err)
+		}
+
+		q := l.Query()
+		q.Set("email", usr.Email)
+		q.Set("token", token)
+		l.RawQuery = q.Encode()
+		link = *l
+	}
+
+	data := struct {
+		Name  string
+		Email string
+		Token string
+		URL   string
+	}{
+		Name:  usr.Name,
+		Email: usr.Email,
+		Token: token,
+		URL:   link.String(),
+	}
+
+	return c.send(ctx, usr.Email, name, data)
+}
+
+// send renders the subject and body of the named template and emails them.
+func (c Core) send(ctx context.Context, email string, name string, data interface{}) error {
+	tmpl, err := template.ParseFS(templates, "templates/"+name)
+	if err != nil {
+		return fmt.Errorf("parsing template %s: %w", name, err)
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return fmt.Errorf("rendering subject: %w", err)
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return fmt.Errorf("rendering body: %w", err)
+	}
+
+	if err := c.opts.mail.Send(ctx, email, subject.String(), body.String()); err != nil {
+		return fmt.Errorf("sending %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -14,7 +14,6 @@ type User struct {
 	PasswordHash []byte    `json:"-"`
 	DateCreated  time.Time `json:"date_created"`
 	DateUpdated  time.Time `json:"date_updated"`
-	ConfirmHash  int64     `json:"confirm_hash"`
 	Confirmed    bool      `json:"confirmed"`
 }
 
@@ -39,19 +38,20 @@ type UpdateUser struct {
 	Roles           []string `json:"roles"`
 	Password        *string  `json:"password"`
 	PasswordConfirm *string  `json:"password_confirm" validate:"omitempty,eqfield=Password"`
-	ConfirmHash     *int64   `json:"confirm_hash"`
-	Confirmed       *bool    `json:"confirmed"`
+}
+
+// ResetPassword contains information needed to set a new password with a
+// password reset token.
+type ResetPassword struct {
+	Email           string `json:"email" validate:"required,email"`
+	Token           string `json:"token" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
 }
 
 // =============================================================================
 
 func toUser(dbUsr db.User) User {
-	var confirmHash int64
-	if dbUsr.ConfirmHash.Valid {
-		confirmHash = dbUsr.ConfirmHash.Int64
-	} else {
-		confirmHash = 0
-	}
 	return User{
 		ID:          dbUsr.ID,
 		Name:        dbUsr.Name,
@@ -59,7 +59,6 @@ func toUser(dbUsr db.User) User {
 		Roles:       dbUsr.Roles,
 		DateCreated: dbUsr.DateCreated,
 		DateUpdated: dbUsr.DateUpdated,
-		ConfirmHash: confirmHash,
 		Confirmed:   dbUsr.Confirmed,
 	}
 }
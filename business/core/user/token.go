@@ -0,0 +1,138 @@
+package user
+
+import (
+	"context"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/user/db"
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/jmoiron/sqlx"
+)
+
+// Set of purposes a verification token can be issued for. A token only
+// verifies the purpose it was issued for.
+const (
+	purposeConfirm = "confirm"
+	purposeReset   = "reset"
+)
+
+const (
+	// confirmTokenTTL is how long a user has to confirm their email address.
+	confirmTokenTTL = 24 * time.Hour
+
+	// resetTokenTTL is how long a user has to reset their password.
+	resetTokenTTL = time.Hour
+
+	// maxTokenAttempts is how many wrong tokens can be tried before the
+	// outstanding token is locked and a new one has to be requested.
+	maxTokenAttempts = 5
+)
+
+// issueToken revokes the tokens the user already holds for the purpose and
+// stores a new one. The raw token is returned so it can be sent to the user;
+// only its hash is kept.
+func (c Core) issueToken(ctx context.Context, store db.Store, userID string, purpose string, ttl time.Duration, now time.Time) (string, error) {
+	var b [32]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b[:])
+
+	if err := store.RevokeTokens(ctx, userID, purpose, now); err != nil {
+		return "", fmt.Errorf("revoke: %w", err)
+	}
+
+	dbTkn := db.Token{
+		ID:          validate.GenerateID(),
+		UserID:      userID,
+		Purpose:     purpose,
+		Hash:        hashToken(token),
+		DateCreated: now,
+		DateExpires: now.Add(ttl),
+	}
+
+	if err := store.CreateToken(ctx, dbTkn); err != nil {
+		return "", fmt.Errorf("create token: %w", err)
+	}
+
+	return token, nil
+}
+
+// consumeToken checks the token against the one the user holds for the
+// purpose. A wrong token counts as a failed attempt and enough of them lock
+// the outstanding token. The attempt is counted before the token is checked
+// so guesses made at the same time are held to the limit too. On a match the
+// token is marked used and fn runs in the same transaction.
+func (c Core) consumeToken(ctx context.Context, userID string, purpose string, token string, now time.Time, fn func(tx sqlx.ExtContext) error) error {
+	dbTkn, err := c.store.QueryActiveToken(ctx, userID, purpose)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return ErrTokenInvalid
+		}
+		return fmt.Errorf("query token: %w", err)
+	}
+
+	if now.After(dbTkn.DateExpires) {
+		return ErrTokenExpired
+	}
+
+	attempts, err := c.reserveTokenAttempt(ctx, dbTkn.ID)
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(dbTkn.Hash)) != 1 {
+		if attempts >= maxTokenAttempts {
+			return ErrTokenLocked
+		}
+		return ErrTokenInvalid
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.store.Tran(tx).UseToken(ctx, dbTkn.ID, now); err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrTokenInvalid
+			}
+			return fmt.Errorf("use token: %w", err)
+		}
+		return fn(tx)
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		if rerr := c.store.ReleaseTokenAttempt(ctx, dbTkn.ID); rerr != nil {
+			return fmt.Errorf("release attempt: %w", rerr)
+		}
+		return fmt.Errorf("tran: %w", err)
+	}
+
+	return nil
+}
+
+// reserveTokenAttempt counts an attempt against the token before it is
+// checked, returning the number of attempts made so far. ErrTokenLocked is
+// returned once the token has had all the attempts it allows.
+func (c Core) reserveTokenAttempt(ctx context.Context, tokenID string) (int, error) {
+	attempts, err := c.store.ReserveTokenAttempt(ctx, tokenID, maxTokenAttempts)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return 0, ErrTokenLocked
+		}
+		return 0, fmt.Errorf("reserve attempt: %w", err)
+	}
+
+	return attempts, nil
+}
+
+// hashToken returns the value stored in place of a raw token.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
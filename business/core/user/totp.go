@@ -0,0 +1,431 @@
+package user
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/user/db"
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/colmmurphy91/go-service/foundation/totp"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// purposeTwoFactor is the purpose of the token handed out when a password has
+// been checked and a second factor is still needed.
+const purposeTwoFactor = "2fa"
+
+const (
+	// totpIssuer names the service in the user's authenticator app.
+	totpIssuer = "service project"
+
+	// totpSkew is how many time steps either side of now a code is accepted
+	// for, to allow for clocks that drift.
+	totpSkew = 1
+
+	// twoFactorTTL is how long a user has to give their second factor after
+	// giving their password.
+	twoFactorTTL = 5 * time.Minute
+
+	// recoveryCodeCount is how many recovery codes a user is given.
+	recoveryCodeCount = 10
+
+	// totpFailureLimit is how many wrong codes or passwords a user can give
+	// confirming or turning off two-factor authentication before each
+	// further attempt has to wait.
+	totpFailureLimit = 5
+)
+
+// Authentication is the result of checking a user's password. When the user
+// has two-factor authentication enabled the claims are withheld and Challenge
+// holds the token to present along with a code to AuthenticateTwoFactor.
+type Authentication struct {
+	Claims    auth.Claims
+	Challenge string
+}
+
+// TwoFactorRequired reports whether a second factor is needed before the
+// user is signed in.
+func (a Authentication) TwoFactorRequired() bool {
+	return a.Challenge != ""
+}
+
+// TOTPEnrollment holds what a user needs to set up their authenticator app.
+// The URI is usually shown as a QR code. The recovery codes are only ever
+// shown this once.
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// EnrollTOTP starts enrolling an authenticator app as a second factor for the
+// user. Two-factor authentication is only enabled once the enrolment has been
+// confirmed with a code from the app. Enrolling again before that starts over
+// with a new secret and recovery codes.
+func (c Core) EnrollTOTP(ctx context.Context, userID string, now time.Time) (TOTPEnrollment, error) {
+	if err := validate.CheckID(userID); err != nil {
+		return TOTPEnrollment{}, ErrInvalidID
+	}
+
+	dbUsr, err := c.store.QueryByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return TOTPEnrollment{}, ErrNotFound
+		}
+		return TOTPEnrollment{}, fmt.Errorf("query: %w", err)
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	codes, dbCodes, err := newRecoveryCodes(userID)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	dbTOTP := db.TOTP{
+		UserID:      userID,
+		Secret:      secret,
+		DateCreated: now,
+	}
+
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		if err := store.SaveTOTP(ctx, dbTOTP); err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrTwoFactorEnabled
+			}
+			return fmt.Errorf("save totp: %w", err)
+		}
+
+		if err := store.ReplaceRecoveryCodes(ctx, userID, dbCodes); err != nil {
+			return fmt.Errorf("replace recovery codes: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("tran: %w", err)
+	}
+
+	enrollment := TOTPEnrollment{
+		Secret:        secret,
+		URI:           totp.URI(totpIssuer, dbUsr.Email, secret),
+		RecoveryCodes: codes,
+	}
+
+	return enrollment, nil
+}
+
+// ConfirmTOTP enables two-factor authentication for the user if the code
+// comes from the authenticator app they enrolled. Wrong codes are counted
+// against the user and a LockoutError is returned once there are too many.
+func (c Core) ConfirmTOTP(ctx context.Context, userID string, code string, now time.Time) error {
+	confirm := func() error {
+		dbTOTP, err := c.store.QueryTOTP(ctx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrTwoFactorNotEnrolled
+			}
+			return fmt.Errorf("query totp: %w", err)
+		}
+
+		if dbTOTP.DateConfirmed.Valid {
+			return ErrTwoFactorEnabled
+		}
+
+		step, ok := totp.Validate(dbTOTP.Secret, strings.TrimSpace(code), now, totpSkew)
+		if !ok {
+			return ErrCodeInvalid
+		}
+
+		if err := c.store.UseTOTPStep(ctx, userID, step, now); err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrCodeInvalid
+			}
+			return fmt.Errorf("use totp step: %w", err)
+		}
+
+		return nil
+	}
+
+	return c.limitTOTPAttempts(ctx, userID, now, confirm)
+}
+
+// DisableTOTP turns off two-factor authentication for the user once they
+// give their password again. Once it is enabled a code from the app or a
+// recovery code is also needed to turn it off. Wrong passwords and codes are
+// counted against the user and a LockoutError is returned once there are too
+// many.
+func (c Core) DisableTOTP(ctx context.Context, userID string, password string, code string, now time.Time) error {
+	disable := func() error {
+		dbUsr, err := c.store.QueryByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("query: %w", err)
+		}
+
+		if err := bcrypt.CompareHashAndPassword(dbUsr.PasswordHash, []byte(password)); err != nil {
+			return ErrAuthenticationFailure
+		}
+
+		dbTOTP, err := c.store.QueryTOTP(ctx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrTwoFactorNotEnrolled
+			}
+			return fmt.Errorf("query totp: %w", err)
+		}
+
+		tran := func(tx sqlx.ExtContext) error {
+			store := c.store.Tran(tx)
+
+			if dbTOTP.DateConfirmed.Valid {
+				if err := c.useSecondFactor(ctx, store, dbTOTP, code, now); err != nil {
+					return err
+				}
+			}
+
+			if err := store.DeleteTOTP(ctx, userID); err != nil {
+				return fmt.Errorf("delete totp: %w", err)
+			}
+
+			return nil
+		}
+
+		if err := c.store.WithinTran(ctx, tran); err != nil {
+			return fmt.Errorf("tran: %w", err)
+		}
+
+		return nil
+	}
+
+	return c.limitTOTPAttempts(ctx, userID, now, disable)
+}
+
+// AuthenticateTwoFactor finishes a sign in that is waiting on a second
+// factor. The code can come from the authenticator app or be a recovery
+// code. A wrong code counts as a failed attempt against the challenge and
+// enough of them lock it, so the user has to give their password again. The
+// attempt is counted before the code is checked so guesses made at the same
+// time are held to the limit too.
+func (c Core) AuthenticateTwoFactor(ctx context.Context, challenge string, code string, now time.Time) (auth.Claims, error) {
+	dbTkn, err := c.store.QueryActiveTokenByHash(ctx, purposeTwoFactor, hashToken(challenge))
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return auth.Claims{}, ErrTokenInvalid
+		}
+		return auth.Claims{}, fmt.Errorf("query token: %w", err)
+	}
+
+	if now.After(dbTkn.DateExpires) {
+		return auth.Claims{}, ErrTokenExpired
+	}
+
+	// Two-factor authentication may have been turned off since the password
+	// was checked, in which case the user has to sign in again.
+	dbTOTP, err := c.store.QueryTOTP(ctx, dbTkn.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return auth.Claims{}, ErrTokenInvalid
+		}
+		return auth.Claims{}, fmt.Errorf("query totp: %w", err)
+	}
+
+	attempts, err := c.reserveTokenAttempt(ctx, dbTkn.ID)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
+	var dbUsr db.User
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		if err := store.UseToken(ctx, dbTkn.ID, now); err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrTokenInvalid
+			}
+			return fmt.Errorf("use token: %w", err)
+		}
+
+		if err := c.useSecondFactor(ctx, store, dbTOTP, code, now); err != nil {
+			return err
+		}
+
+		var err error
+		if dbUsr, err = store.QueryByID(ctx, dbTkn.UserID); err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("query: %w", err)
+		}
+
+		return nil
+	}
+
+	// The attempt was counted outside of the transaction so a wrong code
+	// stays counted when the transaction rolls back. Anything else that went
+	// wrong wasn't a guess, so the attempt is taken back.
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		if !errors.Is(err, ErrCodeInvalid) {
+			if rerr := c.store.ReleaseTokenAttempt(ctx, dbTkn.ID); rerr != nil {
+				return auth.Claims{}, fmt.Errorf("release attempt: %w", rerr)
+			}
+			return auth.Claims{}, fmt.Errorf("tran: %w", err)
+		}
+
+		if attempts >= maxTokenAttempts {
+			return auth.Claims{}, ErrTokenLocked
+		}
+		return auth.Claims{}, ErrCodeInvalid
+	}
+
+	if !dbUsr.Confirmed {
+		return auth.Claims{}, ErrUserNotConfirmed
+	}
+
+	return claims(dbUsr, now), nil
+}
+
+// =============================================================================
+
+// authentication returns the claims for a user who has given their first
+// factor, or a challenge for AuthenticateTwoFactor in their place when the
+// user has two-factor authentication enabled.
+func (c Core) authentication(ctx context.Context, dbUsr db.User, now time.Time) (Authentication, error) {
+	enabled, err := c.twoFactorEnabled(ctx, dbUsr.ID)
+	if err != nil {
+		return Authentication{}, err
+	}
+
+	if enabled {
+		challenge, err := c.issueToken(ctx, c.store, dbUsr.ID, purposeTwoFactor, twoFactorTTL, now)
+		if err != nil {
+			return Authentication{}, fmt.Errorf("issue challenge: %w", err)
+		}
+		return Authentication{Challenge: challenge}, nil
+	}
+
+	return Authentication{Claims: claims(dbUsr, now)}, nil
+}
+
+// limitTOTPAttempts runs a two-factor change for the user, counting it as a
+// failed attempt against them unless it turns out the password and code
+// they gave were right, so codes can't be guessed. The attempt is counted
+// before fn runs so guesses made at the same time are held to the limit too.
+func (c Core) limitTOTPAttempts(ctx context.Context, userID string, now time.Time, fn func() error) error {
+	if err := validate.CheckID(userID); err != nil {
+		return ErrInvalidID
+	}
+
+	limits := []loginLimit{{key: totpKey(userID), limit: totpFailureLimit}}
+	if err := c.reserveLoginAttempt(ctx, limits, now); err != nil {
+		return err
+	}
+
+	err := fn()
+	switch {
+	case err == nil:
+		if err := c.store.DeleteLoginFailure(ctx, totpKey(userID)); err != nil {
+			return fmt.Errorf("clear login failures: %w", err)
+		}
+		return nil
+
+	case errors.Is(err, ErrCodeInvalid), errors.Is(err, ErrAuthenticationFailure):
+		return err
+
+	default:
+		if rerr := c.releaseLoginAttempt(ctx, limits); rerr != nil {
+			return rerr
+		}
+		return err
+	}
+}
+
+// twoFactorEnabled reports whether the user has confirmed an authenticator
+// app as a second factor.
+func (c Core) twoFactorEnabled(ctx context.Context, userID string) (bool, error) {
+	dbTOTP, err := c.store.QueryTOTP(ctx, userID)
+	switch {
+	case err == nil:
+		return dbTOTP.DateConfirmed.Valid, nil
+	case errors.Is(err, sql.ErrDBNotFound):
+		return false, nil
+	default:
+		return false, fmt.Errorf("query totp: %w", err)
+	}
+}
+
+// useSecondFactor accepts a code from the authenticator app or an unused
+// recovery code, marking it used so it can't be accepted again.
+// ErrCodeInvalid is returned if the code is neither.
+func (c Core) useSecondFactor(ctx context.Context, store db.Store, dbTOTP db.TOTP, code string, now time.Time) error {
+	code = strings.TrimSpace(code)
+
+	if step, ok := totp.Validate(dbTOTP.Secret, code, now, totpSkew); ok {
+		if err := store.UseTOTPStep(ctx, dbTOTP.UserID, step, now); err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrCodeInvalid
+			}
+			return fmt.Errorf("use totp step: %w", err)
+		}
+		return nil
+	}
+
+	if err := store.UseRecoveryCode(ctx, dbTOTP.UserID, hashToken(normalizeRecoveryCode(code)), now); err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return ErrCodeInvalid
+		}
+		return fmt.Errorf("use recovery code: %w", err)
+	}
+
+	return nil
+}
+
+// recoveryEncoding is how recovery codes are written for users.
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns a new set of recovery codes for the user along
+// with the hashes to store in their place.
+func newRecoveryCodes(userID string) ([]string, []db.RecoveryCode, error) {
+	codes := make([]string, recoveryCodeCount)
+	dbCodes := make([]db.RecoveryCode, recoveryCodeCount)
+
+	for i := range codes {
+		var b [5]byte
+		if _, err := crand.Read(b[:]); err != nil {
+			return nil, nil, fmt.Errorf("generating recovery code: %w", err)
+		}
+		code := recoveryEncoding.EncodeToString(b[:])
+
+		codes[i] = code[:4] + "-" + code[4:]
+		dbCodes[i] = db.RecoveryCode{
+			UserID: userID,
+			Hash:   hashToken(code),
+		}
+	}
+
+	return codes, dbCodes, nil
+}
+
+// normalizeRecoveryCode lets users type recovery codes with or without the
+// dash and in either case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	return strings.ToLower(code)
+}
@@ -5,18 +5,16 @@ package user
 
 import (
 	"context"
-	crand "crypto/rand"
 	"database/sql"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	csql "github.com/colmmurphy91/go-service/business/sys/database/sql"
-	"log"
-	"math/rand"
 	"time"
 
+	"github.com/colmmurphy91/go-service/business/core/audit"
 	"github.com/colmmurphy91/go-service/business/core/user/db"
 	"github.com/colmmurphy91/go-service/business/sys/auth"
+	"github.com/colmmurphy91/go-service/business/sys/events"
 	"github.com/colmmurphy91/go-service/business/sys/validate"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/jmoiron/sqlx"
@@ -33,23 +31,49 @@ var (
 	ErrAuthenticationFailure = errors.New("authentication failed")
 	ErrUserNotConfirmed      = errors.New("user is not confirmed")
 	ErrAlreadyConfirmed      = errors.New("user is already confirmed")
+	ErrTokenInvalid          = errors.New("token is not valid")
+	ErrTokenExpired          = errors.New("token has expired")
+	ErrTokenLocked           = errors.New("too many failed attempts, request a new token")
+	ErrLoginInvalid          = errors.New("sign in is not valid or has expired")
+	ErrEmailNotVerified      = errors.New("identity provider has not verified the email")
+	ErrTwoFactorEnabled      = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled  = errors.New("two-factor authentication is not enrolled")
+	ErrCodeInvalid           = errors.New("code is not valid")
+	ErrLoginLocked           = errors.New("too many failed sign ins, try again later")
 )
 
+// entity is the name users are recorded under in the audit log.
+const entity = "user"
+
 // Core manages the set of APIs for user access.
 type Core struct {
-	store db.Store
-	log   *zap.SugaredLogger
+	store  db.Store
+	audit  audit.Core
+	events events.Outbox
+	log    *zap.SugaredLogger
+	opts   Options
 }
 
 // NewCore constructs a core for user api access.
-func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Core {
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB, options ...func(opts *Options)) Core {
+	var opts Options
+	for _, option := range options {
+		option(&opts)
+	}
+
 	return Core{
-		store: db.NewStore(log, sqlxDB),
-		log:   log,
+		store:  db.NewStore(log, sqlxDB),
+		audit:  audit.NewCore(log, sqlxDB),
+		events: events.NewOutbox(log, sqlxDB),
+		log:    log,
+		opts:   opts,
 	}
 }
 
-// Create inserts a new user into the database.
+// Create inserts a new user into the database and emails them the token
+// needed to confirm their account. A failure to send the email is logged but
+// does not fail the call since the user has already been stored; the user can
+// ask for the email to be sent again.
 func (c Core) Create(ctx context.Context, nu NewUser, now time.Time) (User, error) {
 	if err := validate.Check(nu); err != nil {
 		return User{}, fmt.Errorf("validating data: %w", err)
@@ -60,16 +84,6 @@ func (c Core) Create(ctx context.Context, nu NewUser, now time.Time) (User, erro
 		return User{}, fmt.Errorf("generating password hash: %w", err)
 	}
 
-	var src cryptoSource
-	rnd := rand.New(src)
-
-	myNum := rnd.Intn(100000)
-
-	toSave := sql.NullInt64{
-		Int64: int64(myNum),
-		Valid: true,
-	}
-
 	dbUsr := db.User{
 		ID:           validate.GenerateID(),
 		Name:         nu.Name,
@@ -79,10 +93,9 @@ func (c Core) Create(ctx context.Context, nu NewUser, now time.Time) (User, erro
 		DateCreated:  now,
 		DateUpdated:  now,
 		Confirmed:    false,
-		ConfirmHash:  toSave,
 	}
 
-	// This provides an example of how to execute a transaction if required.
+	var token string
 	tran := func(tx sqlx.ExtContext) error {
 		if err := c.store.Tran(tx).Create(ctx, dbUsr); err != nil {
 			if errors.Is(err, csql.ErrDBDuplicatedEntry) {
@@ -90,6 +103,17 @@ func (c Core) Create(ctx context.Context, nu NewUser, now time.Time) (User, erro
 			}
 			return fmt.Errorf("create: %w", err)
 		}
+
+		if err := c.auditCreate(ctx, tx, dbUsr, now); err != nil {
+			return err
+		}
+
+		var err error
+		token, err = c.issueToken(ctx, c.store.Tran(tx), dbUsr.ID, purposeConfirm, confirmTokenTTL, now)
+		if err != nil {
+			return fmt.Errorf("issue token: %w", err)
+		}
+
 		return nil
 	}
 
@@ -97,14 +121,18 @@ func (c Core) Create(ctx context.Context, nu NewUser, now time.Time) (User, erro
 		return User{}, fmt.Errorf("tran: %w", err)
 	}
 
-	if err != nil {
-		return User{}, err
+	usr := toUser(dbUsr)
+
+	if err := c.sendConfirmation(ctx, usr, token); err != nil {
+		c.log.Errorw("create", "status", "sending confirmation email", "userID", usr.ID, "ERROR", err)
 	}
 
-	return toUser(dbUsr), nil
+	return usr, nil
 }
 
-// Update replaces a user document in the database.
+// Update replaces a user document in the database. Changing the email address
+// marks the user as unconfirmed and sends a confirmation email to the new
+// address.
 func (c Core) Update(ctx context.Context, userID string, uu UpdateUser, now time.Time) error {
 	if err := validate.CheckID(userID); err != nil {
 		return ErrInvalidID
@@ -121,12 +149,16 @@ func (c Core) Update(ctx context.Context, userID string, uu UpdateUser, now time
 		}
 		return fmt.Errorf("updating user userID[%s]: %w", userID, err)
 	}
+	before := toUser(dbUsr)
 
+	var emailChanged bool
 	if uu.Name != nil {
 		dbUsr.Name = *uu.Name
 	}
-	if uu.Email != nil {
+	if uu.Email != nil && *uu.Email != dbUsr.Email {
 		dbUsr.Email = *uu.Email
+		dbUsr.Confirmed = false
+		emailChanged = true
 	}
 	if uu.Roles != nil {
 		dbUsr.Roles = uu.Roles
@@ -138,36 +170,89 @@ func (c Core) Update(ctx context.Context, userID string, uu UpdateUser, now time
 		}
 		dbUsr.PasswordHash = pw
 	}
-	if uu.ConfirmHash == nil {
-		dbUsr.ConfirmHash = sql.NullInt64{
-			Valid: false,
+	dbUsr.DateUpdated = now
+
+	var token string
+	tran := func(tx sqlx.ExtContext) error {
+		if err := c.store.Tran(tx).Update(ctx, dbUsr); err != nil {
+			if errors.Is(err, csql.ErrDBDuplicatedEntry) {
+				return fmt.Errorf("updating user userID[%s]: %w", userID, ErrUniqueEmail)
+			}
+			return fmt.Errorf("update: %w", err)
 		}
-	}
 
-	if uu.Confirmed != nil {
-		dbUsr.Confirmed = true
+		ch := audit.Change{
+			Action:   audit.ActionUpdate,
+			Entity:   entity,
+			EntityID: userID,
+			Before:   before,
+			After:    toUser(dbUsr),
+		}
+		if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		if emailChanged {
+			var err error
+			token, err = c.issueToken(ctx, c.store.Tran(tx), dbUsr.ID, purposeConfirm, confirmTokenTTL, now)
+			if err != nil {
+				return fmt.Errorf("issue token: %w", err)
+			}
+		}
+
+		return nil
 	}
 
-	dbUsr.DateUpdated = now
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
+	}
 
-	if err := c.store.Update(ctx, dbUsr); err != nil {
-		if errors.Is(err, csql.ErrDBDuplicatedEntry) {
-			return fmt.Errorf("updating user userID[%s]: %w", userID, ErrUniqueEmail)
+	if emailChanged {
+		if err := c.sendConfirmation(ctx, toUser(dbUsr), token); err != nil {
+			c.log.Errorw("update", "status", "sending confirmation email", "userID", dbUsr.ID, "ERROR", err)
 		}
-		return fmt.Errorf("update: %w", err)
 	}
 
 	return nil
 }
 
-// Delete removes a user from the database.
-func (c Core) Delete(ctx context.Context, userID string) error {
+// Delete removes a user from the database. Deleting a user that does not
+// exist is not an error and is not audited.
+func (c Core) Delete(ctx context.Context, userID string, now time.Time) error {
 	if err := validate.CheckID(userID); err != nil {
 		return ErrInvalidID
 	}
 
-	if err := c.store.Delete(ctx, userID); err != nil {
-		return fmt.Errorf("delete: %w", err)
+	tran := func(tx sqlx.ExtContext) error {
+		store := c.store.Tran(tx)
+
+		dbUsr, err := store.QueryByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, csql.ErrDBNotFound) {
+				return nil
+			}
+			return fmt.Errorf("query: %w", err)
+		}
+
+		if err := store.Delete(ctx, userID); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+
+		ch := audit.Change{
+			Action:   audit.ActionDelete,
+			Entity:   entity,
+			EntityID: userID,
+			Before:   toUser(dbUsr),
+		}
+		if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
 	}
 
 	return nil
@@ -220,45 +305,81 @@ func (c Core) QueryByEmail(ctx context.Context, email string) (User, error) {
 }
 
 // Authenticate finds a user by their email and verifies their password. On
-// success it returns a Claims User representing this user. The claims can be
-// used to generate a token for future authentication.
-func (c Core) Authenticate(ctx context.Context, now time.Time, email, password string) (auth.Claims, error) {
+// success it returns the claims representing this user, which can be used to
+// generate a token for future authentication. If the user has two-factor
+// authentication enabled the claims are withheld until a code is given to
+// AuthenticateTwoFactor with the challenge returned instead.
+//
+// Failed attempts are counted against the email and the client address.
+// Once either has failed too often, attempts have to wait longer after each
+// failure and a LockoutError is returned until they may try again. Each
+// attempt is counted before the password is checked and taken back when it
+// turns out not to have failed, so guesses made at the same time are held to
+// the same limits as guesses made one after another.
+func (c Core) Authenticate(ctx context.Context, now time.Time, clientIP string, email, password string) (Authentication, error) {
+	limits := loginLimits(email, clientIP)
+	if err := c.reserveLoginAttempt(ctx, limits, now); err != nil {
+		return Authentication{}, err
+	}
+
 	dbUsr, err := c.store.QueryByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, csql.ErrDBNotFound) {
-			return auth.Claims{}, ErrNotFound
+			return Authentication{}, ErrNotFound
 		}
-		return auth.Claims{}, fmt.Errorf("query: %w", err)
+		return Authentication{}, fmt.Errorf("query: %w", err)
 	}
 
 	if !dbUsr.Confirmed {
-		return auth.Claims{}, ErrUserNotConfirmed
+		if err := c.releaseLoginAttempt(ctx, limits); err != nil {
+			return Authentication{}, err
+		}
+		return Authentication{}, ErrUserNotConfirmed
 	}
 
 	// Compare the provided password with the saved hash. Use the bcrypt
 	// comparison function so it is cryptographically secure.
 	if err := bcrypt.CompareHashAndPassword(dbUsr.PasswordHash, []byte(password)); err != nil {
-		return auth.Claims{}, ErrAuthenticationFailure
+		return Authentication{}, ErrAuthenticationFailure
 	}
 
-	// If we are this far the request is valid. Create some claims for the user
-	// and generate their token.
-	claims := auth.Claims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   dbUsr.ID,
-			Issuer:    "service project",
-			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		},
-		Roles: dbUsr.Roles,
+	// The failures counted against the client address are left to expire so
+	// signing in to one account can't clear them for guessing at others.
+	if err := c.releaseLoginAttempt(ctx, limits); err != nil {
+		return Authentication{}, err
+	}
+	if err := c.store.DeleteLoginFailure(ctx, emailKey(email)); err != nil {
+		return Authentication{}, fmt.Errorf("clear login failures: %w", err)
 	}
 
-	return claims, nil
+	// If we are this far the password is right. Create some claims for the
+	// user, or a challenge if they still need to give a second factor.
+	return c.authentication(ctx, dbUsr, now)
 }
 
-func (c Core) Confirm(ctx context.Context, email string, token int64) error {
-	dbUser, err := c.store.QueryByEmail(ctx, email)
+// RefreshClaims returns new claims for the specified user so an access token
+// can be reissued against a refresh token. The user must still exist and be
+// confirmed.
+func (c Core) RefreshClaims(ctx context.Context, userID string, now time.Time) (auth.Claims, error) {
+	dbUsr, err := c.store.QueryByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, csql.ErrDBNotFound) {
+			return auth.Claims{}, ErrNotFound
+		}
+		return auth.Claims{}, fmt.Errorf("query: %w", err)
+	}
 
+	if !dbUsr.Confirmed {
+		return auth.Claims{}, ErrUserNotConfirmed
+	}
+
+	return claims(dbUsr, now), nil
+}
+
+// Confirm marks the user with the specified email as confirmed if the token
+// matches the confirmation token they were sent.
+func (c Core) Confirm(ctx context.Context, email string, token string, now time.Time) error {
+	dbUsr, err := c.store.QueryByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, csql.ErrDBNotFound) {
 			return ErrNotFound
@@ -266,40 +387,205 @@ func (c Core) Confirm(ctx context.Context, email string, token int64) error {
 		return fmt.Errorf("query: %w", err)
 	}
 
-	if dbUser.Confirmed {
+	if dbUsr.Confirmed {
 		return ErrAlreadyConfirmed
 	}
 
-	if dbUser.ConfirmHash.Int64 != token {
-		return errors.New("user token is not equal")
+	confirm := func(tx sqlx.ExtContext) error {
+		before := toUser(dbUsr)
+		dbUsr.Confirmed = true
+		dbUsr.DateUpdated = now
+
+		if err := c.store.Tran(tx).Update(ctx, dbUsr); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+
+		ch := audit.Change{
+			Action:   audit.ActionUpdate,
+			Entity:   entity,
+			EntityID: dbUsr.ID,
+			Before:   before,
+			After:    toUser(dbUsr),
+		}
+		if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+
+		return c.publishConfirmed(ctx, tx, dbUsr, now)
 	}
 
-	if dbUser.ConfirmHash.Int64 == token {
-		t := true
-		err := c.Update(ctx, dbUser.ID, UpdateUser{
-			Confirmed:   &t,
-			ConfirmHash: nil,
-		}, time.Now())
+	return c.consumeToken(ctx, dbUsr.ID, purposeConfirm, token, now, confirm)
+}
 
-		if err != nil {
-			return err
+// ResendConfirmation issues a new confirmation token to the user with the
+// specified email and emails it to them. Any token sent before stops working.
+func (c Core) ResendConfirmation(ctx context.Context, email string, now time.Time) error {
+	if !validate.CheckEmail(email) {
+		return ErrInvalidEmail
+	}
+
+	dbUsr, err := c.store.QueryByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, csql.ErrDBNotFound) {
+			return ErrNotFound
 		}
+		return fmt.Errorf("query: %w", err)
+	}
+
+	if dbUsr.Confirmed {
+		return ErrAlreadyConfirmed
+	}
+
+	var token string
+	tran := func(tx sqlx.ExtContext) error {
+		var err error
+		token, err = c.issueToken(ctx, c.store.Tran(tx), dbUsr.ID, purposeConfirm, confirmTokenTTL, now)
+		return err
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
 	}
+
+	if err := c.sendConfirmation(ctx, toUser(dbUsr), token); err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+
 	return nil
 }
 
-type cryptoSource struct{}
+// ForgotPassword issues a password reset token to the user with the specified
+// email and emails it to them. Any reset token sent before stops working.
+func (c Core) ForgotPassword(ctx context.Context, email string, now time.Time) error {
+	if !validate.CheckEmail(email) {
+		return ErrInvalidEmail
+	}
 
-func (s cryptoSource) Seed(seed int64) {}
+	dbUsr, err := c.store.QueryByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, csql.ErrDBNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("query: %w", err)
+	}
 
-func (s cryptoSource) Int63() int64 {
-	return int64(s.Uint64() & ^uint64(1<<63))
+	var token string
+	tran := func(tx sqlx.ExtContext) error {
+		var err error
+		token, err = c.issueToken(ctx, c.store.Tran(tx), dbUsr.ID, purposeReset, resetTokenTTL, now)
+		return err
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
+	}
+
+	if err := c.sendReset(ctx, toUser(dbUsr), token); err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+
+	return nil
 }
 
-func (s cryptoSource) Uint64() (v uint64) {
-	err := binary.Read(crand.Reader, binary.BigEndian, &v)
+// ResetPassword sets a new password for the user if the token matches the
+// reset token they were sent. Every token issued to the user before the reset
+// is revoked so existing sessions have to log in again.
+func (c Core) ResetPassword(ctx context.Context, rp ResetPassword, now time.Time) error {
+	if err := validate.Check(rp); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	dbUsr, err := c.store.QueryByEmail(ctx, rp.Email)
 	if err != nil {
-		log.Fatal(err)
+		if errors.Is(err, csql.ErrDBNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("query: %w", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(rp.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("generating password hash: %w", err)
+	}
+
+	reset := func(tx sqlx.ExtContext) error {
+		dbUsr.PasswordHash = hash
+		dbUsr.DateSessionsRevoked = sql.NullTime{Time: now, Valid: true}
+		dbUsr.DateUpdated = now
+
+		if err := c.store.Tran(tx).Update(ctx, dbUsr); err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+		return nil
+	}
+
+	return c.consumeToken(ctx, dbUsr.ID, purposeReset, rp.Token, now, reset)
+}
+
+// Revoked reports whether the claims were issued to a user who has since been
+// removed or had their sessions revoked. It lets the user core act as the
+// revocation check for auth.
+func (c Core) Revoked(ctx context.Context, claims auth.Claims) (bool, error) {
+	dbUsr, err := c.store.QueryByID(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, csql.ErrDBNotFound) {
+			return true, nil
+		}
+		return false, fmt.Errorf("query: %w", err)
+	}
+
+	if !dbUsr.DateSessionsRevoked.Valid {
+		return false, nil
+	}
+
+	// Tokens carry their issue time in whole seconds.
+	if claims.IssuedAt == nil {
+		return true, nil
+	}
+	revokedAt := dbUsr.DateSessionsRevoked.Time.Truncate(time.Second)
+
+	return claims.IssuedAt.Time.Before(revokedAt), nil
+}
+
+// =============================================================================
+
+// claims constructs the claims for an access token issued to the user.
+func claims(dbUsr db.User, now time.Time) auth.Claims {
+	return auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   dbUsr.ID,
+			Issuer:    "service project",
+			ExpiresAt: jwt.NewNumericDate(now.UTC().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now.UTC()),
+		},
+		Roles: dbUsr.Roles,
 	}
-	return v
+}
+
+// auditCreate records the creation of the user in the audit log.
+func (c Core) auditCreate(ctx context.Context, tx sqlx.ExtContext, dbUsr db.User, now time.Time) error {
+	ch := audit.Change{
+		Action:   audit.ActionCreate,
+		Entity:   entity,
+		EntityID: dbUsr.ID,
+		After:    toUser(dbUsr),
+	}
+	if err := c.audit.Record(ctx, tx, ch, now); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	return nil
+}
+
+// publishConfirmed tells other systems the user has a confirmed email.
+func (c Core) publishConfirmed(ctx context.Context, tx sqlx.ExtContext, dbUsr db.User, now time.Time) error {
+	evt := ConfirmedEvent{
+		UserID: dbUsr.ID,
+		Email:  dbUsr.Email,
+	}
+	if err := c.events.Publish(ctx, tx, EventConfirmed, evt, now); err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+
+	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/colmmurphy91/go-service/business/sys/auth"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,6 +13,10 @@ import (
 	"github.com/colmmurphy91/go-service/business/data/dbschema"
 	"github.com/colmmurphy91/go-service/business/data/dbtest"
 	"github.com/colmmurphy91/go-service/foundation/docker"
+	"github.com/colmmurphy91/go-service/foundation/mail"
+	"github.com/colmmurphy91/go-service/foundation/mail/mailtest"
+	"github.com/colmmurphy91/go-service/foundation/totp"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -33,7 +38,22 @@ func TestUser(t *testing.T) {
 	log, db, teardown := dbtest.NewUnit(t, c, "testuser")
 	t.Cleanup(teardown)
 
-	core := user.NewCore(log, db)
+	smtp, err := mailtest.StartServer()
+	if err != nil {
+		t.Fatalf("Starting SMTP server: %s", err)
+	}
+	t.Cleanup(smtp.Close)
+
+	sender := mail.NewSMTP(mail.SMTPConfig{
+		Host: smtp.Host(),
+		Port: smtp.Port(),
+		From: "noreply@ardanlabs.com",
+	})
+
+	core := user.NewCore(log, db, user.WithMail(sender, user.MailURLs{
+		Confirm: "http://localhost:3000/v1/users/confirm",
+		Reset:   "http://localhost:3000/v1/users/password/reset",
+	}))
 
 	t.Log("Given the need to work with User records.")
 	{
@@ -71,10 +91,12 @@ func TestUser(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould get back the same user.", dbtest.Success, testID)
 
+			first := lastToken(t, smtp, nu.Email)
+			t.Logf("\t%s\tTest %d:\tShould send a confirmation email.", dbtest.Success, testID)
+
 			upd := user.UpdateUser{
-				Name:        dbtest.StringPointer("Jacob Walker"),
-				Email:       dbtest.StringPointer("jacob@ardanlabs.com"),
-				ConfirmHash: &saved.ConfirmHash,
+				Name:  dbtest.StringPointer("Jacob Walker"),
+				Email: dbtest.StringPointer("jacob@ardanlabs.com"),
 			}
 
 			if err := core.Update(ctx, usr.ID, upd, now); err != nil {
@@ -104,31 +126,278 @@ func TestUser(t *testing.T) {
 				t.Logf("\t%s\tTest %d:\tShould be able to see updates to Email.", dbtest.Success, testID)
 			}
 
-			err = core.Confirm(ctx, saved.Email, 1234567)
-			if err == nil {
-				t.Fatalf("\t%s\tTest %d:\tShould not be able to confirm user : %s.", dbtest.Failed, testID, err)
+			second := lastToken(t, smtp, *upd.Email)
+			t.Logf("\t%s\tTest %d:\tShould send a confirmation email to the new address.", dbtest.Success, testID)
+
+			if err := core.Confirm(ctx, saved.Email, first, now); !errors.Is(err, user.ErrTokenInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to confirm with a replaced token : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to confirm with a replaced token.", dbtest.Success, testID)
+
+			if err := core.Confirm(ctx, saved.Email, second, now.Add(25*time.Hour)); !errors.Is(err, user.ErrTokenExpired) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to confirm with an expired token : %v.", dbtest.Failed, testID, err)
 			}
-			t.Logf("\t%s\tTest %d:\tShould not be able to confirm user with incorrect token", dbtest.Success, testID)
+			t.Logf("\t%s\tTest %d:\tShould not be able to confirm with an expired token.", dbtest.Success, testID)
 
-			err = core.Confirm(ctx, saved.Email, saved.ConfirmHash)
-			if err != nil {
-				t.Fatalf("\t%s\tTest %d:\tShould not be able to confirm user : %s.", dbtest.Failed, testID, err)
+			for i := 0; i < 5; i++ {
+				err = core.Confirm(ctx, saved.Email, "wrong", now)
+			}
+			if !errors.Is(err, user.ErrTokenLocked) {
+				t.Fatalf("\t%s\tTest %d:\tShould lock the token after too many attempts : %v.", dbtest.Failed, testID, err)
+			}
+			if err := core.Confirm(ctx, saved.Email, second, now); !errors.Is(err, user.ErrTokenLocked) {
+				t.Fatalf("\t%s\tTest %d:\tShould not accept a locked token : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock the token after too many attempts.", dbtest.Success, testID)
+
+			if err := core.ResendConfirmation(ctx, saved.Email, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to resend the confirmation : %s.", dbtest.Failed, testID, err)
+			}
+			third := lastToken(t, smtp, saved.Email)
+			t.Logf("\t%s\tTest %d:\tShould be able to resend the confirmation.", dbtest.Success, testID)
+
+			if err := core.Confirm(ctx, saved.Email, third, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to confirm user with correct token : %s.", dbtest.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to confirm user with correct token", dbtest.Success, testID)
 
 			confirmed, err := core.QueryByEmail(ctx, saved.Email)
+			if err != nil || !confirmed.Confirmed {
+				t.Fatalf("\t%s\tTest %d:\tUser should be confirmed : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to confirm user", dbtest.Success, testID)
+
+			if err := core.Confirm(ctx, saved.Email, third, now); !errors.Is(err, user.ErrAlreadyConfirmed) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to confirm twice : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to confirm twice.", dbtest.Success, testID)
+
+			authn, err := core.Authenticate(ctx, now, "127.0.0.1", saved.Email, "gophers")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate : %s.", dbtest.Failed, testID, err)
+			}
+			oldClaims := authn.Claims
+			oldClaims.IssuedAt = jwt.NewNumericDate(now.Add(-time.Hour))
 
-			if !confirmed.Confirmed {
-				t.Fatalf("\t%s\tTest %d:\tUser should be confirmed : %s.", dbtest.Failed, testID, err)
+			if err := core.ForgotPassword(ctx, saved.Email, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to request a password reset : %s.", dbtest.Failed, testID, err)
+			}
+			reset := lastToken(t, smtp, saved.Email)
+			t.Logf("\t%s\tTest %d:\tShould send a password reset email.", dbtest.Success, testID)
+
+			rp := user.ResetPassword{
+				Email:           saved.Email,
+				Token:           reset,
+				Password:        "gophers2",
+				PasswordConfirm: "gophers2",
 			}
 
-			if confirmed.ConfirmHash != 0 {
-				t.Fatalf("\t%s\tTest %d:\tHash should be deleted : %s.", dbtest.Failed, testID, err)
+			if err := core.ResetPassword(ctx, rp, now.Add(2*time.Hour)); !errors.Is(err, user.ErrTokenExpired) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to reset with an expired token : %v.", dbtest.Failed, testID, err)
 			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to reset with an expired token.", dbtest.Success, testID)
 
-			t.Logf("\t%s\tTest %d:\tShould be able to confirm user", dbtest.Success, testID)
+			if err := core.ResetPassword(ctx, rp, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reset the password : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to reset the password.", dbtest.Success, testID)
+
+			if err := core.ResetPassword(ctx, rp, now); !errors.Is(err, user.ErrTokenInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to use a reset token twice : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to use a reset token twice.", dbtest.Success, testID)
+
+			if _, err := core.Authenticate(ctx, now, "127.0.0.1", saved.Email, "gophers"); !errors.Is(err, user.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould not authenticate with the old password : %v.", dbtest.Failed, testID, err)
+			}
+			if _, err := core.Authenticate(ctx, now, "127.0.0.1", saved.Email, "gophers2"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould authenticate with the new password : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould authenticate with the new password.", dbtest.Success, testID)
+
+			revoked, err := core.Revoked(ctx, oldClaims)
+			if err != nil || !revoked {
+				t.Fatalf("\t%s\tTest %d:\tShould revoke sessions issued before the reset : %v %v.", dbtest.Failed, testID, revoked, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould revoke sessions issued before the reset.", dbtest.Success, testID)
+
+			enrollment, err := core.EnrollTOTP(ctx, usr.ID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enroll an authenticator app : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to enroll an authenticator app.", dbtest.Success, testID)
+
+			code := func(at time.Time) string {
+				c, err := totp.Code(enrollment.Secret, totp.Step(at))
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to generate a code : %s.", dbtest.Failed, testID, err)
+				}
+				return c
+			}
+
+			if err := core.ConfirmTOTP(ctx, usr.ID, code(now), now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to confirm the authenticator app : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to confirm the authenticator app.", dbtest.Success, testID)
+
+			if _, err := core.EnrollTOTP(ctx, usr.ID, now); !errors.Is(err, user.ErrTwoFactorEnabled) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to enroll twice : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to enroll twice.", dbtest.Success, testID)
+
+			authn, err = core.Authenticate(ctx, now, "127.0.0.1", saved.Email, "gophers2")
+			if err != nil || !authn.TwoFactorRequired() {
+				t.Fatalf("\t%s\tTest %d:\tShould require a second factor : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould require a second factor.", dbtest.Success, testID)
+
+			if _, err := core.AuthenticateTwoFactor(ctx, authn.Challenge, code(now), now); !errors.Is(err, user.ErrCodeInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould not accept a code twice : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not accept a code twice.", dbtest.Success, testID)
+
+			later := now.Add(totp.Period)
+			claims, err := core.AuthenticateTwoFactor(ctx, authn.Challenge, code(later), later)
+			if err != nil || claims.Subject != usr.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould authenticate with a code from the app : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould authenticate with a code from the app.", dbtest.Success, testID)
+
+			if _, err := core.AuthenticateTwoFactor(ctx, authn.Challenge, code(later), later); !errors.Is(err, user.ErrTokenInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to use a challenge twice : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to use a challenge twice.", dbtest.Success, testID)
 
-			if err := core.Delete(ctx, usr.ID); err != nil {
+			authn, err = core.Authenticate(ctx, later, "127.0.0.1", saved.Email, "gophers2")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate : %s.", dbtest.Failed, testID, err)
+			}
+			recovery := strings.ToUpper(enrollment.RecoveryCodes[0])
+			if _, err := core.AuthenticateTwoFactor(ctx, authn.Challenge, recovery, later); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould authenticate with a recovery code : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould authenticate with a recovery code.", dbtest.Success, testID)
+
+			authn, err = core.Authenticate(ctx, later, "127.0.0.1", saved.Email, "gophers2")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to authenticate : %s.", dbtest.Failed, testID, err)
+			}
+
+			const codeGuesses = 10
+			codeErrs := make(chan error, codeGuesses)
+			for i := 0; i < codeGuesses; i++ {
+				go func() {
+					_, err := core.AuthenticateTwoFactor(ctx, authn.Challenge, "not-a-code", later)
+					codeErrs <- err
+				}()
+			}
+
+			var wrong, challengeLocked int
+			for i := 0; i < codeGuesses; i++ {
+				switch err := <-codeErrs; {
+				case errors.Is(err, user.ErrCodeInvalid):
+					wrong++
+				case errors.Is(err, user.ErrTokenLocked):
+					challengeLocked++
+				default:
+					t.Fatalf("\t%s\tTest %d:\tShould reject or lock out guessed codes : %v.", dbtest.Failed, testID, err)
+				}
+			}
+			if wrong != 4 || challengeLocked != codeGuesses-4 {
+				t.Fatalf("\t%s\tTest %d:\tShould lock the challenge against codes guessed at the same time : %d wrong %d locked.", dbtest.Failed, testID, wrong, challengeLocked)
+			}
+			if _, err := core.AuthenticateTwoFactor(ctx, authn.Challenge, enrollment.RecoveryCodes[2], later); !errors.Is(err, user.ErrTokenLocked) {
+				t.Fatalf("\t%s\tTest %d:\tShould not accept a recovery code once the challenge is locked : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock the challenge against codes guessed at the same time.", dbtest.Success, testID)
+
+			if err := core.DisableTOTP(ctx, usr.ID, "wrong", enrollment.RecoveryCodes[1], later); !errors.Is(err, user.ErrAuthenticationFailure) {
+				t.Fatalf("\t%s\tTest %d:\tShould not disable two-factor authentication without the password : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not disable two-factor authentication without the password.", dbtest.Success, testID)
+
+			if err := core.DisableTOTP(ctx, usr.ID, "gophers2", recovery, later); !errors.Is(err, user.ErrCodeInvalid) {
+				t.Fatalf("\t%s\tTest %d:\tShould not accept a recovery code twice : %v.", dbtest.Failed, testID, err)
+			}
+			if err := core.DisableTOTP(ctx, usr.ID, "gophers2", enrollment.RecoveryCodes[1], later); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to disable two-factor authentication : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to disable two-factor authentication.", dbtest.Success, testID)
+
+			authn, err = core.Authenticate(ctx, later, "127.0.0.1", saved.Email, "gophers2")
+			if err != nil || authn.TwoFactorRequired() {
+				t.Fatalf("\t%s\tTest %d:\tShould not require a second factor once disabled : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not require a second factor once disabled.", dbtest.Success, testID)
+
+			if _, err := core.EnrollTOTP(ctx, usr.ID, later); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enroll again : %s.", dbtest.Failed, testID, err)
+			}
+			for i := 0; i < 5; i++ {
+				if err := core.ConfirmTOTP(ctx, usr.ID, "not-a-code", later); !errors.Is(err, user.ErrCodeInvalid) {
+					t.Fatalf("\t%s\tTest %d:\tShould not confirm with a wrong code : %v.", dbtest.Failed, testID, err)
+				}
+			}
+			if err := core.ConfirmTOTP(ctx, usr.ID, "not-a-code", later); !errors.Is(err, user.ErrLoginLocked) {
+				t.Fatalf("\t%s\tTest %d:\tShould lock out confirming after too many wrong codes : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock out confirming after too many wrong codes.", dbtest.Success, testID)
+
+			for i := 0; i < 5; i++ {
+				if _, err := core.Authenticate(ctx, later, "127.0.0.2", saved.Email, "wrong"); !errors.Is(err, user.ErrAuthenticationFailure) {
+					t.Fatalf("\t%s\tTest %d:\tShould not authenticate with a wrong password : %v.", dbtest.Failed, testID, err)
+				}
+			}
+
+			var lockout *user.LockoutError
+			_, err = core.Authenticate(ctx, later, "127.0.0.3", saved.Email, "gophers2")
+			if !errors.Is(err, user.ErrLoginLocked) || !errors.As(err, &lockout) || lockout.RetryAfter <= 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould lock the email out after too many failures : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock the email out after too many failures.", dbtest.Success, testID)
+
+			if _, err := core.Authenticate(ctx, later.Add(lockout.RetryAfter), "127.0.0.3", saved.Email, "gophers2"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould authenticate once the lockout is over : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould authenticate once the lockout is over.", dbtest.Success, testID)
+
+			for i := 0; i < 6; i++ {
+				core.Authenticate(ctx, later, "127.0.0.2", saved.Email, "wrong")
+			}
+			if err := core.Unlock(ctx, usr.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to unlock the user : %s.", dbtest.Failed, testID, err)
+			}
+			if _, err := core.Authenticate(ctx, later, "127.0.0.3", saved.Email, "gophers2"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould authenticate once unlocked : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould authenticate once unlocked.", dbtest.Success, testID)
+
+			const guesses = 10
+			errs := make(chan error, guesses)
+			for i := 0; i < guesses; i++ {
+				go func() {
+					_, err := core.Authenticate(ctx, later, "127.0.0.4", saved.Email, "wrong")
+					errs <- err
+				}()
+			}
+
+			var failures, locked int
+			for i := 0; i < guesses; i++ {
+				switch err := <-errs; {
+				case errors.Is(err, user.ErrAuthenticationFailure):
+					failures++
+				case errors.Is(err, user.ErrLoginLocked):
+					locked++
+				default:
+					t.Fatalf("\t%s\tTest %d:\tShould fail or be locked out guessing : %v.", dbtest.Failed, testID, err)
+				}
+			}
+			if failures != 5 || locked != guesses-5 {
+				t.Fatalf("\t%s\tTest %d:\tShould lock out guesses made at the same time : %d failed %d locked.", dbtest.Failed, testID, failures, locked)
+			}
+			t.Logf("\t%s\tTest %d:\tShould lock out guesses made at the same time.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, usr.ID, now); err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", dbtest.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to delete user.", dbtest.Success, testID)
@@ -191,3 +460,27 @@ func TestPagingUser(t *testing.T) {
 		}
 	}
 }
+
+// lastToken returns the confirmation token from the last email sent to the
+// specified address.
+func lastToken(t *testing.T, smtp *mailtest.Server, email string) string {
+	t.Helper()
+
+	msgs := smtp.Messages()
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].To[0] != email {
+			continue
+		}
+
+		data := msgs[i].Data
+		idx := strings.Index(data, "token=")
+		if idx == -1 {
+			t.Fatalf("\t%s\tShould include the confirm link in the email : %s.", dbtest.Failed, data)
+		}
+
+		return strings.Fields(data[idx+len("token="):])[0]
+	}
+
+	t.Fatalf("\t%s\tShould send an email to %s.", dbtest.Failed, email)
+	return ""
+}
@@ -0,0 +1,324 @@
+// Package db contains webhook related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for webhook access.
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           sql.Transactor
+	db           sqlx.ExtContext
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		tr:  db,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	return sql.WithinTran(ctx, s.log, s.tr, fn)
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// Create adds a Webhook to the database.
+func (s Store) Create(ctx context.Context, wh Webhook) error {
+	const q = `
+	INSERT INTO webhooks
+		(webhook_id, cafe_id, url, event_types, secret, active, date_created, date_updated)
+	VALUES
+		(:webhook_id, :cafe_id, :url, :event_types, :secret, :active, :date_created, :date_updated)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, wh); err != nil {
+		return fmt.Errorf("inserting webhook: %w", err)
+	}
+
+	return nil
+}
+
+// Update modifies the URL, event types and whether a webhook is active.
+func (s Store) Update(ctx context.Context, wh Webhook) error {
+	const q = `
+	UPDATE
+		webhooks
+	SET
+		"url" = :url,
+		"event_types" = :event_types,
+		"active" = :active,
+		"date_updated" = :date_updated
+	WHERE
+		webhook_id = :webhook_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, wh); err != nil {
+		return fmt.Errorf("updating webhookID[%s]: %w", wh.ID, err)
+	}
+
+	return nil
+}
+
+// Delete removes a webhook along with its deliveries.
+func (s Store) Delete(ctx context.Context, webhookID string) error {
+	data := struct {
+		WebhookID string `db:"webhook_id"`
+	}{
+		WebhookID: webhookID,
+	}
+
+	const q = `
+	DELETE FROM
+		webhooks
+	WHERE
+		webhook_id = :webhook_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting webhookID[%s]: %w", webhookID, err)
+	}
+
+	return nil
+}
+
+// QueryByID gets the specified webhook from the database.
+func (s Store) QueryByID(ctx context.Context, webhookID string) (Webhook, error) {
+	data := struct {
+		WebhookID string `db:"webhook_id"`
+	}{
+		WebhookID: webhookID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		webhooks
+	WHERE
+		webhook_id = :webhook_id`
+
+	var wh Webhook
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &wh); err != nil {
+		return Webhook{}, fmt.Errorf("selecting webhookID[%q]: %w", webhookID, err)
+	}
+
+	return wh, nil
+}
+
+// QueryByCafeID gets the webhooks registered by a cafe.
+func (s Store) QueryByCafeID(ctx context.Context, cafeID string) ([]Webhook, error) {
+	data := struct {
+		CafeID string `db:"cafe_id"`
+	}{
+		CafeID: cafeID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		webhooks
+	WHERE
+		cafe_id = :cafe_id
+	ORDER BY
+		date_created`
+
+	var whs []Webhook
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &whs); err != nil {
+		return nil, fmt.Errorf("selecting webhooks cafeID[%s]: %w", cafeID, err)
+	}
+
+	return whs, nil
+}
+
+// QuerySubscribed gets the active webhooks of the cafes that subscribe to
+// the specified type of event.
+func (s Store) QuerySubscribed(ctx context.Context, cafeIDs []string, eventType string) ([]Webhook, error) {
+	data := struct {
+		CafeIDs   pq.StringArray `db:"cafe_ids"`
+		EventType string         `db:"event_type"`
+	}{
+		CafeIDs:   cafeIDs,
+		EventType: eventType,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		webhooks
+	WHERE
+		active AND
+		CAST(cafe_id AS TEXT) = ANY(:cafe_ids) AND
+		:event_type = ANY(event_types)`
+
+	var whs []Webhook
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &whs); err != nil {
+		return nil, fmt.Errorf("selecting subscribed webhooks: %w", err)
+	}
+
+	return whs, nil
+}
+
+// CreateDelivery adds a delivery of an event to a webhook. An event is only
+// ever queued for a webhook once, so adding it again does nothing.
+func (s Store) CreateDelivery(ctx context.Context, d Delivery) error {
+	const q = `
+	INSERT INTO webhook_deliveries
+		(delivery_id, webhook_id, event_id, event_type, payload, status, attempts, date_created, date_next_attempt)
+	VALUES
+		(:delivery_id, :webhook_id, :event_id, :event_type, :payload, :status, :attempts, :date_created, :date_next_attempt)
+	ON CONFLICT (webhook_id, event_id) DO NOTHING`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, d); err != nil {
+		return fmt.Errorf("inserting delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimDeliveries takes up to limit pending deliveries that are due, oldest
+// first, and holds them until leaseUntil so no other dispatcher sends them
+// in the meantime.
+func (s Store) ClaimDeliveries(ctx context.Context, status string, now time.Time, leaseUntil time.Time, limit int) ([]Delivery, error) {
+	data := struct {
+		Status     string    `db:"status"`
+		Now        time.Time `db:"now"`
+		LeaseUntil time.Time `db:"lease_until"`
+		Limit      int       `db:"limit"`
+	}{
+		Status:     status,
+		Now:        now,
+		LeaseUntil: leaseUntil,
+		Limit:      limit,
+	}
+
+	const q = `
+	UPDATE
+		webhook_deliveries
+	SET
+		"attempts" = attempts + 1,
+		"date_next_attempt" = :lease_until
+	WHERE
+		delivery_id IN (
+			SELECT
+				delivery_id
+			FROM
+				webhook_deliveries
+			WHERE
+				status = :status AND
+				date_next_attempt <= :now
+			ORDER BY
+				date_created
+			LIMIT :limit
+			FOR UPDATE SKIP LOCKED
+		)
+	RETURNING
+		*`
+
+	var ds []Delivery
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &ds); err != nil {
+		return nil, fmt.Errorf("claiming deliveries: %w", err)
+	}
+
+	return ds, nil
+}
+
+// UpdateDelivery records the outcome of an attempt to send a delivery.
+func (s Store) UpdateDelivery(ctx context.Context, d Delivery) error {
+	const q = `
+	UPDATE
+		webhook_deliveries
+	SET
+		"status" = :status,
+		"attempts" = :attempts,
+		"response_code" = :response_code,
+		"last_error" = :last_error,
+		"date_next_attempt" = :date_next_attempt,
+		"date_completed" = :date_completed
+	WHERE
+		delivery_id = :delivery_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, d); err != nil {
+		return fmt.Errorf("updating deliveryID[%s]: %w", d.ID, err)
+	}
+
+	return nil
+}
+
+// QueryDeliveryByID gets the specified delivery from the database.
+func (s Store) QueryDeliveryByID(ctx context.Context, deliveryID string) (Delivery, error) {
+	data := struct {
+		DeliveryID string `db:"delivery_id"`
+	}{
+		DeliveryID: deliveryID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		webhook_deliveries
+	WHERE
+		delivery_id = :delivery_id`
+
+	var d Delivery
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &d); err != nil {
+		return Delivery{}, fmt.Errorf("selecting deliveryID[%q]: %w", deliveryID, err)
+	}
+
+	return d, nil
+}
+
+// QueryDeliveries gets the deliveries to a webhook, newest first.
+func (s Store) QueryDeliveries(ctx context.Context, webhookID string, pageNumber int, rowsPerPage int) ([]Delivery, error) {
+	data := struct {
+		WebhookID   string `db:"webhook_id"`
+		Offset      int    `db:"offset"`
+		RowsPerPage int    `db:"rows_per_page"`
+	}{
+		WebhookID:   webhookID,
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		webhook_deliveries
+	WHERE
+		webhook_id = :webhook_id
+	ORDER BY
+		date_created DESC, delivery_id
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var ds []Delivery
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &ds); err != nil {
+		return nil, fmt.Errorf("selecting deliveries webhookID[%s]: %w", webhookID, err)
+	}
+
+	return ds, nil
+}
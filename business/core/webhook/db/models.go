@@ -0,0 +1,37 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Webhook represents a URL a cafe wants events sent to.
+type Webhook struct {
+	ID          string         `db:"webhook_id"`
+	CafeID      string         `db:"cafe_id"`
+	URL         string         `db:"url"`
+	EventTypes  pq.StringArray `db:"event_types"`
+	Secret      string         `db:"secret"`
+	Active      bool           `db:"active"`
+	DateCreated time.Time      `db:"date_created"`
+	DateUpdated time.Time      `db:"date_updated"`
+}
+
+// Delivery represents sending an event to a webhook, along with how the
+// last attempt went. Payload holds the JSON body that is sent.
+type Delivery struct {
+	ID              string         `db:"delivery_id"`
+	WebhookID       string         `db:"webhook_id"`
+	EventID         string         `db:"event_id"`
+	EventType       string         `db:"event_type"`
+	Payload         string         `db:"payload"`
+	Status          string         `db:"status"`
+	Attempts        int            `db:"attempts"`
+	ResponseCode    sql.NullInt32  `db:"response_code"`
+	LastError       sql.NullString `db:"last_error"`
+	DateCreated     time.Time      `db:"date_created"`
+	DateNextAttempt time.Time      `db:"date_next_attempt"`
+	DateCompleted   sql.NullTime   `db:"date_completed"`
+}
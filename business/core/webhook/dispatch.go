@@ -0,0 +1,289 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	gosql "database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/webhook/db"
+	"github.com/colmmurphy91/go-service/foundation/webhook"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// DispatchJob is the key the dispatcher is registered under with a worker.
+const DispatchJob = "webhook-dispatch"
+
+// DispatchConfig controls how often the dispatcher looks for deliveries and
+// how it retries them. Zero values take the defaults.
+type DispatchConfig struct {
+	Interval    time.Duration // How long to wait when there is nothing to send.
+	BatchSize   int           // How many deliveries to claim at a time.
+	Timeout     time.Duration // How long a receiver has to answer.
+	MaxAttempts int           // How many times to try a delivery before giving up.
+	Backoff     time.Duration // The wait after the first failure, doubling after each one.
+	MaxBackoff  time.Duration // The longest wait between attempts.
+
+	// AllowPrivate lets deliveries be sent to loopback and private
+	// addresses, for local development and tests.
+	AllowPrivate bool
+}
+
+// errPrivateAddr is returned when a delivery would be sent to an address
+// that is not on the public internet.
+var errPrivateAddr = errors.New("address is not public")
+
+// Dispatcher sends pending deliveries to their webhooks. Any number of
+// dispatchers can run against the same database.
+type Dispatcher struct {
+	log    *zap.SugaredLogger
+	store  db.Store
+	client *http.Client
+	cfg    DispatchConfig
+}
+
+// NewDispatcher constructs a dispatcher for webhook deliveries.
+func NewDispatcher(log *zap.SugaredLogger, sqlxDB *sqlx.DB, cfg DispatchConfig) *Dispatcher {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 30 * time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 6 * time.Hour
+	}
+
+	// The address is checked as each connection is made, once the host name
+	// has resolved, so a webhook can't be pointed inside our own network by
+	// changing what its host name resolves to after it was registered. A
+	// proxy would be connected to in place of the receiver, so none is used.
+	dialer := net.Dialer{
+		Timeout:   cfg.Timeout,
+		KeepAlive: 30 * time.Second,
+	}
+	if !cfg.AllowPrivate {
+		dialer.Control = publicOnly
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	// Redirects are not followed so a receiver can't point deliveries at
+	// somewhere else.
+	client := http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &Dispatcher{
+		log:    log,
+		store:  db.NewStore(log, sqlxDB),
+		client: &client,
+		cfg:    cfg,
+	}
+}
+
+// Run sends deliveries until the context is cancelled. It has the signature
+// of a worker job so it can be started by a worker.
+func (d *Dispatcher) Run(ctx context.Context, traceID string, payload interface{}) (interface{}, error) {
+	d.log.Infow("webhook dispatch", "traceid", traceID, "status", "started")
+	defer d.log.Infow("webhook dispatch", "traceid", traceID, "status", "stopped")
+
+	for {
+		n, err := d.Dispatch(ctx, time.Now())
+		if err != nil {
+			d.log.Errorw("webhook dispatch", "traceid", traceID, "ERROR", err)
+		}
+
+		// Keep going straight away while there are full batches waiting.
+		wait := d.cfg.Interval
+		if err == nil && n == d.cfg.BatchSize {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Dispatch claims a batch of deliveries that are due and sends them
+// concurrently, returning once they have all been sent. It returns how many
+// deliveries were claimed.
+func (d *Dispatcher) Dispatch(ctx context.Context, now time.Time) (int, error) {
+
+	// A delivery is held for long enough to send it before another
+	// dispatcher may pick it up again. The whole batch is sent at once, so
+	// this doesn't depend on how many were claimed.
+	lease := now.Add(2 * d.cfg.Timeout)
+
+	dbDs, err := d.store.ClaimDeliveries(ctx, DeliveryPending, now, lease, d.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("claim: %w", err)
+	}
+
+	dbWhs := make(map[string]db.Webhook)
+	for _, dbD := range dbDs {
+		if _, ok := dbWhs[dbD.WebhookID]; ok {
+			continue
+		}
+
+		dbWh, err := d.store.QueryByID(ctx, dbD.WebhookID)
+		if err != nil {
+			return len(dbDs), fmt.Errorf("query webhook: %w", err)
+		}
+		dbWhs[dbD.WebhookID] = dbWh
+	}
+
+	errs := make([]error, len(dbDs))
+
+	var wg sync.WaitGroup
+	wg.Add(len(dbDs))
+	for i, dbD := range dbDs {
+		go func(i int, dbD db.Delivery) {
+			defer wg.Done()
+			errs[i] = d.dispatch(ctx, dbWhs[dbD.WebhookID], dbD, now)
+		}(i, dbD)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return len(dbDs), err
+		}
+	}
+
+	return len(dbDs), nil
+}
+
+// =============================================================================
+
+// dispatch sends the delivery and records the outcome.
+func (d *Dispatcher) dispatch(ctx context.Context, dbWh db.Webhook, dbD db.Delivery, now time.Time) error {
+	if !dbWh.Active {
+		dbD.Status = DeliveryFailed
+		dbD.LastError = gosql.NullString{String: "webhook is not active", Valid: true}
+		dbD.DateCompleted = gosql.NullTime{Time: now, Valid: true}
+		return d.update(ctx, dbD)
+	}
+
+	code, err := d.send(ctx, dbWh, dbD)
+	dbD.ResponseCode = gosql.NullInt32{Int32: int32(code), Valid: code != 0}
+
+	switch {
+	case err == nil:
+		dbD.Status = DeliverySucceeded
+		dbD.LastError = gosql.NullString{}
+		dbD.DateCompleted = gosql.NullTime{Time: now, Valid: true}
+
+	case dbD.Attempts >= d.cfg.MaxAttempts:
+		d.log.Infow("webhook dispatch", "status", "giving up", "delivery", dbD.ID, "webhook", dbWh.ID, "attempts", dbD.Attempts, "ERROR", err)
+		dbD.Status = DeliveryFailed
+		dbD.LastError = gosql.NullString{String: err.Error(), Valid: true}
+		dbD.DateCompleted = gosql.NullTime{Time: now, Valid: true}
+
+	default:
+		dbD.LastError = gosql.NullString{String: err.Error(), Valid: true}
+		dbD.DateNextAttempt = now.Add(d.backoff(dbD.Attempts))
+	}
+
+	return d.update(ctx, dbD)
+}
+
+// send posts the delivery to the webhook, signed with its secret. It returns
+// the response code when the receiver answered, and an error unless that
+// code reports success. The signature is made with the time of sending, as
+// receivers reject signatures that are too old.
+func (d *Dispatcher) send(ctx context.Context, dbWh db.Webhook, dbD db.Delivery) (int, error) {
+	body := []byte(dbD.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dbWh.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("new request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sales-api-webhooks")
+	req.Header.Set("Webhook-Id", dbD.EventID)
+	req.Header.Set("Webhook-Event", dbD.EventType)
+	req.Header.Set("Webhook-Delivery", dbD.ID)
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(dbWh.Secret, time.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("post: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// publicOnly refuses connections to addresses that are not on the public
+// internet. It has the signature of a net.Dialer Control function, which is
+// called with the resolved address just before connecting.
+func publicOnly(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return errPrivateAddr
+	}
+
+	return nil
+}
+
+// update records the outcome of an attempt.
+func (d *Dispatcher) update(ctx context.Context, dbD db.Delivery) error {
+	if err := d.store.UpdateDelivery(ctx, dbD); err != nil {
+		return fmt.Errorf("update delivery: %w", err)
+	}
+	return nil
+}
+
+// backoff returns how long to wait after the specified number of failed
+// attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.Backoff
+	for i := 1; i < attempts && wait < d.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	if wait > d.cfg.MaxBackoff {
+		wait = d.cfg.MaxBackoff
+	}
+
+	return wait
+}
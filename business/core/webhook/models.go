@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/webhook/db"
+)
+
+// Webhook represents a URL a cafe wants events sent to. The secret requests
+// are signed with is only given out when the webhook is created.
+type Webhook struct {
+	ID          string    `json:"id"`
+	CafeID      string    `json:"cafe_id"`
+	URL         string    `json:"url"`
+	EventTypes  []string  `json:"event_types"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"secret,omitempty"`
+	DateCreated time.Time `json:"date_created"`
+	DateUpdated time.Time `json:"date_updated"`
+}
+
+// NewWebhook contains information needed to register a webhook.
+type NewWebhook struct {
+	URL        string   `json:"url" validate:"required,url"`
+	EventTypes []string `json:"event_types" validate:"required,min=1"`
+}
+
+// UpdateWebhook defines what information may be provided to modify an
+// existing Webhook. All fields are optional so clients can send just the
+// fields they want changed.
+type UpdateWebhook struct {
+	URL        *string  `json:"url" validate:"omitempty,url"`
+	EventTypes []string `json:"event_types" validate:"omitempty,min=1"`
+	Active     *bool    `json:"active"`
+}
+
+// Set of statuses a delivery can be in.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Delivery represents sending an event to a webhook. It records how the last
+// attempt went, with the response code when the receiver answered.
+type Delivery struct {
+	ID              string          `json:"id"`
+	WebhookID       string          `json:"webhook_id"`
+	EventID         string          `json:"event_id"`
+	EventType       string          `json:"event_type"`
+	Payload         json.RawMessage `json:"payload"`
+	Status          string          `json:"status"`
+	Attempts        int             `json:"attempts"`
+	ResponseCode    int             `json:"response_code,omitempty"`
+	LastError       string          `json:"last_error,omitempty"`
+	DateCreated     time.Time       `json:"date_created"`
+	DateNextAttempt time.Time       `json:"date_next_attempt"`
+	DateCompleted   time.Time       `json:"date_completed"` // Zero while the delivery is pending.
+}
+
+// =============================================================================
+
+func toWebhook(dbWh db.Webhook) Webhook {
+	return Webhook{
+		ID:          dbWh.ID,
+		CafeID:      dbWh.CafeID,
+		URL:         dbWh.URL,
+		EventTypes:  dbWh.EventTypes,
+		Active:      dbWh.Active,
+		DateCreated: dbWh.DateCreated,
+		DateUpdated: dbWh.DateUpdated,
+	}
+}
+
+func toWebhookSlice(dbWhs []db.Webhook) []Webhook {
+	whs := make([]Webhook, len(dbWhs))
+	for i, dbWh := range dbWhs {
+		whs[i] = toWebhook(dbWh)
+	}
+	return whs
+}
+
+func toDelivery(dbD db.Delivery) Delivery {
+	return Delivery{
+		ID:              dbD.ID,
+		WebhookID:       dbD.WebhookID,
+		EventID:         dbD.EventID,
+		EventType:       dbD.EventType,
+		Payload:         json.RawMessage(dbD.Payload),
+		Status:          dbD.Status,
+		Attempts:        dbD.Attempts,
+		ResponseCode:    int(dbD.ResponseCode.Int32),
+		LastError:       dbD.LastError.String,
+		DateCreated:     dbD.DateCreated,
+		DateNextAttempt: dbD.DateNextAttempt,
+		DateCompleted:   dbD.DateCompleted.Time,
+	}
+}
+
+func toDeliverySlice(dbDs []db.Delivery) []Delivery {
+	ds := make([]Delivery, len(dbDs))
+	for i, dbD := range dbDs {
+		ds[i] = toDelivery(dbD)
+	}
+	return ds
+}
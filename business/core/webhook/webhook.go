@@ -0,0 +1,450 @@
+// Package webhook provides the core business API for cafes to have events
+// sent to their own systems. Events published to the outbox are queued as a
+// delivery for every webhook subscribed to them, and the dispatcher sends the
+// deliveries signed with the secret of the webhook.
+package webhook
+
+import (
+	"context"
+	gosql "database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	cafedb "github.com/colmmurphy91/go-service/business/core/cafev2/db"
+	"github.com/colmmurphy91/go-service/business/core/order"
+	"github.com/colmmurphy91/go-service/business/core/product"
+	"github.com/colmmurphy91/go-service/business/core/user"
+	"github.com/colmmurphy91/go-service/business/core/webhook/db"
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/events"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/colmmurphy91/go-service/foundation/webhook"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound         = errors.New("webhook not found")
+	ErrInvalidID        = errors.New("ID is not in its proper form")
+	ErrInvalidURL       = errors.New("webhook url must be a public http or https url")
+	ErrInvalidEventType = errors.New("event type can't be subscribed to")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// EventTypes are the events a webhook can subscribe to.
+var EventTypes = []string{
+	order.EventPlaced,
+	order.EventStatusChanged,
+	product.EventStockChanged,
+	product.EventSoldOut,
+	user.EventConfirmed,
+}
+
+// Options represent optional parameters.
+type Options struct {
+	allowPrivate bool
+}
+
+// WithPrivateAddrs lets webhooks be registered at loopback and private
+// addresses, for local development and tests.
+func WithPrivateAddrs() func(opts *Options) {
+	return func(opts *Options) {
+		opts.allowPrivate = true
+	}
+}
+
+// Core manages the set of APIs for webhook access.
+type Core struct {
+	store db.Store
+	cafe  cafedb.Store
+	opts  Options
+}
+
+// NewCore constructs a core for webhook api access.
+func NewCore(log *zap.SugaredLogger, sqlxDB *sqlx.DB, options ...func(opts *Options)) Core {
+	var opts Options
+	for _, option := range options {
+		option(&opts)
+	}
+
+	return Core{
+		store: db.NewStore(log, sqlxDB),
+		cafe:  cafedb.NewStore(log, sqlxDB),
+		opts:  opts,
+	}
+}
+
+// Create registers a webhook for the cafe. The webhook returned holds the
+// secret its requests are signed with, which is not given out again.
+func (c Core) Create(ctx context.Context, cafeID string, nw NewWebhook, now time.Time) (Webhook, error) {
+	if err := validate.CheckID(cafeID); err != nil {
+		return Webhook{}, ErrInvalidID
+	}
+
+	if err := validate.Check(nw); err != nil {
+		return Webhook{}, fmt.Errorf("validating data: %w", err)
+	}
+
+	if err := c.checkURL(nw.URL); err != nil {
+		return Webhook{}, err
+	}
+
+	if err := checkEventTypes(nw.EventTypes); err != nil {
+		return Webhook{}, err
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	dbWh := db.Webhook{
+		ID:          validate.GenerateID(),
+		CafeID:      cafeID,
+		URL:         nw.URL,
+		EventTypes:  nw.EventTypes,
+		Secret:      secret,
+		Active:      true,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := c.store.Create(ctx, dbWh); err != nil {
+		return Webhook{}, fmt.Errorf("create: %w", err)
+	}
+
+	wh := toWebhook(dbWh)
+	wh.Secret = secret
+
+	return wh, nil
+}
+
+// Update modifies a webhook. Deliveries already queued for it are sent to
+// the new URL.
+func (c Core) Update(ctx context.Context, webhookID string, uw UpdateWebhook, now time.Time) error {
+	if err := validate.Check(uw); err != nil {
+		return fmt.Errorf("validating data: %w", err)
+	}
+
+	dbWh, err := c.queryByID(ctx, webhookID)
+	if err != nil {
+		return err
+	}
+
+	if uw.URL != nil {
+		if err := c.checkURL(*uw.URL); err != nil {
+			return err
+		}
+		dbWh.URL = *uw.URL
+	}
+	if uw.EventTypes != nil {
+		if err := checkEventTypes(uw.EventTypes); err != nil {
+			return err
+		}
+		dbWh.EventTypes = uw.EventTypes
+	}
+	if uw.Active != nil {
+		dbWh.Active = *uw.Active
+	}
+	dbWh.DateUpdated = now
+
+	if err := c.store.Update(ctx, dbWh); err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a webhook along with its deliveries.
+func (c Core) Delete(ctx context.Context, webhookID string) error {
+	if err := validate.CheckID(webhookID); err != nil {
+		return ErrInvalidID
+	}
+
+	if err := c.store.Delete(ctx, webhookID); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+// QueryByID finds the webhook identified by a given ID.
+func (c Core) QueryByID(ctx context.Context, webhookID string) (Webhook, error) {
+	dbWh, err := c.queryByID(ctx, webhookID)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	return toWebhook(dbWh), nil
+}
+
+// QueryByCafeID finds the webhooks registered by a cafe.
+func (c Core) QueryByCafeID(ctx context.Context, cafeID string) ([]Webhook, error) {
+	if err := validate.CheckID(cafeID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	dbWhs, err := c.store.QueryByCafeID(ctx, cafeID)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toWebhookSlice(dbWhs), nil
+}
+
+// QueryDeliveries gets the deliveries to a webhook, newest first.
+func (c Core) QueryDeliveries(ctx context.Context, webhookID string, pageNumber int, rowsPerPage int) ([]Delivery, error) {
+	if err := validate.CheckID(webhookID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	dbDs, err := c.store.QueryDeliveries(ctx, webhookID, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toDeliverySlice(dbDs), nil
+}
+
+// Redeliver queues a delivery to the webhook to be sent again straight away
+// with the same body, whatever happened to it before. It gets a fresh set
+// of attempts.
+func (c Core) Redeliver(ctx context.Context, webhookID string, deliveryID string, now time.Time) (Delivery, error) {
+	if err := validate.CheckID(deliveryID); err != nil {
+		return Delivery{}, ErrInvalidID
+	}
+
+	dbD, err := c.store.QueryDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return Delivery{}, ErrDeliveryNotFound
+		}
+		return Delivery{}, fmt.Errorf("query: %w", err)
+	}
+
+	if dbD.WebhookID != webhookID {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+
+	dbD.Status = DeliveryPending
+	dbD.Attempts = 0
+	dbD.DateNextAttempt = now
+	dbD.DateCompleted = gosql.NullTime{}
+
+	if err := c.store.UpdateDelivery(ctx, dbD); err != nil {
+		return Delivery{}, fmt.Errorf("update: %w", err)
+	}
+
+	return toDelivery(dbD), nil
+}
+
+// Enqueue queues a delivery of the event for every active webhook of the
+// cafes it concerns that subscribes to it. It is registered as an events
+// handler for each of the EventTypes. Queuing the same event again does not
+// queue it twice.
+func (c Core) Enqueue(ctx context.Context, evt events.Event) error {
+	cafeIDs, err := c.cafesFor(ctx, evt)
+	if err != nil {
+		return err
+	}
+
+	if len(cafeIDs) == 0 {
+		return nil
+	}
+
+	dbWhs, err := c.store.QuerySubscribed(ctx, cafeIDs, evt.Type)
+	if err != nil {
+		return fmt.Errorf("query subscribed: %w", err)
+	}
+
+	if len(dbWhs) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(payload{
+		ID:      evt.ID,
+		Type:    evt.Type,
+		Created: evt.DateCreated,
+		Data:    evt.Payload,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	now := time.Now()
+
+	tran := func(tx sqlx.ExtContext) error {
+		for _, dbWh := range dbWhs {
+			dbD := db.Delivery{
+				ID:              validate.GenerateID(),
+				WebhookID:       dbWh.ID,
+				EventID:         evt.ID,
+				EventType:       evt.Type,
+				Payload:         string(body),
+				Status:          DeliveryPending,
+				DateCreated:     now,
+				DateNextAttempt: now,
+			}
+
+			if err := c.store.Tran(tx).CreateDelivery(ctx, dbD); err != nil {
+				return fmt.Errorf("create delivery: %w", err)
+			}
+		}
+
+		return nil
+	}
+
+	if err := c.store.WithinTran(ctx, tran); err != nil {
+		return fmt.Errorf("tran: %w", err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// payload is the body sent to a webhook.
+type payload struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Created time.Time       `json:"created"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// queryByID finds the webhook identified by a given ID.
+func (c Core) queryByID(ctx context.Context, webhookID string) (db.Webhook, error) {
+	if err := validate.CheckID(webhookID); err != nil {
+		return db.Webhook{}, ErrInvalidID
+	}
+
+	dbWh, err := c.store.QueryByID(ctx, webhookID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return db.Webhook{}, ErrNotFound
+		}
+		return db.Webhook{}, fmt.Errorf("query: %w", err)
+	}
+
+	return dbWh, nil
+}
+
+// cafesFor returns the cafes the event concerns. Orders concern the cafe
+// they were placed at, products the cafe of the user selling them and users
+// the cafe they own and those they are on the staff of.
+func (c Core) cafesFor(ctx context.Context, evt events.Event) ([]string, error) {
+	switch evt.Type {
+	case order.EventPlaced, order.EventStatusChanged:
+		var oe order.OrderEvent
+		if err := evt.Decode(&oe); err != nil {
+			return nil, err
+		}
+		return []string{oe.Order.CafeID}, nil
+
+	case product.EventStockChanged, product.EventSoldOut:
+		var pe struct {
+			UserID string `json:"user_id"`
+		}
+		if err := evt.Decode(&pe); err != nil {
+			return nil, err
+		}
+		return c.ownedCafes(ctx, pe.UserID)
+
+	case user.EventConfirmed:
+		var ue user.ConfirmedEvent
+		if err := evt.Decode(&ue); err != nil {
+			return nil, err
+		}
+
+		cafeIDs, err := c.ownedCafes(ctx, ue.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		dbMems, err := c.cafe.QueryMembershipsByUserID(ctx, ue.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("query memberships: %w", err)
+		}
+		for _, dbMem := range dbMems {
+			cafeIDs = append(cafeIDs, dbMem.CafeID)
+		}
+		return cafeIDs, nil
+	}
+
+	return nil, nil
+}
+
+// ownedCafes returns the cafe owned by the user, if they have one.
+func (c Core) ownedCafes(ctx context.Context, userID string) ([]string, error) {
+	dbCaf, err := c.cafe.QueryByOwnerID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query cafe: %w", err)
+	}
+
+	return []string{dbCaf.ID}, nil
+}
+
+// checkURL makes sure deliveries are only ever made over http, and not to
+// an address inside our own network unless that was allowed. Host names can
+// resolve to a different address later, so the dispatcher checks the address
+// again each time it connects.
+func (c Core) checkURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+
+	if c.opts.allowPrivate {
+		return nil
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrInvalidURL
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return ErrInvalidURL
+	}
+
+	return nil
+}
+
+// publicIP reports whether the address is on the public internet rather
+// than being loopback, private, link-local, multicast or unspecified.
+func publicIP(ip net.IP) bool {
+	switch {
+	case ip.IsLoopback(), ip.IsPrivate(), ip.IsUnspecified(),
+		ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast(),
+		ip.IsInterfaceLocalMulticast(), ip.IsMulticast():
+		return false
+	}
+
+	// The carrier-grade NAT range is shared address space, not the internet.
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return false
+	}
+
+	return true
+}
+
+// checkEventTypes makes sure every event type can be subscribed to.
+func checkEventTypes(eventTypes []string) error {
+next:
+	for _, et := range eventTypes {
+		for _, known := range EventTypes {
+			if et == known {
+				continue next
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrInvalidEventType, et)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/core/cafev2"
+	"github.com/colmmurphy91/go-service/business/core/product"
+	"github.com/colmmurphy91/go-service/business/core/webhook"
+	"github.com/colmmurphy91/go-service/business/data/dbtest"
+	"github.com/colmmurphy91/go-service/business/sys/events"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/colmmurphy91/go-service/foundation/docker"
+	signing "github.com/colmmurphy91/go-service/foundation/webhook"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func TestWebhook(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testwebhook")
+	t.Cleanup(teardown)
+
+	core := webhook.NewCore(log, db, webhook.WithPrivateAddrs())
+	cafeCore := cafev2.NewCore(log, db)
+
+	const ownerID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2e"
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	t.Log("Given the need to deliver events to a cafe's webhooks.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a signed delivery.", testID)
+		{
+			var (
+				gotBody   []byte
+				gotHeader string
+				status    = http.StatusInternalServerError
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotBody, _ = io.ReadAll(r.Body)
+				gotHeader = r.Header.Get(signing.SignatureHeader)
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			caf, err := cafeCore.Create(ctx, cafev2.NewCafe{Name: "Gopher Beans", Address: "1 Main Street"}, ownerID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a cafe : %s.", dbtest.Failed, testID, err)
+			}
+
+			nw := webhook.NewWebhook{
+				URL:        srv.URL,
+				EventTypes: []string{product.EventStockChanged},
+			}
+
+			wh, err := core.Create(ctx, caf.ID, nw, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a webhook : %s.", dbtest.Failed, testID, err)
+			}
+			if wh.Secret == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould return the secret on create.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to create a webhook.", dbtest.Success, testID)
+
+			saved, err := core.QueryByID(ctx, wh.ID)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve webhook by ID: %s.", dbtest.Failed, testID, err)
+			}
+			if saved.Secret != "" {
+				t.Fatalf("\t%s\tTest %d:\tShould not return the secret after create.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not return the secret after create.", dbtest.Success, testID)
+
+			data, err := json.Marshal(product.StockChangedEvent{ProductID: validate.GenerateID(), UserID: ownerID, Quantity: 3})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to marshal the event : %s.", dbtest.Failed, testID, err)
+			}
+			evt := events.Event{
+				ID:          validate.GenerateID(),
+				Type:        product.EventStockChanged,
+				Payload:     data,
+				DateCreated: now,
+			}
+
+			for i := 0; i < 2; i++ {
+				if err := core.Enqueue(ctx, evt); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to enqueue the event : %s.", dbtest.Failed, testID, err)
+				}
+			}
+
+			dispatcher := webhook.NewDispatcher(log, db, webhook.DispatchConfig{Backoff: time.Minute, AllowPrivate: true})
+			sendAt := time.Now().Add(time.Second)
+
+			n, err := dispatcher.Dispatch(ctx, sendAt)
+			if err != nil || n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould send an event once however often it is queued : %d %v.", dbtest.Failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould send an event once however often it is queued.", dbtest.Success, testID)
+
+			if err := signing.Verify(wh.Secret, gotHeader, gotBody, sendAt, time.Minute); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould sign the delivery with the webhook secret : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould sign the delivery with the webhook secret.", dbtest.Success, testID)
+
+			if n, err := dispatcher.Dispatch(ctx, sendAt); err != nil || n != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould wait before retrying a failed delivery : %d %v.", dbtest.Failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould wait before retrying a failed delivery.", dbtest.Success, testID)
+
+			status = http.StatusOK
+			if n, err := dispatcher.Dispatch(ctx, sendAt.Add(time.Minute)); err != nil || n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould retry a failed delivery after the backoff : %d %v.", dbtest.Failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould retry a failed delivery after the backoff.", dbtest.Success, testID)
+
+			ds, err := core.QueryDeliveries(ctx, wh.ID, 1, 10)
+			if err != nil || len(ds) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the delivery log : %d %v.", dbtest.Failed, testID, len(ds), err)
+			}
+			if ds[0].Status != webhook.DeliverySucceeded || ds[0].Attempts != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould log the delivery as succeeded : %+v.", dbtest.Failed, testID, ds[0])
+			}
+			t.Logf("\t%s\tTest %d:\tShould log the delivery as succeeded.", dbtest.Success, testID)
+
+			if _, err := core.Redeliver(ctx, wh.ID, ds[0].ID, sendAt); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to redeliver : %s.", dbtest.Failed, testID, err)
+			}
+			if n, err := dispatcher.Dispatch(ctx, sendAt.Add(2*time.Minute)); err != nil || n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould send a redelivery : %d %v.", dbtest.Failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould send a redelivery.", dbtest.Success, testID)
+
+			gotBody = nil
+			rd, err := core.Redeliver(ctx, wh.ID, ds[0].ID, sendAt.Add(2*time.Minute))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to redeliver : %s.", dbtest.Failed, testID, err)
+			}
+
+			strict := webhook.NewDispatcher(log, db, webhook.DispatchConfig{Backoff: time.Minute})
+			if n, err := strict.Dispatch(ctx, sendAt.Add(3*time.Minute)); err != nil || n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould attempt the redelivery : %d %v.", dbtest.Failed, testID, n, err)
+			}
+
+			ds, err = core.QueryDeliveries(ctx, wh.ID, 1, 10)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to query the delivery log : %s.", dbtest.Failed, testID, err)
+			}
+			for _, d := range ds {
+				if d.ID == rd.ID && (gotBody != nil || d.Status == webhook.DeliverySucceeded || d.LastError == "") {
+					t.Fatalf("\t%s\tTest %d:\tShould not connect to a private address : %+v.", dbtest.Failed, testID, d)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould not connect to a private address.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen registering a webhook inside our own network.", testID)
+		{
+			public := webhook.NewCore(log, db)
+
+			caf, err := cafeCore.Create(ctx, cafev2.NewCafe{Name: "Gopher Grounds", Address: "2 Main Street"}, ownerID, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create a cafe : %s.", dbtest.Failed, testID, err)
+			}
+
+			urls := []string{
+				"http://localhost:3000/hook",
+				"http://127.0.0.1/hook",
+				"http://10.0.0.1/hook",
+				"http://169.254.169.254/latest/meta-data",
+				"http://0.0.0.0/hook",
+				"http://[::1]/hook",
+			}
+			for _, u := range urls {
+				nw := webhook.NewWebhook{
+					URL:        u,
+					EventTypes: []string{product.EventStockChanged},
+				}
+				if _, err := public.Create(ctx, caf.ID, nw, now); !errors.Is(err, webhook.ErrInvalidURL) {
+					t.Fatalf("\t%s\tTest %d:\tShould refuse the url %s : %v.", dbtest.Failed, testID, u, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse urls that are not public.", dbtest.Success, testID)
+		}
+	}
+}
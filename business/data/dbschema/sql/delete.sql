@@ -1,3 +1,24 @@
 DELETE FROM sales;
 DELETE FROM products;
+DELETE FROM order_items;
+DELETE FROM orders;
+DELETE FROM webhook_deliveries;
+DELETE FROM webhooks;
+DELETE FROM api_keys;
+DELETE FROM cafe_members;
+DELETE FROM menu_items;
+DELETE FROM cafes;
+DELETE FROM verification_tokens;
+DELETE FROM refresh_tokens;
+DELETE FROM revoked_tokens;
+DELETE FROM user_identities;
+DELETE FROM oidc_logins;
+DELETE FROM user_totp;
+DELETE FROM recovery_codes;
+DELETE FROM login_failures;
+DELETE FROM rate_limits;
+DELETE FROM audit_log;
+DELETE FROM outbox;
+DELETE FROM jobs;
+DELETE FROM job_schedules;
 DELETE FROM users;
\ No newline at end of file
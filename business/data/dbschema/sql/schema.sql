@@ -59,3 +59,347 @@ CREATE TABLE cafes
     PRIMARY KEY (cafe_id),
     FOREIGN KEY (owner_id) REFERENCES users (user_id)
 );
+
+-- Version: 1.5
+-- Description: Track refunded sales
+ALTER TABLE sales
+    ADD COLUMN date_refunded TIMESTAMP;
+
+-- Version: 1.6
+-- Description: Create table menu_items
+CREATE TABLE menu_items
+(
+    menu_item_id UUID,
+    cafe_id      UUID,
+    name         TEXT,
+    description  TEXT,
+    category     TEXT,
+    price        INT,
+    available    BOOL NOT NULL DEFAULT TRUE,
+    position     INT  NOT NULL DEFAULT 0,
+    date_created TIMESTAMP,
+    date_updated TIMESTAMP,
+
+    PRIMARY KEY (menu_item_id),
+    FOREIGN KEY (cafe_id) REFERENCES cafes (cafe_id) ON DELETE CASCADE
+);
+
+-- Version: 1.7
+-- Description: Create tables orders and order_items
+CREATE TABLE orders
+(
+    order_id       UUID,
+    cafe_id        UUID,
+    customer_id    UUID,
+    status         TEXT,
+    total          INT,
+    note           TEXT,
+    date_placed    TIMESTAMP,
+    date_accepted  TIMESTAMP,
+    date_preparing TIMESTAMP,
+    date_ready     TIMESTAMP,
+    date_collected TIMESTAMP,
+    date_cancelled TIMESTAMP,
+    date_updated   TIMESTAMP,
+
+    PRIMARY KEY (order_id),
+    FOREIGN KEY (cafe_id) REFERENCES cafes (cafe_id) ON DELETE CASCADE,
+    FOREIGN KEY (customer_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE order_items
+(
+    order_item_id UUID,
+    order_id      UUID,
+    menu_item_id  UUID,
+    name          TEXT,
+    price         INT,
+    quantity      INT,
+
+    PRIMARY KEY (order_item_id),
+    FOREIGN KEY (order_id) REFERENCES orders (order_id) ON DELETE CASCADE,
+    FOREIGN KEY (menu_item_id) REFERENCES menu_items (menu_item_id) ON DELETE SET NULL
+);
+
+-- Version: 1.8
+-- Description: Replace confirm_hash with expiring verification tokens
+CREATE TABLE verification_tokens
+(
+    token_id     UUID,
+    user_id      UUID,
+    purpose      TEXT,
+    token_hash   TEXT UNIQUE,
+    attempts     INT NOT NULL DEFAULT 0,
+    date_created TIMESTAMP,
+    date_expires TIMESTAMP,
+    date_used    TIMESTAMP,
+    date_revoked TIMESTAMP,
+
+    PRIMARY KEY (token_id),
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX verification_tokens_user_purpose ON verification_tokens (user_id, purpose);
+
+ALTER TABLE users DROP COLUMN confirm_hash;
+
+-- Version: 1.9
+-- Description: Track when a user's sessions were last revoked
+ALTER TABLE users ADD COLUMN date_sessions_revoked TIMESTAMP;
+
+-- Version: 1.10
+-- Description: Create tables refresh_tokens and revoked_tokens
+CREATE TABLE refresh_tokens
+(
+    refresh_token_id   UUID,
+    family_id          UUID,
+    user_id            UUID,
+    token_hash         TEXT UNIQUE,
+    date_authenticated TIMESTAMP,
+    date_created       TIMESTAMP,
+    date_expires       TIMESTAMP,
+    date_used          TIMESTAMP,
+    date_revoked       TIMESTAMP,
+
+    PRIMARY KEY (refresh_token_id),
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX refresh_tokens_family ON refresh_tokens (family_id);
+
+CREATE TABLE revoked_tokens
+(
+    jti          TEXT,
+    date_expires TIMESTAMP,
+
+    PRIMARY KEY (jti)
+);
+
+-- Version: 1.11
+-- Description: Create table api_keys
+CREATE TABLE api_keys
+(
+    api_key_id     UUID,
+    user_id        UUID,
+    cafe_id        UUID,
+    name           TEXT,
+    prefix         TEXT,
+    key_hash       TEXT UNIQUE,
+    roles          TEXT[],
+    date_created   TIMESTAMP,
+    date_expires   TIMESTAMP,
+    date_last_used TIMESTAMP,
+    date_revoked   TIMESTAMP,
+
+    PRIMARY KEY (api_key_id),
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE,
+    FOREIGN KEY (cafe_id) REFERENCES cafes (cafe_id) ON DELETE CASCADE
+);
+
+-- Version: 1.12
+-- Description: Create table cafe_members
+CREATE TABLE cafe_members
+(
+    cafe_id       UUID,
+    user_id       UUID,
+    role          TEXT,
+    invited_by    UUID,
+    date_invited  TIMESTAMP,
+    date_accepted TIMESTAMP,
+
+    PRIMARY KEY (cafe_id, user_id),
+    FOREIGN KEY (cafe_id) REFERENCES cafes (cafe_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX cafe_members_user ON cafe_members (user_id);
+
+-- Version: 1.13
+-- Description: Create tables user_identities and oidc_logins
+CREATE TABLE user_identities
+(
+    issuer       TEXT,
+    subject      TEXT,
+    user_id      UUID,
+    email        TEXT,
+    date_created TIMESTAMP,
+
+    PRIMARY KEY (issuer, subject),
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE oidc_logins
+(
+    state_hash    TEXT,
+    nonce         TEXT,
+    code_verifier TEXT,
+    date_created  TIMESTAMP,
+    date_expires  TIMESTAMP,
+
+    PRIMARY KEY (state_hash)
+);
+
+-- Version: 1.14
+-- Description: Create tables user_totp and recovery_codes
+CREATE TABLE user_totp
+(
+    user_id        UUID,
+    secret         TEXT,
+    last_step      BIGINT NOT NULL DEFAULT 0,
+    date_created   TIMESTAMP,
+    date_confirmed TIMESTAMP,
+
+    PRIMARY KEY (user_id),
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes
+(
+    user_id   UUID,
+    code_hash TEXT,
+    date_used TIMESTAMP,
+
+    PRIMARY KEY (user_id, code_hash),
+    FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE
+);
+
+-- Version: 1.15
+-- Description: Create table login_failures
+CREATE TABLE login_failures
+(
+    failure_key       TEXT,
+    failures          INT NOT NULL DEFAULT 0,
+    date_last_failure TIMESTAMP,
+
+    PRIMARY KEY (failure_key)
+);
+
+-- Version: 1.16
+-- Description: Create table rate_limits
+CREATE TABLE rate_limits
+(
+    limit_key    TEXT,
+    tokens       DOUBLE PRECISION,
+    date_updated TIMESTAMP,
+    date_full    TIMESTAMP,
+
+    PRIMARY KEY (limit_key)
+);
+
+-- Version: 1.17
+-- Description: Create table audit_log
+CREATE TABLE audit_log
+(
+    audit_id     UUID,
+    actor_id     TEXT,
+    trace_id     TEXT,
+    action       TEXT,
+    entity       TEXT,
+    entity_id    TEXT,
+    before       JSONB,
+    after        JSONB,
+    date_created TIMESTAMP,
+
+    PRIMARY KEY (audit_id)
+);
+
+CREATE INDEX audit_log_entity ON audit_log (entity, entity_id);
+CREATE INDEX audit_log_actor ON audit_log (actor_id);
+
+-- Version: 1.18
+-- Description: Create table outbox
+CREATE TABLE outbox
+(
+    event_id          UUID,
+    event_type        TEXT,
+    trace_id          TEXT,
+    payload           JSONB,
+    attempts          INT,
+    last_error        TEXT NULL,
+    date_created      TIMESTAMP,
+    date_next_attempt TIMESTAMP,
+    date_delivered    TIMESTAMP NULL,
+    date_failed       TIMESTAMP NULL,
+
+    PRIMARY KEY (event_id)
+);
+
+CREATE INDEX outbox_pending ON outbox (date_next_attempt) WHERE date_delivered IS NULL AND date_failed IS NULL;
+
+-- Version: 1.19
+-- Description: Create tables webhooks and webhook_deliveries
+CREATE TABLE webhooks
+(
+    webhook_id   UUID,
+    cafe_id      UUID,
+    url          TEXT,
+    event_types  TEXT[],
+    secret       TEXT,
+    active       BOOLEAN,
+    date_created TIMESTAMP,
+    date_updated TIMESTAMP,
+
+    PRIMARY KEY (webhook_id),
+    FOREIGN KEY (cafe_id) REFERENCES cafes (cafe_id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhooks_cafe ON webhooks (cafe_id);
+
+CREATE TABLE webhook_deliveries
+(
+    delivery_id       UUID,
+    webhook_id        UUID,
+    event_id          UUID,
+    event_type        TEXT,
+    payload           JSONB,
+    status            TEXT,
+    attempts          INT,
+    response_code     INT NULL,
+    last_error        TEXT NULL,
+    date_created      TIMESTAMP,
+    date_next_attempt TIMESTAMP,
+    date_completed    TIMESTAMP NULL,
+
+    PRIMARY KEY (delivery_id),
+    UNIQUE (webhook_id, event_id),
+    FOREIGN KEY (webhook_id) REFERENCES webhooks (webhook_id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_deliveries_pending ON webhook_deliveries (date_next_attempt) WHERE status = 'pending';
+
+-- Version: 1.20
+-- Description: Create tables jobs and job_schedules
+CREATE TABLE jobs
+(
+    job_id           UUID,
+    job_type         TEXT,
+    trace_id         TEXT,
+    payload          JSONB,
+    status           TEXT,
+    attempts         INT,
+    max_attempts     INT,
+    last_error       TEXT NULL,
+    schedule_name    TEXT NULL,
+    date_created     TIMESTAMP,
+    date_run_at      TIMESTAMP,
+    date_lease_until TIMESTAMP NULL,
+    date_started     TIMESTAMP NULL,
+    date_completed   TIMESTAMP NULL,
+
+    PRIMARY KEY (job_id)
+);
+
+CREATE INDEX jobs_due ON jobs (date_run_at) WHERE status IN ('queued', 'running');
+CREATE INDEX jobs_status ON jobs (status, date_created);
+
+CREATE TABLE job_schedules
+(
+    schedule_name TEXT,
+    job_type      TEXT,
+    spec          TEXT,
+    payload       JSONB,
+    date_next_run TIMESTAMP,
+    date_updated  TIMESTAMP,
+
+    PRIMARY KEY (schedule_name)
+);
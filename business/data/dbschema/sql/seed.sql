@@ -1,7 +1,11 @@
-INSERT INTO users (user_id, name, email, roles, password_hash, date_created, date_updated, confirmed, confirm_hash) VALUES
-	('5cf37266-3473-4006-984f-9325122678b7', 'Admin Gopher', 'admin@example.com', '{ADMIN,USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', '2019-03-24 00:00:00', '2019-03-24 00:00:00', TRUE, NULL),
-	('45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'User Gopher', 'user@example.com', '{USER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', '2019-03-24 00:00:00', '2019-03-24 00:00:00', TRUE, NULL),
-    ('45b5fbd3-755f-4379-8f07-a58d4a30fa2e', 'User Gopher', 'notconfirmed@example.com', '{OWNER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', '2019-03-24 00:00:00', '2019-03-24 00:00:00', FALSE, 12345)
+INSERT INTO users (user_id, name, email, roles, password_hash, date_created, date_updated, confirmed) VALUES
+	('5cf37266-3473-4006-984f-9325122678b7', 'Admin Gopher', 'admin@example.com', '{ADMIN,USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', '2019-03-24 00:00:00', '2019-03-24 00:00:00', TRUE),
+	('45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'User Gopher', 'user@example.com', '{USER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', '2019-03-24 00:00:00', '2019-03-24 00:00:00', TRUE),
+    ('45b5fbd3-755f-4379-8f07-a58d4a30fa2e', 'User Gopher', 'notconfirmed@example.com', '{OWNER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', '2019-03-24 00:00:00', '2019-03-24 00:00:00', FALSE)
+	ON CONFLICT DO NOTHING;
+
+INSERT INTO verification_tokens (token_id, user_id, purpose, token_hash, attempts, date_created, date_expires) VALUES
+	('c1d6e0b5-3c55-4d0c-9a8e-2f1f7f0f6a11', '45b5fbd3-755f-4379-8f07-a58d4a30fa2e', 'confirm', '5994471abb01112afcc18159f6cc74b4f511b99806da59b3caf5a9c173cacfc5', 0, '2019-03-24 00:00:00', '2100-01-01 00:00:00')
 	ON CONFLICT DO NOTHING;
 
 INSERT INTO products (product_id, user_id, name, cost, quantity, date_created, date_updated) VALUES
@@ -5,6 +5,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
@@ -13,10 +14,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/colmmurphy91/go-service/business/core/apikey"
+	"github.com/colmmurphy91/go-service/business/core/user"
 	dbUser "github.com/colmmurphy91/go-service/business/core/user/db"
 
 	"github.com/colmmurphy91/go-service/business/data/dbschema"
 	"github.com/colmmurphy91/go-service/business/sys/auth"
+	authdb "github.com/colmmurphy91/go-service/business/sys/auth/db"
 	"github.com/colmmurphy91/go-service/foundation/docker"
 	"github.com/colmmurphy91/go-service/foundation/keystore"
 	"github.com/jmoiron/sqlx"
@@ -148,7 +152,13 @@ func NewIntegration(t *testing.T, c *docker.Container, dbName string) *Test {
 	}
 
 	// Build an authenticator using this private key and id for the key store.
-	auth, err := auth.New(keyID, keystore.NewMap(map[string]*rsa.PrivateKey{keyID: privateKey}))
+	auth, err := auth.New(
+		keyID,
+		keystore.NewMap(map[string]crypto.PrivateKey{keyID: privateKey}),
+		auth.WithStore(authdb.NewStore(log, db)),
+		auth.WithRevocationChecker(user.NewCore(log, db)),
+		auth.WithAPIKeys(apikey.NewCore(log, db)),
+	)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -205,3 +215,10 @@ func StringPointer(s string) *string {
 func IntPointer(i int) *int {
 	return &i
 }
+
+// BoolPointer is a helper to get a *bool from a bool. It is in the tests
+// package because we normally don't want to deal with pointers to basic types
+// but it's useful in some tests.
+func BoolPointer(b bool) *bool {
+	return &b
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
@@ -83,7 +84,7 @@ func NewMongoIntegration(t *testing.T, c *docker.Container, dbName string) *Mong
 	}
 
 	// Build an authenticator using this private key and id for the key store.
-	auth1, err := auth.New(keyID, keystore.NewMap(map[string]*rsa.PrivateKey{keyID: privateKey}))
+	auth1, err := auth.New(keyID, keystore.NewMap(map[string]crypto.PrivateKey{keyID: privateKey}))
 
 	if err != nil {
 		t.Fatal(err)
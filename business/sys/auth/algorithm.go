@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// These are the signing algorithms tokens can be signed with.
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// SupportedAlgorithms is the set of signing algorithms Auth understands.
+var SupportedAlgorithms = []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA}
+
+// algorithm returns the signing algorithm a private or public key is used
+// with. RSA keys sign RS256, ECDSA P-256 keys sign ES256 and Ed25519 keys sign
+// EdDSA.
+func algorithm(key interface{}) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return AlgorithmRS256, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve == elliptic.P256() {
+			return AlgorithmES256, nil
+		}
+	case *ecdsa.PublicKey:
+		if k.Curve == elliptic.P256() {
+			return AlgorithmES256, nil
+		}
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return AlgorithmEdDSA, nil
+	}
+
+	return "", fmt.Errorf("unsupported key type %T", key)
+}
+
+// signingMethod returns the jwt signing method for a private key.
+func signingMethod(privateKey interface{}) (jwt.SigningMethod, error) {
+	alg, err := algorithm(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, fmt.Errorf("configuring algorithm %s", alg)
+	}
+
+	return method, nil
+}
+
+// checkSigningKey checks the key behind the kid can be used to sign new tokens
+// with one of the accepted algorithms and returns its signing method.
+func (a *Auth) checkSigningKey(kid string) (jwt.SigningMethod, error) {
+	privateKey, err := a.keyLookup.PrivateKey(kid)
+	if err != nil {
+		return nil, errors.New("active KID does not exist in store")
+	}
+
+	method, err := signingMethod(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, alg := range a.opts.algorithms {
+		if alg == method.Alg() {
+			return method, nil
+		}
+	}
+
+	return nil, fmt.Errorf("active KID algorithm %s is not accepted", method.Alg())
+}
+
+// supported reports whether the algorithm is one Auth understands.
+func supported(alg string) bool {
+	for _, s := range SupportedAlgorithms {
+		if s == alg {
+			return true
+		}
+	}
+	return false
+}
@@ -2,45 +2,121 @@
 package auth
 
 import (
-	"crypto/rsa"
+	"context"
+	"crypto"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/colmmurphy91/go-service/business/sys/auth/db"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
 // ErrForbidden is returned when a auth issue is identified.
 var ErrForbidden = errors.New("attempted action is not allowed")
 
+// ErrRevoked is returned when a correctly signed token has been revoked.
+var ErrRevoked = errors.New("token has been revoked")
+
 // KeyLookup declares a method set of behavior for looking up
-// private and public keys for JWT use.
+// private and public keys for JWT use. The algorithm a key signs with is
+// determined by its type, see SupportedAlgorithms.
 type KeyLookup interface {
-	PrivateKey(kid string) (*rsa.PrivateKey, error)
-	PublicKey(kid string) (*rsa.PublicKey, error)
+	PrivateKey(kid string) (crypto.PrivateKey, error)
+	PublicKey(kid string) (crypto.PublicKey, error)
+}
+
+// ErrAPIKeyInvalid is returned when an API key is unknown, expired or revoked.
+var ErrAPIKeyInvalid = errors.New("api key is not valid")
+
+// APIKeyAuthenticator declares a method set of behavior for exchanging an API
+// key for the claims it grants.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string, now time.Time) (Claims, error)
+}
+
+// RevocationChecker declares a method set of behavior for finding out if the
+// claims of a correctly signed token have since been revoked.
+type RevocationChecker interface {
+	Revoked(ctx context.Context, claims Claims) (bool, error)
+}
+
+// Options represent optional parameters.
+type Options struct {
+	algorithms []string
+	apiKeys    APIKeyAuthenticator
+	revocation RevocationChecker
+	store      *db.Store
+	refreshTTL time.Duration
+}
+
+// WithAlgorithms limits the signing algorithms that are accepted when
+// validating tokens. By default every supported algorithm is accepted.
+func WithAlgorithms(algorithms ...string) func(opts *Options) {
+	return func(opts *Options) {
+		opts.algorithms = algorithms
+	}
+}
+
+// WithAPIKeys configures Auth to accept API keys from machine clients.
+func WithAPIKeys(ak APIKeyAuthenticator) func(opts *Options) {
+	return func(opts *Options) {
+		opts.apiKeys = ak
+	}
+}
+
+// WithRevocationChecker configures Auth to reject tokens the checker reports
+// as revoked.
+func WithRevocationChecker(rc RevocationChecker) func(opts *Options) {
+	return func(opts *Options) {
+		opts.revocation = rc
+	}
+}
+
+// WithStore configures Auth to persist refresh tokens and the jti revocation
+// list in the database.
+func WithStore(store db.Store) func(opts *Options) {
+	return func(opts *Options) {
+		opts.store = &store
+	}
+}
+
+// WithRefreshTTL overrides how long a refresh token remains valid.
+func WithRefreshTTL(ttl time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.refreshTTL = ttl
+	}
 }
 
 // Auth is used to authenticate clients. It can generate a token for a
 // set of user claims and recreate the claims by parsing the token.
 type Auth struct {
+	mu        sync.RWMutex
 	activeKID string
 	keyLookup KeyLookup
-	method    jwt.SigningMethod
 	keyFunc   func(t *jwt.Token) (interface{}, error)
 	parser    *jwt.Parser
+	opts      Options
 }
 
 // New creates an Auth to support authentication/authorization.
-func New(activeKID string, keyLookup KeyLookup) (*Auth, error) {
-
-	// The activeKID represents the private key used to signed new tokens.
-	_, err := keyLookup.PrivateKey(activeKID)
-	if err != nil {
-		return nil, errors.New("active KID does not exist in store")
+func New(activeKID string, keyLookup KeyLookup, options ...func(opts *Options)) (*Auth, error) {
+	opts := Options{
+		refreshTTL: defaultRefreshTTL,
+	}
+	for _, option := range options {
+		option(&opts)
 	}
 
-	method := jwt.GetSigningMethod("RS256")
-	if method == nil {
-		return nil, errors.New("configuring algorithm RS256")
+	if len(opts.algorithms) == 0 {
+		opts.algorithms = SupportedAlgorithms
+	}
+	for _, alg := range opts.algorithms {
+		if !supported(alg) {
+			return nil, fmt.Errorf("algorithm %s is not supported", alg)
+		}
 	}
 
 	keyFunc := func(t *jwt.Token) (interface{}, error) {
@@ -52,35 +128,68 @@ func New(activeKID string, keyLookup KeyLookup) (*Auth, error) {
 		if !ok {
 			return nil, errors.New("user token key id (kid) must be string")
 		}
-		return keyLookup.PublicKey(kidID)
+
+		publicKey, err := keyLookup.PublicKey(kidID)
+		if err != nil {
+			return nil, err
+		}
+
+		// A key only ever verifies the algorithm it was made for.
+		alg, err := algorithm(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != alg {
+			return nil, fmt.Errorf("token algorithm %s does not match key algorithm %s", t.Method.Alg(), alg)
+		}
+
+		return publicKey, nil
 	}
 
 	// Create the token parser to use. The algorithm used to sign the JWT must be
 	// validated to avoid a critical vulnerability:
 	// https://auth0.com/blog/critical-vulnerabilities-in-json-web-token-libraries/
-	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256"}))
+	parser := jwt.NewParser(jwt.WithValidMethods(opts.algorithms))
 
 	a := Auth{
 		activeKID: activeKID,
 		keyLookup: keyLookup,
-		method:    method,
 		keyFunc:   keyFunc,
 		parser:    parser,
+		opts:      opts,
+	}
+
+	// The activeKID represents the private key used to signed new tokens.
+	if _, err := a.checkSigningKey(activeKID); err != nil {
+		return nil, err
 	}
 
 	return &a, nil
 }
 
 // GenerateToken generates a signed JWT token string representing the user Claims.
+// A token ID (jti) is assigned if the claims do not carry one so the token can
+// be revoked individually.
 func (a *Auth) GenerateToken(claims Claims) (string, error) {
-	token := jwt.NewWithClaims(a.method, claims)
-	token.Header["kid"] = a.activeKID
+	if claims.ID == "" {
+		claims.ID = uuid.NewString()
+	}
+
+	activeKID := a.ActiveKID()
 
-	privateKey, err := a.keyLookup.PrivateKey(a.activeKID)
+	privateKey, err := a.keyLookup.PrivateKey(activeKID)
 	if err != nil {
 		return "", errors.New("kid lookup failed")
 	}
 
+	method, err := signingMethod(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = activeKID
+
 	str, err := token.SignedString(privateKey)
 	if err != nil {
 		return "", fmt.Errorf("signing token: %w", err)
@@ -89,6 +198,31 @@ func (a *Auth) GenerateToken(claims Claims) (string, error) {
 	return str, nil
 }
 
+// ActiveKID returns the id of the key used to sign new tokens.
+func (a *Auth) ActiveKID() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.activeKID
+}
+
+// SetActiveKID switches the key used to sign new tokens and returns the id of
+// the key it replaces. Tokens signed with the previous key keep validating
+// for as long as the key lookup still provides its public key.
+func (a *Auth) SetActiveKID(kid string) (string, error) {
+	if _, err := a.checkSigningKey(kid); err != nil {
+		return "", err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	previous := a.activeKID
+	a.activeKID = kid
+
+	return previous, nil
+}
+
 // ValidateToken recreates the Claims that were used to generate a token. It
 // verifies that the token was signed using our key.
 func (a *Auth) ValidateToken(tokenStr string) (Claims, error) {
@@ -104,3 +238,43 @@ func (a *Auth) ValidateToken(tokenStr string) (Claims, error) {
 
 	return claims, nil
 }
+
+// AuthenticateAPIKey returns the claims granted by an API key. ErrAPIKeyInvalid
+// is returned when the key is not accepted, including when Auth was
+// constructed without WithAPIKeys.
+func (a *Auth) AuthenticateAPIKey(ctx context.Context, key string, now time.Time) (Claims, error) {
+	if a.opts.apiKeys == nil {
+		return Claims{}, ErrAPIKeyInvalid
+	}
+
+	return a.opts.apiKeys.Authenticate(ctx, key, now)
+}
+
+// CheckRevoked returns ErrRevoked if the claims of a validated token have
+// since been revoked. Any other error means the check could not be made.
+func (a *Auth) CheckRevoked(ctx context.Context, claims Claims) error {
+	if a.opts.store != nil && claims.ID != "" {
+		revoked, err := a.opts.store.QueryRevokedJTI(ctx, claims.ID)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrRevoked
+		}
+	}
+
+	if a.opts.revocation == nil {
+		return nil
+	}
+
+	revoked, err := a.opts.revocation.Revoked(ctx, claims)
+	if err != nil {
+		return err
+	}
+
+	if revoked {
+		return ErrRevoked
+	}
+
+	return nil
+}
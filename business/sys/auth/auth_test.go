@@ -1,8 +1,13 @@
 package auth_test
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"testing"
 	"time"
 
@@ -29,7 +34,7 @@ func TestAuth(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to create a private key.", success, testID)
 
-			a, err := auth.New(keyID, &keyStore{pk: privateKey})
+			a, err := auth.New(keyID, keyStore{keyID: privateKey})
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
 			}
@@ -74,16 +79,123 @@ func TestAuth(t *testing.T) {
 	}
 }
 
-// =============================================================================
+func TestAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ecdsa key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %v", err)
+	}
+
+	ks := keyStore{
+		"rsa":     rsaKey,
+		"ecdsa":   ecKey,
+		"ed25519": edKey,
+	}
+
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "5cf37266-3473-4006-984f-9325122678b7",
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Hour)),
+		},
+	}
+
+	t.Log("Given the need to sign tokens with different algorithms.")
+	{
+		for testID, kid := range []string{"rsa", "ecdsa", "ed25519"} {
+			t.Logf("\tTest %d:\tWhen signing with the %s key.", testID, kid)
+			{
+				a, err := auth.New(kid, ks)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+				}
+
+				token, err := a.GenerateToken(claims)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to generate a JWT.", success, testID)
+
+				if _, err := a.ValidateToken(token); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to parse the claims: %v", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould be able to parse the claims.", success, testID)
+			}
+		}
+
+		testID := 3
+		t.Logf("\tTest %d:\tWhen limiting the accepted algorithms.", testID)
+		{
+			if _, err := auth.New("ed25519", ks, auth.WithAlgorithms(auth.AlgorithmRS256)); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not sign with an algorithm that is not accepted.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not sign with an algorithm that is not accepted.", success, testID)
+
+			signer, err := auth.New("ed25519", ks)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+			token, err := signer.GenerateToken(claims)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a JWT: %v", failed, testID, err)
+			}
 
-type keyStore struct {
-	pk *rsa.PrivateKey
+			a, err := auth.New("rsa", ks, auth.WithAlgorithms(auth.AlgorithmRS256))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+
+			if _, err := a.ValidateToken(token); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a token signed with an algorithm that is not accepted.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a token signed with an algorithm that is not accepted.", success, testID)
+		}
+
+		testID = 4
+		t.Logf("\tTest %d:\tWhen a token claims a key made for another algorithm.", testID)
+		{
+			a, err := auth.New("rsa", ks)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to create an authenticator: %v", failed, testID, err)
+			}
+
+			token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+			token.Header["kid"] = "rsa"
+			str, err := token.SignedString(ecKey)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to sign the token: %v", failed, testID, err)
+			}
+
+			if _, err := a.ValidateToken(str); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the token.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the token.", success, testID)
+		}
+	}
 }
 
-func (ks *keyStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
-	return ks.pk, nil
+// =============================================================================
+
+type keyStore map[string]crypto.PrivateKey
+
+func (ks keyStore) PrivateKey(kid string) (crypto.PrivateKey, error) {
+	pk, found := ks[kid]
+	if !found {
+		return nil, errors.New("kid lookup failed")
+	}
+	return pk, nil
 }
 
-func (ks *keyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
-	return &ks.pk.PublicKey, nil
+func (ks keyStore) PublicKey(kid string) (crypto.PublicKey, error) {
+	pk, found := ks[kid]
+	if !found {
+		return nil, errors.New("kid lookup failed")
+	}
+	return pk.(crypto.Signer).Public(), nil
 }
@@ -14,10 +14,13 @@ const (
 	RoleOwner = "OWNER"
 )
 
-// Claims represents the authorization claims transmitted via a JWT.
+// Claims represents the authorization claims transmitted via a JWT. CafeID
+// is set when the claims are limited to a single cafe, as with cafe scoped
+// API keys.
 type Claims struct {
 	jwt.RegisteredClaims
-	Roles []string `json:"roles"`
+	Roles  []string `json:"roles"`
+	CafeID string   `json:"cafe_id,omitempty"`
 }
 
 // Authorized returns true if the claims has at least one of the provided roles.
@@ -0,0 +1,209 @@
+// Package db contains the persistence auth needs for refresh tokens and the
+// token revocation list.
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for refresh token and revocation access.
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           sql.Transactor
+	db           sqlx.ExtContext
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		tr:  db,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	return sql.WithinTran(ctx, s.log, s.tr, fn)
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// CreateRefreshToken inserts a new refresh token into the database.
+func (s Store) CreateRefreshToken(ctx context.Context, rt RefreshToken) error {
+	const q = `
+	INSERT INTO refresh_tokens
+		(refresh_token_id, family_id, user_id, token_hash, date_authenticated, date_created, date_expires)
+	VALUES
+		(:refresh_token_id, :family_id, :user_id, :token_hash, :date_authenticated, :date_created, :date_expires)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, rt); err != nil {
+		return fmt.Errorf("inserting refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// QueryRefreshTokenByHash gets the refresh token with the specified hash.
+func (s Store) QueryRefreshTokenByHash(ctx context.Context, hash string) (RefreshToken, error) {
+	data := struct {
+		Hash string `db:"token_hash"`
+	}{
+		Hash: hash,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		refresh_tokens
+	WHERE
+		token_hash = :token_hash`
+
+	var rt RefreshToken
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &rt); err != nil {
+		return RefreshToken{}, fmt.Errorf("selecting refresh token: %w", err)
+	}
+
+	return rt, nil
+}
+
+// UseRefreshToken marks a refresh token as used. ErrDBNotFound is returned if
+// the token was already used or revoked so a token can only be rotated once.
+func (s Store) UseRefreshToken(ctx context.Context, id string, now time.Time) error {
+	data := struct {
+		ID  string    `db:"refresh_token_id"`
+		Now time.Time `db:"now"`
+	}{
+		ID:  id,
+		Now: now,
+	}
+
+	const q = `
+	UPDATE
+		refresh_tokens
+	SET
+		"date_used" = :now
+	WHERE
+		refresh_token_id = :refresh_token_id AND
+		date_used IS NULL AND
+		date_revoked IS NULL
+	RETURNING
+		refresh_token_id`
+
+	var result struct {
+		ID string `db:"refresh_token_id"`
+	}
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &result); err != nil {
+		return fmt.Errorf("using refresh token[%s]: %w", id, err)
+	}
+
+	return nil
+}
+
+// RevokeRefreshFamily revokes every outstanding refresh token in a family.
+func (s Store) RevokeRefreshFamily(ctx context.Context, familyID string, now time.Time) error {
+	data := struct {
+		FamilyID string    `db:"family_id"`
+		Now      time.Time `db:"now"`
+	}{
+		FamilyID: familyID,
+		Now:      now,
+	}
+
+	const q = `
+	UPDATE
+		refresh_tokens
+	SET
+		"date_revoked" = :now
+	WHERE
+		family_id = :family_id AND
+		date_revoked IS NULL`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking refresh family[%s]: %w", familyID, err)
+	}
+
+	return nil
+}
+
+// RevokeJTI adds a token ID to the revocation list until the token expires.
+// Entries for tokens that have since expired are removed at the same time.
+func (s Store) RevokeJTI(ctx context.Context, jti string, expires time.Time, now time.Time) error {
+	data := struct {
+		JTI     string    `db:"jti"`
+		Expires time.Time `db:"date_expires"`
+		Now     time.Time `db:"now"`
+	}{
+		JTI:     jti,
+		Expires: expires,
+		Now:     now,
+	}
+
+	const qDelete = `
+	DELETE FROM
+		revoked_tokens
+	WHERE
+		date_expires < :now`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, qDelete, data); err != nil {
+		return fmt.Errorf("removing expired revocations: %w", err)
+	}
+
+	const q = `
+	INSERT INTO revoked_tokens
+		(jti, date_expires)
+	VALUES
+		(:jti, :date_expires)
+	ON CONFLICT DO NOTHING`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking jti[%s]: %w", jti, err)
+	}
+
+	return nil
+}
+
+// QueryRevokedJTI reports whether a token ID is on the revocation list.
+func (s Store) QueryRevokedJTI(ctx context.Context, jti string) (bool, error) {
+	data := struct {
+		JTI string `db:"jti"`
+	}{
+		JTI: jti,
+	}
+
+	const q = `
+	SELECT
+		count(*) AS count
+	FROM
+		revoked_tokens
+	WHERE
+		jti = :jti`
+
+	var result struct {
+		Count int `db:"count"`
+	}
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &result); err != nil {
+		return false, fmt.Errorf("selecting jti[%s]: %w", jti, err)
+	}
+
+	return result.Count > 0, nil
+}
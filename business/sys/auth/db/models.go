@@ -0,0 +1,21 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RefreshToken represents a refresh token issued to a user. Every rotation
+// creates a new token in the same family so reuse of an old token can be
+// detected and the whole family revoked.
+type RefreshToken struct {
+	ID                string       `db:"refresh_token_id"`
+	FamilyID          string       `db:"family_id"`
+	UserID            string       `db:"user_id"`
+	Hash              string       `db:"token_hash"`
+	DateAuthenticated time.Time    `db:"date_authenticated"`
+	DateCreated       time.Time    `db:"date_created"`
+	DateExpires       time.Time    `db:"date_expires"`
+	DateUsed          sql.NullTime `db:"date_used"`
+	DateRevoked       sql.NullTime `db:"date_revoked"`
+}
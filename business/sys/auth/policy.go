@@ -0,0 +1,160 @@
+package auth
+
+import "fmt"
+
+// Permission represents an action that can be taken against a kind of
+// resource, such as writing a cafe or deleting a product.
+type Permission string
+
+// Set of permissions checked by the web handlers.
+const (
+	PermCafeCreate    Permission = "cafe:create"
+	PermCafeList      Permission = "cafe:list"
+	PermCafeRead      Permission = "cafe:read"
+	PermCafeWrite     Permission = "cafe:write"
+	PermCafeDelete    Permission = "cafe:delete"
+	PermMenuRead      Permission = "menu:read"
+	PermMenuWrite     Permission = "menu:write"
+	PermOrderManage   Permission = "order:manage"
+	PermProductWrite  Permission = "product:write"
+	PermProductDelete Permission = "product:delete"
+	PermStaffManage   Permission = "staff:manage"
+	PermWebhookManage Permission = "webhook:manage"
+)
+
+// These are the roles a user can hold as a member of a cafe's staff.
+const (
+	CafeRoleManager = "MANAGER"
+	CafeRoleBarista = "BARISTA"
+)
+
+// Resource describes the thing a permission is being checked against. The
+// OwnerID is the user that owns the resource and CafeID is the cafe it belongs
+// to, when there is one. MemberRole is the role the subject of the claims
+// holds on the staff of that cafe, if any.
+type Resource struct {
+	OwnerID    string
+	CafeID     string
+	MemberRole string
+}
+
+// Predicate reports whether the claims can act on the specified resource.
+type Predicate func(claims Claims, res Resource) bool
+
+// Any allows the action against every resource.
+func Any(claims Claims, res Resource) bool {
+	return true
+}
+
+// Owner allows the action only against resources owned by the subject of the
+// claims.
+func Owner(claims Claims, res Resource) bool {
+	return res.OwnerID != "" && res.OwnerID == claims.Subject
+}
+
+// Policy maps roles to the permissions they grant. Roles are the roles held
+// in the claims, where each permission carries the predicate a resource must
+// satisfy. CafeRoles are the roles held on the staff of a cafe, which only
+// ever apply to resources belonging to that cafe.
+type Policy struct {
+	Roles     map[string]map[Permission]Predicate
+	CafeRoles map[string][]Permission
+}
+
+// DefaultPolicy is the policy enforced by Check. Admins can act on every cafe
+// and product, while menus and order queues are limited to the cafe owner and
+// staff.
+var DefaultPolicy = Policy{
+	Roles: map[string]map[Permission]Predicate{
+		RoleAdmin: {
+			PermCafeCreate:    Any,
+			PermCafeList:      Any,
+			PermCafeRead:      Any,
+			PermCafeWrite:     Any,
+			PermCafeDelete:    Any,
+			PermMenuRead:      Owner,
+			PermMenuWrite:     Owner,
+			PermProductWrite:  Any,
+			PermProductDelete: Any,
+			PermStaffManage:   Any,
+		},
+		RoleUser: {
+			PermCafeCreate:    Any,
+			PermCafeRead:      Owner,
+			PermCafeWrite:     Owner,
+			PermCafeDelete:    Owner,
+			PermMenuRead:      Owner,
+			PermMenuWrite:     Owner,
+			PermProductWrite:  Owner,
+			PermProductDelete: Owner,
+			PermStaffManage:   Owner,
+			PermWebhookManage: Owner,
+		},
+		RoleOwner: {
+			PermCafeCreate:    Any,
+			PermCafeRead:      Owner,
+			PermCafeWrite:     Owner,
+			PermCafeDelete:    Owner,
+			PermMenuRead:      Owner,
+			PermMenuWrite:     Owner,
+			PermOrderManage:   Owner,
+			PermProductWrite:  Owner,
+			PermProductDelete: Owner,
+			PermStaffManage:   Owner,
+			PermWebhookManage: Owner,
+		},
+	},
+	CafeRoles: map[string][]Permission{
+		CafeRoleManager: {
+			PermCafeRead,
+			PermCafeWrite,
+			PermMenuRead,
+			PermMenuWrite,
+			PermOrderManage,
+			PermStaffManage,
+		},
+		CafeRoleBarista: {
+			PermCafeRead,
+			PermMenuRead,
+			PermOrderManage,
+		},
+	},
+}
+
+// Check validates the claims hold the permission against the resource using
+// the DefaultPolicy.
+func Check(claims Claims, perm Permission, res *Resource) error {
+	return DefaultPolicy.Check(claims, perm, res)
+}
+
+// Check validates the claims hold the permission against the resource. A nil
+// resource means there is nothing specific to check against, so holding the
+// permission through any role is enough. A resource's MemberRole grants its
+// cafe role permissions on top of those. Claims limited to a single cafe can't
+// act on resources belonging to any other.
+func (p Policy) Check(claims Claims, perm Permission, res *Resource) error {
+	if res != nil && claims.CafeID != "" && res.CafeID != "" && claims.CafeID != res.CafeID {
+		return fmt.Errorf("%w: claims limited to cafe[%s]", ErrForbidden, claims.CafeID)
+	}
+
+	for _, role := range claims.Roles {
+		pred, exists := p.Roles[role][perm]
+		if !exists {
+			continue
+		}
+
+		if res == nil || pred(claims, *res) {
+			return nil
+		}
+	}
+
+	if res != nil && res.MemberRole != "" {
+		for _, granted := range p.CafeRoles[res.MemberRole] {
+			if granted == perm {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: roles%v lack permission[%s]", ErrForbidden, claims.Roles, perm)
+}
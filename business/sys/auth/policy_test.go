@@ -0,0 +1,76 @@
+package auth_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestPolicy(t *testing.T) {
+	const (
+		ownerID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2e"
+		otherID = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+		cafeID  = "a2b0639f-2cc6-44b8-b97b-15d69dbb511e"
+	)
+
+	claimsFor := func(subject string, roles ...string) auth.Claims {
+		return auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: subject},
+			Roles:            roles,
+		}
+	}
+
+	keyClaims := claimsFor(ownerID, auth.RoleOwner)
+	keyClaims.CafeID = "b9f5a2c1-4d0e-4b8a-9e2f-3c7d6a1b0e55"
+
+	cafe := &auth.Resource{OwnerID: ownerID, CafeID: cafeID}
+	managed := &auth.Resource{OwnerID: ownerID, CafeID: cafeID, MemberRole: auth.CafeRoleManager}
+	staffed := &auth.Resource{OwnerID: ownerID, CafeID: cafeID, MemberRole: auth.CafeRoleBarista}
+
+	tests := []struct {
+		name   string
+		claims auth.Claims
+		perm   auth.Permission
+		res    *auth.Resource
+		allow  bool
+	}{
+		{"owner writes own cafe", claimsFor(ownerID, auth.RoleUser), auth.PermCafeWrite, cafe, true},
+		{"user writes other cafe", claimsFor(otherID, auth.RoleUser), auth.PermCafeWrite, cafe, false},
+		{"admin writes other cafe", claimsFor(otherID, auth.RoleAdmin), auth.PermCafeWrite, cafe, true},
+		{"admin writes other menu", claimsFor(otherID, auth.RoleAdmin), auth.PermMenuWrite, cafe, false},
+		{"user lists cafes", claimsFor(ownerID, auth.RoleUser), auth.PermCafeList, nil, false},
+		{"user creates cafe", claimsFor(ownerID, auth.RoleUser), auth.PermCafeCreate, nil, true},
+		{"user manages orders", claimsFor(ownerID, auth.RoleUser), auth.PermOrderManage, cafe, false},
+		{"owner manages orders", claimsFor(ownerID, auth.RoleOwner), auth.PermOrderManage, cafe, true},
+		{"cafe key manages other cafe", keyClaims, auth.PermOrderManage, cafe, false},
+		{"no roles", claimsFor(ownerID), auth.PermCafeRead, cafe, false},
+		{"manager writes cafe", claimsFor(otherID, auth.RoleUser), auth.PermCafeWrite, managed, true},
+		{"manager manages staff", claimsFor(otherID, auth.RoleUser), auth.PermStaffManage, managed, true},
+		{"manager deletes cafe", claimsFor(otherID, auth.RoleUser), auth.PermCafeDelete, managed, false},
+		{"barista manages orders", claimsFor(otherID, auth.RoleUser), auth.PermOrderManage, staffed, true},
+		{"barista writes menu", claimsFor(otherID, auth.RoleUser), auth.PermMenuWrite, staffed, false},
+		{"owner manages webhooks", claimsFor(ownerID, auth.RoleUser), auth.PermWebhookManage, cafe, true},
+		{"manager manages webhooks", claimsFor(otherID, auth.RoleUser), auth.PermWebhookManage, managed, false},
+		{"admin manages other webhooks", claimsFor(otherID, auth.RoleAdmin), auth.PermWebhookManage, cafe, false},
+	}
+
+	t.Log("Given the need to check permissions against resources.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen checking %q.", testID, tt.name)
+			{
+				err := auth.Check(tt.claims, tt.perm, tt.res)
+
+				switch {
+				case tt.allow && err != nil:
+					t.Fatalf("\t%s\tTest %d:\tShould be allowed: %v", failed, testID, err)
+				case !tt.allow && !errors.Is(err, auth.ErrForbidden):
+					t.Fatalf("\t%s\tTest %d:\tShould be forbidden: %v", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the expected decision.", success, testID)
+			}
+		}
+	}
+}
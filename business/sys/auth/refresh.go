@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/auth/db"
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// defaultRefreshTTL is how long a refresh token is valid unless configured
+// otherwise with WithRefreshTTL.
+const defaultRefreshTTL = 30 * 24 * time.Hour
+
+// ErrRefreshInvalid is returned when a refresh token is unknown, expired, used
+// or revoked.
+var ErrRefreshInvalid = errors.New("refresh token is invalid")
+
+// ErrNoStore is returned when an operation needs the database but Auth was
+// constructed without WithStore.
+var ErrNoStore = errors.New("auth store not configured")
+
+// Refresh is the result of rotating a refresh token. The caller is expected to
+// build fresh claims for the subject and hand the new refresh token back to
+// the client in place of the one it presented.
+type Refresh struct {
+	Token             string
+	Subject           string
+	DateAuthenticated time.Time
+}
+
+// IssueRefreshToken creates a refresh token for the subject of the claims that
+// starts a new token family.
+func (a *Auth) IssueRefreshToken(ctx context.Context, claims Claims, now time.Time) (string, error) {
+	if a.opts.store == nil {
+		return "", ErrNoStore
+	}
+
+	authenticated := now
+	if claims.IssuedAt != nil {
+		authenticated = claims.IssuedAt.Time
+	}
+
+	rt := db.RefreshToken{
+		FamilyID:          uuid.NewString(),
+		UserID:            claims.Subject,
+		DateAuthenticated: authenticated,
+	}
+
+	return a.createRefreshToken(ctx, *a.opts.store, rt, now)
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same
+// family. A token can only be exchanged once; presenting an already used token
+// is treated as theft and revokes the whole family.
+func (a *Auth) RotateRefreshToken(ctx context.Context, token string, now time.Time) (Refresh, error) {
+	if a.opts.store == nil {
+		return Refresh{}, ErrNoStore
+	}
+	store := *a.opts.store
+
+	rt, err := store.QueryRefreshTokenByHash(ctx, hashRefreshToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return Refresh{}, ErrRefreshInvalid
+		}
+		return Refresh{}, fmt.Errorf("query: %w", err)
+	}
+
+	switch {
+	case rt.DateRevoked.Valid:
+		return Refresh{}, ErrRefreshInvalid
+
+	case rt.DateUsed.Valid:
+		if err := store.RevokeRefreshFamily(ctx, rt.FamilyID, now); err != nil {
+			return Refresh{}, fmt.Errorf("revoke family: %w", err)
+		}
+		return Refresh{}, ErrRefreshInvalid
+
+	case !now.Before(rt.DateExpires):
+		return Refresh{}, ErrRefreshInvalid
+	}
+
+	// Sessions revoked after the original login take their refresh tokens
+	// with them.
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  rt.UserID,
+			IssuedAt: jwt.NewNumericDate(rt.DateAuthenticated),
+		},
+	}
+	if err := a.CheckRevoked(ctx, claims); err != nil {
+		if errors.Is(err, ErrRevoked) {
+			if err := store.RevokeRefreshFamily(ctx, rt.FamilyID, now); err != nil {
+				return Refresh{}, fmt.Errorf("revoke family: %w", err)
+			}
+			return Refresh{}, ErrRefreshInvalid
+		}
+		return Refresh{}, fmt.Errorf("checking revocation: %w", err)
+	}
+
+	var newToken string
+	tran := func(tx sqlx.ExtContext) error {
+		store := store.Tran(tx)
+
+		if err := store.UseRefreshToken(ctx, rt.ID, now); err != nil {
+			if errors.Is(err, sql.ErrDBNotFound) {
+				return ErrRefreshInvalid
+			}
+			return fmt.Errorf("use: %w", err)
+		}
+
+		next := db.RefreshToken{
+			FamilyID:          rt.FamilyID,
+			UserID:            rt.UserID,
+			DateAuthenticated: rt.DateAuthenticated,
+		}
+
+		var err error
+		if newToken, err = a.createRefreshToken(ctx, store, next, now); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if err := store.WithinTran(ctx, tran); err != nil {
+		return Refresh{}, fmt.Errorf("tran: %w", err)
+	}
+
+	r := Refresh{
+		Token:             newToken,
+		Subject:           rt.UserID,
+		DateAuthenticated: rt.DateAuthenticated,
+	}
+
+	return r, nil
+}
+
+// RevokeRefreshToken revokes the family of the specified refresh token. The
+// token must belong to the subject of the claims.
+func (a *Auth) RevokeRefreshToken(ctx context.Context, claims Claims, token string, now time.Time) error {
+	if a.opts.store == nil {
+		return ErrNoStore
+	}
+
+	rt, err := a.opts.store.QueryRefreshTokenByHash(ctx, hashRefreshToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return ErrRefreshInvalid
+		}
+		return fmt.Errorf("query: %w", err)
+	}
+
+	if rt.UserID != claims.Subject {
+		return ErrRefreshInvalid
+	}
+
+	if err := a.opts.store.RevokeRefreshFamily(ctx, rt.FamilyID, now); err != nil {
+		return fmt.Errorf("revoke family: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke adds the token ID of the claims to the revocation list so the token
+// is rejected until it expires.
+func (a *Auth) Revoke(ctx context.Context, claims Claims, now time.Time) error {
+	if a.opts.store == nil {
+		return ErrNoStore
+	}
+
+	if claims.ID == "" {
+		return errors.New("token has no id (jti)")
+	}
+
+	expires := now.Add(defaultRefreshTTL)
+	if claims.ExpiresAt != nil {
+		expires = claims.ExpiresAt.Time
+	}
+
+	if err := a.opts.store.RevokeJTI(ctx, claims.ID, expires, now); err != nil {
+		return fmt.Errorf("revoke: %w", err)
+	}
+
+	return nil
+}
+
+// createRefreshToken generates a random token and stores only its hash.
+func (a *Auth) createRefreshToken(ctx context.Context, store db.Store, rt db.RefreshToken, now time.Time) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	rt.ID = uuid.NewString()
+	rt.Hash = hashRefreshToken(token)
+	rt.DateCreated = now
+	rt.DateExpires = now.Add(a.opts.refreshTTL)
+
+	if err := store.CreateRefreshToken(ctx, rt); err != nil {
+		return "", fmt.Errorf("create: %w", err)
+	}
+
+	return token, nil
+}
+
+// hashRefreshToken returns the hex encoded sha256 of a refresh token.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,236 @@
+// Package db contains outbox related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for outbox access.
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           sql.Transactor
+	db           sqlx.ExtContext
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		tr:  db,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	return sql.WithinTran(ctx, s.log, s.tr, fn)
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// Create adds an event to the outbox.
+func (s Store) Create(ctx context.Context, evt Event) error {
+	const q = `
+	INSERT INTO outbox
+		(event_id, event_type, trace_id, payload, attempts, date_created, date_next_attempt)
+	VALUES
+		(:event_id, :event_type, :trace_id, :payload, :attempts, :date_created, :date_next_attempt)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, evt); err != nil {
+		return fmt.Errorf("inserting event: %w", err)
+	}
+
+	return nil
+}
+
+// Claim takes up to limit events that are due for delivery, oldest first,
+// and holds them until leaseUntil so no other relay picks them up in the
+// meantime. Events held by another relay are skipped rather than waited on.
+func (s Store) Claim(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]Event, error) {
+	data := struct {
+		Now        time.Time `db:"now"`
+		LeaseUntil time.Time `db:"lease_until"`
+		Limit      int       `db:"limit"`
+	}{
+		Now:        now,
+		LeaseUntil: leaseUntil,
+		Limit:      limit,
+	}
+
+	const q = `
+	UPDATE
+		outbox
+	SET
+		"attempts" = attempts + 1,
+		"date_next_attempt" = :lease_until
+	WHERE
+		event_id IN (
+			SELECT
+				event_id
+			FROM
+				outbox
+			WHERE
+				date_delivered IS NULL AND
+				date_failed IS NULL AND
+				date_next_attempt <= :now
+			ORDER BY
+				date_created
+			LIMIT :limit
+			FOR UPDATE SKIP LOCKED
+		)
+	RETURNING
+		*`
+
+	var evts []Event
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &evts); err != nil {
+		return nil, fmt.Errorf("claiming events: %w", err)
+	}
+
+	return evts, nil
+}
+
+// Delivered marks the event as delivered.
+func (s Store) Delivered(ctx context.Context, eventID string, now time.Time) error {
+	data := struct {
+		EventID       string    `db:"event_id"`
+		DateDelivered time.Time `db:"date_delivered"`
+	}{
+		EventID:       eventID,
+		DateDelivered: now,
+	}
+
+	const q = `
+	UPDATE
+		outbox
+	SET
+		"date_delivered" = :date_delivered,
+		"last_error" = NULL
+	WHERE
+		event_id = :event_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("marking eventID[%s] delivered: %w", eventID, err)
+	}
+
+	return nil
+}
+
+// Retry records why delivering the event failed and when to try again.
+func (s Store) Retry(ctx context.Context, eventID string, reason string, nextAttempt time.Time) error {
+	data := struct {
+		EventID         string    `db:"event_id"`
+		LastError       string    `db:"last_error"`
+		DateNextAttempt time.Time `db:"date_next_attempt"`
+	}{
+		EventID:         eventID,
+		LastError:       reason,
+		DateNextAttempt: nextAttempt,
+	}
+
+	const q = `
+	UPDATE
+		outbox
+	SET
+		"last_error" = :last_error,
+		"date_next_attempt" = :date_next_attempt
+	WHERE
+		event_id = :event_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("retrying eventID[%s]: %w", eventID, err)
+	}
+
+	return nil
+}
+
+// Failed records why delivering the event failed and that no more attempts
+// will be made.
+func (s Store) Failed(ctx context.Context, eventID string, reason string, now time.Time) error {
+	data := struct {
+		EventID    string    `db:"event_id"`
+		LastError  string    `db:"last_error"`
+		DateFailed time.Time `db:"date_failed"`
+	}{
+		EventID:    eventID,
+		LastError:  reason,
+		DateFailed: now,
+	}
+
+	const q = `
+	UPDATE
+		outbox
+	SET
+		"last_error" = :last_error,
+		"date_failed" = :date_failed
+	WHERE
+		event_id = :event_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("failing eventID[%s]: %w", eventID, err)
+	}
+
+	return nil
+}
+
+// QueryByID gets the specified event from the outbox.
+func (s Store) QueryByID(ctx context.Context, eventID string) (Event, error) {
+	data := struct {
+		EventID string `db:"event_id"`
+	}{
+		EventID: eventID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		outbox
+	WHERE
+		event_id = :event_id`
+
+	var evt Event
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &evt); err != nil {
+		return Event{}, fmt.Errorf("selecting eventID[%q]: %w", eventID, err)
+	}
+
+	return evt, nil
+}
+
+// DeleteDelivered removes the events delivered before the specified time.
+func (s Store) DeleteDelivered(ctx context.Context, before time.Time) error {
+	data := struct {
+		Before time.Time `db:"before"`
+	}{
+		Before: before,
+	}
+
+	const q = `
+	DELETE FROM
+		outbox
+	WHERE
+		date_delivered < :before`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting delivered events: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Event represents an event waiting in the outbox, or one that has been
+// delivered or given up on.
+type Event struct {
+	ID              string         `db:"event_id"`
+	Type            string         `db:"event_type"`
+	TraceID         string         `db:"trace_id"`
+	Payload         string         `db:"payload"`
+	Attempts        int            `db:"attempts"`
+	LastError       sql.NullString `db:"last_error"`
+	DateCreated     time.Time      `db:"date_created"`
+	DateNextAttempt time.Time      `db:"date_next_attempt"`
+	DateDelivered   sql.NullTime   `db:"date_delivered"`
+	DateFailed      sql.NullTime   `db:"date_failed"`
+}
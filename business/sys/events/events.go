@@ -0,0 +1,91 @@
+// Package events lets core packages tell other systems about changes they
+// make. Events are written to an outbox table in the same transaction as the
+// change, so an event is published if and only if the change is committed.
+// A relay then delivers them to the registered handlers.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/events/db"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/colmmurphy91/go-service/foundation/web"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Event is something that happened which other systems may want to know
+// about. Payload holds the JSON the publisher gave for the type of event.
+type Event struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	TraceID     string          `json:"trace_id"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempt     int             `json:"attempt"`
+	DateCreated time.Time       `json:"date_created"`
+}
+
+// Decode unmarshals the payload of the event into the value.
+func (e Event) Decode(v interface{}) error {
+	if err := json.Unmarshal(e.Payload, v); err != nil {
+		return fmt.Errorf("decoding event[%s] payload: %w", e.Type, err)
+	}
+	return nil
+}
+
+// Handler is given the events of the types it is registered for. An event is
+// delivered at least once, so handlers must cope with seeing it again. If a
+// handler returns an error the event is retried later.
+type Handler func(ctx context.Context, evt Event) error
+
+// Outbox writes events for the relay to deliver.
+type Outbox struct {
+	store db.Store
+}
+
+// NewOutbox constructs an outbox for publishing events.
+func NewOutbox(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Outbox {
+	return Outbox{
+		store: db.NewStore(log, sqlxDB),
+	}
+}
+
+// Publish adds an event to the outbox using the transaction the change it
+// describes was made in. The payload is marshalled to JSON.
+func (o Outbox) Publish(ctx context.Context, tx sqlx.ExtContext, eventType string, payload interface{}, now time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	dbEvt := db.Event{
+		ID:              validate.GenerateID(),
+		Type:            eventType,
+		TraceID:         web.GetTraceID(ctx),
+		Payload:         string(data),
+		DateCreated:     now,
+		DateNextAttempt: now,
+	}
+
+	if err := o.store.Tran(tx).Create(ctx, dbEvt); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+func toEvent(dbEvt db.Event) Event {
+	return Event{
+		ID:          dbEvt.ID,
+		Type:        dbEvt.Type,
+		TraceID:     dbEvt.TraceID,
+		Payload:     json.RawMessage(dbEvt.Payload),
+		Attempt:     dbEvt.Attempts,
+		DateCreated: dbEvt.DateCreated,
+	}
+}
@@ -0,0 +1,133 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/data/dbtest"
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/events"
+	"github.com/colmmurphy91/go-service/foundation/docker"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+type payload struct {
+	Name string `json:"name"`
+}
+
+func TestEvents(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testevents")
+	t.Cleanup(teardown)
+
+	outbox := events.NewOutbox(log, db)
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	t.Log("Given the need to publish events from transactions.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen publishing events.", testID)
+		{
+			relay := events.NewRelay(log, db, events.RelayConfig{})
+
+			var got []events.Event
+			relay.Register("test.published", func(ctx context.Context, evt events.Event) error {
+				got = append(got, evt)
+				return nil
+			})
+
+			publish(t, log, db, outbox, testID, "test.published", now, nil)
+
+			rollback := errors.New("rollback")
+			publish(t, log, db, outbox, testID, "test.published", now, rollback)
+
+			n, err := relay.Deliver(ctx, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to deliver events : %s.", dbtest.Failed, testID, err)
+			}
+			if n != 1 || len(got) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould only deliver events that were committed : claimed %d delivered %d.", dbtest.Failed, testID, n, len(got))
+			}
+			t.Logf("\t%s\tTest %d:\tShould only deliver events that were committed.", dbtest.Success, testID)
+
+			var p payload
+			if err := got[0].Decode(&p); err != nil || p.Name != "gopher" {
+				t.Fatalf("\t%s\tTest %d:\tShould deliver the payload : %+v %v.", dbtest.Failed, testID, p, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould deliver the payload.", dbtest.Success, testID)
+
+			if n, err := relay.Deliver(ctx, now.Add(time.Hour)); err != nil || n != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould not deliver an event twice once it is handled : %d %v.", dbtest.Failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not deliver an event twice once it is handled.", dbtest.Success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen a handler fails.", testID)
+		{
+			relay := events.NewRelay(log, db, events.RelayConfig{
+				MaxAttempts: 2,
+				Backoff:     time.Minute,
+			})
+
+			var attempts int
+			relay.Register("test.failing", func(ctx context.Context, evt events.Event) error {
+				attempts++
+				return errors.New("downstream unavailable")
+			})
+
+			publish(t, log, db, outbox, testID, "test.failing", now, nil)
+
+			if _, err := relay.Deliver(ctx, now); err != nil || attempts != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould attempt delivery : %d %v.", dbtest.Failed, testID, attempts, err)
+			}
+
+			if n, err := relay.Deliver(ctx, now.Add(30*time.Second)); err != nil || n != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould wait before retrying : %d %v.", dbtest.Failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould wait before retrying.", dbtest.Success, testID)
+
+			if _, err := relay.Deliver(ctx, now.Add(time.Minute)); err != nil || attempts != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould retry after the backoff : %d %v.", dbtest.Failed, testID, attempts, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould retry after the backoff.", dbtest.Success, testID)
+
+			if n, err := relay.Deliver(ctx, now.Add(24*time.Hour)); err != nil || n != 0 || attempts != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould give up after the maximum attempts : %d %v.", dbtest.Failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould give up after the maximum attempts.", dbtest.Success, testID)
+		}
+	}
+}
+
+// publish writes an event in a transaction that commits unless fail is set.
+func publish(t *testing.T, log *zap.SugaredLogger, db *sqlx.DB, outbox events.Outbox, testID int, eventType string, now time.Time, fail error) {
+	tran := func(tx sqlx.ExtContext) error {
+		if err := outbox.Publish(context.Background(), tx, eventType, payload{Name: "gopher"}, now); err != nil {
+			return err
+		}
+		return fail
+	}
+
+	if err := sql.WithinTran(context.Background(), log, db, tran); !errors.Is(err, fail) {
+		t.Fatalf("\t%s\tTest %d:\tShould be able to publish an event : %s.", dbtest.Failed, testID, err)
+	}
+}
@@ -0,0 +1,217 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/events/db"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// RelayJob is the key the relay is registered under with a worker.
+const RelayJob = "events-relay"
+
+// RelayConfig controls how often the relay looks for events and how it
+// retries them. Zero values take the defaults.
+type RelayConfig struct {
+	Interval    time.Duration // How long to wait when there is nothing to deliver.
+	BatchSize   int           // How many events to claim at a time.
+	Lease       time.Duration // How long a relay has to deliver an event it claimed.
+	MaxAttempts int           // How many times to try an event before giving up.
+	Backoff     time.Duration // The wait after the first failure, doubling after each one.
+	MaxBackoff  time.Duration // The longest wait between attempts.
+	Retention   time.Duration // How long delivered events are kept.
+}
+
+// Relay delivers the events in the outbox to the handlers registered for
+// their type. Any number of relays can run against the same outbox.
+type Relay struct {
+	log   *zap.SugaredLogger
+	store db.Store
+	cfg   RelayConfig
+
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+	pruned   time.Time
+}
+
+// NewRelay constructs a relay for the outbox.
+func NewRelay(log *zap.SugaredLogger, sqlxDB *sqlx.DB, cfg RelayConfig) *Relay {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = time.Minute
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Hour
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 7 * 24 * time.Hour
+	}
+
+	return &Relay{
+		log:      log,
+		store:    db.NewStore(log, sqlxDB),
+		cfg:      cfg,
+		handlers: make(map[string][]Handler),
+	}
+}
+
+// Register adds a handler for the specified type of event. An event is only
+// delivered once every handler for its type has handled it.
+func (r *Relay) Register(eventType string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[eventType] = append(r.handlers[eventType], h)
+}
+
+// Run delivers events until the context is cancelled. It has the signature
+// of a worker job so it can be started by a worker.
+func (r *Relay) Run(ctx context.Context, traceID string, payload interface{}) (interface{}, error) {
+	r.log.Infow("events relay", "traceid", traceID, "status", "started")
+	defer r.log.Infow("events relay", "traceid", traceID, "status", "stopped")
+
+	for {
+		n, err := r.Deliver(ctx, time.Now())
+		if err != nil {
+			r.log.Errorw("events relay", "traceid", traceID, "ERROR", err)
+		}
+
+		// Keep going straight away while there are full batches waiting.
+		wait := r.cfg.Interval
+		if err == nil && n == r.cfg.BatchSize {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Deliver claims a batch of events that are due and hands each to its
+// handlers. It returns how many events were claimed.
+func (r *Relay) Deliver(ctx context.Context, now time.Time) (int, error) {
+	r.prune(ctx, now)
+
+	dbEvts, err := r.store.Claim(ctx, now, now.Add(r.cfg.Lease), r.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("claim: %w", err)
+	}
+
+	for _, dbEvt := range dbEvts {
+		if ctx.Err() != nil {
+
+			// The remaining events are picked up again once their lease
+			// runs out.
+			return len(dbEvts), ctx.Err()
+		}
+
+		if err := r.deliver(ctx, toEvent(dbEvt), now); err != nil {
+			return len(dbEvts), err
+		}
+	}
+
+	return len(dbEvts), nil
+}
+
+// =============================================================================
+
+// deliver hands the event to its handlers and records the outcome.
+func (r *Relay) deliver(ctx context.Context, evt Event, now time.Time) error {
+	hctx, cancel := context.WithTimeout(ctx, r.cfg.Lease)
+	defer cancel()
+
+	err := r.handle(hctx, evt)
+	if err == nil {
+		if err := r.store.Delivered(ctx, evt.ID, now); err != nil {
+			return fmt.Errorf("delivered: %w", err)
+		}
+		return nil
+	}
+
+	if evt.Attempt >= r.cfg.MaxAttempts {
+		r.log.Errorw("events relay", "traceid", evt.TraceID, "status", "giving up", "event", evt.ID, "type", evt.Type, "attempts", evt.Attempt, "ERROR", err)
+		if err := r.store.Failed(ctx, evt.ID, err.Error(), now); err != nil {
+			return fmt.Errorf("failed: %w", err)
+		}
+		return nil
+	}
+
+	next := now.Add(r.backoff(evt.Attempt))
+	r.log.Infow("events relay", "traceid", evt.TraceID, "status", "retrying", "event", evt.ID, "type", evt.Type, "attempts", evt.Attempt, "next", next, "ERROR", err)
+	if err := r.store.Retry(ctx, evt.ID, err.Error(), next); err != nil {
+		return fmt.Errorf("retry: %w", err)
+	}
+
+	return nil
+}
+
+// handle calls each handler registered for the type of the event, stopping
+// at the first that fails.
+func (r *Relay) handle(ctx context.Context, evt Event) (err error) {
+	r.mu.RLock()
+	handlers := r.handlers[evt.Type]
+	r.mu.RUnlock()
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("handler panic: %v", rec)
+		}
+	}()
+
+	for _, h := range handlers {
+		if err := h(ctx, evt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backoff returns how long to wait after the specified number of failed
+// attempts.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.cfg.Backoff
+	for i := 1; i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > r.cfg.MaxBackoff {
+		d = r.cfg.MaxBackoff
+	}
+
+	return d
+}
+
+// prune deletes delivered events that are past the retention period, at
+// most once an hour.
+func (r *Relay) prune(ctx context.Context, now time.Time) {
+	r.mu.Lock()
+	if now.Sub(r.pruned) < time.Hour {
+		r.mu.Unlock()
+		return
+	}
+	r.pruned = now
+	r.mu.Unlock()
+
+	if err := r.store.DeleteDelivered(ctx, now.Add(-r.cfg.Retention)); err != nil {
+		r.log.Errorw("events relay", "status", "pruning delivered events", "ERROR", err)
+	}
+}
@@ -0,0 +1,435 @@
+// Package db contains job queue related CRUD functionality.
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for job queue access.
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           sql.Transactor
+	db           sqlx.ExtContext
+	isWithinTran bool
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log: log,
+		tr:  db,
+		db:  db,
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	return sql.WithinTran(ctx, s.log, s.tr, fn)
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+	}
+}
+
+// Create adds a job to the queue.
+func (s Store) Create(ctx context.Context, job Job) error {
+	const q = `
+	INSERT INTO jobs
+		(job_id, job_type, trace_id, payload, status, attempts, max_attempts, schedule_name, date_created, date_run_at)
+	VALUES
+		(:job_id, :job_type, :trace_id, :payload, :status, :attempts, :max_attempts, :schedule_name, :date_created, :date_run_at)`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, job); err != nil {
+		return fmt.Errorf("inserting job: %w", err)
+	}
+
+	return nil
+}
+
+// Claim takes up to limit queued jobs that are due, oldest first, and marks
+// them running until leaseUntil. Running jobs whose lease has run out, because
+// the runner holding them went away, are taken again. Jobs held by another
+// runner are skipped rather than waited on.
+func (s Store) Claim(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]Job, error) {
+	data := struct {
+		Now        time.Time `db:"now"`
+		LeaseUntil time.Time `db:"lease_until"`
+		Limit      int       `db:"limit"`
+	}{
+		Now:        now,
+		LeaseUntil: leaseUntil,
+		Limit:      limit,
+	}
+
+	const q = `
+	UPDATE
+		jobs
+	SET
+		"status" = 'running',
+		"attempts" = attempts + 1,
+		"date_lease_until" = :lease_until,
+		"date_started" = :now
+	WHERE
+		job_id IN (
+			SELECT
+				job_id
+			FROM
+				jobs
+			WHERE
+				(status = 'queued' AND date_run_at <= :now) OR
+				(status = 'running' AND date_lease_until <= :now)
+			ORDER BY
+				date_run_at
+			LIMIT :limit
+			FOR UPDATE SKIP LOCKED
+		)
+	RETURNING
+		*`
+
+	var jobs []Job
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &jobs); err != nil {
+		return nil, fmt.Errorf("claiming jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// Extend holds a running job for longer.
+func (s Store) Extend(ctx context.Context, jobID string, leaseUntil time.Time) error {
+	data := struct {
+		JobID      string    `db:"job_id"`
+		LeaseUntil time.Time `db:"date_lease_until"`
+	}{
+		JobID:      jobID,
+		LeaseUntil: leaseUntil,
+	}
+
+	const q = `
+	UPDATE
+		jobs
+	SET
+		"date_lease_until" = :date_lease_until
+	WHERE
+		job_id = :job_id AND
+		status = 'running'`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("extending jobID[%s]: %w", jobID, err)
+	}
+
+	return nil
+}
+
+// Succeeded marks the job as done.
+func (s Store) Succeeded(ctx context.Context, jobID string, now time.Time) error {
+	data := struct {
+		JobID         string    `db:"job_id"`
+		DateCompleted time.Time `db:"date_completed"`
+	}{
+		JobID:         jobID,
+		DateCompleted: now,
+	}
+
+	const q = `
+	UPDATE
+		jobs
+	SET
+		"status" = 'succeeded',
+		"last_error" = NULL,
+		"date_lease_until" = NULL,
+		"date_completed" = :date_completed
+	WHERE
+		job_id = :job_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("marking jobID[%s] succeeded: %w", jobID, err)
+	}
+
+	return nil
+}
+
+// Retry records why the job failed and queues it again for the specified
+// time.
+func (s Store) Retry(ctx context.Context, jobID string, reason string, runAt time.Time) error {
+	data := struct {
+		JobID     string    `db:"job_id"`
+		LastError string    `db:"last_error"`
+		DateRunAt time.Time `db:"date_run_at"`
+	}{
+		JobID:     jobID,
+		LastError: reason,
+		DateRunAt: runAt,
+	}
+
+	const q = `
+	UPDATE
+		jobs
+	SET
+		"status" = 'queued',
+		"last_error" = :last_error,
+		"date_run_at" = :date_run_at,
+		"date_lease_until" = NULL
+	WHERE
+		job_id = :job_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("retrying jobID[%s]: %w", jobID, err)
+	}
+
+	return nil
+}
+
+// Failed records why the job failed and that no more attempts will be made.
+func (s Store) Failed(ctx context.Context, jobID string, reason string, now time.Time) error {
+	data := struct {
+		JobID         string    `db:"job_id"`
+		LastError     string    `db:"last_error"`
+		DateCompleted time.Time `db:"date_completed"`
+	}{
+		JobID:         jobID,
+		LastError:     reason,
+		DateCompleted: now,
+	}
+
+	const q = `
+	UPDATE
+		jobs
+	SET
+		"status" = 'failed',
+		"last_error" = :last_error,
+		"date_lease_until" = NULL,
+		"date_completed" = :date_completed
+	WHERE
+		job_id = :job_id`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("failing jobID[%s]: %w", jobID, err)
+	}
+
+	return nil
+}
+
+// Requeue queues a failed job to be run again from its first attempt. It
+// reports whether the job was failed.
+func (s Store) Requeue(ctx context.Context, jobID string, runAt time.Time) (bool, error) {
+	data := struct {
+		JobID     string    `db:"job_id"`
+		DateRunAt time.Time `db:"date_run_at"`
+	}{
+		JobID:     jobID,
+		DateRunAt: runAt,
+	}
+
+	const q = `
+	UPDATE
+		jobs
+	SET
+		"status" = 'queued',
+		"attempts" = 0,
+		"date_run_at" = :date_run_at,
+		"date_started" = NULL,
+		"date_completed" = NULL
+	WHERE
+		job_id = :job_id AND
+		status = 'failed'
+	RETURNING
+		*`
+
+	var jobs []Job
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &jobs); err != nil {
+		return false, fmt.Errorf("requeuing jobID[%s]: %w", jobID, err)
+	}
+
+	return len(jobs) == 1, nil
+}
+
+// QueryByID gets the specified job from the database.
+func (s Store) QueryByID(ctx context.Context, jobID string) (Job, error) {
+	data := struct {
+		JobID string `db:"job_id"`
+	}{
+		JobID: jobID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		jobs
+	WHERE
+		job_id = :job_id`
+
+	var job Job
+	if err := sql.NamedQueryStruct(ctx, s.log, s.db, q, data, &job); err != nil {
+		return Job{}, fmt.Errorf("selecting jobID[%q]: %w", jobID, err)
+	}
+
+	return job, nil
+}
+
+// QueryByStatus retrieves a page of the jobs with the specified status, the
+// most recently created first.
+func (s Store) QueryByStatus(ctx context.Context, status string, pageNumber int, rowsPerPage int) ([]Job, error) {
+	data := struct {
+		Status      string `db:"status"`
+		Offset      int    `db:"offset"`
+		RowsPerPage int    `db:"rows_per_page"`
+	}{
+		Status:      status,
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		jobs
+	WHERE
+		status = :status
+	ORDER BY
+		date_created DESC, job_id
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var jobs []Job
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &jobs); err != nil {
+		return nil, fmt.Errorf("selecting jobs status[%s]: %w", status, err)
+	}
+
+	return jobs, nil
+}
+
+// DeleteSucceeded removes the jobs that succeeded before the specified time.
+func (s Store) DeleteSucceeded(ctx context.Context, before time.Time) error {
+	data := struct {
+		Before time.Time `db:"before"`
+	}{
+		Before: before,
+	}
+
+	const q = `
+	DELETE FROM
+		jobs
+	WHERE
+		status = 'succeeded' AND
+		date_completed < :before`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting succeeded jobs: %w", err)
+	}
+
+	return nil
+}
+
+// SaveSchedule adds a schedule or replaces the one with the same name. The
+// next run of an existing schedule is kept unless its spec changed.
+func (s Store) SaveSchedule(ctx context.Context, sch Schedule) error {
+	const q = `
+	INSERT INTO job_schedules
+		(schedule_name, job_type, spec, payload, date_next_run, date_updated)
+	VALUES
+		(:schedule_name, :job_type, :spec, :payload, :date_next_run, :date_updated)
+	ON CONFLICT (schedule_name) DO UPDATE SET
+		"job_type" = EXCLUDED.job_type,
+		"payload" = EXCLUDED.payload,
+		"date_next_run" = CASE
+			WHEN job_schedules.spec = EXCLUDED.spec THEN job_schedules.date_next_run
+			ELSE EXCLUDED.date_next_run
+		END,
+		"spec" = EXCLUDED.spec,
+		"date_updated" = EXCLUDED.date_updated`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, sch); err != nil {
+		return fmt.Errorf("saving schedule[%s]: %w", sch.Name, err)
+	}
+
+	return nil
+}
+
+// DeleteSchedule removes the specified schedule.
+func (s Store) DeleteSchedule(ctx context.Context, name string) error {
+	data := struct {
+		Name string `db:"schedule_name"`
+	}{
+		Name: name,
+	}
+
+	const q = `
+	DELETE FROM
+		job_schedules
+	WHERE
+		schedule_name = :schedule_name`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting schedule[%s]: %w", name, err)
+	}
+
+	return nil
+}
+
+// QueryDueSchedules retrieves the schedules that are due to fire, locking
+// them until the transaction ends. Schedules locked by another runner are
+// skipped. It must be called within a transaction.
+func (s Store) QueryDueSchedules(ctx context.Context, now time.Time) ([]Schedule, error) {
+	data := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: now,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		job_schedules
+	WHERE
+		date_next_run <= :now
+	FOR UPDATE SKIP LOCKED`
+
+	var schs []Schedule
+	if err := sql.NamedQuerySlice(ctx, s.log, s.db, q, data, &schs); err != nil {
+		return nil, fmt.Errorf("selecting due schedules: %w", err)
+	}
+
+	return schs, nil
+}
+
+// UpdateScheduleNextRun sets when the specified schedule next fires.
+func (s Store) UpdateScheduleNextRun(ctx context.Context, name string, nextRun time.Time) error {
+	data := struct {
+		Name        string    `db:"schedule_name"`
+		DateNextRun time.Time `db:"date_next_run"`
+	}{
+		Name:        name,
+		DateNextRun: nextRun,
+	}
+
+	const q = `
+	UPDATE
+		job_schedules
+	SET
+		"date_next_run" = :date_next_run
+	WHERE
+		schedule_name = :schedule_name`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("updating schedule[%s]: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Job represents a job waiting in the queue, running, or finished.
+type Job struct {
+	ID             string         `db:"job_id"`
+	Type           string         `db:"job_type"`
+	TraceID        string         `db:"trace_id"`
+	Payload        string         `db:"payload"`
+	Status         string         `db:"status"`
+	Attempts       int            `db:"attempts"`
+	MaxAttempts    int            `db:"max_attempts"`
+	LastError      sql.NullString `db:"last_error"`
+	ScheduleName   sql.NullString `db:"schedule_name"`
+	DateCreated    time.Time      `db:"date_created"`
+	DateRunAt      time.Time      `db:"date_run_at"`
+	DateLeaseUntil sql.NullTime   `db:"date_lease_until"`
+	DateStarted    sql.NullTime   `db:"date_started"`
+	DateCompleted  sql.NullTime   `db:"date_completed"`
+}
+
+// Schedule represents a job that is queued every time a cron spec fires.
+type Schedule struct {
+	Name        string    `db:"schedule_name"`
+	Type        string    `db:"job_type"`
+	Spec        string    `db:"spec"`
+	Payload     string    `db:"payload"`
+	DateNextRun time.Time `db:"date_next_run"`
+	DateUpdated time.Time `db:"date_updated"`
+}
@@ -0,0 +1,264 @@
+// Package jobs provides a durable job queue kept in the database. Jobs
+// survive restarts, can be delayed or queued on a cron schedule, are retried
+// with backoff when they fail, and are kept as failed once they run out of
+// attempts so they can be looked at. A runner started by a worker claims
+// and runs them.
+package jobs
+
+import (
+	"context"
+	gosql "database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/jobs/db"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/colmmurphy91/go-service/foundation/cron"
+	"github.com/colmmurphy91/go-service/foundation/web"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for job queue operations.
+var (
+	ErrNotFound    = errors.New("job not found")
+	ErrInvalidID   = errors.New("ID is not in its proper form")
+	ErrInvalidSpec = errors.New("schedule spec is not valid")
+	ErrNotFailed   = errors.New("job has not failed")
+)
+
+// Set of statuses a job can be in.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Job is a unit of work in the queue. Payload holds the JSON given when the
+// job was queued.
+type Job struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	TraceID       string          `json:"trace_id"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempt       int             `json:"attempt"`
+	MaxAttempts   int             `json:"max_attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	ScheduleName  string          `json:"schedule_name,omitempty"`
+	DateCreated   time.Time       `json:"date_created"`
+	DateRunAt     time.Time       `json:"date_run_at"`
+	DateStarted   *time.Time      `json:"date_started,omitempty"`
+	DateCompleted *time.Time      `json:"date_completed,omitempty"`
+}
+
+// Decode unmarshals the payload of the job into the value.
+func (j Job) Decode(v interface{}) error {
+	if err := json.Unmarshal(j.Payload, v); err != nil {
+		return fmt.Errorf("decoding job[%s] payload: %w", j.Type, err)
+	}
+	return nil
+}
+
+// NewJob is what we require to queue a job. A zero RunAt runs the job as
+// soon as possible, and a zero MaxAttempts takes the runner's default.
+type NewJob struct {
+	Type        string
+	Payload     interface{}
+	RunAt       time.Time
+	MaxAttempts int
+}
+
+// Handler runs the jobs of the type it is registered for. A job may be run
+// more than once, so handlers must cope with that. If a handler returns an
+// error the job is retried later.
+type Handler func(ctx context.Context, job Job) error
+
+// Queue adds jobs to the queue and reports on them.
+type Queue struct {
+	store db.Store
+}
+
+// NewQueue constructs a queue for adding jobs.
+func NewQueue(log *zap.SugaredLogger, sqlxDB *sqlx.DB) Queue {
+	return Queue{
+		store: db.NewStore(log, sqlxDB),
+	}
+}
+
+// Enqueue adds a job to the queue. When tx is not nil the job is added in
+// that transaction, so it only runs if the transaction commits.
+func (q Queue) Enqueue(ctx context.Context, tx sqlx.ExtContext, nj NewJob, now time.Time) (Job, error) {
+	data, err := json.Marshal(nj.Payload)
+	if err != nil {
+		return Job{}, fmt.Errorf("marshal payload: %w", err)
+	}
+
+	runAt := nj.RunAt
+	if runAt.IsZero() {
+		runAt = now
+	}
+
+	dbJob := db.Job{
+		ID:          validate.GenerateID(),
+		Type:        nj.Type,
+		TraceID:     web.GetTraceID(ctx),
+		Payload:     string(data),
+		Status:      StatusQueued,
+		MaxAttempts: nj.MaxAttempts,
+		DateCreated: now,
+		DateRunAt:   runAt,
+	}
+
+	store := q.store
+	if tx != nil {
+		store = store.Tran(tx)
+	}
+
+	if err := store.Create(ctx, dbJob); err != nil {
+		return Job{}, fmt.Errorf("create: %w", err)
+	}
+
+	return toJob(dbJob), nil
+}
+
+// Schedule queues a job of the specified type every time the cron spec
+// fires, until it is unscheduled. Scheduling the same name again replaces
+// the schedule. However many runners there are, each firing queues one job.
+func (q Queue) Schedule(ctx context.Context, name string, spec string, jobType string, payload interface{}, now time.Time) error {
+	sch, err := cron.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSpec, err)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	dbSch := db.Schedule{
+		Name:        name,
+		Type:        jobType,
+		Spec:        spec,
+		Payload:     string(data),
+		DateNextRun: sch.Next(now),
+		DateUpdated: now,
+	}
+
+	if err := q.store.SaveSchedule(ctx, dbSch); err != nil {
+		return fmt.Errorf("save schedule: %w", err)
+	}
+
+	return nil
+}
+
+// Unschedule stops the named schedule queuing jobs. Jobs it already queued
+// still run.
+func (q Queue) Unschedule(ctx context.Context, name string) error {
+	if err := q.store.DeleteSchedule(ctx, name); err != nil {
+		return fmt.Errorf("delete schedule: %w", err)
+	}
+
+	return nil
+}
+
+// Requeue queues a job that failed to be run again with all its attempts.
+func (q Queue) Requeue(ctx context.Context, jobID string, now time.Time) (Job, error) {
+	dbJob, err := q.queryByID(ctx, jobID)
+	if err != nil {
+		return Job{}, err
+	}
+
+	requeued, err := q.store.Requeue(ctx, dbJob.ID, now)
+	if err != nil {
+		return Job{}, fmt.Errorf("requeue: %w", err)
+	}
+
+	if !requeued {
+		return Job{}, ErrNotFailed
+	}
+
+	return q.QueryByID(ctx, jobID)
+}
+
+// QueryByID gets the specified job from the queue.
+func (q Queue) QueryByID(ctx context.Context, jobID string) (Job, error) {
+	dbJob, err := q.queryByID(ctx, jobID)
+	if err != nil {
+		return Job{}, err
+	}
+
+	return toJob(dbJob), nil
+}
+
+// QueryByStatus retrieves a page of the jobs with the specified status, the
+// most recently created first.
+func (q Queue) QueryByStatus(ctx context.Context, status string, pageNumber int, rowsPerPage int) ([]Job, error) {
+	dbJobs, err := q.store.QueryByStatus(ctx, status, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	return toJobSlice(dbJobs), nil
+}
+
+// =============================================================================
+
+// queryByID finds the job identified by a given ID.
+func (q Queue) queryByID(ctx context.Context, jobID string) (db.Job, error) {
+	if err := validate.CheckID(jobID); err != nil {
+		return db.Job{}, ErrInvalidID
+	}
+
+	dbJob, err := q.store.QueryByID(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, sql.ErrDBNotFound) {
+			return db.Job{}, ErrNotFound
+		}
+		return db.Job{}, fmt.Errorf("query: %w", err)
+	}
+
+	return dbJob, nil
+}
+
+func toJob(dbJob db.Job) Job {
+	j := Job{
+		ID:           dbJob.ID,
+		Type:         dbJob.Type,
+		TraceID:      dbJob.TraceID,
+		Payload:      json.RawMessage(dbJob.Payload),
+		Status:       dbJob.Status,
+		Attempt:      dbJob.Attempts,
+		MaxAttempts:  dbJob.MaxAttempts,
+		LastError:    dbJob.LastError.String,
+		ScheduleName: dbJob.ScheduleName.String,
+		DateCreated:  dbJob.DateCreated,
+		DateRunAt:    dbJob.DateRunAt,
+	}
+
+	if dbJob.DateStarted.Valid {
+		j.DateStarted = &dbJob.DateStarted.Time
+	}
+	if dbJob.DateCompleted.Valid {
+		j.DateCompleted = &dbJob.DateCompleted.Time
+	}
+
+	return j
+}
+
+func toJobSlice(dbJobs []db.Job) []Job {
+	jobs := make([]Job, len(dbJobs))
+	for i, dbJob := range dbJobs {
+		jobs[i] = toJob(dbJob)
+	}
+	return jobs
+}
+
+// nullString returns a NullString that is only valid when s is not empty.
+func nullString(s string) gosql.NullString {
+	return gosql.NullString{String: s, Valid: s != ""}
+}
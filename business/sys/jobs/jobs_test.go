@@ -0,0 +1,186 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/data/dbtest"
+	"github.com/colmmurphy91/go-service/business/sys/jobs"
+	"github.com/colmmurphy91/go-service/foundation/docker"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+type payload struct {
+	Name string `json:"name"`
+}
+
+func TestJobs(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testjobs")
+	t.Cleanup(teardown)
+
+	queue := jobs.NewQueue(log, db)
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	t.Log("Given the need to run jobs from a durable queue.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen running delayed jobs.", testID)
+		{
+			runner := jobs.NewRunner(log, db, jobs.RunnerConfig{})
+
+			var (
+				mu  sync.Mutex
+				got []payload
+			)
+			runner.Register("test.delayed", func(ctx context.Context, job jobs.Job) error {
+				var p payload
+				if err := job.Decode(&p); err != nil {
+					return err
+				}
+				mu.Lock()
+				got = append(got, p)
+				mu.Unlock()
+				return nil
+			})
+
+			nj := jobs.NewJob{
+				Type:    "test.delayed",
+				Payload: payload{Name: "gopher"},
+				RunAt:   now.Add(time.Minute),
+			}
+
+			job, err := queue.Enqueue(ctx, nil, nj, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enqueue a job : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to enqueue a job.", dbtest.Success, testID)
+
+			if n, err := runner.Work(ctx, now); err != nil || n != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould not run a job before it is due : %d %v.", dbtest.Failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not run a job before it is due.", dbtest.Success, testID)
+
+			if n, err := runner.Work(ctx, now.Add(time.Minute)); err != nil || n != 1 || len(got) != 1 || got[0].Name != "gopher" {
+				t.Fatalf("\t%s\tTest %d:\tShould run a job once it is due : %d %+v %v.", dbtest.Failed, testID, n, got, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould run a job once it is due.", dbtest.Success, testID)
+
+			saved, err := queue.QueryByID(ctx, job.ID)
+			if err != nil || saved.Status != jobs.StatusSucceeded {
+				t.Fatalf("\t%s\tTest %d:\tShould mark the job succeeded : %+v %v.", dbtest.Failed, testID, saved, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould mark the job succeeded.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen a job keeps failing.", testID)
+		{
+			runner := jobs.NewRunner(log, db, jobs.RunnerConfig{Backoff: time.Minute})
+
+			attempts := 0
+			runner.Register("test.failing", func(ctx context.Context, job jobs.Job) error {
+				attempts++
+				return errors.New("failing")
+			})
+
+			job, err := queue.Enqueue(ctx, nil, jobs.NewJob{Type: "test.failing", MaxAttempts: 2}, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to enqueue a job : %s.", dbtest.Failed, testID, err)
+			}
+
+			if _, err := runner.Work(ctx, now); err != nil || attempts != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould run the job : %d %v.", dbtest.Failed, testID, attempts, err)
+			}
+
+			if n, err := runner.Work(ctx, now.Add(30*time.Second)); err != nil || n != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould wait before retrying the job : %d %v.", dbtest.Failed, testID, n, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould wait before retrying the job.", dbtest.Success, testID)
+
+			if _, err := runner.Work(ctx, now.Add(2*time.Minute)); err != nil || attempts != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould retry the job after the backoff : %d %v.", dbtest.Failed, testID, attempts, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould retry the job after the backoff.", dbtest.Success, testID)
+
+			saved, err := queue.QueryByID(ctx, job.ID)
+			if err != nil || saved.Status != jobs.StatusFailed || saved.LastError != "failing" {
+				t.Fatalf("\t%s\tTest %d:\tShould keep the job as failed after its last attempt : %+v %v.", dbtest.Failed, testID, saved, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep the job as failed after its last attempt.", dbtest.Success, testID)
+
+			failed, err := queue.QueryByStatus(ctx, jobs.StatusFailed, 1, 10)
+			if err != nil || len(failed) != 1 || failed[0].ID != job.ID {
+				t.Fatalf("\t%s\tTest %d:\tShould list the failed job : %d %v.", dbtest.Failed, testID, len(failed), err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list the failed job.", dbtest.Success, testID)
+
+			if _, err := queue.Requeue(ctx, job.ID, now.Add(time.Hour)); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to requeue the failed job : %s.", dbtest.Failed, testID, err)
+			}
+			if _, err := runner.Work(ctx, now.Add(time.Hour)); err != nil || attempts != 3 {
+				t.Fatalf("\t%s\tTest %d:\tShould run the requeued job : %d %v.", dbtest.Failed, testID, attempts, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould run the requeued job.", dbtest.Success, testID)
+
+			if _, err := queue.Requeue(ctx, job.ID, now.Add(time.Hour)); !errors.Is(err, jobs.ErrNotFailed) {
+				t.Fatalf("\t%s\tTest %d:\tShould not requeue a job that has not failed : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not requeue a job that has not failed.", dbtest.Success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen running scheduled jobs.", testID)
+		{
+			runner := jobs.NewRunner(log, db, jobs.RunnerConfig{})
+
+			runs := 0
+			runner.Register("test.scheduled", func(ctx context.Context, job jobs.Job) error {
+				runs++
+				return nil
+			})
+
+			if err := queue.Schedule(ctx, "hourly", "0 * * * *", "test.scheduled", nil, now); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to schedule a job : %s.", dbtest.Failed, testID, err)
+			}
+
+			if _, err := runner.Work(ctx, now.Add(30*time.Minute)); err != nil || runs != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould not run a schedule before it fires : %d %v.", dbtest.Failed, testID, runs, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not run a schedule before it fires.", dbtest.Success, testID)
+
+			if _, err := runner.Work(ctx, now.Add(time.Hour)); err != nil || runs != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould run a schedule when it fires : %d %v.", dbtest.Failed, testID, runs, err)
+			}
+			if _, err := runner.Work(ctx, now.Add(90*time.Minute)); err != nil || runs != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould run a schedule once each time it fires : %d %v.", dbtest.Failed, testID, runs, err)
+			}
+			if _, err := runner.Work(ctx, now.Add(2*time.Hour)); err != nil || runs != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould run a schedule again when it next fires : %d %v.", dbtest.Failed, testID, runs, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould run a schedule once each time it fires.", dbtest.Success, testID)
+
+			if err := queue.Schedule(ctx, "broken", "every hour", "test.scheduled", nil, now); !errors.Is(err, jobs.ErrInvalidSpec) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an invalid spec : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an invalid spec.", dbtest.Success, testID)
+		}
+	}
+}
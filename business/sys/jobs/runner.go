@@ -0,0 +1,304 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/jobs/db"
+	"github.com/colmmurphy91/go-service/business/sys/validate"
+	"github.com/colmmurphy91/go-service/foundation/cron"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// RunJob is the key the runner is registered under with a worker.
+const RunJob = "jobs-runner"
+
+// RunnerConfig controls how often the runner looks for jobs, how many it
+// runs at once and how it retries them. Zero values take the defaults.
+type RunnerConfig struct {
+	Interval    time.Duration // How long to wait when there is nothing to run.
+	BatchSize   int           // How many jobs to claim and run at a time.
+	Lease       time.Duration // How long a job is held without the runner checking in.
+	MaxAttempts int           // How many times to try a job that did not say.
+	Backoff     time.Duration // The wait after the first failure, doubling after each one.
+	MaxBackoff  time.Duration // The longest wait between attempts.
+	Retention   time.Duration // How long jobs that succeeded are kept.
+}
+
+// Runner runs the jobs in the queue with the handlers registered for their
+// type, and queues the jobs of schedules as they fire. Any number of runners
+// can run against the same queue.
+type Runner struct {
+	log   *zap.SugaredLogger
+	store db.Store
+	cfg   RunnerConfig
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	pruned   time.Time
+}
+
+// NewRunner constructs a runner for the queue.
+func NewRunner(log *zap.SugaredLogger, sqlxDB *sqlx.DB, cfg RunnerConfig) *Runner {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = time.Minute
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Hour
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 7 * 24 * time.Hour
+	}
+
+	return &Runner{
+		log:      log,
+		store:    db.NewStore(log, sqlxDB),
+		cfg:      cfg,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Register sets the handler for the specified type of job, replacing any
+// registered before.
+func (r *Runner) Register(jobType string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[jobType] = h
+}
+
+// Run runs jobs until the context is cancelled. It has the signature of a
+// worker job so it can be started by a worker.
+func (r *Runner) Run(ctx context.Context, traceID string, payload interface{}) (interface{}, error) {
+	r.log.Infow("jobs runner", "traceid", traceID, "status", "started")
+	defer r.log.Infow("jobs runner", "traceid", traceID, "status", "stopped")
+
+	for {
+		n, err := r.Work(ctx, time.Now())
+		if err != nil {
+			r.log.Errorw("jobs runner", "traceid", traceID, "ERROR", err)
+		}
+
+		// Keep going straight away while there are full batches waiting.
+		wait := r.cfg.Interval
+		if err == nil && n == r.cfg.BatchSize {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Work queues the jobs of the schedules that are due, then claims a batch of
+// jobs that are due and runs them concurrently, returning once they have all
+// finished. It returns how many jobs were claimed.
+func (r *Runner) Work(ctx context.Context, now time.Time) (int, error) {
+	r.prune(ctx, now)
+
+	if err := r.schedule(ctx, now); err != nil {
+		return 0, fmt.Errorf("schedule: %w", err)
+	}
+
+	dbJobs, err := r.store.Claim(ctx, now, now.Add(r.cfg.Lease), r.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("claim: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(dbJobs))
+	for _, dbJob := range dbJobs {
+		go func(job Job) {
+			defer wg.Done()
+			r.run(ctx, job, now)
+		}(toJob(dbJob))
+	}
+	wg.Wait()
+
+	return len(dbJobs), nil
+}
+
+// =============================================================================
+
+// run runs the job and records the outcome.
+func (r *Runner) run(ctx context.Context, job Job, now time.Time) {
+	start := time.Now()
+	elapsed := func() time.Time { return now.Add(time.Since(start)) }
+
+	err := r.handle(ctx, job, elapsed)
+
+	// The job did not get to finish because we are shutting down, so hand
+	// it back to be run again straight away.
+	if ctx.Err() != nil {
+		r.log.Infow("jobs runner", "traceid", job.TraceID, "status", "interrupted", "job", job.ID, "type", job.Type)
+		r.record(job, r.store.Retry(context.Background(), job.ID, "interrupted by shutdown", elapsed()))
+		return
+	}
+
+	if err == nil {
+		r.record(job, r.store.Succeeded(ctx, job.ID, elapsed()))
+		return
+	}
+
+	maxAttempts := job.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = r.cfg.MaxAttempts
+	}
+
+	if job.Attempt >= maxAttempts {
+		r.log.Errorw("jobs runner", "traceid", job.TraceID, "status", "giving up", "job", job.ID, "type", job.Type, "attempts", job.Attempt, "ERROR", err)
+		r.record(job, r.store.Failed(ctx, job.ID, err.Error(), elapsed()))
+		return
+	}
+
+	next := elapsed().Add(r.backoff(job.Attempt))
+	r.log.Infow("jobs runner", "traceid", job.TraceID, "status", "retrying", "job", job.ID, "type", job.Type, "attempts", job.Attempt, "next", next, "ERROR", err)
+	r.record(job, r.store.Retry(ctx, job.ID, err.Error(), next))
+}
+
+// handle calls the handler registered for the type of the job, extending
+// the lease on the job for as long as it runs.
+func (r *Runner) handle(ctx context.Context, job Job, elapsed func() time.Time) (err error) {
+	r.mu.RLock()
+	h, exists := r.handlers[job.Type]
+	r.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("no handler registered for job type[%s]", job.Type)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(r.cfg.Lease / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := r.store.Extend(ctx, job.ID, elapsed().Add(r.cfg.Lease)); err != nil {
+					r.log.Errorw("jobs runner", "traceid", job.TraceID, "status", "extending lease", "job", job.ID, "ERROR", err)
+				}
+			}
+		}
+	}()
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("handler panic: %v", rec)
+		}
+	}()
+
+	return h(ctx, job)
+}
+
+// record logs a failure to record the outcome of a job. The job is run
+// again once its lease runs out.
+func (r *Runner) record(job Job, err error) {
+	if err != nil {
+		r.log.Errorw("jobs runner", "traceid", job.TraceID, "status", "recording outcome", "job", job.ID, "ERROR", err)
+	}
+}
+
+// schedule queues a job for each schedule that is due and works out when
+// it next fires. Firings missed while no runner was running are not made
+// up, only the latest is queued.
+func (r *Runner) schedule(ctx context.Context, now time.Time) error {
+	tran := func(tx sqlx.ExtContext) error {
+		store := r.store.Tran(tx)
+
+		dbSchs, err := store.QueryDueSchedules(ctx, now)
+		if err != nil {
+			return err
+		}
+
+		for _, dbSch := range dbSchs {
+			sch, err := cron.Parse(dbSch.Spec)
+			if err != nil {
+				return fmt.Errorf("parsing schedule[%s]: %w", dbSch.Name, err)
+			}
+
+			dbJob := db.Job{
+				ID:           validate.GenerateID(),
+				Type:         dbSch.Type,
+				Payload:      dbSch.Payload,
+				Status:       StatusQueued,
+				ScheduleName: nullString(dbSch.Name),
+				DateCreated:  now,
+				DateRunAt:    dbSch.DateNextRun,
+			}
+
+			if err := store.Create(ctx, dbJob); err != nil {
+				return err
+			}
+
+			next := sch.Next(now)
+			if next.IsZero() {
+				if err := store.DeleteSchedule(ctx, dbSch.Name); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := store.UpdateScheduleNextRun(ctx, dbSch.Name, next); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return r.store.WithinTran(ctx, tran)
+}
+
+// backoff returns how long to wait after the specified number of failed
+// attempts.
+func (r *Runner) backoff(attempts int) time.Duration {
+	d := r.cfg.Backoff
+	for i := 1; i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > r.cfg.MaxBackoff {
+		d = r.cfg.MaxBackoff
+	}
+
+	return d
+}
+
+// prune deletes jobs that succeeded and are past the retention period, at
+// most once an hour. Failed jobs are kept until someone deals with them.
+func (r *Runner) prune(ctx context.Context, now time.Time) {
+	r.mu.Lock()
+	if now.Sub(r.pruned) < time.Hour {
+		r.mu.Unlock()
+		return
+	}
+	r.pruned = now
+	r.mu.Unlock()
+
+	if err := r.store.DeleteSucceeded(ctx, now.Add(-r.cfg.Retention)); err != nil {
+		r.log.Errorw("jobs runner", "status", "pruning succeeded jobs", "ERROR", err)
+	}
+}
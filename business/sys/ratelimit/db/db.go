@@ -0,0 +1,168 @@
+// Package db keeps rate limit buckets in the database so every replica of
+// the service counts against the same limits.
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/database/sql"
+	"github.com/colmmurphy91/go-service/business/sys/ratelimit"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// pruneInterval is how often buckets that have refilled are deleted.
+const pruneInterval = time.Minute
+
+// Store manages the set of APIs for rate limit bucket access.
+type Store struct {
+	log          *zap.SugaredLogger
+	tr           sql.Transactor
+	db           sqlx.ExtContext
+	isWithinTran bool
+
+	mu     *sync.Mutex
+	pruned *time.Time
+}
+
+// NewStore constructs a data for api access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		log:    log,
+		tr:     db,
+		db:     db,
+		mu:     &sync.Mutex{},
+		pruned: &time.Time{},
+	}
+}
+
+// WithinTran runs passed function and do commit/rollback at the end.
+func (s Store) WithinTran(ctx context.Context, fn func(sqlx.ExtContext) error) error {
+	if s.isWithinTran {
+		return fn(s.db)
+	}
+	return sql.WithinTran(ctx, s.log, s.tr, fn)
+}
+
+// Tran return new Store with transaction in it.
+func (s Store) Tran(tx sqlx.ExtContext) Store {
+	return Store{
+		log:          s.log,
+		tr:           s.tr,
+		db:           tx,
+		isWithinTran: true,
+		mu:           s.mu,
+		pruned:       s.pruned,
+	}
+}
+
+// Take takes a request from the bucket for the key. The bucket row is locked
+// while it is updated so concurrent requests from any replica are counted
+// one after the other.
+func (s Store) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	if err := s.prune(ctx, now); err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	var res ratelimit.Result
+	tran := func(tx sqlx.ExtContext) error {
+		store := s.Tran(tx)
+
+		full := ratelimit.NewBucket(limit, now)
+		dbBkt := Bucket{
+			Key:         key,
+			Tokens:      full.Tokens,
+			DateUpdated: now,
+			DateFull:    now,
+		}
+
+		const insert = `
+		INSERT INTO rate_limits
+			(limit_key, tokens, date_updated, date_full)
+		VALUES
+			(:limit_key, :tokens, :date_updated, :date_full)
+		ON CONFLICT (limit_key) DO NOTHING`
+
+		if err := sql.NamedExecContext(ctx, store.log, store.db, insert, dbBkt); err != nil {
+			return fmt.Errorf("inserting bucket key[%s]: %w", key, err)
+		}
+
+		const query = `
+		SELECT
+			*
+		FROM
+			rate_limits
+		WHERE
+			limit_key = :limit_key
+		FOR UPDATE`
+
+		if err := sql.NamedQueryStruct(ctx, store.log, store.db, query, dbBkt, &dbBkt); err != nil {
+			return fmt.Errorf("selecting bucket key[%s]: %w", key, err)
+		}
+
+		bkt := ratelimit.Bucket{
+			Tokens:  dbBkt.Tokens,
+			Updated: dbBkt.DateUpdated,
+		}
+		bkt, res = bkt.Take(limit, now)
+
+		dbBkt.Tokens = bkt.Tokens
+		dbBkt.DateUpdated = bkt.Updated
+		dbBkt.DateFull = now.Add(res.Reset)
+
+		const update = `
+		UPDATE
+			rate_limits
+		SET
+			"tokens" = :tokens,
+			"date_updated" = :date_updated,
+			"date_full" = :date_full
+		WHERE
+			limit_key = :limit_key`
+
+		if err := sql.NamedExecContext(ctx, store.log, store.db, update, dbBkt); err != nil {
+			return fmt.Errorf("updating bucket key[%s]: %w", key, err)
+		}
+
+		return nil
+	}
+
+	if err := s.WithinTran(ctx, tran); err != nil {
+		return ratelimit.Result{}, fmt.Errorf("tran: %w", err)
+	}
+
+	return res, nil
+}
+
+// prune deletes the buckets that have refilled since they are no different
+// to new ones. It runs at most once every pruneInterval.
+func (s Store) prune(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	if now.Sub(*s.pruned) < pruneInterval {
+		s.mu.Unlock()
+		return nil
+	}
+	*s.pruned = now
+	s.mu.Unlock()
+
+	data := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: now,
+	}
+
+	const q = `
+	DELETE FROM
+		rate_limits
+	WHERE
+		date_full <= :now`
+
+	if err := sql.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("pruning buckets: %w", err)
+	}
+
+	return nil
+}
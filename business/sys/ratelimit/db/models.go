@@ -0,0 +1,11 @@
+package db
+
+import "time"
+
+// Bucket represents a rate limit bucket kept for a key.
+type Bucket struct {
+	Key         string    `db:"limit_key"`
+	Tokens      float64   `db:"tokens"`
+	DateUpdated time.Time `db:"date_updated"`
+	DateFull    time.Time `db:"date_full"`
+}
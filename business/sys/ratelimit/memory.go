@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled are dropped.
+const sweepInterval = time.Minute
+
+// MemoryStore keeps buckets in memory. Each replica of the service counts
+// requests on its own, so a limit is per replica.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]memoryBucket
+	swept   time.Time
+}
+
+// memoryBucket is a bucket along with when it will be full again, after which
+// it is no different to a new bucket and can be dropped.
+type memoryBucket struct {
+	Bucket
+	full time.Time
+}
+
+// NewMemoryStore constructs a store that keeps buckets in memory.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]memoryBucket),
+	}
+}
+
+// Take takes a request from the bucket for the key.
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.swept) >= sweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.swept = now
+	}
+
+	b, exists := s.buckets[key]
+	if !exists {
+		b.Bucket = NewBucket(limit, now)
+	}
+
+	var res Result
+	b.Bucket, res = b.Bucket.Take(limit, now)
+	b.full = now.Add(res.Reset)
+	s.buckets[key] = b
+
+	return res, nil
+}
@@ -0,0 +1,106 @@
+// Package ratelimit provides token bucket rate limits. Buckets can be kept in
+// memory or, so limits hold across replicas, in the database.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrLimited is returned when a request is over its rate limit.
+var ErrLimited = errors.New("rate limit exceeded")
+
+// Limit describes a token bucket. It holds up to Burst requests and refills
+// at Rate requests every Per. A Limit without a Rate or Per doesn't limit
+// anything.
+type Limit struct {
+	Rate  int
+	Per   time.Duration
+	Burst int
+}
+
+// Unlimited reports whether the limit lets every request through.
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0 || l.Per <= 0
+}
+
+// size returns how many requests the bucket holds, which is never less than
+// one.
+func (l Limit) size() int {
+	if l.Burst < 1 {
+		return 1
+	}
+	return l.Burst
+}
+
+// interval returns how long the bucket takes to refill one request.
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Rate)
+}
+
+// Result reports on the bucket a request was taken from.
+type Result struct {
+	Allowed bool
+
+	// Limit is how many requests the bucket holds and Remaining is how
+	// many are left in it.
+	Limit     int
+	Remaining int
+
+	// Reset is how long until the bucket is full again. RetryAfter is how
+	// long until a request is allowed, which is zero when this one was.
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store declares a method set of behavior for keeping the buckets requests
+// are taken from.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Bucket is the state of a token bucket as of the last time a request was
+// taken from it.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// NewBucket returns a full bucket for the limit.
+func NewBucket(limit Limit, now time.Time) Bucket {
+	return Bucket{
+		Tokens:  float64(limit.size()),
+		Updated: now,
+	}
+}
+
+// Take refills the bucket for the time since it was last updated and takes a
+// request from it if there is one. It returns the new state of the bucket
+// for the store to keep.
+func (b Bucket) Take(limit Limit, now time.Time) (Bucket, Result) {
+	size := float64(limit.size())
+	interval := limit.interval()
+
+	tokens := b.Tokens
+	if elapsed := now.Sub(b.Updated); elapsed > 0 {
+		tokens = math.Min(size, tokens+float64(elapsed)/float64(interval))
+	}
+
+	res := Result{
+		Limit: limit.size(),
+	}
+
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - tokens) * float64(interval))
+	}
+
+	res.Remaining = int(tokens)
+	res.Reset = time.Duration((size - tokens) * float64(interval))
+
+	return Bucket{Tokens: tokens, Updated: now}, res
+}
@@ -0,0 +1,76 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/ratelimit"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Rate: 1, Per: time.Second, Burst: 3}
+	now := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	t.Log("Given the need to limit the rate of requests.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen taking requests from a bucket.", testID)
+		{
+			for i := 0; i < 3; i++ {
+				res, err := store.Take(ctx, "gopher", limit, now)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to take a request: %v", failed, testID, err)
+				}
+				if !res.Allowed || res.Limit != 3 || res.Remaining != 2-i {
+					t.Fatalf("\t%s\tTest %d:\tShould allow the burst: %+v", failed, testID, res)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould allow the burst.", success, testID)
+
+			res, err := store.Take(ctx, "gopher", limit, now)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to take a request: %v", failed, testID, err)
+			}
+			if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse requests over the burst: %+v", failed, testID, res)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse requests over the burst.", success, testID)
+
+			res, err = store.Take(ctx, "other", limit, now)
+			if err != nil || !res.Allowed {
+				t.Fatalf("\t%s\tTest %d:\tShould keep a bucket per key: %+v %v", failed, testID, res, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould keep a bucket per key.", success, testID)
+
+			res, err = store.Take(ctx, "gopher", limit, now.Add(time.Second))
+			if err != nil || !res.Allowed || res.Remaining != 0 {
+				t.Fatalf("\t%s\tTest %d:\tShould refill at the rate: %+v %v", failed, testID, res, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refill at the rate.", success, testID)
+
+			res, err = store.Take(ctx, "gopher", limit, now.Add(time.Hour))
+			if err != nil || !res.Allowed || res.Remaining != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould refill no further than the burst: %+v %v", failed, testID, res, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refill no further than the burst.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen the limit has no rate.", testID)
+		{
+			if !(ratelimit.Limit{}).Unlimited() {
+				t.Fatalf("\t%s\tTest %d:\tShould not limit anything.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not limit anything.", success, testID)
+		}
+	}
+}
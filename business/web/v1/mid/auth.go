@@ -12,7 +12,8 @@ import (
 	"github.com/colmmurphy91/go-service/foundation/web"
 )
 
-// Authenticate validates a JWT from the `Authorization` header.
+// Authenticate validates a JWT from the `Authorization` header. Machine
+// clients can instead present an API key in the `X-API-Key` header.
 func Authenticate(a *auth.Auth) web.Middleware {
 
 	// This is the actual middleware function to be executed.
@@ -21,6 +22,25 @@ func Authenticate(a *auth.Auth) web.Middleware {
 		// Create the handler that will be attached in the middleware chain.
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 
+			// An API key takes the place of a token when it is provided.
+			if key := r.Header.Get("X-API-Key"); key != "" {
+				v, err := web.GetValues(ctx)
+				if err != nil {
+					return web.NewShutdownError("web value missing from context")
+				}
+
+				claims, err := a.AuthenticateAPIKey(ctx, key, v.Now)
+				if err != nil {
+					if errors.Is(err, auth.ErrAPIKeyInvalid) {
+						return v1Web.NewRequestError(err, http.StatusUnauthorized)
+					}
+					return fmt.Errorf("authenticating api key: %w", err)
+				}
+
+				ctx = auth.SetClaims(ctx, claims)
+				return handler(ctx, w, r)
+			}
+
 			// Expecting: bearer <token>
 			authStr := r.Header.Get("authorization")
 
@@ -37,6 +57,14 @@ func Authenticate(a *auth.Auth) web.Middleware {
 				return v1Web.NewRequestError(err, http.StatusUnauthorized)
 			}
 
+			// Make sure the token has not been revoked since it was issued.
+			if err := a.CheckRevoked(ctx, claims); err != nil {
+				if errors.Is(err, auth.ErrRevoked) {
+					return v1Web.NewRequestError(err, http.StatusUnauthorized)
+				}
+				return fmt.Errorf("checking revocation: %w", err)
+			}
+
 			// Add claims to the context, so they can be retrieved later.
 			ctx = auth.SetClaims(ctx, claims)
 
@@ -84,3 +112,48 @@ func Authorize(roles ...string) web.Middleware {
 
 	return m
 }
+
+// ResourceLoader looks up the resource a request acts on so its ownership can
+// be checked. A nil resource with a nil error means there is no resource to
+// check against, such as a delete of something already gone.
+type ResourceLoader func(ctx context.Context, r *http.Request) (*auth.Resource, error)
+
+// Require validates that an authenticated user holds the permission against
+// the resource returned by the loader. The loader can be nil for permissions
+// that don't apply to a specific resource.
+func Require(perm auth.Permission, loader ResourceLoader) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			// If the context is missing this value return failure.
+			claims, err := auth.GetClaims(ctx)
+			if err != nil {
+				return v1Web.NewRequestError(
+					fmt.Errorf("you are not authorized for that action, no claims"),
+					http.StatusForbidden,
+				)
+			}
+
+			var res *auth.Resource
+			if loader != nil {
+				if res, err = loader(ctx, r); err != nil {
+					return err
+				}
+			}
+
+			if err := auth.Check(claims, perm, res); err != nil {
+				return v1Web.NewRequestError(err, http.StatusForbidden)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
@@ -3,6 +3,7 @@ package mid
 import (
 	"context"
 	"net/http"
+	"strconv"
 
 	"github.com/colmmurphy91/go-service/business/sys/validate"
 	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
@@ -53,6 +54,11 @@ func Errors(log *zap.SugaredLogger) web.Middleware {
 					}
 					status = reqErr.Status
 
+					// Round up so the client never retries too early.
+					if reqErr.RetryAfter > 0 {
+						w.Header().Set("Retry-After", strconv.Itoa(seconds(reqErr.RetryAfter)))
+					}
+
 				default:
 					er = v1Web.ErrorResponse{
 						Error: http.StatusText(http.StatusInternalServerError),
@@ -0,0 +1,94 @@
+package mid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/colmmurphy91/go-service/business/sys/auth"
+	"github.com/colmmurphy91/go-service/business/sys/ratelimit"
+	v1Web "github.com/colmmurphy91/go-service/business/web/v1"
+	"github.com/colmmurphy91/go-service/foundation/web"
+)
+
+// KeyFunc returns the key a request is rate limited by. Requests with the same
+// key share a bucket.
+type KeyFunc func(ctx context.Context, r *http.Request) string
+
+// ByIP limits requests by the address of the client.
+func ByIP(ctx context.Context, r *http.Request) string {
+	return "ip:" + web.ClientIP(r)
+}
+
+// BySubject limits requests by the user they were authenticated as. Requests
+// that weren't authenticated are limited by the address of the client.
+func BySubject(ctx context.Context, r *http.Request) string {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil || claims.Subject == "" {
+		return ByIP(ctx, r)
+	}
+	return "sub:" + claims.Subject
+}
+
+// ByAPIKey limits requests made with an API key by the key, so each machine
+// client has a bucket of its own. Other requests are limited by subject.
+func ByAPIKey(ctx context.Context, r *http.Request) string {
+	if r.Header.Get("X-API-Key") != "" {
+		if claims, err := auth.GetClaims(ctx); err == nil && claims.ID != "" {
+			return "key:" + claims.ID
+		}
+	}
+	return BySubject(ctx, r)
+}
+
+// RateLimit limits how often requests with the same key can be made to the
+// routes of a group, using a token bucket from the store for each key.
+// Requests over the limit are refused with a 429. A nil store or an unlimited
+// limit lets every request through.
+//
+// Keys that use the claims need the middleware to run after Authenticate.
+func RateLimit(group string, store ratelimit.Store, limit ratelimit.Limit, keyFn KeyFunc) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+		if store == nil || limit.Unlimited() {
+			return handler
+		}
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v, err := web.GetValues(ctx)
+			if err != nil {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			key := group + ":" + keyFn(ctx, r)
+
+			res, err := store.Take(ctx, key, limit, v.Now)
+			if err != nil {
+				return fmt.Errorf("rate limit key[%s]: %w", key, err)
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+
+			if !res.Allowed {
+				return v1Web.NewThrottleError(ratelimit.ErrLimited, res.RetryAfter)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// seconds rounds a duration up to whole seconds for a header.
+func seconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
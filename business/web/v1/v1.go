@@ -1,7 +1,11 @@
 // Package v1 represents types used by the web application for v1.
 package v1
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+	"time"
+)
 
 // ErrorResponse is the form used for API responses from failures in the API.
 type ErrorResponse struct {
@@ -14,12 +18,21 @@ type ErrorResponse struct {
 type RequestError struct {
 	Err    error
 	Status int
+
+	// RetryAfter tells the client how long to wait before trying again.
+	RetryAfter time.Duration
 }
 
 // NewRequestError wraps a provided error with an HTTP status code. This
 // function should be used when handlers encounter expected errors.
 func NewRequestError(err error, status int) error {
-	return &RequestError{err, status}
+	return &RequestError{Err: err, Status: status}
+}
+
+// NewThrottleError wraps a provided error for a client that has to wait
+// before trying again. It responds with a 429 and a Retry-After header.
+func NewThrottleError(err error, retryAfter time.Duration) error {
+	return &RequestError{Err: err, Status: http.StatusTooManyRequests, RetryAfter: retryAfter}
 }
 
 // Error implements the error interface. It uses the default message of the
@@ -0,0 +1,216 @@
+// Package cron parses cron expressions and works out when they next fire.
+// Expressions have the five standard fields, minute hour day-of-month month
+// day-of-week, each a *, a value, a range or a list of those, optionally
+// with a /step. The descriptors @yearly, @monthly, @weekly, @daily and
+// @hourly are accepted too.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSpec is returned when an expression can not be parsed.
+var ErrInvalidSpec = errors.New("invalid cron spec")
+
+// descriptors maps the named schedules to their expressions.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// bounds holds the range of values a field may take.
+type bounds struct {
+	name     string
+	min, max int
+}
+
+var fields = []bounds{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// Schedule is a parsed cron expression. Each field is a bit set of the
+// values it matches.
+type Schedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	// When both day fields are restricted a day matches if either does, as
+	// it does in cron.
+	domStar bool
+	dowStar bool
+}
+
+// Parse parses a cron expression.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if expr, exists := descriptors[spec]; exists {
+		spec = expr
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return Schedule{}, fmt.Errorf("%w: %q must have %d fields", ErrInvalidSpec, spec, len(fields))
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return Schedule{}, fmt.Errorf("%w: %q: %s", ErrInvalidSpec, spec, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday may be given as 7 as well as 0.
+	dow := sets[4]
+	if dow&(1<<7) != 0 {
+		dow = dow&^(1<<7) | 1
+	}
+
+	s := Schedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     dow,
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}
+
+	return s, nil
+}
+
+// Next returns the first time after t the schedule fires, in the location
+// of t. It returns the zero time if the schedule never fires, like on the
+// 31st of February.
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// Every possible day comes round within a few years.
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// =============================================================================
+
+// matchDay reports whether the day of t matches the day fields.
+func (s Schedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case s.domStar && s.dowStar:
+		return true
+	case s.domStar:
+		return dow
+	case s.dowStar:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// parseField parses a comma separated list of terms into a bit set.
+func parseField(field string, b bounds) (uint64, error) {
+	max := b.max
+	if b.name == "day of week" {
+		max = 7
+	}
+
+	var set uint64
+	for _, term := range strings.Split(field, ",") {
+		lo, hi, step, err := parseTerm(term, b.min, max)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %s", b.name, err)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
+
+// parseTerm parses a *, value or range with an optional step.
+func parseTerm(term string, min int, max int) (lo int, hi int, step int, err error) {
+	step = 1
+	if i := strings.Index(term, "/"); i >= 0 {
+		step, err = strconv.Atoi(term[i+1:])
+		if err != nil || step < 1 {
+			return 0, 0, 0, fmt.Errorf("invalid step in %q", term)
+		}
+		term = term[:i]
+	}
+
+	switch i := strings.Index(term, "-"); {
+	case term == "*":
+		return min, max, step, nil
+
+	case i >= 0:
+		if lo, err = strconv.Atoi(term[:i]); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid value in %q", term)
+		}
+		if hi, err = strconv.Atoi(term[i+1:]); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid value in %q", term)
+		}
+
+	default:
+		if lo, err = strconv.Atoi(term); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid value in %q", term)
+		}
+		hi = lo
+
+		// A single value with a step runs to the end of the range.
+		if step > 1 {
+			hi = max
+		}
+	}
+
+	if lo < min || hi > max || lo > hi {
+		return 0, 0, 0, fmt.Errorf("%q is out of range %d-%d", term, min, max)
+	}
+
+	return lo, hi, step, nil
+}
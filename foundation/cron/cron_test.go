@@ -0,0 +1,72 @@
+package cron_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/foundation/cron"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2021, time.March, 15, 10, 30, 20, 0, time.UTC) // A Monday.
+
+	tt := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2021, time.March, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, time.March, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2021, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2021, time.March, 16, 10, 30, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2021, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, time.March, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2021, time.March, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2021, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+
+	t.Log("Given the need to work out when a schedule next fires.")
+	{
+		for testID, tc := range tt {
+			t.Logf("\tTest %d:\tWhen handling %q.", testID, tc.spec)
+			{
+				s, err := cron.Parse(tc.spec)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to parse the spec : %s.", failed, testID, err)
+				}
+
+				if got := s.Next(from); !got.Equal(tc.next) {
+					t.Fatalf("\t%s\tTest %d:\tShould fire at %v : got %v.", failed, testID, tc.next, got)
+				}
+				t.Logf("\t%s\tTest %d:\tShould fire at %v.", success, testID, tc.next)
+			}
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	specs := []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@often"}
+
+	t.Log("Given the need to reject invalid schedules.")
+	{
+		for testID, spec := range specs {
+			t.Logf("\tTest %d:\tWhen handling %q.", testID, spec)
+			{
+				if _, err := cron.Parse(spec); !errors.Is(err, cron.ErrInvalidSpec) {
+					t.Fatalf("\t%s\tTest %d:\tShould reject the spec : %v.", failed, testID, err)
+				}
+				t.Logf("\t%s\tTest %d:\tShould reject the spec.", success, testID)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package keystore
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NewEnv constructs a KeyStore from environment variables that start with the
+// specified prefix. This suits deployments that mount keys from a secret into
+// the environment rather than shipping them inside the image. The rest of the
+// variable name is the key id, lower cased with underscores turned into
+// dashes. The value is the PEM encoded private key, optionally base64 encoded.
+// RSA, ECDSA P-256 and Ed25519 keys are supported.
+// Example: SALES_JWT_KEY_54BB2165_71E1_41A6_AF3E_7DA4A0E1E2C1=LS0tLS1CRUdJTi...
+func NewEnv(prefix string, options ...func(opts *Options)) (*KeyStore, error) {
+	ks := New(options...)
+
+	for _, env := range os.Environ() {
+		name, value, ok := cut(env, "=")
+		if !ok || !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+
+		kid := strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(name, prefix)), "_", "-")
+
+		privatePEM, err := decodePEM(value)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key %s: %w", name, err)
+		}
+
+		privateKey, err := parsePrivateKey(privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("parsing key %s: %w", name, err)
+		}
+
+		ks.store[kid] = privateKey
+	}
+
+	if len(ks.store) == 0 {
+		return nil, fmt.Errorf("no keys found with prefix %s", prefix)
+	}
+
+	return ks, nil
+}
+
+// decodePEM accepts a PEM document as is or base64 encoded.
+func decodePEM(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(value); err == nil {
+			return b, nil
+		}
+	}
+
+	return nil, errors.New("value is neither PEM nor base64 encoded PEM")
+}
+
+// cut slices s around the first instance of sep. It can be replaced by
+// strings.Cut once the module moves past Go 1.17.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,240 @@
+package keystore
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPConfig represents the configuration for fetching keys from an HTTP
+// secrets API that speaks the Vault KV version 2 protocol.
+type HTTPConfig struct {
+	Address  string
+	Token    string
+	Path     string
+	CacheTTL time.Duration
+	Client   *http.Client
+}
+
+// HTTPStore implements the KeyLookup interface for use with the auth package
+// by reading keys from a secret in an HTTP secrets API. Every field of the
+// secret is a key, the field name being the key id and the value the PEM
+// encoded private key. Keys are cached and the secret is read again once the
+// cache has expired or an unknown key id is requested.
+type HTTPStore struct {
+	cfg HTTPConfig
+
+	mu       sync.Mutex
+	store    map[string]crypto.PrivateKey
+	fetched  time.Time
+	fetching *fetch
+}
+
+// fetch is a read of the secret that is in progress. Refreshes that start
+// while it runs wait for it rather than reading the secret again.
+type fetch struct {
+	done chan struct{}
+	err  error
+}
+
+// fetchTimeout bounds how long a lookup can wait on the secrets API.
+const fetchTimeout = 5 * time.Second
+
+// minRefresh limits how often an unknown key id can force the secret to be
+// read again, so tokens with made up key ids can't flood the secrets API.
+const minRefresh = 5 * time.Second
+
+// NewHTTP constructs an HTTPStore and reads the secret once to make sure the
+// secrets API can be reached.
+func NewHTTP(cfg HTTPConfig) (*HTTPStore, error) {
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = 5 * time.Minute
+	}
+
+	hs := HTTPStore{
+		cfg: cfg,
+	}
+
+	if err := hs.refresh(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return &hs, nil
+}
+
+// PrivateKey searches the cached secret for a given kid and returns
+// the private key.
+func (hs *HTTPStore) PrivateKey(kid string) (crypto.PrivateKey, error) {
+	return hs.lookup(kid)
+}
+
+// PublicKey searches the cached secret for a given kid and returns
+// the public key.
+func (hs *HTTPStore) PublicKey(kid string) (crypto.PublicKey, error) {
+	privateKey, err := hs.lookup(kid)
+	if err != nil {
+		return nil, err
+	}
+	return publicKey(privateKey)
+}
+
+// JWKS returns the public half of every cached key.
+func (hs *HTTPStore) JWKS() JWKS {
+	now := time.Now()
+
+	hs.mu.Lock()
+	stale := now.Sub(hs.fetched) >= hs.cfg.CacheTTL && hs.fetching == nil
+	hs.mu.Unlock()
+
+	if stale {
+
+		// A failed refresh keeps serving the keys we already have.
+		_ = hs.refresh(now)
+	}
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	kids := make([]string, 0, len(hs.store))
+	for kid := range hs.store {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	jwks := JWKS{
+		Keys: make([]JWK, 0, len(kids)),
+	}
+	for _, kid := range kids {
+		publicKey, err := publicKey(hs.store[kid])
+		if err != nil {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, newJWK(kid, publicKey))
+	}
+
+	return jwks
+}
+
+// lookup returns the key for the kid, reading the secret again when the cache
+// has expired or the kid is unknown.
+func (hs *HTTPStore) lookup(kid string) (crypto.PrivateKey, error) {
+	now := time.Now()
+
+	// A cached key is used while another lookup reads the secret again, an
+	// unknown one waits for the read to finish.
+	hs.mu.Lock()
+	age := now.Sub(hs.fetched)
+	_, found := hs.store[kid]
+	fetching := hs.fetching != nil
+	hs.mu.Unlock()
+
+	if (found && age >= hs.cfg.CacheTTL && !fetching) || (!found && (age >= minRefresh || fetching)) {
+		if err := hs.refresh(now); err != nil && !found {
+			return nil, fmt.Errorf("kid lookup failed: %w", err)
+		}
+	}
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	privateKey, found := hs.store[kid]
+	if !found {
+		return nil, errors.New("kid lookup failed")
+	}
+	return privateKey, nil
+}
+
+// refresh reads the secret and replaces the cached keys. The lock is not held
+// while the secret is read, so lookups of cached keys are never held up by
+// the secrets API, and a refresh already in progress is waited on rather than
+// started again. The fetch time moves forward even on failure so an
+// unavailable secrets API is not retried on every lookup.
+func (hs *HTTPStore) refresh(now time.Time) error {
+	hs.mu.Lock()
+	if f := hs.fetching; f != nil {
+		hs.mu.Unlock()
+		<-f.done
+		return f.err
+	}
+
+	f := fetch{
+		done: make(chan struct{}),
+	}
+	hs.fetching = &f
+	hs.fetched = now
+	hs.mu.Unlock()
+
+	store, err := hs.read()
+
+	hs.mu.Lock()
+	if err == nil {
+		hs.store = store
+	}
+	f.err = err
+	hs.fetching = nil
+	hs.mu.Unlock()
+
+	close(f.done)
+
+	return err
+}
+
+// read reads the secret and parses the keys in it.
+func (hs *HTTPStore) read() (map[string]crypto.PrivateKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	url := strings.TrimSuffix(hs.cfg.Address, "/") + "/v1/" + strings.TrimPrefix(hs.cfg.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", hs.cfg.Token)
+
+	resp, err := hs.cfg.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("reading secret: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("reading secret: status %d", resp.StatusCode)
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("decoding secret: %w", err)
+	}
+
+	store := make(map[string]crypto.PrivateKey, len(secret.Data.Data))
+	for kid, value := range secret.Data.Data {
+		privatePEM, err := decodePEM(value)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key %s: %w", kid, err)
+		}
+
+		privateKey, err := parsePrivateKey(privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("parsing key %s: %w", kid, err)
+		}
+
+		store[kid] = privateKey
+	}
+
+	return store, nil
+}
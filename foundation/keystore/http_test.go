@@ -0,0 +1,215 @@
+package keystore_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/foundation/keystore"
+)
+
+// secretsAPI is an httptest stand-in for a Vault style secrets API.
+type secretsAPI struct {
+	mu    sync.Mutex
+	keys  map[string]string
+	calls int
+}
+
+func (s *secretsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/secret/data/sales" || r.Header.Get("X-Vault-Token") != "token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+
+	var resp struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	resp.Data.Data = s.keys
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *secretsAPI) set(keys map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *secretsAPI) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func TestHTTP(t *testing.T) {
+	pem, err := keyDocs.ReadFile("test.pem")
+	if err != nil {
+		t.Fatalf("reading test key: %v", err)
+	}
+
+	api := secretsAPI{
+		keys: map[string]string{"one": string(pem)},
+	}
+	srv := httptest.NewServer(&api)
+	defer srv.Close()
+
+	t.Log("Given the need to read keys from a secrets API.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling a secret with key(s).", testID)
+		{
+			cfg := keystore.HTTPConfig{
+				Address:  srv.URL,
+				Token:    "token",
+				Path:     "secret/data/sales",
+				CacheTTL: time.Hour,
+			}
+
+			hs, err := keystore.NewHTTP(cfg)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct the store: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to construct the store.", success, testID)
+
+			for i := 0; i < 3; i++ {
+				if _, err := hs.PublicKey("one"); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to find key in store: %v", failed, testID, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to find key in store.", success, testID)
+
+			if n := api.count(); n != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould serve lookups from the cache: %d calls", failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould serve lookups from the cache.", success, testID)
+
+			api.set(map[string]string{
+				"one": string(pem),
+				"two": base64.StdEncoding.EncodeToString(pem),
+			})
+
+			if _, err := hs.PrivateKey("two"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not refresh again straight away for an unknown key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not refresh again straight away for an unknown key.", success, testID)
+
+			if jwks := hs.JWKS(); len(jwks.Keys) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould publish the cached keys: %d", failed, testID, len(jwks.Keys))
+			}
+			t.Logf("\t%s\tTest %d:\tShould publish the cached keys.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen the cache has expired.", testID)
+		{
+			cfg := keystore.HTTPConfig{
+				Address:  srv.URL,
+				Token:    "token",
+				Path:     "secret/data/sales",
+				CacheTTL: time.Nanosecond,
+			}
+
+			hs, err := keystore.NewHTTP(cfg)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct the store: %v", failed, testID, err)
+			}
+
+			api.set(map[string]string{"three": string(pem)})
+
+			if _, err := hs.PrivateKey("one"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould drop keys removed from the secret.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould drop keys removed from the secret.", success, testID)
+
+			if _, err := hs.PrivateKey("three"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould pick up keys added to the secret: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould pick up keys added to the secret.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen the secrets API rejects the request.", testID)
+		{
+			cfg := keystore.HTTPConfig{
+				Address: srv.URL,
+				Token:   "wrong",
+				Path:    "secret/data/sales",
+			}
+
+			if _, err := keystore.NewHTTP(cfg); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould fail to construct the store.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail to construct the store.", success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen the secrets API is slow to answer.", testID)
+		{
+			var calls int32
+			held := make(chan struct{})
+			release := make(chan struct{})
+			slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) > 1 {
+					held <- struct{}{}
+					<-release
+				}
+				api.ServeHTTP(w, r)
+			}))
+			defer slow.Close()
+
+			api.set(map[string]string{"one": string(pem)})
+
+			cfg := keystore.HTTPConfig{
+				Address:  slow.URL,
+				Token:    "token",
+				Path:     "secret/data/sales",
+				CacheTTL: time.Nanosecond,
+			}
+
+			hs, err := keystore.NewHTTP(cfg)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct the store: %v", failed, testID, err)
+			}
+
+			refreshed := make(chan error, 1)
+			go func() {
+				_, err := hs.PrivateKey("one")
+				refreshed <- err
+			}()
+			<-held
+
+			found := make(chan error, 1)
+			go func() {
+				_, err := hs.PublicKey("one")
+				found <- err
+			}()
+
+			select {
+			case err := <-found:
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould find the cached key during a refresh: %v", failed, testID, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("\t%s\tTest %d:\tShould not wait on a refresh for a cached key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not wait on a refresh for a cached key.", success, testID)
+
+			close(release)
+			if err := <-refreshed; err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould finish the refresh: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould finish the refresh.", success, testID)
+		}
+	}
+}
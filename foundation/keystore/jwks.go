@@ -0,0 +1,141 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JWK represents a single public key in JSON Web Key format (RFC 7517 and
+// RFC 8037). Which of the key fields are set depends on the key type.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// PublicKey decodes the public key described by the JWK. Only the key types
+// we can sign with are supported.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	enc := base64.RawURLEncoding
+
+	switch k.KeyType {
+	case "RSA":
+		n, err := enc.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding modulus: %w", err)
+		}
+		e, err := enc.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding exponent: %w", err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("exponent is too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := enc.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding x: %w", err)
+		}
+		y, err := enc.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decoding y: %w", err)
+		}
+		pub := ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &pub, nil
+
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := enc.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding x: %w", err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("key is the wrong size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+}
+
+// JWKS represents a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every key that can currently be used to
+// validate tokens, so other services can verify tokens we sign.
+func (ks *KeyStore) JWKS() JWKS {
+	jwks := JWKS{
+		Keys: []JWK{},
+	}
+
+	for _, kid := range ks.KIDs() {
+		publicKey, err := ks.PublicKey(kid)
+		if err != nil {
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, newJWK(kid, publicKey))
+	}
+
+	return jwks
+}
+
+// newJWK describes a public key along with the algorithm it signs with.
+func newJWK(kid string, publicKey crypto.PublicKey) JWK {
+	enc := base64.RawURLEncoding
+
+	jwk := JWK{
+		Use:   "sig",
+		KeyID: kid,
+	}
+
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.Algorithm = "RS256"
+		jwk.N = enc.EncodeToString(k.N.Bytes())
+		jwk.E = enc.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Algorithm = "ES256"
+		jwk.Curve = k.Curve.Params().Name
+		jwk.X = enc.EncodeToString(k.X.FillBytes(make([]byte, size)))
+		jwk.Y = enc.EncodeToString(k.Y.FillBytes(make([]byte, size)))
+
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Algorithm = "EdDSA"
+		jwk.Curve = "Ed25519"
+		jwk.X = enc.EncodeToString(k)
+	}
+
+	return jwk
+}
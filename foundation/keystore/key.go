@@ -0,0 +1,63 @@
+package keystore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// parsePrivateKey parses a PEM encoded RSA, ECDSA P-256 or Ed25519 private key.
+// RSA keys are accepted in PKCS #1 form, ECDSA keys in SEC 1 form and any of
+// them in PKCS #8 form.
+func parsePrivateKey(privatePEM []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(privatePEM)
+	if block == nil {
+		return nil, errors.New("key must be PEM encoded")
+	}
+
+	var key crypto.PrivateKey
+	var err error
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := publicKey(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// publicKey returns the public half of a supported private key.
+func publicKey(key crypto.PrivateKey) (crypto.PublicKey, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &k.PublicKey, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported curve %s, only P-256 is supported", k.Curve.Params().Name)
+		}
+		return &k.PublicKey, nil
+	case ed25519.PrivateKey:
+		return k.Public(), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
@@ -3,36 +3,65 @@
 package keystore
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"path"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+)
 
-	"github.com/golang-jwt/jwt/v4"
+// Set of error variables for reloading a key store.
+var (
+	ErrNoSource      = errors.New("key store has no directory to reload from")
+	ErrActiveMissing = errors.New("active kid is not in the key directory")
 )
 
+// Options represent optional parameters.
+type Options struct {
+	grace time.Duration
+}
+
+// WithGrace sets how long a retired key can still be used to validate tokens
+// after it stops being used for signing.
+func WithGrace(grace time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.grace = grace
+	}
+}
+
 // KeyStore represents an in memory store implementation of the
 // KeyLookup interface for use with the auth package.
 type KeyStore struct {
-	mu    sync.RWMutex
-	store map[string]*rsa.PrivateKey
+	mu      sync.RWMutex
+	store   map[string]crypto.PrivateKey
+	retired map[string]time.Time
+	missing map[string]struct{}
+	fsys    fs.FS
+	opts    Options
 }
 
 // New constructs an empty KeyStore ready for use.
-func New() *KeyStore {
-	return &KeyStore{
-		store: make(map[string]*rsa.PrivateKey),
-	}
+func New(options ...func(opts *Options)) *KeyStore {
+	return NewMap(make(map[string]crypto.PrivateKey), options...)
 }
 
 // NewMap constructs a KeyStore with an initial set of keys.
-func NewMap(store map[string]*rsa.PrivateKey) *KeyStore {
+func NewMap(store map[string]crypto.PrivateKey, options ...func(opts *Options)) *KeyStore {
+	var opts Options
+	for _, option := range options {
+		option(&opts)
+	}
+
 	return &KeyStore{
-		store: store,
+		store:   store,
+		retired: make(map[string]time.Time),
+		missing: make(map[string]struct{}),
+		opts:    opts,
 	}
 }
 
@@ -40,58 +69,83 @@ func NewMap(store map[string]*rsa.PrivateKey) *KeyStore {
 // of a directory. The name of each PEM file will be used as the key id.
 // Example: keystore.NewFS(os.DirFS("/zarf/keys/"))
 // Example: /zarf/keys/54bb2165-71e1-41a6-af3e-7da4a0e1e2c1.pem
-func NewFS(fsys fs.FS) (*KeyStore, error) {
-	ks := New()
+func NewFS(fsys fs.FS, options ...func(opts *Options)) (*KeyStore, error) {
+	store, err := readFS(fsys)
+	if err != nil {
+		return nil, err
+	}
 
-	fn := func(fileName string, dirEntry fs.DirEntry, err error) error {
-		if err != nil {
-			return fmt.Errorf("walkdir failure: %w", err)
-		}
+	ks := NewMap(store, options...)
+	ks.fsys = fsys
 
-		if dirEntry.IsDir() {
-			return nil
-		}
+	return ks, nil
+}
 
-		if path.Ext(fileName) != ".pem" {
-			return nil
-		}
+// Reload reads the directory the store was constructed from again and brings
+// the store in line with it. Keys that are new on disk are added, as are keys
+// that come back after being removed. Keys that are no longer on disk are
+// retired and dropped once their grace period has passed. When activeKID is
+// not empty and its key is not on disk, ErrActiveMissing is returned and the
+// store is left as it was. The ids of the added keys are returned.
+func (ks *KeyStore) Reload(now time.Time, activeKID string) ([]string, error) {
+	if ks.fsys == nil {
+		return nil, ErrNoSource
+	}
 
-		file, err := fsys.Open(fileName)
-		if err != nil {
-			return fmt.Errorf("opening key file: %w", err)
-		}
-		defer file.Close()
+	store, err := readFS(ks.fsys)
+	if err != nil {
+		return nil, err
+	}
 
-		// limit PEM file size to 1 megabyte. This should be reasonable for
-		// almost any PEM file and prevents shenanigans like linking the file
-		// to /dev/random or something like that.
-		privatePEM, err := io.ReadAll(io.LimitReader(file, 1024*1024))
-		if err != nil {
-			return fmt.Errorf("reading auth private key: %w", err)
-		}
+	if _, exists := store[activeKID]; activeKID != "" && !exists {
+		return nil, fmt.Errorf("kid[%s]: %w", activeKID, ErrActiveMissing)
+	}
 
-		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
-		if err != nil {
-			return fmt.Errorf("parsing auth private key: %w", err)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	var added []string
+	for kid, privateKey := range store {
+		_, exists := ks.store[kid]
+		_, missing := ks.missing[kid]
+		if !exists || missing {
+			ks.store[kid] = privateKey
+			delete(ks.retired, kid)
+			delete(ks.missing, kid)
+			added = append(added, kid)
 		}
+	}
 
-		ks.store[strings.TrimSuffix(dirEntry.Name(), ".pem")] = privateKey
-		return nil
+	for kid := range ks.store {
+		if _, exists := store[kid]; exists {
+			continue
+		}
+		if _, retired := ks.retired[kid]; !retired {
+			ks.retired[kid] = now.Add(ks.opts.grace)
+		}
+		ks.missing[kid] = struct{}{}
 	}
 
-	if err := fs.WalkDir(fsys, ".", fn); err != nil {
-		return nil, fmt.Errorf("walking directory: %w", err)
+	for kid, until := range ks.retired {
+		if !now.Before(until) {
+			delete(ks.store, kid)
+			delete(ks.retired, kid)
+			delete(ks.missing, kid)
+		}
 	}
 
-	return ks, nil
+	sort.Strings(added)
+	return added, nil
 }
 
 // Add adds a private key and combination kid to the store.
-func (ks *KeyStore) Add(privateKey *rsa.PrivateKey, kid string) {
+func (ks *KeyStore) Add(privateKey crypto.PrivateKey, kid string) {
 	ks.mu.Lock()
 	defer ks.mu.Unlock()
 
 	ks.store[kid] = privateKey
+	delete(ks.retired, kid)
+	delete(ks.missing, kid)
 }
 
 // Remove removes a private key and combination kid to the store.
@@ -100,11 +154,47 @@ func (ks *KeyStore) Remove(kid string) {
 	defer ks.mu.Unlock()
 
 	delete(ks.store, kid)
+	delete(ks.retired, kid)
+	delete(ks.missing, kid)
+}
+
+// Retire stops a key from being used for signing. Its public key can still be
+// used to validate tokens until the grace period has passed.
+func (ks *KeyStore) Retire(kid string, now time.Time) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if _, exists := ks.store[kid]; !exists {
+		return
+	}
+	if _, retired := ks.retired[kid]; !retired {
+		ks.retired[kid] = now.Add(ks.opts.grace)
+	}
+}
+
+// KIDs returns the sorted set of key ids that can currently be used to
+// validate tokens.
+func (ks *KeyStore) KIDs() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+
+	kids := make([]string, 0, len(ks.store))
+	for kid := range ks.store {
+		if until, retired := ks.retired[kid]; retired && !now.Before(until) {
+			continue
+		}
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	return kids
 }
 
 // PrivateKey searches the key store for a given kid and returns
-// the private key.
-func (ks *KeyStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
+// the private key. Retired keys are not returned.
+func (ks *KeyStore) PrivateKey(kid string) (crypto.PrivateKey, error) {
 	ks.mu.RLock()
 	defer ks.mu.RUnlock()
 
@@ -112,12 +202,15 @@ func (ks *KeyStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
 	if !found {
 		return nil, errors.New("kid lookup failed")
 	}
+	if _, retired := ks.retired[kid]; retired {
+		return nil, errors.New("kid has been retired")
+	}
 	return privateKey, nil
 }
 
 // PublicKey searches the key store for a given kid and returns
-// the public key.
-func (ks *KeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
+// the public key. Retired keys are returned until their grace period ends.
+func (ks *KeyStore) PublicKey(kid string) (crypto.PublicKey, error) {
 	ks.mu.RLock()
 	defer ks.mu.RUnlock()
 
@@ -125,5 +218,57 @@ func (ks *KeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
 	if !found {
 		return nil, errors.New("kid lookup failed")
 	}
-	return &privateKey.PublicKey, nil
+	if until, retired := ks.retired[kid]; retired && !time.Now().Before(until) {
+		return nil, errors.New("kid lookup failed")
+	}
+	return publicKey(privateKey)
+}
+
+// =============================================================================
+
+// readFS parses every PEM file rooted inside of a directory.
+func readFS(fsys fs.FS) (map[string]crypto.PrivateKey, error) {
+	store := make(map[string]crypto.PrivateKey)
+
+	fn := func(fileName string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("walkdir failure: %w", err)
+		}
+
+		if dirEntry.IsDir() {
+			return nil
+		}
+
+		if path.Ext(fileName) != ".pem" {
+			return nil
+		}
+
+		file, err := fsys.Open(fileName)
+		if err != nil {
+			return fmt.Errorf("opening key file: %w", err)
+		}
+		defer file.Close()
+
+		// limit PEM file size to 1 megabyte. This should be reasonable for
+		// almost any PEM file and prevents shenanigans like linking the file
+		// to /dev/random or something like that.
+		privatePEM, err := io.ReadAll(io.LimitReader(file, 1024*1024))
+		if err != nil {
+			return fmt.Errorf("reading auth private key: %w", err)
+		}
+
+		privateKey, err := parsePrivateKey(privatePEM)
+		if err != nil {
+			return fmt.Errorf("parsing auth private key: %w", err)
+		}
+
+		store[strings.TrimSuffix(dirEntry.Name(), ".pem")] = privateKey
+		return nil
+	}
+
+	if err := fs.WalkDir(fsys, ".", fn); err != nil {
+		return nil, fmt.Errorf("walking directory: %w", err)
+	}
+
+	return store, nil
 }
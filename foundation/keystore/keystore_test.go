@@ -1,8 +1,19 @@
 package keystore_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"embed" // Calls init function.
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/colmmurphy91/go-service/foundation/keystore"
 )
@@ -35,10 +46,284 @@ func TestRead(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to find key in store.", success, testID)
 
-			if err := pk.Validate(); err != nil {
+			rsaKey, ok := pk.(*rsa.PrivateKey)
+			if !ok {
+				t.Fatalf("\t%s\tTest %d:\tShould get back an RSA key: %T", failed, testID, pk)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back an RSA key.", success, testID)
+
+			if err := rsaKey.Validate(); err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to validate the key: %v", failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to validate the key.", success, testID)
 		}
 	}
 }
+
+func TestReload(t *testing.T) {
+	pem, err := keyDocs.ReadFile("test.pem")
+	if err != nil {
+		t.Fatalf("reading test key: %v", err)
+	}
+
+	t.Log("Given the need to rotate keys without a restart.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen keys are added to and removed from the directory.", testID)
+		{
+			fsys := fstest.MapFS{
+				"old.pem": {Data: pem},
+			}
+
+			ks, err := keystore.NewFS(fsys, keystore.WithGrace(time.Hour))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct key store: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to construct key store.", success, testID)
+
+			now := time.Now()
+			delete(fsys, "old.pem")
+			fsys["new.pem"] = &fstest.MapFile{Data: pem}
+
+			added, err := ks.Reload(now, "")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reload the key store: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to reload the key store.", success, testID)
+
+			if len(added) != 1 || added[0] != "new" {
+				t.Fatalf("\t%s\tTest %d:\tShould report the new key as added: %v", failed, testID, added)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the new key as added.", success, testID)
+
+			if _, err := ks.PrivateKey("old"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not sign with a retired key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not sign with a retired key.", success, testID)
+
+			if _, err := ks.PublicKey("old"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould validate with a retired key during the grace period: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould validate with a retired key during the grace period.", success, testID)
+
+			if _, err := ks.Reload(now.Add(2*time.Hour), ""); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reload the key store again: %v", failed, testID, err)
+			}
+
+			if _, err := ks.PublicKey("old"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould drop a retired key after the grace period.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould drop a retired key after the grace period.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen the active key is not in the directory.", testID)
+		{
+			fsys := fstest.MapFS{
+				"active.pem": {Data: pem},
+			}
+
+			ks, err := keystore.NewFS(fsys, keystore.WithGrace(time.Hour))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct key store: %v", failed, testID, err)
+			}
+
+			now := time.Now()
+			delete(fsys, "active.pem")
+			fsys["new.pem"] = &fstest.MapFile{Data: pem}
+
+			if _, err := ks.Reload(now, "active"); !errors.Is(err, keystore.ErrActiveMissing) {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse to reload without the active key: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse to reload without the active key.", success, testID)
+
+			if _, err := ks.PrivateKey("active"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould still sign with the active key: %v", failed, testID, err)
+			}
+			if _, err := ks.PublicKey("new"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould not add keys when the reload is refused.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould leave the store as it was.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen a removed key comes back.", testID)
+		{
+			fsys := fstest.MapFS{
+				"back.pem": {Data: pem},
+			}
+
+			ks, err := keystore.NewFS(fsys, keystore.WithGrace(time.Hour))
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct key store: %v", failed, testID, err)
+			}
+
+			now := time.Now()
+			delete(fsys, "back.pem")
+			if _, err := ks.Reload(now, ""); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reload the key store: %v", failed, testID, err)
+			}
+
+			fsys["back.pem"] = &fstest.MapFile{Data: pem}
+			added, err := ks.Reload(now.Add(time.Minute), "")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reload the key store again: %v", failed, testID, err)
+			}
+
+			if len(added) != 1 || added[0] != "back" {
+				t.Fatalf("\t%s\tTest %d:\tShould report the returning key as added: %v", failed, testID, added)
+			}
+			if _, err := ks.PrivateKey("back"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould sign with the returning key: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould sign with the returning key.", success, testID)
+		}
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	t.Log("Given the need to publish our public keys.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen building a JSON Web Key Set.", testID)
+		{
+			ks, err := keystore.NewFS(keyDocs)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct key store: %v", failed, testID, err)
+			}
+
+			jwks := ks.JWKS()
+			if len(jwks.Keys) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould get one key in the set: %d", failed, testID, len(jwks.Keys))
+			}
+			t.Logf("\t%s\tTest %d:\tShould get one key in the set.", success, testID)
+
+			jwk := jwks.Keys[0]
+			if jwk.KeyID != "test" || jwk.KeyType != "RSA" || jwk.Algorithm != "RS256" || jwk.E != "AQAB" || jwk.N == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould describe the RSA public key: %+v", failed, testID, jwk)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe the RSA public key.", success, testID)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	pem, err := keyDocs.ReadFile("test.pem")
+	if err != nil {
+		t.Fatalf("reading test key: %v", err)
+	}
+
+	t.Log("Given the need to read keys from the environment.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling base64 encoded key variable(s).", testID)
+		{
+			t.Setenv("TEST_AUTH_KEY_54BB2165_71E1", base64.StdEncoding.EncodeToString(pem))
+
+			ks, err := keystore.NewEnv("TEST_AUTH_KEY_")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct key store: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to construct key store.", success, testID)
+
+			if _, err := ks.PrivateKey("54bb2165-71e1"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to find key by its id: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to find key by its id.", success, testID)
+
+			if _, err := ks.Reload(time.Now(), ""); !errors.Is(err, keystore.ErrNoSource) {
+				t.Fatalf("\t%s\tTest %d:\tShould not be able to reload: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not be able to reload.", success, testID)
+		}
+	}
+}
+
+func TestKeyTypes(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ecdsa key: %v", err)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("marshaling ecdsa key: %v", err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %v", err)
+	}
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatalf("marshaling ed25519 key: %v", err)
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ecdsa key: %v", err)
+	}
+	p384DER, err := x509.MarshalECPrivateKey(p384)
+	if err != nil {
+		t.Fatalf("marshaling ecdsa key: %v", err)
+	}
+
+	t.Log("Given the need to hold keys for different algorithms.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen handling ECDSA and Ed25519 key files.", testID)
+		{
+			fsys := fstest.MapFS{
+				"ec.pem": {Data: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER})},
+				"ed.pem": {Data: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER})},
+			}
+
+			ks, err := keystore.NewFS(fsys)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct key store: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to construct key store.", success, testID)
+
+			if pub, err := ks.PublicKey("ed"); err != nil || !edKey.Public().(ed25519.PublicKey).Equal(pub) {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the Ed25519 public key: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the Ed25519 public key.", success, testID)
+
+			jwks := ks.JWKS()
+			if len(jwks.Keys) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould get two keys in the set: %d", failed, testID, len(jwks.Keys))
+			}
+
+			ec, ed := jwks.Keys[0], jwks.Keys[1]
+			if ec.KeyType != "EC" || ec.Algorithm != "ES256" || ec.Curve != "P-256" || len(ec.X) != 43 || len(ec.Y) != 43 {
+				t.Fatalf("\t%s\tTest %d:\tShould describe the ECDSA public key: %+v", failed, testID, ec)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe the ECDSA public key.", success, testID)
+
+			if ed.KeyType != "OKP" || ed.Algorithm != "EdDSA" || ed.Curve != "Ed25519" || len(ed.X) != 43 {
+				t.Fatalf("\t%s\tTest %d:\tShould describe the Ed25519 public key: %+v", failed, testID, ed)
+			}
+			t.Logf("\t%s\tTest %d:\tShould describe the Ed25519 public key.", success, testID)
+
+			if pub, err := ec.PublicKey(); err != nil || !ecKey.PublicKey.Equal(pub) {
+				t.Fatalf("\t%s\tTest %d:\tShould decode the ECDSA public key from the set: %v", failed, testID, err)
+			}
+			if pub, err := ed.PublicKey(); err != nil || !edKey.Public().(ed25519.PublicKey).Equal(pub) {
+				t.Fatalf("\t%s\tTest %d:\tShould decode the Ed25519 public key from the set: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould decode the public keys from the set.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen handling a key on an unsupported curve.", testID)
+		{
+			fsys := fstest.MapFS{
+				"p384.pem": {Data: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: p384DER})},
+			}
+
+			if _, err := keystore.NewFS(fsys); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould refuse the key.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould refuse the key.", success, testID)
+		}
+	}
+}
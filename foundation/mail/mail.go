@@ -0,0 +1,200 @@
+// Package mail provides support for sending plain text email either through
+// an SMTP server or, for local development, by writing each message to a
+// directory on disk.
+package mail
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SMTPConfig represents the information required to send mail through an
+// SMTP server. Username and Password are optional and are only used when the
+// server supports authentication.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration
+}
+
+// SMTP sends mail through an SMTP server.
+type SMTP struct {
+	cfg SMTPConfig
+}
+
+// NewSMTP constructs an SMTP sender for the specified server.
+func NewSMTP(cfg SMTPConfig) *SMTP {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	return &SMTP{
+		cfg: cfg,
+	}
+}
+
+// Send delivers a message to the specified address. The connection is
+// upgraded to TLS when the server supports it.
+func (s *SMTP) Send(ctx context.Context, to string, subject string, body string) error {
+	msg, err := compose(s.cfg.From, to, subject, body, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(s.cfg.Host, fmt.Sprint(s.cfg.Port))
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("dialing %s: %w", addr, err)
+	}
+
+	// The smtp client does not take a context so the deadline is applied
+	// to the connection instead.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("greeting %s: %w", addr, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		tlsCfg := tls.Config{
+			ServerName: s.cfg.Host,
+			MinVersion: tls.VersionTLS12,
+		}
+		if err := c.StartTLS(&tlsCfg); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+
+	if s.cfg.Username != "" {
+		if ok, _ := c.Extension("AUTH"); ok {
+			auth := smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+			if err := c.Auth(auth); err != nil {
+				return fmt.Errorf("auth: %w", err)
+			}
+		}
+	}
+
+	if err := c.Mail(s.cfg.From); err != nil {
+		return fmt.Errorf("mail from: %w", err)
+	}
+
+	if err := c.Rcpt(to); err != nil {
+		return fmt.Errorf("rcpt to: %w", err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("closing message: %w", err)
+	}
+
+	return c.Quit()
+}
+
+// =============================================================================
+
+// File writes each message to its own file in a directory instead of sending
+// it. This is meant for local development where the outbox can be read to
+// follow links sent by the service.
+type File struct {
+	dir  string
+	from string
+}
+
+// NewFile constructs a File sender writing to the specified directory, which
+// is created if it does not exist.
+func NewFile(dir string, from string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating outbox: %w", err)
+	}
+
+	f := File{
+		dir:  dir,
+		from: from,
+	}
+
+	return &f, nil
+}
+
+// Send writes the message to a new .eml file in the outbox directory.
+func (f *File) Send(ctx context.Context, to string, subject string, body string) error {
+	now := time.Now().UTC()
+
+	msg, err := compose(f.from, to, subject, body, now)
+	if err != nil {
+		return err
+	}
+
+	var suffix [4]byte
+	if _, err := crand.Read(suffix[:]); err != nil {
+		return fmt.Errorf("generating file name: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405.000000000"), hex.EncodeToString(suffix[:]))
+	path := filepath.Join(f.dir, name)
+
+	if err := os.WriteFile(path, msg, 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// compose builds an RFC 5322 message with a plain text body.
+func compose(from string, to string, subject string, body string, now time.Time) ([]byte, error) {
+	for _, v := range []string{from, to, subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, errors.New("mail header contains a line break")
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+
+	// SMTP requires CRLF line endings in the body.
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,100 @@
+package mail_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/colmmurphy91/go-service/foundation/mail"
+	"github.com/colmmurphy91/go-service/foundation/mail/mailtest"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestSMTP(t *testing.T) {
+	srv, err := mailtest.StartServer()
+	if err != nil {
+		t.Fatalf("Should be able to start the SMTP server : %s", err)
+	}
+	defer srv.Close()
+
+	sender := mail.NewSMTP(mail.SMTPConfig{
+		Host: srv.Host(),
+		Port: srv.Port(),
+		From: "noreply@example.com",
+	})
+
+	t.Log("Given the need to send mail through an SMTP server.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen sending a single message.", testID)
+		{
+			if err := sender.Send(context.Background(), "bill@example.com", "Hello", "First line\n.Second line"); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to send a message : %s.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to send a message.", success, testID)
+
+			msgs := srv.Messages()
+			if len(msgs) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould have received one message : got %d.", failed, testID, len(msgs))
+			}
+			t.Logf("\t%s\tTest %d:\tShould have received one message.", success, testID)
+
+			msg := msgs[0]
+			if msg.From != "noreply@example.com" || len(msg.To) != 1 || msg.To[0] != "bill@example.com" {
+				t.Fatalf("\t%s\tTest %d:\tShould have the right envelope : got %+v.", failed, testID, msg)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have the right envelope.", success, testID)
+
+			if !strings.Contains(msg.Data, "Subject: Hello\r\n") || !strings.HasSuffix(msg.Data, "First line\r\n.Second line\r\n") {
+				t.Fatalf("\t%s\tTest %d:\tShould have the subject and body : got %q.", failed, testID, msg.Data)
+			}
+			t.Logf("\t%s\tTest %d:\tShould have the subject and body.", success, testID)
+
+			if err := sender.Send(context.Background(), "bill@example.com\r\nBcc: eve@example.com", "Hello", "body"); err == nil {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a header with a line break.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a header with a line break.", success, testID)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+
+	sender, err := mail.NewFile(dir, "noreply@example.com")
+	if err != nil {
+		t.Fatalf("Should be able to create the outbox : %s", err)
+	}
+
+	t.Log("Given the need to write mail to an outbox.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen sending two messages.", testID)
+		{
+			for i := 0; i < 2; i++ {
+				if err := sender.Send(context.Background(), "bill@example.com", "Hello", "Body"); err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to send a message : %s.", failed, testID, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to send messages.", success, testID)
+
+			files, err := os.ReadDir(dir)
+			if err != nil || len(files) != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould write one file per message : %d %v.", failed, testID, len(files), err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould write one file per message.", success, testID)
+
+			data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+			if err != nil || !strings.Contains(string(data), "To: bill@example.com\r\n") {
+				t.Fatalf("\t%s\tTest %d:\tShould write the message headers : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould write the message headers.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package mailtest provides an in-process SMTP server for tests that need to
+// see the mail a package sends.
+package mailtest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Message represents a message received by the Server.
+type Message struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Server is a minimal SMTP server that accepts every message and keeps it in
+// memory. It does not support TLS or authentication.
+type Server struct {
+	ln       net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	messages []Message
+}
+
+// StartServer starts a Server listening on a random local port.
+func StartServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := Server{
+		ln: ln,
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+
+	return &s, nil
+}
+
+// Host returns the host the server is listening on.
+func (s *Server) Host() string {
+	return s.ln.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port the server is listening on.
+func (s *Server) Port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// Messages returns the messages received so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := make([]Message, len(s.messages))
+	copy(msgs, s.messages)
+	return msgs
+}
+
+// Close stops the server and waits for open connections to finish.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.wg.Wait()
+}
+
+// serve runs a single SMTP session.
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 mailtest ready")
+
+	var msg Message
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 mailtest")
+
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = Message{From: address(line[len("MAIL FROM:"):])}
+			reply("250 OK")
+
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.To = append(msg.To, address(line[len("RCPT TO:"):]))
+			reply("250 OK")
+
+		case cmd == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Data = data.String()
+
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+
+			reply("250 OK")
+
+		case cmd == "RSET", cmd == "NOOP":
+			reply("250 OK")
+
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// address strips the angle brackets and any parameters from an address.
+func address(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, ">"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimPrefix(s, "<")
+}
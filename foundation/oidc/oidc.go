@@ -0,0 +1,390 @@
+// Package oidc provides support for signing users in through an external
+// OpenID Connect identity provider using the authorization code flow with
+// PKCE (RFC 7636).
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/colmmurphy91/go-service/foundation/keystore"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Set of errors returned when a sign in can't be completed.
+var (
+	ErrExchange       = errors.New("authorization code was not accepted")
+	ErrInvalidIDToken = errors.New("id token is not valid")
+)
+
+// fetchTimeout bounds how long a call can wait on the identity provider.
+const fetchTimeout = 5 * time.Second
+
+// minRefresh limits how often an unknown key id can force the provider keys
+// to be read again, so tokens with made up key ids can't flood the provider.
+const minRefresh = 5 * time.Second
+
+// Config represents the configuration of the client registered with the
+// identity provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Client       *http.Client
+}
+
+// Identity represents the user the identity provider signed in. The issuer
+// and subject together identify the user; the rest is profile information.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// metadata represents the parts of the provider's discovery document we use.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// idClaims represents the claims of an id token we use.
+type idClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// Provider is a client of an OpenID Connect identity provider. The provider's
+// discovery document is read on first use and its signing keys are cached
+// until a token signed with an unknown key id shows up.
+type Provider struct {
+	cfg Config
+
+	mu       sync.Mutex
+	meta     *metadata
+	keys     map[string]crypto.PublicKey
+	fetched  time.Time
+	fetching *fetch
+}
+
+// fetch is a read of the provider keys that is in progress. Lookups that
+// need the keys while it runs wait for it rather than reading them again.
+type fetch struct {
+	done chan struct{}
+	err  error
+}
+
+// New constructs a Provider for the specified client configuration.
+func New(cfg Config) (*Provider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("issuer, client id and redirect url are required")
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	p := Provider{
+		cfg: cfg,
+	}
+
+	return &p, nil
+}
+
+// AuthCodeURL returns the provider URL to send the user to so they can sign
+// in. The state comes back on the redirect and the nonce inside the id token,
+// while only the challenge of the PKCE verifier is sent.
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	v := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return meta.AuthorizationEndpoint + sep + v.Encode(), nil
+}
+
+// Exchange trades the authorization code from the redirect for the id token
+// of the user, proving with the PKCE verifier that we started the sign in.
+// The id token must be signed by the provider, issued to this client and
+// carry the nonce sent with the sign in.
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string, nonce string) (Identity, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.cfg.Client.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("calling token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return Identity{}, fmt.Errorf("decoding token response, status %d: %w", resp.StatusCode, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized:
+		return Identity{}, fmt.Errorf("%w: %s %s", ErrExchange, result.Error, result.Description)
+	case resp.StatusCode != http.StatusOK:
+		return Identity{}, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	case result.IDToken == "":
+		return Identity{}, fmt.Errorf("%w: no id token in response", ErrInvalidIDToken)
+	}
+
+	return p.verify(ctx, meta, result.IDToken, nonce)
+}
+
+// =============================================================================
+
+// verify checks the id token and returns the identity it describes.
+func (p *Provider) verify(ctx context.Context, meta metadata, idToken string, nonce string) (Identity, error) {
+	keyFunc := func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.publicKey(ctx, meta, kid)
+	}
+
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}))
+
+	var claims idClaims
+	if _, err := parser.ParseWithClaims(idToken, &claims, keyFunc); err != nil {
+		return Identity{}, fmt.Errorf("%w: %s", ErrInvalidIDToken, err)
+	}
+
+	switch {
+	case claims.Issuer != meta.Issuer:
+		return Identity{}, fmt.Errorf("%w: issued by %q", ErrInvalidIDToken, claims.Issuer)
+	case !claims.VerifyAudience(p.cfg.ClientID, true):
+		return Identity{}, fmt.Errorf("%w: not issued to this client", ErrInvalidIDToken)
+	case claims.ExpiresAt == nil:
+		return Identity{}, fmt.Errorf("%w: no expiry", ErrInvalidIDToken)
+	case claims.Nonce != nonce:
+		return Identity{}, fmt.Errorf("%w: nonce does not match", ErrInvalidIDToken)
+	case claims.Subject == "":
+		return Identity{}, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+
+	ident := Identity{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}
+
+	return ident, nil
+}
+
+// metadata returns the provider's discovery document, reading it the first
+// time it's needed. The lock is not held while the document is read, so
+// callers that race on first use may each read it.
+func (p *Provider) metadata(ctx context.Context) (metadata, error) {
+	p.mu.Lock()
+	cached := p.meta
+	p.mu.Unlock()
+
+	if cached != nil {
+		return *cached, nil
+	}
+
+	var meta metadata
+	if err := p.get(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", &meta); err != nil {
+		return metadata{}, fmt.Errorf("discovery: %w", err)
+	}
+
+	// The discovery document must be for the issuer we were configured with
+	// or tokens from some other issuer could be accepted.
+	if meta.Issuer != p.cfg.Issuer {
+		return metadata{}, fmt.Errorf("discovery: issuer %q does not match %q", meta.Issuer, p.cfg.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return metadata{}, errors.New("discovery: endpoints missing from document")
+	}
+
+	p.mu.Lock()
+	p.meta = &meta
+	p.mu.Unlock()
+
+	return meta, nil
+}
+
+// publicKey returns the provider key for the specified kid, reading the
+// provider keys again if the kid isn't known yet.
+func (p *Provider) publicKey(ctx context.Context, meta metadata, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, exists := p.keys[kid]
+	f := p.fetching
+	throttled := time.Since(p.fetched) < minRefresh
+	p.mu.Unlock()
+
+	switch {
+	case exists:
+		return key, nil
+	case f != nil:
+		<-f.done
+	case throttled:
+		return nil, fmt.Errorf("kid %q lookup failed", kid)
+	default:
+		f = p.refresh(ctx, meta)
+	}
+
+	if f.err != nil {
+		return nil, fmt.Errorf("reading keys: %w", f.err)
+	}
+
+	p.mu.Lock()
+	key, exists = p.keys[kid]
+	p.mu.Unlock()
+
+	if !exists {
+		return nil, fmt.Errorf("kid %q lookup failed", kid)
+	}
+
+	return key, nil
+}
+
+// refresh reads the provider keys and replaces the cached ones, unless a read
+// is already in progress, in which case it waits for that one. The lock is
+// not held while the keys are read.
+func (p *Provider) refresh(ctx context.Context, meta metadata) *fetch {
+	p.mu.Lock()
+	if f := p.fetching; f != nil {
+		p.mu.Unlock()
+		<-f.done
+		return f
+	}
+
+	f := fetch{
+		done: make(chan struct{}),
+	}
+	p.fetching = &f
+	p.mu.Unlock()
+
+	keys, err := p.readKeys(ctx, meta)
+
+	p.mu.Lock()
+	if err == nil {
+		p.keys = keys
+		p.fetched = time.Now()
+	}
+	f.err = err
+	p.fetching = nil
+	p.mu.Unlock()
+
+	close(f.done)
+
+	return &f
+}
+
+// readKeys reads the signing keys published by the provider.
+func (p *Provider) readKeys(ctx context.Context, meta metadata) (map[string]crypto.PublicKey, error) {
+	var jwks keystore.JWKS
+	if err := p.get(ctx, meta.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		// Skip keys we can't use rather than failing the whole set.
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	return keys, nil
+}
+
+// get reads the JSON document at the url into v.
+func (p *Provider) get(ctx context.Context, url string, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling provider: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("provider returned status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/colmmurphy91/go-service/foundation/oidc"
+	"github.com/colmmurphy91/go-service/foundation/oidc/oidctest"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestSignIn(t *testing.T) {
+	idp, err := oidctest.New("sales-api")
+	if err != nil {
+		t.Fatalf("starting identity provider: %v", err)
+	}
+	defer idp.Close()
+
+	p, err := oidc.New(oidc.Config{
+		Issuer:      idp.URL,
+		ClientID:    "sales-api",
+		RedirectURL: "http://localhost:3000/v1/users/oidc/callback",
+	})
+	if err != nil {
+		t.Fatalf("constructing provider: %v", err)
+	}
+
+	usr := oidctest.User{
+		Subject:       "gopher-1",
+		Email:         "gopher@example.com",
+		EmailVerified: true,
+		Name:          "Gopher",
+	}
+
+	// signIn starts a sign in and has the user sign in at the provider,
+	// returning the code from the redirect.
+	signIn := func(t *testing.T, testID int, verifier string, nonce string) string {
+		authURL, err := p.AuthCodeURL(context.Background(), "state-1", nonce, verifier)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to build the sign in url: %v", failed, testID, err)
+		}
+
+		redirect, err := idp.Authorize(authURL, usr)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to sign in at the provider: %v", failed, testID, err)
+		}
+
+		u, err := url.Parse(redirect)
+		if err != nil {
+			t.Fatalf("\t%s\tTest %d:\tShould be able to parse the redirect: %v", failed, testID, err)
+		}
+		if u.Query().Get("state") != "state-1" {
+			t.Fatalf("\t%s\tTest %d:\tShould get the state back on the redirect: %s", failed, testID, redirect)
+		}
+
+		return u.Query().Get("code")
+	}
+
+	t.Log("Given the need to sign users in through an identity provider.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen completing a sign in.", testID)
+		{
+			verifier, err := oidc.NewVerifier()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a verifier: %v", failed, testID, err)
+			}
+
+			code := signIn(t, testID, verifier, "nonce-1")
+
+			ident, err := p.Exchange(context.Background(), code, verifier, "nonce-1")
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to exchange the code: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to exchange the code.", success, testID)
+
+			want := oidc.Identity{
+				Issuer:        idp.URL,
+				Subject:       usr.Subject,
+				Email:         usr.Email,
+				EmailVerified: true,
+				Name:          usr.Name,
+			}
+			if ident != want {
+				t.Fatalf("\t%s\tTest %d:\tShould get the identity of the user: got %+v want %+v", failed, testID, ident, want)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the identity of the user.", success, testID)
+
+			if _, err := p.Exchange(context.Background(), code, verifier, "nonce-1"); !errors.Is(err, oidc.ErrExchange) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to exchange the code twice: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to exchange the code twice.", success, testID)
+		}
+
+		testID = 1
+		t.Logf("\tTest %d:\tWhen exchanging with the wrong verifier.", testID)
+		{
+			code := signIn(t, testID, "verifier-1", "nonce-1")
+
+			if _, err := p.Exchange(context.Background(), code, "verifier-2", "nonce-1"); !errors.Is(err, oidc.ErrExchange) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to exchange the code: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to exchange the code.", success, testID)
+		}
+
+		testID = 2
+		t.Logf("\tTest %d:\tWhen the id token carries another nonce.", testID)
+		{
+			code := signIn(t, testID, "verifier-1", "nonce-1")
+
+			if _, err := p.Exchange(context.Background(), code, "verifier-1", "nonce-2"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the id token: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the id token.", success, testID)
+		}
+
+		testID = 3
+		t.Logf("\tTest %d:\tWhen the client isn't the one the token was issued to.", testID)
+		{
+			other, err := oidc.New(oidc.Config{
+				Issuer:      idp.URL,
+				ClientID:    "other-client",
+				RedirectURL: "http://localhost:3000/v1/users/oidc/callback",
+			})
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to construct provider: %v", failed, testID, err)
+			}
+
+			code := signIn(t, testID, "verifier-1", "nonce-1")
+
+			if _, err := other.Exchange(context.Background(), code, "verifier-1", "nonce-1"); !errors.Is(err, oidc.ErrExchange) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to exchange the code: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to exchange the code.", success, testID)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Package oidctest provides a stand-in OpenID Connect identity provider for
+// tests, built on httptest.
+package oidctest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/colmmurphy91/go-service/foundation/keystore"
+	"github.com/colmmurphy91/go-service/foundation/oidc"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// kid is the key id the provider signs id tokens with.
+const kid = "oidctest"
+
+// User represents the user that signs in at the provider.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// grant represents an authorization code waiting to be exchanged.
+type grant struct {
+	usr         User
+	redirectURL string
+	challenge   string
+	nonce       string
+}
+
+// IdP is an identity provider that signs in whichever user a test asks it
+// to. It supports discovery, a key set and the token endpoint, and enforces
+// PKCE on every code.
+type IdP struct {
+	*httptest.Server
+	ClientID string
+
+	key   *ecdsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+// New starts an identity provider that issues tokens to the specified client.
+// Close must be called when the test is done with it.
+func New(clientID string) (*IdP, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+
+	idp := IdP{
+		ClientID: clientID,
+		key:      key,
+		codes:    make(map[string]grant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/keys", idp.keys)
+	mux.HandleFunc("/token", idp.token)
+	idp.Server = httptest.NewServer(mux)
+
+	return &idp, nil
+}
+
+// Authorize plays the part of the user signing in at the provider. It takes
+// the URL the client sent the user to and returns the URL the provider sends
+// the user back to, carrying the code and state.
+func (idp *IdP) Authorize(authURL string, usr User) (string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing url: %w", err)
+	}
+	q := u.Query()
+
+	switch {
+	case q.Get("response_type") != "code":
+		return "", errors.New("response type must be code")
+	case q.Get("client_id") != idp.ClientID:
+		return "", fmt.Errorf("unknown client %q", q.Get("client_id"))
+	case q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "":
+		return "", errors.New("an S256 code challenge is required")
+	case q.Get("redirect_uri") == "":
+		return "", errors.New("redirect uri is required")
+	}
+
+	code, err := oidc.NewVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	idp.mu.Lock()
+	idp.codes[code] = grant{
+		usr:         usr,
+		redirectURL: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+	}
+	idp.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		return "", fmt.Errorf("parsing redirect uri: %w", err)
+	}
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+
+	return redirect.String(), nil
+}
+
+// =============================================================================
+
+func (idp *IdP) discovery(w http.ResponseWriter, r *http.Request) {
+	doc := map[string]string{
+		"issuer":                 idp.URL,
+		"authorization_endpoint": idp.URL + "/authorize",
+		"token_endpoint":         idp.URL + "/token",
+		"jwks_uri":               idp.URL + "/keys",
+	}
+	respond(w, http.StatusOK, doc)
+}
+
+func (idp *IdP) keys(w http.ResponseWriter, r *http.Request) {
+	enc := base64.RawURLEncoding
+	pub := idp.key.PublicKey
+
+	jwks := keystore.JWKS{
+		Keys: []keystore.JWK{
+			{
+				KeyType:   "EC",
+				Use:       "sig",
+				Algorithm: "ES256",
+				KeyID:     kid,
+				Curve:     "P-256",
+				X:         enc.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+				Y:         enc.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+			},
+		},
+	}
+	respond(w, http.StatusOK, jwks)
+}
+
+func (idp *IdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		fail(w, "invalid_request", err.Error())
+		return
+	}
+
+	code := r.PostForm.Get("code")
+
+	// Codes can only be exchanged once.
+	idp.mu.Lock()
+	g, exists := idp.codes[code]
+	delete(idp.codes, code)
+	idp.mu.Unlock()
+
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code":
+		fail(w, "unsupported_grant_type", "")
+		return
+	case !exists:
+		fail(w, "invalid_grant", "unknown code")
+		return
+	case r.PostForm.Get("client_id") != idp.ClientID:
+		fail(w, "invalid_client", "")
+		return
+	case r.PostForm.Get("redirect_uri") != g.redirectURL:
+		fail(w, "invalid_grant", "redirect uri does not match")
+		return
+	case oidc.Challenge(r.PostForm.Get("code_verifier")) != g.challenge:
+		fail(w, "invalid_grant", "code verifier does not match")
+		return
+	}
+
+	now := time.Now()
+	claims := struct {
+		jwt.RegisteredClaims
+		Nonce         string `json:"nonce,omitempty"`
+		Email         string `json:"email,omitempty"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name,omitempty"`
+	}{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    idp.URL,
+			Subject:   g.usr.Subject,
+			Audience:  jwt.ClaimStrings{idp.ClientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Nonce:         g.nonce,
+		Email:         g.usr.Email,
+		EmailVerified: g.usr.EmailVerified,
+		Name:          g.usr.Name,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+
+	idToken, err := token.SignedString(idp.key)
+	if err != nil {
+		respond(w, http.StatusInternalServerError, nil)
+		return
+	}
+
+	resp := map[string]string{
+		"access_token": code,
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+func fail(w http.ResponseWriter, code string, description string) {
+	resp := map[string]string{
+		"error":             code,
+		"error_description": description,
+	}
+	respond(w, http.StatusBadRequest, resp)
+}
+
+func respond(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
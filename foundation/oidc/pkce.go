@@ -0,0 +1,25 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// NewVerifier returns a random PKCE code verifier. The same kind of value
+// works for the state and nonce of a sign in.
+func NewVerifier() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generating verifier: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
+
+// Challenge returns the S256 code challenge for the PKCE verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
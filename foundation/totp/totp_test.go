@@ -0,0 +1,95 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/foundation/totp"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestCode(t *testing.T) {
+
+	// The SHA1 test vectors from RFC 6238 appendix B, cut down to six digits.
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	t.Log("Given the need to generate codes for a secret.")
+	{
+		for testID, tt := range tests {
+			t.Logf("\tTest %d:\tWhen generating the code at %d.", testID, tt.unix)
+			{
+				code, err := totp.Code(secret, totp.Step(time.Unix(tt.unix, 0)))
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to generate a code: %v", failed, testID, err)
+				}
+
+				if code != tt.code {
+					t.Fatalf("\t%s\tTest %d:\tShould get the expected code: got %s want %s", failed, testID, code, tt.code)
+				}
+				t.Logf("\t%s\tTest %d:\tShould get the expected code.", success, testID)
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Log("Given the need to validate codes.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen validating codes around the current time.", testID)
+		{
+			secret, err := totp.NewSecret()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a secret: %v", failed, testID, err)
+			}
+
+			now := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+			prev, err := totp.Code(secret, totp.Step(now)-1)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a code: %v", failed, testID, err)
+			}
+
+			step, ok := totp.Validate(secret, prev, now, 1)
+			if !ok || step != totp.Step(now)-1 {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the code from the previous step.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept the code from the previous step.", success, testID)
+
+			if _, ok := totp.Validate(secret, prev, now.Add(totp.Period), 1); ok {
+				t.Fatalf("\t%s\tTest %d:\tShould reject the code once it is out of the window.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject the code once it is out of the window.", success, testID)
+
+			if _, ok := totp.Validate(secret, "12345", now, 1); ok {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a code of the wrong length.", failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a code of the wrong length.", success, testID)
+
+			uri := totp.URI("Sales API", "admin@example.com", secret)
+			if !strings.HasPrefix(uri, "otpauth://totp/Sales%20API:admin@example.com?") || !strings.Contains(uri, "secret="+secret) {
+				t.Fatalf("\t%s\tTest %d:\tShould build the provisioning uri: %s", failed, testID, uri)
+			}
+			t.Logf("\t%s\tTest %d:\tShould build the provisioning uri.", success, testID)
+		}
+	}
+}
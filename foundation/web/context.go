@@ -28,6 +28,13 @@ func GetValues(ctx context.Context) (*Values, error) {
 	return v, nil
 }
 
+// SetValues stores a copy of the values in the context. It is for work that
+// outlives the request it was started by but still belongs to it.
+func SetValues(ctx context.Context, v *Values) context.Context {
+	cp := *v
+	return context.WithValue(ctx, key, &cp)
+}
+
 // GetTraceID returns the trace id from the context.
 func GetTraceID(ctx context.Context) string {
 	v, ok := ctx.Value(key).(*Values)
@@ -22,3 +22,11 @@ func wrapMiddleware(mw []Middleware, handler Handler) Handler {
 
 	return handler
 }
+
+// Chain combines middleware into a single middleware that runs them in the
+// order they are provided.
+func Chain(mw ...Middleware) Middleware {
+	return func(handler Handler) Handler {
+		return wrapMiddleware(mw, handler)
+	}
+}
@@ -2,6 +2,7 @@ package web
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 
 	"github.com/dimfeld/httptreemux/v5"
@@ -13,6 +14,17 @@ func Param(r *http.Request, key string) string {
 	return m[key]
 }
 
+// ClientIP returns the address of the client that made the request. It
+// doesn't trust headers like X-Forwarded-For since any client can set them,
+// so a proxy in front of the service has to preserve the remote address.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // Decode reads the body of an HTTP request looking for a JSON document. The
 // body is decoded into the provided value.
 //
@@ -0,0 +1,157 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// ErrStreamClosed is returned when writing to a stream that has stopped,
+// either because the request context was cancelled or a write failed.
+var ErrStreamClosed = errors.New("stream closed")
+
+// Event represents a single server-sent event. Name and ID are optional and
+// Data is sent as JSON.
+type Event struct {
+	ID   string
+	Name string
+	Data interface{}
+}
+
+// Stream writes server-sent events to a client. Every write is flushed
+// straight away and a comment line is sent on the heartbeat interval so
+// proxies don't close an idle connection. The stream stops when the request
+// context is cancelled.
+type Stream struct {
+	w      http.ResponseWriter
+	f      http.Flusher
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewStream writes the server-sent event headers and returns a Stream ready
+// to send events. A heartbeat of zero disables heartbeats. Close must be
+// called before the handler returns.
+func NewStream(ctx context.Context, w http.ResponseWriter, heartbeat time.Duration) (*Stream, error) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("response writer does not support flushing")
+	}
+
+	// Set the status code for the request logger middleware.
+	SetStatusCode(ctx, http.StatusOK)
+
+	// Tell any proxy in front of us not to buffer the response.
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := Stream{
+		w:      w,
+		f:      f,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	if heartbeat > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.heartbeat(heartbeat)
+		}()
+	}
+
+	return &s, nil
+}
+
+// Done returns a channel that is closed when the stream stops.
+func (s *Stream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send writes an event to the client.
+func (s *Stream) Send(ev Event) error {
+	_, span := otel.GetTracerProvider().Tracer("").Start(s.ctx, "foundation.web.stream.send")
+	defer span.End()
+
+	data, err := json.Marshal(ev.Data)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if ev.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", oneLine(ev.ID))
+	}
+	if ev.Name != "" {
+		fmt.Fprintf(&b, "event: %s\n", oneLine(ev.Name))
+	}
+	fmt.Fprintf(&b, "data: %s\n\n", data)
+
+	return s.write(b.Bytes())
+}
+
+// Close stops the stream and waits for the heartbeat to finish. Nothing is
+// written to the response once Close returns.
+func (s *Stream) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// heartbeat sends a comment line on every tick until the stream stops.
+func (s *Stream) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.write([]byte(": heartbeat\n\n")); err != nil {
+				return
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// write sends the bytes and flushes them to the client. A failed write stops
+// the stream since the client has most likely gone away.
+func (s *Stream) write(b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx.Err() != nil {
+		return ErrStreamClosed
+	}
+
+	if _, err := s.w.Write(b); err != nil {
+		s.cancel()
+		return fmt.Errorf("%w: %v", ErrStreamClosed, err)
+	}
+	s.f.Flush()
+
+	return nil
+}
+
+// oneLine stops a field value from breaking out of its line.
+func oneLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
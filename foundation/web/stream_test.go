@@ -0,0 +1,99 @@
+package web_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/foundation/web"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestStream(t *testing.T) {
+	ready := make(chan struct{})
+	done := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+
+		stream, err := web.NewStream(r.Context(), w, 10*time.Millisecond)
+		if err != nil {
+			t.Errorf("\t%s\tShould be able to start a stream : %s.", failed, err)
+			return
+		}
+		defer stream.Close()
+
+		if err := stream.Send(web.Event{ID: "1", Name: "order", Data: map[string]string{"status": "placed"}}); err != nil {
+			t.Errorf("\t%s\tShould be able to send an event : %s.", failed, err)
+		}
+		close(ready)
+
+		<-stream.Done()
+	}))
+	defer srv.Close()
+
+	t.Log("Given the need to stream server-sent events.")
+	{
+		t.Logf("\tTest 0:\tWhen a client connects and then goes away.")
+		{
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest 0:\tShould be able to build a request : %s.", failed, err)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("\t%s\tTest 0:\tShould be able to connect : %s.", failed, err)
+			}
+			defer resp.Body.Close()
+
+			if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+				t.Fatalf("\t%s\tTest 0:\tShould get an event stream content type : got %q.", failed, ct)
+			}
+			t.Logf("\t%s\tTest 0:\tShould get an event stream content type.", success)
+
+			<-ready
+
+			var lines []string
+			var heartbeat bool
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() && !heartbeat {
+				line := scanner.Text()
+				lines = append(lines, line)
+				heartbeat = strings.HasPrefix(line, ": heartbeat")
+			}
+
+			got := strings.Join(lines, "\n")
+			want := "id: 1\nevent: order\ndata: {\"status\":\"placed\"}\n"
+			if !strings.HasPrefix(got, want) {
+				t.Fatalf("\t%s\tTest 0:\tShould receive the event flushed : got %q.", failed, got)
+			}
+			t.Logf("\t%s\tTest 0:\tShould receive the event flushed.", success)
+
+			if !heartbeat {
+				t.Fatalf("\t%s\tTest 0:\tShould receive a heartbeat.", failed)
+			}
+			t.Logf("\t%s\tTest 0:\tShould receive a heartbeat.", success)
+
+			cancel()
+
+			select {
+			case <-done:
+				t.Logf("\t%s\tTest 0:\tShould stop the stream when the client goes away.", success)
+			case <-time.After(time.Second):
+				t.Fatalf("\t%s\tTest 0:\tShould stop the stream when the client goes away.", failed)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package webhook_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/colmmurphy91/go-service/foundation/webhook"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestSign(t *testing.T) {
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"order.placed"}`)
+
+	t.Log("Given the need to sign webhook requests.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen verifying a signed body.", testID)
+		{
+			secret, err := webhook.NewSecret()
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to generate a secret: %v", failed, testID, err)
+			}
+
+			sig := webhook.Sign(secret, now, body)
+
+			if err := webhook.Verify(secret, sig, body, now.Add(time.Minute), 5*time.Minute); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould accept the signature: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould accept the signature.", success, testID)
+
+			if err := webhook.Verify(secret, sig, []byte(`{"type":"order.cancelled"}`), now, 5*time.Minute); !errors.Is(err, webhook.ErrInvalidSignature) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a changed body: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a changed body.", success, testID)
+
+			if err := webhook.Verify("other", sig, body, now, 5*time.Minute); !errors.Is(err, webhook.ErrInvalidSignature) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject another secret: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject another secret.", success, testID)
+
+			if err := webhook.Verify(secret, sig, body, now.Add(time.Hour), 5*time.Minute); !errors.Is(err, webhook.ErrSignatureExpired) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject an old signature: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject an old signature.", success, testID)
+
+			if err := webhook.Verify(secret, "garbage", body, now, 5*time.Minute); !errors.Is(err, webhook.ErrInvalidSignature) {
+				t.Fatalf("\t%s\tTest %d:\tShould reject a malformed header: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould reject a malformed header.", success, testID)
+		}
+	}
+}
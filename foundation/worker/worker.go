@@ -3,28 +3,125 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
-// JobFunc defines a function that can execute work for a specific job.
-type JobFunc func(ctx context.Context, traceID string, payload interface{})
+// Set of error variables for work.
+var (
+	// ErrUnknownWork is returned when a work key does not identify any work,
+	// or identifies work that finished longer ago than the retention period.
+	ErrUnknownWork = errors.New("work is not known")
+
+	// ErrOtherWorker is returned when a work key identifies work started by
+	// a worker with another name, such as one in another replica.
+	ErrOtherWorker = errors.New("work belongs to another worker")
+)
+
+// Set of states work can be in.
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCanceled  = "canceled"
+)
+
+// JobFunc defines a function that can execute work for a specific job. The
+// result and error it returns are kept in the status of the work.
+type JobFunc func(ctx context.Context, traceID string, payload interface{}) (interface{}, error)
+
+// Status describes a piece of work and, once it has finished, how it went.
+type Status struct {
+	WorkKey     string      `json:"work_key"`
+	JobKey      string      `json:"job_key"`
+	TraceID     string      `json:"trace_id"`
+	State       string      `json:"state"`
+	Progress    interface{} `json:"progress,omitempty"`
+	Result      interface{} `json:"result,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	DateQueued  time.Time   `json:"date_queued"`
+	DateStarted *time.Time  `json:"date_started,omitempty"`
+	DateEnded   *time.Time  `json:"date_ended,omitempty"`
+}
+
+// Options represent optional parameters.
+type Options struct {
+	limits    map[string]int
+	retention time.Duration
+	name      string
+}
+
+// WithLimit caps how many pieces of work for the job can run at once. Work
+// started beyond the cap is queued and run in order as running work ends.
+func WithLimit(jobKey string, max int) func(opts *Options) {
+	return func(opts *Options) {
+		if opts.limits == nil {
+			opts.limits = make(map[string]int)
+		}
+		opts.limits[jobKey] = max
+	}
+}
+
+// WithRetention sets how long the status of finished work is kept. The
+// default is an hour.
+func WithRetention(retention time.Duration) func(opts *Options) {
+	return func(opts *Options) {
+		opts.retention = retention
+	}
+}
+
+// WithName names the worker. The work keys of a named worker start with its
+// name, so the worker that knows about a piece of work can be told from the
+// key alone. Work is only known to the worker that started it.
+func WithName(name string) func(opts *Options) {
+	return func(opts *Options) {
+		opts.name = name
+	}
+}
+
+// work is a piece of work the worker knows about.
+type work struct {
+	status Status
+	fn     JobFunc
+	ctx    context.Context
+	cancel context.CancelFunc
+	data   interface{}
+}
 
 // Worker manages jobs and the execution of those jobs concurrently.
 type Worker struct {
 	wg       sync.WaitGroup
 	mu       sync.Mutex
 	registry map[string]JobFunc
-	running  map[string]context.CancelFunc
+	opts     Options
+	running  map[string]*work
+	queued   map[string][]*work
+	works    map[string]*work
+	shutdown bool
 }
 
 // New constructs a Worker for managing and executing jobs.
-func New(registry map[string]JobFunc) *Worker {
+func New(registry map[string]JobFunc, options ...func(opts *Options)) *Worker {
+	opts := Options{
+		retention: time.Hour,
+	}
+	for _, option := range options {
+		option(&opts)
+	}
+
 	return &Worker{
 		registry: registry,
-		running:  make(map[string]context.CancelFunc),
+		opts:     opts,
+		running:  make(map[string]*work),
+		queued:   make(map[string][]*work),
+		works:    make(map[string]*work),
 	}
 }
 
@@ -36,14 +133,39 @@ func (w *Worker) Running() int {
 	return len(w.running)
 }
 
-// Shutdown waits for all jobs to complete before it returns.
+// Queued returns the number of jobs waiting for running work to end.
+func (w *Worker) Queued() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var n int
+	for _, q := range w.queued {
+		n += len(q)
+	}
+
+	return n
+}
+
+// Shutdown cancels queued work, then waits for all running jobs to complete
+// before it returns.
 func (w *Worker) Shutdown(ctx context.Context) error {
 
-	// Call the cancel function for all running goroutines.
+	// Cancel the queued work and call the cancel function for all running
+	// goroutines.
 	w.mu.Lock()
 	{
-		for _, cancel := range w.running {
-			cancel()
+		w.shutdown = true
+
+		now := time.Now()
+		for jobKey, q := range w.queued {
+			for _, wk := range q {
+				w.end(wk, StateCanceled, nil, context.Canceled, now)
+			}
+			delete(w.queued, jobKey)
+		}
+
+		for _, wk := range w.running {
+			wk.cancel()
 		}
 	}
 	w.mu.Unlock()
@@ -66,57 +188,265 @@ func (w *Worker) Shutdown(ctx context.Context) error {
 	}
 }
 
-// Start lookups a job by key and launches a goroutine to perform the work. A
-// work key is returned so the caller can cancel work early.
+// Start lookups a job by key and launches a goroutine to perform the work,
+// or queues the work if the job is already running as often as its limit
+// allows. A work key is returned so the caller can check on or cancel the
+// work.
 func (w *Worker) Start(ctx context.Context, traceID string, jobKey string, payload interface{}) (string, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	if w.shutdown {
+		return "", fmt.Errorf("job[%s] can not start, worker is shut down", jobKey)
+	}
+
 	// Locate the job in the jobs registry.
 	f, exists := w.registry[jobKey]
 	if !exists {
 		return "", fmt.Errorf("job[%s] is not registered", jobKey)
 	}
 
+	now := time.Now()
+	w.prune(now)
+
 	// Need a unique key for this work.
 	workKey := uuid.NewString()
+	if w.opts.name != "" {
+		workKey = w.opts.name + "." + workKey
+	}
 
 	// Create a cancel function and keep it for stop/shutdown purposes.
 	ctx, cancel := context.WithCancel(ctx)
-	w.running[workKey] = cancel
 
-	// Launch a goroutine to perform the work.
-	w.wg.Add(1)
-	go func() {
-		defer w.wg.Done()
-		defer func() { cancel(); w.removeWork(workKey) }()
-		f(ctx, traceID, payload)
-	}()
+	wk := work{
+		status: Status{
+			WorkKey:    workKey,
+			JobKey:     jobKey,
+			TraceID:    traceID,
+			State:      StateQueued,
+			DateQueued: now,
+		},
+		fn:     f,
+		cancel: cancel,
+		data:   payload,
+	}
+	wk.ctx = context.WithValue(ctx, progressKey, &progress{w: w, wk: &wk})
+	w.works[workKey] = &wk
+
+	// Queue the work if the job is running as often as it may.
+	if limit := w.opts.limits[jobKey]; limit > 0 && w.runningFor(jobKey) >= limit {
+		w.queued[jobKey] = append(w.queued[jobKey], &wk)
+		return workKey, nil
+	}
+
+	w.launch(&wk, now)
 
 	return workKey, nil
 }
 
-// Stop is used to cancel an existing job that is running.
+// Stop is used to cancel an existing job that is running or queued.
 func (w *Worker) Stop(workKey string) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	// Queued work is taken out of the queue so it never runs.
+	if wk, exists := w.works[workKey]; exists && wk.status.State == StateQueued {
+		q := w.queued[wk.status.JobKey]
+		for i := range q {
+			if q[i] == wk {
+				w.queued[wk.status.JobKey] = append(q[:i], q[i+1:]...)
+				break
+			}
+		}
+		w.end(wk, StateCanceled, nil, context.Canceled, time.Now())
+		return nil
+	}
+
 	// Locate the work in the running list.
-	cancel, exists := w.running[workKey]
+	wk, exists := w.running[workKey]
 	if !exists {
+		if err := w.other(workKey); err != nil {
+			return err
+		}
 		return fmt.Errorf("work[%s] is not running", workKey)
 	}
 
 	// Call cancel to stop the work.
-	cancel()
+	wk.cancel()
 
 	return nil
 }
 
-// Convenience function to remove work from the running list.
-func (w *Worker) removeWork(workKey string) {
+// Status returns the status of the work identified by the work key. The
+// status of finished work is kept for the retention period.
+func (w *Worker) Status(workKey string) (Status, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.prune(time.Now())
+
+	wk, exists := w.works[workKey]
+	if !exists {
+		if err := w.other(workKey); err != nil {
+			return Status{}, err
+		}
+		return Status{}, fmt.Errorf("work[%s]: %w", workKey, ErrUnknownWork)
+	}
+
+	return wk.status, nil
+}
+
+// Statuses returns the status of all the work the worker knows about, the
+// most recently queued first.
+func (w *Worker) Statuses() []Status {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	delete(w.running, workKey)
+	w.prune(time.Now())
+
+	statuses := make([]Status, 0, len(w.works))
+	for _, wk := range w.works {
+		statuses = append(statuses, wk.status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].DateQueued.After(statuses[j].DateQueued)
+	})
+
+	return statuses
+}
+
+// SetProgress records how far along the work running with the context is,
+// to be reported in its status. It does nothing for a context that did not
+// come from a worker.
+func SetProgress(ctx context.Context, v interface{}) {
+	p, ok := ctx.Value(progressKey).(*progress)
+	if !ok {
+		return
+	}
+
+	p.w.mu.Lock()
+	defer p.w.mu.Unlock()
+
+	p.wk.status.Progress = v
+}
+
+// =============================================================================
+
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// progressKey is how progress is found in the context of running work.
+const progressKey ctxKey = 1
+
+// progress links running work back to its worker.
+type progress struct {
+	w  *Worker
+	wk *work
+}
+
+// other returns ErrOtherWorker when the work key was made by a worker with
+// another name.
+func (w *Worker) other(workKey string) error {
+	name := ""
+	if i := strings.LastIndex(workKey, "."); i >= 0 {
+		name = workKey[:i]
+	}
+
+	if name != w.opts.name {
+		return fmt.Errorf("work[%s]: %w", workKey, ErrOtherWorker)
+	}
+
+	return nil
+}
+
+// launch starts a goroutine to perform the work. The caller must hold the
+// lock.
+func (w *Worker) launch(wk *work, now time.Time) {
+	wk.status.State = StateRunning
+	wk.status.DateStarted = &now
+	w.running[wk.status.WorkKey] = wk
+
+	// Launch a goroutine to perform the work.
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		result, err := w.run(wk)
+		canceled := wk.ctx.Err() != nil
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		// Work that gave up because it was told to stop was canceled rather
+		// than failed.
+		state := StateSucceeded
+		switch {
+		case canceled && (err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)):
+			state = StateCanceled
+		case err != nil:
+			state = StateFailed
+		}
+
+		delete(w.running, wk.status.WorkKey)
+		w.end(wk, state, result, err, time.Now())
+		w.next(wk.status.JobKey)
+	}()
+}
+
+// run calls the job function, turning a panic into an error.
+func (w *Worker) run(wk *work) (result interface{}, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("job[%s] panic: %v", wk.status.JobKey, rec)
+		}
+	}()
+
+	return wk.fn(wk.ctx, wk.status.TraceID, wk.data)
+}
+
+// next launches the oldest queued work for the job, if there is any. The
+// caller must hold the lock.
+func (w *Worker) next(jobKey string) {
+	q := w.queued[jobKey]
+	if len(q) == 0 || w.shutdown {
+		return
+	}
+
+	wk := q[0]
+	w.queued[jobKey] = q[1:]
+	w.launch(wk, time.Now())
+}
+
+// end records the outcome of the work. The caller must hold the lock.
+func (w *Worker) end(wk *work, state string, result interface{}, err error, now time.Time) {
+	wk.cancel()
+	wk.status.State = state
+	wk.status.Result = result
+	wk.status.DateEnded = &now
+	if err != nil {
+		wk.status.Error = err.Error()
+	}
+}
+
+// runningFor returns how many pieces of work for the job are running. The
+// caller must hold the lock.
+func (w *Worker) runningFor(jobKey string) int {
+	var n int
+	for _, wk := range w.running {
+		if wk.status.JobKey == jobKey {
+			n++
+		}
+	}
+	return n
+}
+
+// prune forgets work that ended longer ago than the retention period. The
+// caller must hold the lock.
+func (w *Worker) prune(now time.Time) {
+	for workKey, wk := range w.works {
+		if wk.status.DateEnded != nil && now.Sub(*wk.status.DateEnded) > w.opts.retention {
+			delete(w.works, workKey)
+		}
+	}
 }
@@ -2,6 +2,8 @@ package worker_test
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -24,11 +26,12 @@ func TestWorker(t *testing.T) {
 		t.Logf("\tTest %d:\tWhen handling multiple jobs", testID)
 		{
 			// Define a work function that waits to be canceled.
-			work := func(ctx context.Context, workKey string, data interface{}) {
+			work := func(ctx context.Context, workKey string, data interface{}) (interface{}, error) {
 				t := data.(*testing.T)
 				t.Logf("\t\t%s\tTest %d:\tGoroutine running.", success, testID)
 				<-ctx.Done()
 				t.Logf("\t\t%s\tTest %d:\tGoroutine terminating.", success, testID)
+				return nil, nil
 			}
 
 			// Load 4 jobs in the system.
@@ -87,12 +90,13 @@ func TestCancelWorker(t *testing.T) {
 			wg.Add(4)
 
 			// Define a work function that waits to be canceled.
-			work := func(ctx context.Context, workKey string, data interface{}) {
+			work := func(ctx context.Context, workKey string, data interface{}) (interface{}, error) {
 				wg.Done()
 				t := data.(*testing.T)
 				t.Logf("\t\t%s\tTest %d:\tGoroutine running.", success, testID)
 				<-ctx.Done()
 				t.Logf("\t\t%s\tTest %d:\tGoroutine terminating.", success, testID)
+				return nil, nil
 			}
 
 			// Load 4 jobs in the system.
@@ -139,12 +143,13 @@ func TestStopWorker(t *testing.T) {
 			wg.Add(4)
 
 			// Define a work function that waits to be canceled.
-			work := func(ctx context.Context, workKey string, data interface{}) {
+			work := func(ctx context.Context, workKey string, data interface{}) (interface{}, error) {
 				wg.Done()
 				t := data.(*testing.T)
 				t.Logf("\t%s\tTest %d:\tGoroutine running.", success, testID)
 				<-ctx.Done()
 				t.Logf("\t%s\tTest %d:\tGoroutine terminating.", success, testID)
+				return nil, nil
 			}
 
 			// Load 4 jobs in the system.
@@ -204,3 +209,167 @@ func TestStopWorker(t *testing.T) {
 		}
 	}
 }
+
+func TestLimitWorker(t *testing.T) {
+	t.Log("Given the need to cap how much work for a job runs at once.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen starting more work than the limit", testID)
+		{
+			release := make(chan struct{})
+
+			// Define a work function that waits to be released.
+			work := func(ctx context.Context, workKey string, data interface{}) (interface{}, error) {
+				select {
+				case <-release:
+					return data, nil
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+
+			w := worker.New(map[string]worker.JobFunc{"script": work}, worker.WithLimit("script", 2))
+
+			var works []string
+			for i := 0; i < 4; i++ {
+				work, err := w.Start(context.Background(), traceID, "script", i)
+				if err != nil {
+					t.Fatalf("\t%s\tTest %d:\tShould be able to execute work: %v", failed, testID, err)
+				}
+				works = append(works, work)
+			}
+
+			if r, q := w.Running(), w.Queued(); r != 2 || q != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould run 2 and queue 2 : running %d queued %d.", failed, testID, r, q)
+			}
+			t.Logf("\t%s\tTest %d:\tShould run 2 and queue 2.", success, testID)
+
+			if err := w.Stop(works[3]); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to stop queued work: %v", failed, testID, err)
+			}
+			if st, err := w.Status(works[3]); err != nil || st.State != worker.StateCanceled || st.DateStarted != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould cancel queued work without running it : %+v %v.", failed, testID, st, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould cancel queued work without running it.", success, testID)
+
+			// Release the work one piece at a time.
+			release <- struct{}{}
+			release <- struct{}{}
+			release <- struct{}{}
+
+			for i := 0; i < 10 && w.Running() != 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			for i, work := range works[:3] {
+				st, err := w.Status(work)
+				if err != nil || st.State != worker.StateSucceeded || st.Result != i || st.DateStarted == nil || st.DateEnded == nil {
+					t.Fatalf("\t%s\tTest %d:\tShould run queued work once there is room : %+v %v.", failed, testID, st, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d:\tShould run queued work once there is room.", success, testID)
+
+			if err := w.Shutdown(context.Background()); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to shutdown work cleanly: %v", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to shutdown work cleanly.", success, testID)
+		}
+	}
+}
+
+func TestStatusWorker(t *testing.T) {
+	t.Log("Given the need to check on work.")
+	{
+		testID := 0
+		t.Logf("\tTest %d:\tWhen work fails", testID)
+		{
+			jobs := map[string]worker.JobFunc{
+				"fail": func(ctx context.Context, workKey string, data interface{}) (interface{}, error) {
+					worker.SetProgress(ctx, "halfway")
+					return nil, errors.New("broken")
+				},
+				"panic": func(ctx context.Context, workKey string, data interface{}) (interface{}, error) {
+					panic("broken")
+				},
+			}
+
+			w := worker.New(jobs, worker.WithRetention(time.Hour))
+
+			failKey, err := w.Start(context.Background(), traceID, "fail", nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to execute work: %v", failed, testID, err)
+			}
+			panicKey, err := w.Start(context.Background(), traceID, "panic", nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to execute work: %v", failed, testID, err)
+			}
+
+			for i := 0; i < 10 && w.Running() != 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			st, err := w.Status(failKey)
+			if err != nil || st.State != worker.StateFailed || st.Error != "broken" || st.Progress != "halfway" {
+				t.Fatalf("\t%s\tTest %d:\tShould report the error and progress : %+v %v.", failed, testID, st, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report the error and progress.", success, testID)
+
+			st, err = w.Status(panicKey)
+			if err != nil || st.State != worker.StateFailed || st.Error == "" {
+				t.Fatalf("\t%s\tTest %d:\tShould report a panic as an error : %+v %v.", failed, testID, st, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report a panic as an error.", success, testID)
+
+			if n := len(w.Statuses()); n != 2 {
+				t.Fatalf("\t%s\tTest %d:\tShould list all the work : %d.", failed, testID, n)
+			}
+			t.Logf("\t%s\tTest %d:\tShould list all the work.", success, testID)
+
+			if _, err := w.Status("unknown"); !errors.Is(err, worker.ErrUnknownWork) {
+				t.Fatalf("\t%s\tTest %d:\tShould not know unknown work : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not know unknown work.", success, testID)
+		}
+
+		testID++
+		t.Logf("\tTest %d:\tWhen work is started by a named worker", testID)
+		{
+			jobs := map[string]worker.JobFunc{
+				"noop": func(ctx context.Context, workKey string, data interface{}) (interface{}, error) {
+					return nil, nil
+				},
+			}
+
+			w := worker.New(jobs, worker.WithName("replica-1"))
+			other := worker.New(jobs, worker.WithName("replica-2"))
+
+			key, err := w.Start(context.Background(), traceID, "noop", nil)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to execute work: %v", failed, testID, err)
+			}
+
+			if !strings.HasPrefix(key, "replica-1.") {
+				t.Fatalf("\t%s\tTest %d:\tShould name the worker in the work key : %s.", failed, testID, key)
+			}
+			t.Logf("\t%s\tTest %d:\tShould name the worker in the work key.", success, testID)
+
+			if _, err := w.Status(key); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould know its own work : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould know its own work.", success, testID)
+
+			if _, err := other.Status(key); !errors.Is(err, worker.ErrOtherWorker) {
+				t.Fatalf("\t%s\tTest %d:\tShould report work started by another worker : %v.", failed, testID, err)
+			}
+			if err := other.Stop(key); !errors.Is(err, worker.ErrOtherWorker) {
+				t.Fatalf("\t%s\tTest %d:\tShould not stop work started by another worker : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report work started by another worker.", success, testID)
+
+			if _, err := w.Status("replica-1.unknown"); !errors.Is(err, worker.ErrUnknownWork) {
+				t.Fatalf("\t%s\tTest %d:\tShould not know unknown work : %v.", failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould not know unknown work.", success, testID)
+		}
+	}
+}